@@ -8,9 +8,6 @@ package main
 
 // Short-term goals.
 // TODO update the symmetric tensor contraction functions to use the symmetric permutation loop.
-// TODO allow for a refractive index that varies over space and color.
-// TODO create polyhedra.
-// TODO polygon objects.
 // TODO add a glow mode which randomly assigns some pastel color to each object so as to generate
 //      quick test images of a scene.
 // TODO  conformal transformations.
@@ -20,77 +17,779 @@ package main
 // TODO      fractals.
 
 // Longer-term goals.
-// TODO It is very easy to make images that are over- or under-exposed.
-// Allow the program to handle this gracefully.
-// TODO image is very grainy. Make less grainy by employing more uniform preset
-//		distributions of points.
 // TODO allow for solid objects that affect the light ray during its entire
 // course through it.
 // TODO other kinds of boundary conditions: elliptic and hyperbolic geometry!
 // TODO curved space with any kind of metric we want.
-// TODO wormholes.
 
 import (
+	"flag"
 	"fmt"
-	"os"
-	"runtime"
-	//"bufio"
 	"github.com/DanielKrawisz/CurvedSpace/diffeq"
 	"github.com/DanielKrawisz/CurvedSpace/geometry"
-	//"./BlackHoles"
+	"github.com/DanielKrawisz/CurvedSpace/hdr"
+	"github.com/DanielKrawisz/CurvedSpace/pathtrace"
+	"github.com/DanielKrawisz/CurvedSpace/scenes"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
 )
 
+const usage = `usage:
+  CurvedSpace render [options] scene.json   render a scene file
+  CurvedSpace sample [options] name         render a built-in sample scene
+  CurvedSpace list                          list the sample scenes
+  CurvedSpace help                          print this message
+
+options:
+`
+
 func main() {
 	// Use all processor cores.
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	pathtrace_activity_01()
-	pathtrace_activity_02()
-	pathtrace_activity_03()
-	// pathtrace_activity_04()
-	// pathtrace_activity_05()
-	// test_scene_01()
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "render":
+		err = renderCommand(os.Args[2:])
+	case "sample":
+		err = sampleCommand(os.Args[2:])
+	case "list":
+		for _, s := range samples {
+			fmt.Printf("%-18s %s\n", s.name, s.description)
+		}
+	case "help", "-h", "-help", "--help":
+		printUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprint(os.Stderr, usage)
+	f, _ := newFlags("")
+	f.SetOutput(os.Stderr)
+	f.PrintDefaults()
+}
+
+// The options given on the command line. Render parameters
+// which are left as zero are taken from the scene.
+type options struct {
+	output string
+	render scenes.RenderSpec
+	camera string
+	// The lens of the camera, if any.
+	aperture float64
+	focus    float64
+	blades   int
+	// How long the shutter of the camera is open.
+	shutter float64
+	seed    int64
+	quiet   bool
+	// Whether a seed was given.
+	seeded bool
+	// How to turn the radiance of the picture into a png.
+	tonemap  string
+	exposure float64
+	white    float64
+	key      float64
+	gamma    float64
+	// A file in which to save the picture before it is tone mapped.
+	hdr string
+	// A file in which to save the lengths of the paths of each pixel.
+	pathStats string
+	// The number of rays per pixel in each pass of a progressive
+	// render, or zero if the picture is rendered all at once.
+	progressive int
+	// A file in which to save the progress of a progressive render.
+	checkpoint string
+	// How often a progressive render writes its picture and checkpoint.
+	previewEvery time.Duration
+	// A file in which to save a picture of the number of rays of each pixel.
+	heatmap string
+	// The name of the files in which to save the features of the picture.
+	features string
+	// The number of levels over which to denoise the picture, or zero
+	// if it is not denoised.
+	denoise int
+	// A file in which to save the frames of an animation as a gif.
+	gif string
+}
+
+func newFlags(name string) (*flag.FlagSet, *options) {
+	o := &options{}
+	f := flag.NewFlagSet(name, flag.ContinueOnError)
+	f.StringVar(&o.output, "o", "", "the png file to write (default output/<scene>.png)")
+	f.IntVar(&o.render.Width, "width", 0, "the width of the picture in pixels")
+	f.IntVar(&o.render.Height, "height", 0, "the height of the picture in pixels")
+	f.IntVar(&o.render.Depth, "depth", 0, "the greatest number of times a ray may bounce")
+	f.IntVar(&o.render.MinSamples, "min-samples", 0, "the least number of rays per pixel")
+	f.IntVar(&o.render.MaxSamples, "max-samples", 0, "the greatest number of rays per pixel")
+	f.Float64Var(&o.render.MaxMeanVariance, "variance", 0, "stop sampling a pixel when the variance of its mean is below this")
+	f.IntVar(&o.render.Routines, "routines", 0, "the number of goroutines")
+	f.IntVar(&o.render.Wavelengths, "wavelengths", 0, "trace this many wavelengths per ray rather than rgb")
+	f.IntVar(&o.render.Roulette, "roulette", 0, "end paths by russian roulette after this many bounces")
+	f.StringVar(&o.render.Sampler, "sampler", "", "how to choose random numbers: random, stratified, halton, or sobol (default sobol)")
+	f.StringVar(&o.camera, "camera", "", "the type of camera, such as flat or cylindrical")
+	f.Float64Var(&o.aperture, "aperture", 0, "the radius of the lens of the camera, which blurs what is not in focus (default a pinhole)")
+	f.Float64Var(&o.focus, "focus", 0, "the distance from the camera which is in focus (default the distance to where it looks)")
+	f.IntVar(&o.blades, "blades", 0, "make the lens a polygon with this many sides, rather than round")
+	f.Float64Var(&o.shutter, "shutter", 0, "the seconds for which the shutter of the camera is open, which blurs what moves (default the scene's)")
+	f.Int64Var(&o.seed, "seed", 0, "the seed of the random numbers, which overrides the scene's; the same seed gives the same picture")
+	f.BoolVar(&o.quiet, "quiet", false, "do not print progress")
+	f.StringVar(&o.tonemap, "tonemap", "clamp", "how to show bright colors: clamp, reinhard, filmic, or auto")
+	f.Float64Var(&o.exposure, "exposure", 0, "stops by which to brighten the picture before tone mapping it")
+	f.Float64Var(&o.white, "white", 0, "the luminance which reinhard maps to white (default none)")
+	f.Float64Var(&o.key, "key", .18, "the average luminance that auto exposure aims for")
+	f.Float64Var(&o.gamma, "gamma", 1, "the gamma with which to encode the png")
+	f.StringVar(&o.hdr, "hdr", "", "also save the radiance of the picture as .exr, .hdr, or .pfm")
+	f.StringVar(&o.pathStats, "path-stats", "", "save the mean and greatest path length and the number of paths of each pixel as .exr, .hdr, or .pfm")
+	f.IntVar(&o.progressive, "progressive", 0, "render in passes of this many rays per pixel, writing the picture as it goes")
+	f.StringVar(&o.checkpoint, "checkpoint", "", "save the progress of a progressive render in this file, and resume from it if it exists")
+	f.DurationVar(&o.previewEvery, "preview-every", time.Minute, "how often a progressive render writes its picture and checkpoint")
+	f.IntVar(&o.render.Budget, "budget", 0, "render progressively, sharing this many rays per pixel among the noisiest parts of the picture")
+	f.Float64Var(&o.render.MaxError, "max-error", 0, "stop sharing out the budget when the relative noise of every part of the picture is below this")
+	f.StringVar(&o.render.Filter, "filter", "", "how each ray counts toward the pixels around it: box, tent, gaussian, mitchell, or lanczos (default box)")
+	f.Float64Var(&o.render.FilterRadius, "filter-radius", 0, "the radius of the filter in pixels (default depends on the filter)")
+	f.StringVar(&o.features, "features", "", "save the albedo, normal, depth and object of each pixel as .exr, .hdr, or .pfm files with -albedo, -normal, -depth and -object added to this name")
+	f.IntVar(&o.denoise, "denoise", 0, "denoise the picture over this many levels, each blurring twice as far as the last (5 is usually enough)")
+	f.StringVar(&o.gif, "gif", "", "also save the frames of an animated scene as an animated gif")
+	f.StringVar(&o.heatmap, "heatmap", "", "save a picture of the number of rays of each pixel of a progressive render as .png, .exr, .hdr, or .pfm")
+	return f, o
+}
+
+// Parse the options and return the one argument after them.
+func parseFlags(name string, args []string) (*options, string, error) {
+	f, o := newFlags(name)
+	f.SetOutput(os.Stderr)
+	if err := f.Parse(args); err != nil {
+		return nil, "", err
+	}
+	if f.NArg() != 1 {
+		return nil, "", fmt.Errorf("%s needs one argument", name)
+	}
+	f.Visit(func(g *flag.Flag) {
+		if g.Name == "seed" {
+			o.seeded = true
+		}
+	})
+	return o, f.Arg(0), nil
+}
+
+// Replace the parts of the scene that were given on the command line.
+func (o *options) apply(r *scenes.RenderSpec, c *scenes.CameraSpec) error {
+	if o.render.Width != 0 {
+		r.Width = o.render.Width
+	}
+	if o.render.Height != 0 {
+		r.Height = o.render.Height
+	}
+	if o.render.Depth != 0 {
+		r.Depth = o.render.Depth
+	}
+	if o.render.MinSamples != 0 {
+		r.MinSamples = o.render.MinSamples
+		if r.MaxSamples < r.MinSamples {
+			r.MaxSamples = r.MinSamples
+		}
+	}
+	if o.render.MaxSamples != 0 {
+		if o.render.MinSamples != 0 && o.render.MaxSamples < o.render.MinSamples {
+			return fmt.Errorf("-max-samples must not be less than -min-samples")
+		}
+		r.MaxSamples = o.render.MaxSamples
+		if r.MinSamples > r.MaxSamples {
+			r.MinSamples = r.MaxSamples
+		}
+	}
+	if o.render.MaxMeanVariance != 0 {
+		r.MaxMeanVariance = o.render.MaxMeanVariance
+	}
+	if o.render.Routines != 0 {
+		r.Routines = o.render.Routines
+	}
+	if o.render.Wavelengths != 0 {
+		r.Wavelengths = o.render.Wavelengths
+	}
+	if o.render.Roulette != 0 {
+		r.Roulette = o.render.Roulette
+	}
+	if o.render.Sampler != "" {
+		r.Sampler = o.render.Sampler
+	}
+	if o.render.Budget != 0 {
+		r.Budget = o.render.Budget
+	}
+	if o.render.MaxError != 0 {
+		r.MaxError = o.render.MaxError
+	}
+	if o.render.Filter != "" {
+		r.Filter = o.render.Filter
+		r.FilterRadius = 0
+	}
+	if o.render.FilterRadius != 0 {
+		r.FilterRadius = o.render.FilterRadius
+	}
+	if o.camera != "" {
+		c.Type = o.camera
+	}
+	if o.aperture != 0 {
+		c.Aperture = o.aperture
+	}
+	if o.focus != 0 {
+		c.Focus = o.focus
+	}
+	if o.blades != 0 {
+		c.Blades = o.blades
+	}
+	if o.shutter != 0 {
+		c.Shutter = []float64{0, o.shutter}
+	}
+
+	if o.seeded {
+		r.Seed = o.seed
+	}
+
+	if (o.checkpoint != "" || o.heatmap != "" || r.Budget > 0) && o.progressive == 0 {
+		o.progressive = defaultPassSamples
+	}
+	if o.progressive < 0 {
+		return fmt.Errorf("the number of rays in each pass must be positive")
+	}
+	if o.denoise < 0 {
+		return fmt.Errorf("the number of levels to denoise must not be negative")
+	}
+	if o.progressive > 0 && o.pathStats != "" {
+		return fmt.Errorf("path statistics are not kept by progressive renders")
+	}
+
+	if _, err := o.toneMapper(nil); err != nil {
+		return err
+	}
+
+	return r.Check()
+}
+
+// The tone mapper given by the options. If img is nil, this only
+// checks the options.
+func (o *options) toneMapper(img *hdr.Image) (hdr.ToneMapper, error) {
+	var t hdr.ToneMapper
+	switch o.tonemap {
+	case "clamp":
+		t = hdr.Clamp()
+	case "reinhard":
+		t = hdr.Reinhard(o.white)
+	case "filmic":
+		t = hdr.Filmic()
+	case "auto":
+		if !(o.key > 0) {
+			return nil, fmt.Errorf("the key must be positive")
+		}
+		if img != nil {
+			t = hdr.AutoExposure(img, o.key, hdr.Reinhard(o.white))
+		}
+	default:
+		return nil, fmt.Errorf("unknown tone mapper %q", o.tonemap)
+	}
+
+	if o.exposure != 0 {
+		t = hdr.Scale(math.Pow(2, o.exposure), t)
+	}
+	return t, nil
+}
+
+// Prints the progress of a picture, unless the options say to be quiet.
+func (o *options) progress(name string) pathtrace.Progress {
+	if o.quiet {
+		return nil
+	}
+
+	return func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\rrendering %s: %3d%%", name, 100*done/total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
+
+// The file to write to. By default, it is in the output directory.
+func (o *options) outputFile(name string) string {
+	if o.output != "" {
+		return o.output
+	}
+	return filepath.Join("output", name+".png")
+}
+
+func renderCommand(args []string) error {
+	o, filename, err := parseFlags("render", args)
+	if err != nil {
+		return err
+	}
+
+	d, err := scenes.Load(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := o.apply(&d.Render, d.Camera); err != nil {
+		return err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	if d.Frames() > 1 {
+		return o.renderAnimation(name, d.Frames(), d.Animation.Time(1),
+			func(frame int, progress pathtrace.Progress) (*pathtrace.Film, *pathtrace.PathStatistics, error) {
+				return d.Frame(frame).SnapshotFilm(progress)
+			})
+	}
+	if o.progressive > 0 {
+		return o.renderProgressive(name, d.Render, d.Progressive)
+	}
+
+	film, stats, err := d.SnapshotFilm(o.progress(name))
+	if err != nil {
+		return err
+	}
+
+	if err := o.writeStatistics(stats); err != nil {
+		return err
+	}
+	_, err = o.writeFilm(film.Image(), film.Features(), name)
+	return err
 }
 
-func createOutputDirectory(output string) {
-	src, err := os.Stat(output)
+func sampleCommand(args []string) error {
+	o, name, err := parseFlags("sample", args)
 	if err != nil {
-		if os.IsNotExist(err) {
-			os.Mkdir(output, 0777)
-			return
-		} else {
-			panic(err)
+		return err
+	}
+
+	var s *sample
+	for _, e := range samples {
+		if e.name == name {
+			s = e.build()
 		}
 	}
+	if s == nil {
+		return fmt.Errorf("unknown sample %q", name)
+	}
 
-	if !src.IsDir() {
-		fmt.Println("Source is not a directory")
-		os.Exit(1)
+	if err := o.apply(&s.render, s.camera); err != nil {
+		return err
+	}
+
+	r := s.render
+	build := func() *pathtrace.Scene {
+		scene := s.scene()
+		scene.SetSpectral(r.Wavelengths)
+		scene.SetRussianRoulette(r.Roulette)
+		scene.SetSampler(r.NewSampler())
+		scene.SetFilter(r.NewFilter())
+		return scene
 	}
+
+	// Only the camera of a sample can be animated,
+	// since its objects are made in Go.
+	if a := s.animation; a != nil {
+		if err := a.Check(); err != nil {
+			return err
+		}
+		if len(a.Objects) > 0 {
+			return fmt.Errorf("the objects of a sample cannot be animated")
+		}
+
+		return o.renderAnimation(name, a.Frames, a.Time(1),
+			func(frame int, progress pathtrace.Progress) (*pathtrace.Film, *pathtrace.PathStatistics, error) {
+				c, err := a.CameraAt(s.camera, a.Time(frame))
+				if err != nil {
+					return nil, nil, err
+				}
+				camera, err := c.Build(r.Width, r.Height)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				film, stats := pathtrace.SnapshotFilm(build, camera, r.Width, r.Height, r.Depth,
+					r.MinSamples, r.MaxSamples, r.MaxMeanVariance, r.Routines, progress)
+				return film, stats, nil
+			})
+	}
+
+	camera, err := s.camera.Build(r.Width, r.Height)
+	if err != nil {
+		return err
+	}
+
+	if o.progressive > 0 {
+		return o.renderProgressive(name, r, func(p *pathtrace.Progressive, samples int,
+			pass func(*pathtrace.Progressive, int) bool) error {
+			if r.Budget > 0 {
+				p.RenderAdaptive(build, camera, r.Depth, r.MinSamples, r.MaxSamples,
+					r.Budget*r.Width*r.Height, r.MaxError, samples, r.Routines, pass)
+			} else {
+				p.Render(build, camera, r.Depth, r.MinSamples, r.MaxSamples, r.MaxMeanVariance, samples, r.Routines, pass)
+			}
+			return nil
+		})
+	}
+
+	film, stats := pathtrace.SnapshotFilm(build, camera, r.Width, r.Height, r.Depth,
+		r.MinSamples, r.MaxSamples, r.MaxMeanVariance, r.Routines, o.progress(name))
+
+	if err := o.writeStatistics(stats); err != nil {
+		return err
+	}
+	_, err = o.writeFilm(film.Image(), film.Features(), name)
+	return err
 }
 
-func getHandleToOutputFile(activity, filename string) *os.File {
-	createOutputDirectory("output")
-	// Delete file if it exists.
-	fullname := fmt.Sprint("./output/", filename)
-	fmt.Println("Running path trace", activity)
-	if _, err := os.Stat(filename); err == nil {
-		err := os.Remove(fullname)
+// Render each frame of an animation with the given function, and write it
+// and everything else that the options ask for to files numbered by the
+// frame. If the options say so, the frames are also gathered into a gif,
+// which shows each of them for period seconds.
+func (o *options) renderAnimation(name string, frames int, period float64,
+	render func(frame int, progress pathtrace.Progress) (*pathtrace.Film, *pathtrace.PathStatistics, error)) error {
+	if o.progressive > 0 {
+		return fmt.Errorf("animations are not rendered progressively")
+	}
+
+	var g *gif.GIF
+	if o.gif != "" {
+		g = &gif.GIF{}
+	}
+
+	for i := 0; i < frames; i++ {
+		f := *o
+		f.output = numbered(o.outputFile(name), i)
+		for _, file := range []*string{&f.hdr, &f.pathStats, &f.features} {
+			if *file != "" {
+				*file = numbered(*file, i)
+			}
+		}
+
+		film, stats, err := render(i, f.progress(fmt.Sprintf("%s frame %d of %d", name, i+1, frames)))
 		if err != nil {
-			fmt.Println("Could not delete existing file: ", err.Error())
-			return nil
+			return err
+		}
+		if err := f.writeStatistics(stats); err != nil {
+			return err
+		}
+		img, err := f.writeFilm(film.Image(), film.Features(), name)
+		if err != nil {
+			return err
+		}
+
+		if g != nil {
+			p := image.NewPaletted(img.Bounds(), palette.Plan9)
+			draw.FloydSteinberg.Draw(p, img.Bounds(), img, image.Point{})
+			g.Image = append(g.Image, p)
+			g.Delay = append(g.Delay, int(math.Round(100*period)))
+		}
+	}
+
+	if g == nil {
+		return nil
+	}
+	if err := saveGIF(o.gif, g); err != nil {
+		return err
+	}
+	if !o.quiet {
+		fmt.Fprintln(os.Stderr, "wrote", o.gif)
+	}
+	return nil
+}
+
+// The name of a file for a frame of an animation.
+func numbered(filename string, frame int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s-%04d%s", strings.TrimSuffix(filename, ext), frame, ext)
+}
+
+// The number of rays per pixel in each pass when a render is
+// progressive without saying how many.
+const defaultPassSamples = 4
+
+// Render a picture in passes with the given function. The picture and the
+// checkpoint are written every so often and when the render is interrupted,
+// so that it can be resumed from the checkpoint.
+func (o *options) renderProgressive(name string, r scenes.RenderSpec,
+	render func(*pathtrace.Progressive, int, func(*pathtrace.Progressive, int) bool) error) error {
+	p, err := o.readCheckpoint(r.Width, r.Height)
+	if err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	// An adaptive render counts the rays left in its budget.
+	left := "pixels"
+	if r.Budget > 0 {
+		left = "rays"
+	}
+
+	var saveErr error
+	var stopped bool
+	last := time.Now()
+	pass := func(p *pathtrace.Progressive, remaining int) bool {
+		if !o.quiet {
+			fmt.Fprintf(os.Stderr, "\rrendering %s: pass %d, %d %s left  ", name, p.Passes, remaining, left)
+		}
+
+		select {
+		case <-interrupt:
+			stopped = true
+		default:
+		}
+
+		if remaining > 0 && (stopped || time.Since(last) >= o.previewEvery) {
+			last = time.Now()
+			saveErr = o.saveProgress(p, name)
+		}
+		return !stopped && saveErr == nil
+	}
+
+	if err := render(p, o.progressive, pass); err != nil {
+		return err
+	}
+	if saveErr != nil {
+		return saveErr
+	}
+	if stopped {
+		if o.checkpoint == "" {
+			return fmt.Errorf("stopped after pass %d", p.Passes)
 		}
+		return fmt.Errorf("stopped after pass %d; resume with -checkpoint %s", p.Passes, o.checkpoint)
+	}
+
+	if err := o.saveProgress(p, name); err != nil {
+		return err
+	}
+	return o.writeHeatMap(p)
+}
+
+// Save the number of rays of each pixel, if the options say so.
+func (o *options) writeHeatMap(p *pathtrace.Progressive) error {
+	if o.heatmap == "" {
+		return nil
+	}
+
+	var err error
+	if strings.ToLower(filepath.Ext(o.heatmap)) == ".png" {
+		err = savePNG(o.heatmap, p.HeatMap().ToneMap(hdr.Clamp(), 1))
+	} else {
+		err = hdr.Save(o.heatmap, p.HeatMap())
+	}
+	if err != nil {
+		return err
+	}
+
+	if !o.quiet {
+		min, max := p.SampleRange()
+		fmt.Fprintf(os.Stderr, "wrote %s: %d to %d rays per pixel\n", o.heatmap, min, max)
+	}
+	return nil
+}
+
+// Take up a progressive render from the checkpoint, if there is one.
+func (o *options) readCheckpoint(width, height int) (*pathtrace.Progressive, error) {
+	if o.checkpoint == "" {
+		return pathtrace.NewProgressive(width, height), nil
+	}
+
+	file, err := os.Open(o.checkpoint)
+	if os.IsNotExist(err) {
+		return pathtrace.NewProgressive(width, height), nil
 	}
-	// Check if the file can be written.
-	file, err := os.Create(fullname)
 	if err != nil {
-		fmt.Println("Could not write file: ", err.Error())
+		return nil, err
+	}
+	defer file.Close()
+
+	p, err := pathtrace.ReadCheckpoint(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", o.checkpoint, err)
+	}
+	if p.Width != width || p.Height != height {
+		return nil, fmt.Errorf("%s is %dx%d but the picture is %dx%d", o.checkpoint, p.Width, p.Height, width, height)
+	}
+
+	if !o.quiet {
+		fmt.Fprintf(os.Stderr, "resuming from %s after pass %d\n", o.checkpoint, p.Passes)
+	}
+	return p, nil
+}
+
+// Write the picture so far and the checkpoint. The checkpoint is written
+// to another file first, so that it is never left half written.
+func (o *options) saveProgress(p *pathtrace.Progressive, name string) error {
+	if !o.quiet {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if o.checkpoint != "" {
+		temp := o.checkpoint + ".tmp"
+		file, err := os.Create(temp)
+		if err != nil {
+			return err
+		}
+		if err := p.WriteCheckpoint(file); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(temp, o.checkpoint); err != nil {
+			return err
+		}
+		if !o.quiet {
+			fmt.Fprintln(os.Stderr, "wrote", o.checkpoint)
+		}
+	}
+
+	_, err := o.writeFilm(p.Image(), p.Features(), name)
+	return err
+}
+
+// Save the lengths of the paths and print a summary of
+// them, if the options say so.
+func (o *options) writeStatistics(stats *pathtrace.PathStatistics) error {
+	if o.pathStats == "" {
 		return nil
 	}
-	/*fmt.Println("Press any key to write file.")
-	  bio := bufio.NewReader(os.Stdin)
-	  _, _, _ = bio.ReadLine()*/
-	return file
+
+	if err := hdr.Save(o.pathStats, stats.Image()); err != nil {
+		return err
+	}
+
+	if !o.quiet {
+		mean, max := stats.Summary()
+		fmt.Fprintf(os.Stderr, "path length: mean %.3f, greatest %d\n", mean, max)
+		for l, n := range stats.Lengths {
+			fmt.Fprintf(os.Stderr, "  %3d: %d\n", l, n)
+		}
+		fmt.Fprintln(os.Stderr, "wrote", o.pathStats)
+	}
+	return nil
+}
+
+// Save the features of the picture if the options say so, and denoise it
+// with them if the options say so, before writing it. Returns the picture
+// as it was written.
+func (o *options) writeFilm(img *hdr.Image, features *pathtrace.FeatureImages, name string) (*image.NRGBA, error) {
+	if o.features != "" {
+		ext := filepath.Ext(o.features)
+		base := strings.TrimSuffix(o.features, ext)
+		for _, f := range []struct {
+			name string
+			img  *hdr.Image
+		}{{"albedo", features.Albedo}, {"normal", features.Normal}, {"depth", features.Depth}, {"object", features.Object}} {
+			filename := base + "-" + f.name + ext
+			if err := hdr.Save(filename, f.img); err != nil {
+				return nil, err
+			}
+			if !o.quiet {
+				fmt.Fprintln(os.Stderr, "wrote", filename)
+			}
+		}
+	}
+
+	if o.denoise > 0 {
+		img = features.Denoise(img, o.denoise)
+	}
+	return o.write(img, name)
+}
+
+// Tone map the picture and write it as a png, making the directory it goes
+// in if necessary. The radiance is also saved if the options say so.
+// Returns the picture as it was written.
+func (o *options) write(img *hdr.Image, name string) (*image.NRGBA, error) {
+	if o.hdr != "" {
+		if err := hdr.Save(o.hdr, img); err != nil {
+			return nil, err
+		}
+		if !o.quiet {
+			fmt.Fprintln(os.Stderr, "wrote", o.hdr)
+		}
+	}
+
+	t, err := o.toneMapper(img)
+	if err != nil {
+		return nil, err
+	}
+
+	filename := o.outputFile(name)
+	out := img.ToneMap(t, o.gamma)
+	if err := savePNG(filename, out); err != nil {
+		return nil, err
+	}
+
+	if !o.quiet {
+		fmt.Fprintln(os.Stderr, "wrote", filename)
+	}
+	return out, nil
+}
+
+// Write a png, making the directory it goes in if necessary.
+func savePNG(filename string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Write an animated gif, making the directory it goes in if necessary.
+func saveGIF(filename string, g *gif.GIF) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := gif.EncodeAll(file, g); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
 }
 
 // A simple demo of the differential equation solver
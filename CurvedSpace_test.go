@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+import "strings"
+import "github.com/DanielKrawisz/CurvedSpace/scenes"
+
+// The least and greatest numbers of samples given on the command line
+// move the scene's others to match, unless they disagree with each other.
+func TestApplySamples(t *testing.T) {
+	for i, c := range []struct {
+		args     []string
+		min, max int
+	}{{[]string{"-max-samples", "4", "x.json"}, 4, 4},
+		{[]string{"-max-samples", "32", "x.json"}, 16, 32},
+		{[]string{"-min-samples", "128", "x.json"}, 128, 128},
+		{[]string{"-min-samples", "2", "-max-samples", "8", "x.json"}, 2, 8}} {
+		o, _, err := parseFlags("render", c.args)
+		if err != nil {
+			t.Error("apply samples error 1, case ", i, ": ", err)
+			continue
+		}
+
+		r := scenes.DefaultRender
+		r.MinSamples, r.MaxSamples = 16, 64
+		if err := o.apply(&r, &scenes.CameraSpec{}); err != nil || r.MinSamples != c.min || r.MaxSamples != c.max {
+			t.Error("apply samples error 2, case ", i, ": ", err, r.MinSamples, r.MaxSamples)
+		}
+	}
+
+	o, _, _ := parseFlags("render", []string{"-min-samples", "8", "-max-samples", "4", "x.json"})
+	r := scenes.DefaultRender
+	if err := o.apply(&r, &scenes.CameraSpec{}); err == nil || !strings.Contains(err.Error(), "-max-samples") {
+		t.Error("apply samples error 3")
+	}
+}
@@ -3,13 +3,15 @@ package main
 import (
 	"github.com/DanielKrawisz/CurvedSpace/color"
 	"github.com/DanielKrawisz/CurvedSpace/functions"
+	"github.com/DanielKrawisz/CurvedSpace/geometry"
 	"github.com/DanielKrawisz/CurvedSpace/pathtrace"
+	"github.com/DanielKrawisz/CurvedSpace/scenes"
 	"github.com/DanielKrawisz/CurvedSpace/surface"
 	"github.com/DanielKrawisz/CurvedSpace/surface/booleans"
 	"github.com/DanielKrawisz/CurvedSpace/surface/complexes"
 	"github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
 	"github.com/DanielKrawisz/CurvedSpace/vector"
-	"image/png"
+	"math"
 )
 
 // The purpose of the following demos is not only to show what the
@@ -17,9 +19,45 @@ import (
 // of how to design them. Thus, some of them show off things that
 // this cannot do in general yet.
 
+// A scene which is written in Go, along with how to look at it, and
+// how the camera moves if it is animated.
+type sample struct {
+	scene     func() *pathtrace.Scene
+	camera    *scenes.CameraSpec
+	render    scenes.RenderSpec
+	animation *scenes.AnimationSpec
+}
+
+func renderSpec(width, height, depth, minp, maxp int, maxMeanVariance float64, routines int) scenes.RenderSpec {
+	return scenes.RenderSpec{Width: width, Height: height, Depth: depth,
+		MinSamples: minp, MaxSamples: maxp, MaxMeanVariance: maxMeanVariance, Routines: routines}
+}
+
+// A sample scene as it is listed on the command line.
+type sampleEntry struct {
+	name, description string
+	build             func() *sample
+}
+
+// The sample scenes, which can be rendered from the command line.
+var samples []sampleEntry = []sampleEntry{
+	{"activity_01", "four glowing spheres", pathtrace_activity_01},
+	{"activity_01-orbit", "four glowing spheres seen from all around", pathtrace_activity_01_orbit},
+	{"activity_02", "four mirrored spheres making a fractal", pathtrace_activity_02},
+	{"activity_03", "a variety of materials", pathtrace_activity_03},
+	{"activity_03-lens", "a variety of materials through a real lens", pathtrace_activity_03_lens},
+	{"activity_04", "a room with a torus in it", pathtrace_activity_04},
+	{"activity_05", "a room to look at lighting", pathtrace_activity_05},
+	{"activity_06", "a black hole with an accretion disk", pathtrace_activity_06},
+	{"activity_07", "a wormhole to another universe", pathtrace_activity_07},
+	{"test_scene_01-0", "a glowing sphere and a sphere", func() *sample { return test_scene_01(0) }},
+	{"test_scene_01-1", "a glowing sphere and a box", func() *sample { return test_scene_01(1) }},
+	{"test_scene_01-2", "a glowing box and a sphere", func() *sample { return test_scene_01(2) }},
+	{"test_scene_01-3", "a glowing box and a box", func() *sample { return test_scene_01(3) }}}
+
 // A simple demo of the most basic form of path-tracing. There are four spheres,
 // each with a different color, and they only emit light, but do not reflect it.
-func pathtrace_activity_01() {
+func pathtrace_activity_01() *sample {
 
 	scene_1 := func() *pathtrace.Scene {
 		// Four spheres in a tetrahedron.
@@ -39,29 +77,36 @@ func pathtrace_activity_01() {
 		return pathtrace.NewScene(objects, background)
 	}
 
-	var size_u, size_v int = 640, 480
-
-	// get camera function
-	cam_pos := []float64{0, 0, 3}
-	cam_look := []float64{0, 0, 0}
-	cam_up := []float64{0, 1, 0}
-	cam_right := []float64{1, 0, 0}
-	cam_func := pathtrace.FlatCamera(cam_pos,
-		pathtrace.CameraMatrix(cam_pos, cam_look, cam_up, cam_right), size_u, size_v, 1.33333, 1.)
+	// The camera.
+	camera := &scenes.CameraSpec{Type: "flat",
+		Position: []float64{0, 0, 3},
+		Look:     []float64{0, 0, 0},
+		Up:       []float64{0, 1, 0},
+		Right:    []float64{1, 0, 0},
+		Fov:      []float64{1.33333, 1.}}
 
-	img := pathtrace.Snapshot(scene_1, cam_func, size_u, size_v, 1, 1, 1, 1, 1, 100000, 8)
+	return &sample{scene_1, camera, renderSpec(640, 480, 1, 1, 1, 1, 8), nil}
+}
 
-	file := getHandleToOutputFile("activity 01", "activity_01.png")
-	if file == nil {
-		return
+// The same, animated, with the camera going once around the spheres in two
+// seconds. It goes around a circle which is given by points at every quarter
+// turn, from a quarter turn before the first frame to a quarter turn after the
+// last, so that it keeps the same speed all the way around.
+func pathtrace_activity_01_orbit() *sample {
+	s := pathtrace_activity_01()
+	s.animation = &scenes.AnimationSpec{Frames: 48, Rate: 24}
+	for k := -1; k <= 5; k++ {
+		angle := float64(k) * math.Pi / 2
+		cos, sin := math.Cos(angle), math.Sin(angle)
+		s.animation.Camera = append(s.animation.Camera, &scenes.CameraKey{Time: float64(k) / 2,
+			Position: []float64{3 * sin, 0, 3 * cos},
+			Right:    []float64{cos, 0, -sin}})
 	}
-
-	png.Encode(file, img)
-	file.Close()
+	return s
 }
 
 // in this demo, the spheres reflect light and produce a fractal.
-func pathtrace_activity_02() {
+func pathtrace_activity_02() *sample {
 
 	scene_2 := func() *pathtrace.Scene {
 		white := []float64{1, 1, 1}
@@ -86,35 +131,21 @@ func pathtrace_activity_02() {
 		return pathtrace.NewScene(objects, background)
 	}
 
-	var size_u, size_v int = 1600, 1200
-
 	// Set up the camera.
-	cam_pos := []float64{0, 0, 2.6}
-	cam_look := []float64{0, 0, 0}
-	cam_up := []float64{0, 1, 0}
-	cam_right := []float64{1, 0, 0}
-	cam_func := pathtrace.FlatCamera(cam_pos,
-		pathtrace.CameraMatrix(cam_pos, cam_look, cam_up, cam_right), size_u, size_v, 1.33333/2., 1./2.)
+	camera := &scenes.CameraSpec{Type: "flat",
+		Position: []float64{0, 0, 2.6},
+		Look:     []float64{0, 0, 0},
+		Up:       []float64{0, 1, 0},
+		Right:    []float64{1, 0, 0},
+		Fov:      []float64{1.33333 / 2., 1. / 2.}}
 
 	// Four hundred bounces, 16 rays per pixel.
-	var depth, minp, maxp int = 400, 16, 1000
 	// Using the new awy of calculating pixels, there should be almost no variance with each ray.
-	var maxMeanVariance float64 = .00001
-
-	img := pathtrace.Snapshot(scene_2, cam_func, size_u, size_v,
-		depth, minp, maxp, maxMeanVariance, .01, 1000000, 8)
-
-	file := getHandleToOutputFile("activity 02", "activity_02.png")
-	if file == nil {
-		return
-	}
-
-	png.Encode(file, img)
-	file.Close()
+	return &sample{scene_2, camera, renderSpec(1600, 1200, 400, 16, 1000, .00001, 8), nil}
 }
 
 // A prototype which will eventually show off a variety of materials.
-func pathtrace_activity_03() {
+func pathtrace_activity_03() *sample {
 
 	scene_3 := func() *pathtrace.Scene {
 		glow_pink := []float64{1.5, .6, 1.5}
@@ -177,32 +208,34 @@ func pathtrace_activity_03() {
 			color.ConstantColorFunction(color.PresetColor([]float64{0, 0, 0})))
 	}
 
-	var size_u, size_v int = 1600, 1200
-
-	cam_pos := []float64{0, 3, 4}
-	cam_look := []float64{0, 0, 0}
-	cam_up := []float64{0, 0, 1}
-	cam_right := []float64{-1, 0, 0}
-	cam_func := pathtrace.FlatCamera(cam_pos,
-		pathtrace.CameraMatrix(cam_pos, cam_look, cam_up, cam_right), size_u, size_v, 1.33333*.85, .85)
-
-	var depth, minp, maxp int = 40, 100, 5000
-	var maxMeanVariance float64 = .0004
-
-	img := pathtrace.Snapshot(scene_3, cam_func, size_u, size_v,
-		depth, minp, maxp, maxMeanVariance, .01, 1000000, 8)
+	camera := &scenes.CameraSpec{Type: "flat",
+		Position: []float64{0, 3, 4},
+		Look:     []float64{0, 0, 0},
+		Up:       []float64{0, 0, 1},
+		Right:    []float64{-1, 0, 0},
+		Fov:      []float64{1.33333 * .85, .85}}
 
-	file := getHandleToOutputFile("activity 03", "activity_03.png")
-	if file == nil {
-		return
-	}
+	return &sample{scene_3, camera, renderSpec(1600, 1200, 40, 100, 5000, .0004, 8), nil}
+}
 
-	png.Encode(file, img)
-	file.Close()
+// The same, from farther away through a double Gauss lens, with a film
+// wider than the lens is made for, so that the corners are dark. The lens
+// is measured in millimeters, and the scene in tenths of a meter.
+func pathtrace_activity_03_lens() *sample {
+	s := pathtrace_activity_03()
+	s.camera = &scenes.CameraSpec{Type: "lens",
+		Position:     []float64{0, 6, 8},
+		Look:         []float64{0, 0, 0},
+		Up:           []float64{0, 0, 1},
+		Right:        []float64{-1, 0, 0},
+		Prescription: "double_gauss",
+		Scale:        .01,
+		Film:         []float64{.54, .36}}
+	return s
 }
 
 //A room in which different objects can be set. Something is wrong with this scene.
-func pathtrace_activity_04() {
+func pathtrace_activity_04() *sample {
 	var outer_dim float64 = 30
 	var room_width float64 = 18
 	var room_height float64 = 12
@@ -264,33 +297,19 @@ func pathtrace_activity_04() {
 			background)
 	}
 
-	//Aspect ratio is (4/3)^3
-	var size_u, size_v int = 1536, 648 // 1536, 648 // 768, 324 // 384, 162
-
-	cam_pos := []float64{3, 3, 5}
-	cam_look := []float64{room_width, room_width, room_height - 2.5}
-	cam_up := []float64{0, 0, 1}
-	cam_right := []float64{1, 0, 0}
-	cam_func := pathtrace.CylindricalCamera(cam_pos,
-		pathtrace.CameraMatrix(cam_pos, cam_look, cam_up, cam_right), size_u, size_v, .7*2.37, .7*1)
-
-	var depth, minp, maxp int = 10, 100, 5000
-	var maxMeanVariance float64 = .001
+	camera := &scenes.CameraSpec{Type: "cylindrical",
+		Position: []float64{3, 3, 5},
+		Look:     []float64{room_width, room_width, room_height - 2.5},
+		Up:       []float64{0, 0, 1},
+		Right:    []float64{1, 0, 0},
+		Fov:      []float64{.7 * 2.37, .7 * 1}}
 
-	img := pathtrace.Snapshot(scene_4, cam_func, size_u, size_v,
-		depth, minp, maxp, maxMeanVariance, .01, 1000000, 8)
-
-	file := getHandleToOutputFile("activity 04", "activity_04.png")
-	if file == nil {
-		return
-	}
-
-	png.Encode(file, img)
-	file.Close()
+	//Aspect ratio is (4/3)^3
+	return &sample{scene_4, camera, renderSpec(1536, 648, 10, 100, 5000, .001, 8), nil} // 1536, 648 // 768, 324 // 384, 162
 }
 
 //A sample scene to look at lighting.
-func pathtrace_activity_05() {
+func pathtrace_activity_05() *sample {
 	fast_mode := true
 
 	scene_5 := func() *pathtrace.Scene {
@@ -325,31 +344,99 @@ func pathtrace_activity_05() {
 		}
 	}
 
-	var size_u, size_v int = 800, 600
-
-	cam_pos := []float64{19, -19, 5}
-	cam_look := []float64{-19, 19, 10}
-	cam_up := []float64{0, 0, 1}
-	cam_right := []float64{1, 0, 0}
-	cam_func := pathtrace.FlatCamera(cam_pos,
-		pathtrace.CameraMatrix(cam_pos, cam_look, cam_up, cam_right), size_u, size_v, 1.33333*.75, .75)
+	camera := &scenes.CameraSpec{Type: "flat",
+		Position: []float64{19, -19, 5},
+		Look:     []float64{-19, 19, 10},
+		Up:       []float64{0, 0, 1},
+		Right:    []float64{1, 0, 0},
+		Fov:      []float64{1.33333 * .75, .75}}
 
-	var depth, minp, maxp int = 10, 100, 5000
-	var maxMeanVariance float64 = .001
+	render := renderSpec(800, 600, 10, 100, 5000, .001, 8)
 
 	if fast_mode {
-		minp = 1
-		maxMeanVariance = 1
+		render.MinSamples = 1
+		render.MaxMeanVariance = 1
+	}
+
+	return &sample{scene_5, camera, render, nil}
+}
+
+// A black hole with an accretion disk. The light rays follow geodesics
+// around the hole, so the far side of the disk can be seen above and
+// below the hole.
+func pathtrace_activity_06() *sample {
+
+	scene_6 := func() *pathtrace.Scene {
+		hole := geometry.NewKerrSpace(1, .6)
+
+		// The accretion disk is a flattened torus in the plane of rotation.
+		disk := polynomialsurfaces.NewTorus([]float64{0, 0, 0}, []float64{0, 0, 1}, 8, 2)
+		// An infinite cylinder in one dimension is a slab.
+		slab := polynomialsurfaces.NewInfiniteCylinder([]float64{0, 0, 0},
+			[][]float64{[]float64{0, 0, 1. / .3}})
+
+		background := color.ConstantColorFunction(color.PresetColor([]float64{0, 0, 0}))
+
+		return pathtrace.NewCurvedScene(
+			[]*pathtrace.ExtendedObject{
+				pathtrace.NewExtendedObject(booleans.NewIntersection(disk, slab),
+					pathtrace.NewGlowingObject([]float64{1.5, .9, .4}))},
+			background, hole, 0, .25, .000001, 60, 10000)
 	}
 
-	img := pathtrace.Snapshot(scene_5, cam_func, size_u, size_v,
-		depth, minp, maxp, maxMeanVariance, .01, 1000000, 8)
+	camera := &scenes.CameraSpec{Type: "flat",
+		Position: []float64{0, -30, 4},
+		Look:     []float64{0, 0, 0},
+		Up:       []float64{0, 0, 1},
+		Right:    []float64{1, 0, 0},
+		Fov:      []float64{1.33333 / 2., 1. / 2.}}
+
+	return &sample{scene_6, camera, renderSpec(640, 480, 1, 1, 1, 1, 8), nil}
+}
 
-	file := getHandleToOutputFile("activity 05", "activity_05.png")
-	if file == nil {
-		return
+// A wormhole. Through the throat, another universe can be seen with
+// its own objects and its own sky.
+func pathtrace_activity_07() *sample {
+
+	scene_7 := func() *pathtrace.Scene {
+		wormhole := geometry.NewEllisWormhole(2)
+
+		// Each side has a sky lit by a few large spotlights.
+		sky := func(c [][]float64) color.SphericalColorFunction {
+			return color.Spotlights(
+				[][]float64{[]float64{0, 0, 1}, []float64{0, 1, 0}, []float64{1, 0, 0}},
+				[]float64{.5, .5, .5},
+				[]color.Color{color.PresetColor(c[0]), color.PresetColor(c[1]), color.PresetColor(c[2])})
+		}
+
+		upper := []*pathtrace.ExtendedObject{
+			pathtrace.NewExtendedObject(polynomialsurfaces.NewSphere([]float64{4, 4, 0}, 1),
+				pathtrace.NewGlowingObject([]float64{1, 1, 0}))}
+
+		// A ray that goes into the throat comes out of the other side
+		// going the opposite way in the coordinates of that side, so the
+		// camera sees objects on the lower side with negative y.
+		lower := []*pathtrace.ExtendedObject{
+			pathtrace.NewExtendedObject(polynomialsurfaces.NewSphere([]float64{0, -6, 0}, 1),
+				pathtrace.NewGlowingObject([]float64{1, 0, 1})),
+			pathtrace.NewExtendedObject(polynomialsurfaces.NewSphere([]float64{3, -5, 2}, .5),
+				pathtrace.NewGlowingObject([]float64{0, 1, 1}))}
+
+		return pathtrace.NewRegionalScene(
+			[][]*pathtrace.ExtendedObject{upper, lower, []*pathtrace.ExtendedObject{}},
+			[]color.SphericalColorFunction{
+				sky([][]float64{[]float64{.2, .3, .8}, []float64{.1, .1, .4}, []float64{.3, .3, .3}}),
+				sky([][]float64{[]float64{.9, .5, .1}, []float64{.6, .2, .1}, []float64{.8, .8, .3}}),
+				color.ConstantColorFunction(color.PresetColor([]float64{0, 0, 0}))},
+			wormhole, 0, .1, .000001, 60, 10000)
 	}
 
-	png.Encode(file, img)
-	file.Close()
+	camera := &scenes.CameraSpec{Type: "flat",
+		Position: []float64{0, -12, 0},
+		Look:     []float64{0, 0, 0},
+		Up:       []float64{0, 0, 1},
+		Right:    []float64{1, 0, 0},
+		Fov:      []float64{1.33333 / 2., 1. / 2.}}
+
+	return &sample{scene_7, camera, renderSpec(640, 480, 1, 1, 1, 1, 8), nil}
 }
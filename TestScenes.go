@@ -1,14 +1,14 @@
 package main
 
-import "fmt"
-import "image/png"
 //import "github.com/DanielKrawisz/CurvedSpace/surface"
 import "github.com/DanielKrawisz/CurvedSpace/pathtrace"
 import "github.com/DanielKrawisz/CurvedSpace/color"
 import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
 import "github.com/DanielKrawisz/CurvedSpace/surface/complexes"
+import "github.com/DanielKrawisz/CurvedSpace/scenes"
 
-func test_scene_01() {
+//Four variations on a scene with a light, a ground, and a sphere or a box.
+func test_scene_01(variation int) *sample {
 
   scene := func(i int) *pathtrace.Scene {
     ground := polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 0}, []float64{0, 0, 1}, true)
@@ -59,27 +59,13 @@ func test_scene_01() {
     return nil
   }
 
-  var pix_u, pix_v int = 640, 480
+  camera := &scenes.CameraSpec{Type: "cylindrical",
+    Position: []float64{0, -5, 3.75},
+    Look:     []float64{-1, 0, 2},
+    Up:       []float64{0, 0, 1},
+    Right:    []float64{1, 0, 0},
+    Fov:      []float64{1.333, 1}}
 
-  cam_pos   := []float64{0, -5, 3.75}
-  cam_look  := []float64{-1, 0, 2}
-  cam_up    := []float64{0, 0, 1}
-  cam_right := []float64{1, 0, 0}
-  cam_func := pathtrace.CylindricalCamera(cam_pos,
-    pathtrace.CameraMatrix(cam_pos, cam_look, cam_up, cam_right), pix_u, pix_v, 1.333, 1)
-
-  var depth, minp, maxp int = 40, 16, 100
-  var maxMeanVariance float64 = .01
-
-  for i := 0; i < 4; i++ {
-    i := i
-
-    file := getHandleToOutputFile(fmt.Sprint("test scene 01-", i), fmt.Sprint("test_scene_01-", i,".png"))
-    if file == nil {continue}
-
-    img := pathtrace.Snapshot(func() *pathtrace.Scene {return scene(i)},
-      cam_func, pix_u, pix_v, depth, minp, maxp, maxMeanVariance, .05, 1000000, 8)
-
-    png.Encode(file, img)
-  }
+  return &sample{func() *pathtrace.Scene {return scene(variation)},
+    camera, renderSpec(640, 480, 40, 16, 100, .01, 8), nil}
 }
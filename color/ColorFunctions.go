@@ -7,8 +7,10 @@ import "github.com/DanielKrawisz/CurvedSpace/functions"
 type Color func([]float64) []float64
 
 //For when we're working in a set color model, like rgb. 
+//If the receptor is spectral, the color is made into a spectrum. 
 func PresetColor(color []float64) Color {
-  return func ([]float64) []float64 {
+  return func (receptor []float64) []float64 {
+    if IsSpectral(receptor) {return RGBToSpectrum(color, receptor)}
     return color
   }
 }
@@ -0,0 +1,185 @@
+package color
+
+//Light can be traced either in red, green, and blue, or as a spectrum.
+//A ray which is traced in rgb has the receptor RGBReceptor, and its
+//three values are the red, green, and blue channels. A spectral ray
+//has a receptor which is a list of wavelengths in nanometers, and its
+//values are the intensity of the light at those wavelengths. Colors
+//which are given in rgb are turned into smooth spectra as they are
+//needed, and the spectrum of a ray is turned back into rgb with the
+//CIE color matching functions when it reaches the camera.
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//The range of visible wavelengths which spectral rays are sampled from.
+const MinWavelength, MaxWavelength float64 = 380, 780
+
+//The receptor of a ray which is traced in rgb. The numbers stand for
+//the three channels and are not wavelengths.
+var RGBReceptor []float64 = []float64{4, 5, 6}
+
+//Wavelengths near the middle of red, green, and blue, which are used
+//for things like refraction that need a wavelength when a ray is rgb.
+var RGBWavelengths []float64 = []float64{610, 550, 465}
+
+//Whether a receptor is made of visible wavelengths rather than rgb channels.
+func IsSpectral(receptor []float64) bool {
+  if len(receptor) == 0 {return false}
+  for _, l := range receptor {
+    if !(l >= MinWavelength && l <= MaxWavelength) {return false}
+  }
+  return true
+}
+
+//The wavelengths of a receptor. For rgb, these are RGBWavelengths.
+func Wavelengths(receptor []float64) []float64 {
+  if IsSpectral(receptor) {return receptor}
+  return RGBWavelengths
+}
+
+//Choose n wavelengths for a ray. They are evenly spaced across the
+//visible range from a starting point given by u, which is a random
+//number in [0, 1), so that together they cover every wavelength equally.
+func SampleWavelengths(n int, u float64) []float64 {
+  if n <= 0 {return nil}
+  width := MaxWavelength - MinWavelength
+
+  w := make([]float64, n)
+  for i := range w {
+    _, f := math.Modf(u + float64(i) / float64(n))
+    w[i] = MinWavelength + width * f
+  }
+  return w
+}
+
+//A piece of a Gaussian with a different width on each side.
+func lobe(l, mu, below, above float64) float64 {
+  s := above
+  if l < mu {
+    s = below
+  }
+  t := (l - mu) / s
+  return math.Exp(-t * t / 2)
+}
+
+//The CIE 1931 color matching functions, as approximated with sums
+//of Gaussians by Wyman, Sloan, and Shirley. l is in nanometers.
+func CIEMatching(l float64) (x, y, z float64) {
+  x = 1.056 * lobe(l, 599.8, 37.9, 31.0) + .362 * lobe(l, 442.0, 16.0, 26.7) -
+    .065 * lobe(l, 501.1, 20.4, 26.2)
+  y = .821 * lobe(l, 568.8, 46.9, 40.5) + .286 * lobe(l, 530.9, 16.3, 31.1)
+  z = 1.217 * lobe(l, 437.0, 11.8, 36.0) + .681 * lobe(l, 459.0, 26.0, 13.8)
+  return
+}
+
+//From XYZ to linear sRGB.
+var xyzToRGB [][]float64 = [][]float64{
+  []float64{3.2404542, -1.5371385, -.4985314},
+  []float64{-.9692660, 1.8760108, .0415560},
+  []float64{.0556434, -.2040259, 1.0572252}}
+
+//Three smooth spectra which add up to one at every wavelength, and
+//which look mostly blue, green, and red. Any rgb color is given the
+//spectrum which is a sum of these that looks like it.
+func rgbBasis(l float64) []float64 {
+  b := 1 / (1 + math.Exp((l - 490) / 10))
+  r := 1 / (1 + math.Exp((590 - l) / 10))
+  return []float64{r, 1 - r - b, b}
+}
+
+//The rgb of a spectrum, before it is scaled so that white is white.
+func unbalancedRGB(f func(float64) float64) []float64 {
+  var x, y, z float64
+  for l := MinWavelength + .5; l < MaxWavelength; l ++ {
+    v := f(l)
+    cx, cy, cz := CIEMatching(l)
+    x += v * cx
+    y += v * cy
+    z += v * cz
+  }
+  return vector.MatrixMultiply(xyzToRGB, []float64{x, y, z})
+}
+
+//The rgb of a spectrum which is the same at every wavelength, which
+//is taken to be white.
+var whiteRGB []float64 = unbalancedRGB(func(float64) float64 {return 1})
+
+//How much of each part of rgbBasis there is in a color.
+var rgbToBasis [][]float64 = func() [][]float64 {
+  m := make([][]float64, 3)
+  for i := range m {
+    m[i] = make([]float64, 3)
+  }
+
+  for j := 0; j < 3; j ++ {
+    c := unbalancedRGB(func(l float64) float64 {return rgbBasis(l)[j]})
+    for i := 0; i < 3; i ++ {
+      m[i][j] = c[i] / whiteRGB[i]
+    }
+  }
+
+  return vector.Inverse(m)
+}()
+
+//The rgb color of a spectrum, scaled so that a spectrum
+//which is one at every wavelength is white.
+func SpectrumRGB(f func(float64) float64) []float64 {
+  c := unbalancedRGB(f)
+  for i := range c {
+    c[i] /= whiteRGB[i]
+  }
+  return c
+}
+
+//A smooth spectrum which looks like an rgb color, evaluated at some
+//wavelengths. White gives a spectrum that is one everywhere. Colors
+//which are too saturated to be made this way have their spectra
+//cut off at zero.
+func RGBToSpectrum(c []float64, wavelengths []float64) []float64 {
+  a := vector.MatrixMultiply(rgbToBasis, c)
+
+  s := make([]float64, len(wavelengths))
+  for i, l := range wavelengths {
+    s[i] = math.Max(0, vector.Dot(a, rgbBasis(l)))
+  }
+  return s
+}
+
+//Turn the values of a spectrum at some wavelengths into rgb. The
+//wavelengths are assumed to have been chosen so that every visible
+//wavelength is equally likely, as with SampleWavelengths. The result
+//is scaled so that a spectrum which is one everywhere is white.
+func SampledSpectrumToRGB(wavelengths, values []float64) []float64 {
+  var x, y, z float64
+  for i, l := range wavelengths {
+    cx, cy, cz := CIEMatching(l)
+    x += values[i] * cx
+    y += values[i] * cy
+    z += values[i] * cz
+  }
+
+  scale := (MaxWavelength - MinWavelength) / float64(len(wavelengths))
+  c := vector.MatrixMultiply(xyzToRGB, []float64{scale * x, scale * y, scale * z})
+  for i := range c {
+    c[i] /= whiteRGB[i]
+  }
+  return c
+}
+
+//A color given by its spectrum. For a spectral receptor, it is the
+//spectrum at each wavelength, and for rgb, it is the rgb of the spectrum.
+func Spectrum(f func(wavelength float64) float64) Color {
+  if f == nil {return nil}
+  rgb := SpectrumRGB(f)
+
+  return func(receptor []float64) []float64 {
+    if !IsSpectral(receptor) {return rgb}
+
+    c := make([]float64, len(receptor))
+    for i, l := range receptor {
+      c[i] = f(l)
+    }
+    return c
+  }
+}
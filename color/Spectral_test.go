@@ -0,0 +1,126 @@
+package color
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+var spectral_err float64 = .000001
+
+func TestIsSpectral(t *testing.T) {
+  if IsSpectral(RGBReceptor) || IsSpectral(nil) || IsSpectral([]float64{500, 900}) {
+    t.Error("is spectral error 1")
+  }
+  if !IsSpectral([]float64{400, 500, 600, 700}) || !IsSpectral([]float64{550}) {
+    t.Error("is spectral error 2")
+  }
+
+  if !test.VectorCloseEnough(Wavelengths(RGBReceptor), RGBWavelengths, spectral_err) ||
+    !test.VectorCloseEnough(Wavelengths([]float64{420, 680}), []float64{420, 680}, spectral_err) {
+    t.Error("is spectral error 3")
+  }
+}
+
+func TestSampleWavelengths(t *testing.T) {
+  if SampleWavelengths(0, .5) != nil {
+    t.Error("sample wavelengths error 1")
+  }
+
+  for i := 0; i < 100; i ++ {
+    w := SampleWavelengths(4, float64(i) / 100)
+    if len(w) != 4 || !IsSpectral(w) {
+      t.Error("sample wavelengths error 2: ", w)
+      return
+    }
+
+    //The wavelengths are a quarter of the range apart.
+    for j := 1; j < 4; j ++ {
+      d := math.Mod(w[j] - w[0] + 400, 400)
+      if !test.CloseEnough(d, float64(j) * 100, .000001) {
+        t.Error("sample wavelengths error 3: ", w)
+      }
+    }
+  }
+}
+
+func TestCIEMatching(t *testing.T) {
+  //The peaks of the matching functions.
+  x, y, z := CIEMatching(555)
+  if !test.CloseEnough(y, 1, .02) || x < .4 || z > .01 {
+    t.Error("cie matching error 1: ", x, y, z)
+  }
+  x, _, _ = CIEMatching(600)
+  if !test.CloseEnough(x, 1.06, .02) {
+    t.Error("cie matching error 2: ", x)
+  }
+  _, _, z = CIEMatching(445)
+  if !test.CloseEnough(z, 1.78, .05) {
+    t.Error("cie matching error 3: ", z)
+  }
+}
+
+func TestRGBSpectrum(t *testing.T) {
+  //White is flat.
+  w := []float64{380, 450, 500, 550, 600, 650, 779}
+  if !test.VectorCloseEnough(RGBToSpectrum([]float64{1, 1, 1}, w), []float64{1, 1, 1, 1, 1, 1, 1}, spectral_err) {
+    t.Error("rgb spectrum error 1: ", RGBToSpectrum([]float64{1, 1, 1}, w))
+  }
+  if !test.VectorCloseEnough(SpectrumRGB(func(float64) float64 {return .5}), []float64{.5, .5, .5}, spectral_err) {
+    t.Error("rgb spectrum error 2")
+  }
+
+  //Colors which are not too saturated go to a spectrum and back unchanged.
+  for _, c := range [][]float64{{.5, .3, .2}, {.2, .6, .3}, {.3, .4, .8}, {.9, .8, .7}} {
+    s := Spectrum(func(l float64) float64 {return RGBToSpectrum(c, []float64{l})[0]})
+    if rgb := s(RGBReceptor); !test.VectorCloseEnough(rgb, c, .001) {
+      t.Error("rgb spectrum error 3: ", c, rgb)
+    }
+  }
+
+  //Red light is mostly at long wavelengths.
+  r := RGBToSpectrum([]float64{1, 0, 0}, []float64{450, 650})
+  if !(r[1] > .9 && r[0] < .1) {
+    t.Error("rgb spectrum error 4: ", r)
+  }
+}
+
+func TestSampledSpectrumToRGB(t *testing.T) {
+  //On average, sampling a spectrum gives its color.
+  c := []float64{.6, .3, .1}
+  sum := make([]float64, 3)
+  n := 20000
+  for i := 0; i < n; i ++ {
+    w := SampleWavelengths(4, (float64(i) + .5) / float64(n))
+    rgb := SampledSpectrumToRGB(w, RGBToSpectrum(c, w))
+    for j := 0; j < 3; j ++ {
+      sum[j] += rgb[j] / float64(n)
+    }
+  }
+  if !test.VectorCloseEnough(sum, c, .02) {
+    t.Error("sampled spectrum error 1: ", sum)
+  }
+}
+
+func TestSpectralColors(t *testing.T) {
+  p := PresetColor([]float64{.2, .4, .6})
+  if !test.VectorCloseEnough(p(RGBReceptor), []float64{.2, .4, .6}, spectral_err) {
+    t.Error("spectral colors error 1")
+  }
+  if s := p([]float64{450, 550, 650, 700}); len(s) != 4 {
+    t.Error("spectral colors error 2: ", s)
+  }
+
+  //A spectrum that is only at long wavelengths is red.
+  s := Spectrum(func(l float64) float64 {
+    if l > 620 {return 1}
+    return 0
+  })
+  if rgb := s(RGBReceptor); !(rgb[0] > rgb[1] && rgb[0] > rgb[2]) {
+    t.Error("spectral colors error 3: ", rgb)
+  }
+  if v := s([]float64{500, 700}); !test.VectorCloseEnough(v, []float64{0, 1}, spectral_err) {
+    t.Error("spectral colors error 4: ", v)
+  }
+  if Spectrum(nil) != nil {
+    t.Error("spectral colors error 5")
+  }
+}
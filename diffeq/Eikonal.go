@@ -0,0 +1,54 @@
+package diffeq
+
+//The eikonal equation for a light ray in a medium whose refractive
+//index n varies over space. With the parameter s chosen so that
+//ds = dl / n, where l is the length along the ray, the ray obeys
+//
+//  dx/ds = p
+//  dp/ds = n grad n
+//
+//where p = n dx/dl, so that |p| = n. The state is the position
+//followed by p.
+
+import "math"
+
+//The relative size of the step used to differentiate the index.
+const indexDerivativeStep = 1e-6
+
+//Implements Derivative
+type eikonal struct {
+  dim int
+  n func([]float64) float64
+  y []float64
+}
+
+func (e *eikonal) Dimension() int {
+  return 2 * e.dim
+}
+
+func (e *eikonal) DxDs(x []float64, v []float64) {
+  pos := x[:e.dim]
+  n := e.n(pos)
+  copy(e.y, pos)
+
+  for i := 0; i < e.dim; i ++ {
+    v[i] = x[e.dim + i]
+
+    h := indexDerivativeStep * math.Max(1, math.Abs(pos[i]))
+    e.y[i] = pos[i] + h
+    np := e.n(e.y)
+    e.y[i] = pos[i] - h
+    nm := e.n(e.y)
+    e.y[i] = pos[i]
+
+    v[e.dim + i] = n * (np - nm) / (2 * h)
+  }
+}
+
+//dim - the number of dimensions of the space.
+//n - the refractive index.
+//Can return nil!
+func NewEikonalEquation(dim int, n func([]float64) float64) *eikonal {
+  if dim <= 0 || n == nil {return nil}
+  return &eikonal{dim, n, make([]float64, dim)}
+}
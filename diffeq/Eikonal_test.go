@@ -0,0 +1,31 @@
+package diffeq
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+func TestEikonalEquation(t *testing.T) {
+  if NewEikonalEquation(0, func([]float64) float64 {return 1}) != nil {t.Error("eikonal error 1")}
+  if NewEikonalEquation(2, nil) != nil {t.Error("eikonal error 2")}
+
+  //In a uniform medium, rays go in straight lines.
+  e := NewEikonalEquation(3, func([]float64) float64 {return 1.5})
+  v := make([]float64, 6)
+  e.DxDs([]float64{1, 2, 3, .9, 1.2, 0}, v)
+  if !test.VectorCloseEnough(v, []float64{.9, 1.2, 0, 0, 0, 0}, .000001) {
+    t.Error("eikonal error 3: got ", v)
+  }
+
+  //The index increases linearly with y, so the ray bends
+  //toward y along a catenary.
+  //
+  //  y = 10 cosh(s / 10) - 10
+  st := NewState(0, 0, .1, []float64{0, 0, 1, 0})
+  e = NewEikonalEquation(2, func(x []float64) float64 {return 1 + .1 * x[1]})
+  end, _ := NewSolver(st, e, NewRungeKuttaSolverMethodDormandPrince(4, .0000001),
+    []Monitor{NewUntilTime(st, 2, .0000001)}, 1000, 1000).Run()
+
+  if !test.VectorCloseEnough(end.X[:2], []float64{2, 10 * math.Cosh(.2) - 10}, .00001) {
+    t.Error("eikonal error 4: got ", end.X)
+  }
+}
@@ -0,0 +1,247 @@
+package diffeq
+
+//Monitors for differential equations whose solutions encounter discrete
+//changes, such as a light ray hitting a surface or moving from one
+//coordinate region to another.
+
+import "math"
+
+//An Event is a function over the state of a differential equation. The
+//event happens when the function changes sign. The region is the region
+//of space in whose coordinates x is given.
+type Event func(region int, x []float64) float64
+
+//A Monitor which can tell whether its event has happened.
+type EventMonitor interface {
+  Monitor
+  Triggered() bool
+}
+
+//The maximum number of bisections used to find an event.
+const maxBisections = 64
+
+//A Monitor that stops the simulation at the first of several events.
+//Every event is checked over each step, and the step is cut back by
+//bisection to the earliest point at which any of them has changed sign,
+//so that if several happen during one step, the first is the one found.
+//An event which cannot be evaluated at a point may give NaN there, and
+//then it has not changed sign over any step which begins or ends there.
+//Implements EventMonitor
+type untilFirstEvent struct {
+  first int
+  skip []bool
+  err float64
+  e []Event
+  x State
+}
+
+func eventSide(z float64) bool {
+  return z >= 0
+}
+
+//The first event, other than those which are skipped, which is on a
+//different side at the end of the step than at the beginning, or -1.
+func (m *untilFirstEvent) changed(region int, start []float64) int {
+  x := m.x.newPosition()
+  for i, e := range m.e {
+    if m.skip[i] || math.IsNaN(start[i]) {
+      continue
+    }
+    if z := e(region, x); !math.IsNaN(z) && eventSide(z) != eventSide(start[i]) {
+      return i
+    }
+  }
+  return -1
+}
+
+func (m *untilFirstEvent) update(f Derivative, step Step) {
+  //Events are only skipped during the first step, since the
+  //simulation may start out on the boundary of one of them.
+  defer func() {
+    for i := range m.skip {
+      m.skip[i] = false
+    }
+  }()
+
+  region := m.x.Region()
+  start := make([]float64, len(m.e))
+  for i, e := range m.e {
+    start[i] = e(region, m.x.position())
+  }
+
+  if m.changed(region, start) == -1 {
+    return
+  }
+
+  //If the step cannot be taken again, the event is not reported.
+  var lo, hi float64 = 0, m.x.Ds()
+  for i := 0; i < maxBisections && math.Abs(hi - lo) > m.err; i ++ {
+    m.x.setDs((lo + hi) / 2)
+    if step.Step(m.x, f) != nil {
+      break
+    }
+
+    if m.changed(region, start) == -1 {
+      lo = m.x.Ds()
+    } else {
+      hi = m.x.Ds()
+    }
+  }
+
+  m.x.setDs(hi)
+  if step.Step(m.x, f) != nil {
+    return
+  }
+  m.first = m.changed(region, start)
+}
+
+func (m *untilFirstEvent) end() bool {
+  return m.first != -1
+}
+
+//Whether any of the events has happened.
+func (m *untilFirstEvent) Triggered() bool {
+  return m.first != -1
+}
+
+//The index of the event which happened first, or -1 if none has.
+func (m *untilFirstEvent) First() int {
+  return m.first
+}
+
+//A constructor for an UntilFirstEvent monitor. A sign change of event
+//i during the first step is ignored if skipFirst[i] is true.
+//Can return nil!
+func NewUntilFirstEvent(x State, e []Event, err float64, skipFirst []bool) *untilFirstEvent {
+  if math.IsNaN(err) || math.IsInf(err, 0) || err <= 0 {return nil}
+  if x == nil || len(skipFirst) != len(e) {return nil}
+  for _, event := range e {
+    if event == nil {return nil}
+  }
+
+  return &untilFirstEvent{-1, append([]bool(nil), skipFirst...), err, e, x}
+}
+
+//A constructor for a monitor that stops the simulation when a single
+//event happens. If skipFirst is true, then a sign change during the
+//first step is ignored.
+//Can return nil!
+func NewUntilEvent(x State, e Event, err float64, skipFirst bool) *untilFirstEvent {
+  return NewUntilFirstEvent(x, []Event{e}, err, []bool{skipFirst})
+}
+
+//A Monitor that prevents the step size from growing beyond a given
+//limit. This ensures that small events are not skipped over.
+//Implements Monitor
+type maxStepSize struct {
+  max float64
+  x State
+}
+
+func (m *maxStepSize) update(f Derivative, step Step) {
+  if math.Abs(m.x.NextDs()) > m.max {
+    m.x.nextDs(math.Copysign(m.max, m.x.NextDs()))
+  }
+}
+
+func (m *maxStepSize) end() bool {
+  return false
+}
+
+//Can return nil!
+func NewMaxStepSize(x State, max float64) *maxStepSize {
+  if math.IsNaN(max) || max <= 0 {return nil}
+  if x == nil {return nil}
+
+  return &maxStepSize{max, x}
+}
+
+//A derivative over a space which is covered by several regions,
+//each with its own coordinates.
+type RegionalDerivative interface {
+  Derivative
+  //Set the region whose coordinates DxDs expects.
+  SetRegion(region int)
+  //Transform x in place to the coordinates of the region
+  //which is preferred at its location and return that region.
+  ChangeRegion(region int, x []float64) int
+}
+
+//A Monitor that moves the state to a new region when the
+//derivative prefers a different region. This should be the
+//last of the monitors because the others expect the current
+//and the new positions to be given in the same region.
+//Implements Monitor
+type regionMonitor struct {
+  f RegionalDerivative
+  x State
+}
+
+func (m *regionMonitor) update(f Derivative, step Step) {
+  region := m.f.ChangeRegion(m.x.Region(), m.x.newPosition())
+  if region == m.x.Region() {return}
+
+  m.x.setRegion(region)
+  m.f.SetRegion(region)
+  m.f.DxDs(m.x.newPosition(), m.x.newVelocity())
+}
+
+func (m *regionMonitor) end() bool {
+  return false
+}
+
+//Can return nil!
+func NewRegionMonitor(x State, f RegionalDerivative) *regionMonitor {
+  if x == nil || f == nil {return nil}
+
+  f.SetRegion(x.Region())
+  return &regionMonitor{f, x}
+}
+
+//A Monitor that ends the simulation when the state falls through
+//a horizon or escapes to infinity. The radius function gives the
+//distance from the center in the coordinates of any region.
+//Implements EventMonitor
+type horizonMonitor struct {
+  absorbed, escaped bool
+  horizon, escape float64
+  radius func(region int, x []float64) float64
+  x State
+}
+
+func (m *horizonMonitor) update(f Derivative, step Step) {
+  r := m.radius(m.x.Region(), m.x.newPosition())
+  if r < m.horizon {
+    m.absorbed = true
+  } else if r > m.escape {
+    m.escaped = true
+  }
+}
+
+func (m *horizonMonitor) end() bool {
+  return m.absorbed || m.escaped
+}
+
+func (m *horizonMonitor) Triggered() bool {
+  return m.end()
+}
+
+//Whether the state has fallen through the horizon.
+func (m *horizonMonitor) Absorbed() bool {
+  return m.absorbed
+}
+
+//Whether the state has gone beyond the escape radius.
+func (m *horizonMonitor) Escaped() bool {
+  return m.escaped
+}
+
+//horizon - states with a smaller radius than this are absorbed.
+//escape - states with a larger radius than this have escaped. May be infinity.
+//Can return nil!
+func NewHorizonMonitor(x State, radius func(region int, x []float64) float64, horizon, escape float64) *horizonMonitor {
+  if x == nil || radius == nil {return nil}
+  if math.IsNaN(horizon) || math.IsNaN(escape) || !(escape > horizon) {return nil}
+
+  return &horizonMonitor{false, false, horizon, escape, radius, x}
+}
@@ -0,0 +1,152 @@
+package diffeq
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+//A particle moving at a constant velocity.
+type constantVelocity struct {
+  v []float64
+}
+
+func (c *constantVelocity) Dimension() int {
+  return len(c.v)
+}
+
+func (c *constantVelocity) DxDs(x []float64, v []float64) {
+  copy(v, c.v)
+}
+
+func TestUntilEvent(t *testing.T) {
+  if NewUntilEvent(nil, func(int, []float64) float64 {return 0}, .1, false) != nil {
+    t.Error("until event error 1")
+  }
+  if NewUntilEvent(NewState(0, 0, .1, []float64{0}), nil, .1, false) != nil {
+    t.Error("until event error 2")
+  }
+
+  for i, skip := range []bool{false, true} {
+    st := NewState(0, 0, .3, []float64{0, 0})
+    f := &constantVelocity{[]float64{1, 2}}
+    e := NewUntilEvent(st, func(region int, x []float64) float64 {return x[0] - 1.05}, .0000001, skip)
+    a := NewUntilEvent(st, func(region int, x []float64) float64 {return x[1] - 20}, .0000001, false)
+
+    end, _ := NewSolver(st, f, NewRungeKuttaSolverMethodDormandPrince(2, .001),
+      []Monitor{e, a, NewMaxStepSize(st, .5)}, 1000, 1000).Run()
+
+    if !e.Triggered() || a.Triggered() {
+      t.Error("until event error 3, case ", i)
+    }
+    if !test.VectorCloseEnough(end.X, []float64{1.05, 2.1}, .00001) {
+      t.Error("until event error 4, case ", i, ": got ", end.X)
+    }
+  }
+}
+
+func TestUntilFirstEvent(t *testing.T) {
+  st := NewState(0, 0, .3, []float64{0})
+  never := func(int, []float64) float64 {return -1}
+  if NewUntilFirstEvent(nil, []Event{never}, .1, []bool{false}) != nil ||
+    NewUntilFirstEvent(st, []Event{nil}, .1, []bool{false}) != nil ||
+    NewUntilFirstEvent(st, []Event{never}, .1, nil) != nil || NewUntilFirstEvent(st, []Event{never}, 0, []bool{false}) != nil {
+    t.Error("until first event error 1")
+  }
+
+  //Both events happen during the first step, but the second happens first,
+  //unless it is skipped.
+  for i, skip := range []bool{false, true} {
+    st := NewState(0, 0, .5, []float64{0})
+    e := NewUntilFirstEvent(st, []Event{
+      func(region int, x []float64) float64 {return x[0] - .4},
+      func(region int, x []float64) float64 {return x[0] - .2}}, .0000001, []bool{false, skip})
+
+    end, _ := NewSolver(st, &constantVelocity{[]float64{1}}, NewRungeKuttaSolverMethodDormandPrince(1, .001),
+      []Monitor{e, NewMaxStepSize(st, .5)}, 1000, 1000).Run()
+
+    expected, at := 1, .2
+    if skip {
+      expected, at = 0, .4
+    }
+    if !e.Triggered() || e.First() != expected || !test.CloseEnough(end.X[0], at, .00001) {
+      t.Error("until first event error 2, case ", i, ": got ", e.First(), end.X)
+    }
+  }
+
+  //An event which cannot be evaluated beyond .3 does not happen there.
+  st = NewState(0, 0, .25, []float64{0})
+  e := NewUntilEvent(st, func(region int, x []float64) float64 {
+    if x[0] > .3 {
+      return math.NaN()
+    }
+    return 1
+  }, .0000001, false)
+  NewSolver(st, &constantVelocity{[]float64{1}}, NewRungeKuttaSolverMethodDormandPrince(1, .001),
+    []Monitor{e, NewMaxStepSize(st, .25)}, 10, 1000).Run()
+  if e.Triggered() {
+    t.Error("until first event error 3")
+  }
+}
+
+func TestMaxStepSize(t *testing.T) {
+  st := NewState(0, 0, .1, []float64{0})
+  NewSolver(st, &constantVelocity{[]float64{1}}, NewRungeKuttaSolverMethodDormandPrince(1, .001),
+    []Monitor{NewMaxStepSize(st, .25)}, 10, 1000).Run()
+
+  if st.Ds() > .25 || st.NextDs() > .25 {
+    t.Error("max step size error: got ", st.Ds(), st.NextDs())
+  }
+}
+
+//A derivative over two regions, where the second region has
+//coordinates that are twice those of the first.
+type twoRegions struct {
+  constantVelocity
+  region int
+}
+
+func (r *twoRegions) SetRegion(region int) {
+  r.region = region
+}
+
+func (r *twoRegions) ChangeRegion(region int, x []float64) int {
+  if region == 0 && x[0] > 1 {
+    x[0] *= 2
+    return 1
+  }
+  return region
+}
+
+func TestRegionMonitor(t *testing.T) {
+  st := NewState(0, 0, .25, []float64{0})
+  f := &twoRegions{constantVelocity{[]float64{1}}, 0}
+
+  end, _ := NewSolver(st, f, NewRungeKuttaSolverMethodDormandPrince(1, .001),
+    []Monitor{NewMaxStepSize(st, .25), NewRegionMonitor(st, f)}, 6, 1000).Run()
+
+  if end.Region != 1 || f.region != 1 || !test.CloseEnough(end.X[0], 2.75, .00001) {
+    t.Error("region monitor error: got ", end)
+  }
+}
+
+func TestHorizonMonitor(t *testing.T) {
+  radius := func(region int, x []float64) float64 {return x[0]}
+  if NewHorizonMonitor(nil, radius, 1, 2) != nil {t.Error("horizon monitor error 1")}
+  if NewHorizonMonitor(NewState(0, 0, .1, []float64{0}), radius, 2, 1) != nil {
+    t.Error("horizon monitor error 2")
+  }
+
+  for i, v := range []float64{-1, 1} {
+    st := NewState(0, 0, .25, []float64{0})
+    m := NewHorizonMonitor(st, radius, -1, 2)
+
+    end, _ := NewSolver(st, &constantVelocity{[]float64{v}}, NewRungeKuttaSolverMethodDormandPrince(1, .001),
+      []Monitor{NewMaxStepSize(st, .25), m}, 100, 1000).Run()
+
+    if !m.Triggered() || m.Absorbed() != (v < 0) || m.Escaped() != (v > 0) {
+      t.Error("horizon monitor error 3, case ", i)
+    }
+    if !(end.X[0] < -1 || end.X[0] > 2) || math.Abs(end.X[0]) > 2.25 {
+      t.Error("horizon monitor error 4, case ", i, ": got ", end.X)
+    }
+  }
+}
@@ -161,6 +161,8 @@ func (rk *rungeKuttaStepSizer) Step(st State, f Derivative) error {
 		if math.IsNaN(ds) || math.IsInf(ds, 0) {
 			return &OverflowError{}
 		}
+
+		st.setDs(ds)
 	}
 
 	rk.nextVelocity(st, f)
@@ -176,6 +178,7 @@ func (rk *rungeKuttaStepSizer) Step(st State, f Derivative) error {
 }
 
 func (rk *rungeKuttaStepSizer) rkinitialize(n int, errscale float64) {
+	rk.n = n
 	rk.errscale = errscale
 	//Set up some temporary variables for the intermediate parts
 	//of the computation.
@@ -108,6 +108,21 @@ func (sol *solverStepMonitor) Run() (i *Instant, l *list.List) {
   return i, l
 }
 
+//Create a solver which runs until one of its monitors ends it, until
+//it has taken maxsteps steps, or until it has gone farther than maxarclength.
+//Can return nil!
+func NewSolver(x State, f Derivative, step Step, monitor []Monitor, maxsteps int, maxarclength float64) Solver {
+  if x == nil || f == nil || step == nil {return nil}
+  if x.Length() != f.Dimension() || step.Dimension() != f.Dimension() {return nil}
+  if maxsteps <= 0 || math.IsNaN(maxarclength) {return nil}
+
+  for _, m := range monitor {
+    if m == nil {return nil}
+  }
+
+  return &solverStepMonitor{maxsteps, maxarclength, false, x, f, step, monitor}
+}
+
 type State interface {
   //The arc length parameter which defines how far the 
   //simulation has gone. In Newtonian physics this would
@@ -119,6 +134,11 @@ type State interface {
   setDs(dt float64)
   //Set the next ds.
   nextDs(ds float64)
+  //The step size that will be used after this step.
+  NextDs() float64
+  //The region of space in whose coordinates the state is given.
+  Region() int
+  setRegion(region int)
   //The number of elements in the arrays.
   Length() int
   //get the current instant. 
@@ -164,6 +184,18 @@ func (p *state) nextDs(ds float64) {
   p.newds = ds
 }
 
+func (p *state) NextDs() float64 {
+  return p.newds
+}
+
+func (p *state) Region() int {
+  return p.region
+}
+
+func (p *state) setRegion(region int) {
+  p.region = region
+}
+
 func (p *state) position() []float64 {
   return p.pos
 }
@@ -207,6 +239,24 @@ func (p *state) postStep() {
   p.ds = p.newds
 }
 
+//Returns a state for a general system of first-order equations.
+//Can return nil!
+func NewState(region int, initS, initDs float64, initpos []float64) *state {
+  if math.IsNaN(initS) || math.IsInf(initS, 0) {return nil}
+  if math.IsNaN(initDs) || math.IsInf(initDs, 0) || initDs == 0.0 {return nil}
+  if initpos == nil || len(initpos) == 0 {return nil}
+
+  n := len(initpos)
+  pos := make([]float64, n)
+  for i := 0; i < n; i++ {
+    if math.IsNaN(initpos[i]) || math.IsInf(initpos[i], 0) {return nil}
+    pos[i] = initpos[i]
+  }
+
+  return &state{n, region, initS, initDs, initDs,
+    pos, make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)}
+}
+
 //A Monitor that stops the simulation at exactly a specific time. 
 //Implements Monitor
 type untilTime struct {
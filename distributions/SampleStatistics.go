@@ -1,5 +1,9 @@
 package distributions
 
+import "encoding/binary"
+import "errors"
+import "math"
+
 type SampleStatistics struct {
   n int
   k, ex, ex2 float64
@@ -35,3 +39,29 @@ func (v *SampleStatistics) MeanVariance() float64 {
 func NewSampleStatistics() *SampleStatistics {
   return &SampleStatistics{0, 0, 0, 0}
 }
+
+//The number of variables that have been added.
+func (v *SampleStatistics) Count() int {
+  return v.n
+}
+
+//The statistics as 32 bytes, so that they can be saved and taken up again.
+func (v *SampleStatistics) MarshalBinary() ([]byte, error) {
+  b := make([]byte, 32)
+  binary.LittleEndian.PutUint64(b[0:], uint64(v.n))
+  for i, x := range []float64{v.k, v.ex, v.ex2} {
+    binary.LittleEndian.PutUint64(b[8 + 8 * i:], math.Float64bits(x))
+  }
+  return b, nil
+}
+
+func (v *SampleStatistics) UnmarshalBinary(b []byte) error {
+  if len(b) != 32 {
+    return errors.New("distributions: sample statistics must be 32 bytes")
+  }
+  v.n = int(int64(binary.LittleEndian.Uint64(b[0:])))
+  v.k = math.Float64frombits(binary.LittleEndian.Uint64(b[8:]))
+  v.ex = math.Float64frombits(binary.LittleEndian.Uint64(b[16:]))
+  v.ex2 = math.Float64frombits(binary.LittleEndian.Uint64(b[24:]))
+  return nil
+}
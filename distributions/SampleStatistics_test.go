@@ -0,0 +1,28 @@
+package distributions
+
+import "testing"
+
+func TestSampleStatisticsBinary(t *testing.T) {
+  v := NewSampleStatistics()
+  for _, x := range []float64{1, 3, 4, 9} {
+    v.AddVariable(x)
+  }
+
+  b, err := v.MarshalBinary()
+  if err != nil || len(b) != 32 {
+    t.Error("sample statistics binary error 1: ", err, len(b))
+    return
+  }
+
+  w := NewSampleStatistics()
+  if err := w.UnmarshalBinary(b); err != nil {
+    t.Error("sample statistics binary error 2: ", err)
+  }
+  if w.Count() != 4 || w.Mean() != v.Mean() || w.Variance() != v.Variance() {
+    t.Error("sample statistics binary error 3: ", w)
+  }
+
+  if w.UnmarshalBinary(b[:31]) == nil {
+    t.Error("sample statistics binary error 4")
+  }
+}
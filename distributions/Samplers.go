@@ -0,0 +1,332 @@
+package distributions
+
+import "math"
+import "math/rand"
+
+//Random points are grainy because they clump together and leave gaps.
+//A sampler gives points which are spread more evenly, so that the mean
+//of a pixel converges faster. Each sample of a pixel is a point in a
+//space of many dimensions: the first two might be used to choose where
+//the ray goes through the pixel, the next two to choose the direction
+//in which it is scattered, and so on. Dimensions beyond those that a
+//sampler can spread evenly are given at random.
+//
+//The same points are not used for every pixel, or the picture would
+//show patterns instead of grain. Each pixel scrambles the points in
+//a way that keeps them spread out.
+//
+//Everything about a sample is worked out from the seed of the sampler,
+//the pixel, and the index of the sample, so a picture comes out the
+//same every time no matter how its pixels are shared among goroutines.
+//A sampler must not be used by more than one goroutine at once.
+type Sampler interface {
+  //Begin the sample with the given index for pixel x, y.
+  Start(x, y, index int)
+  //The next dimension of the current sample, in [0, 1).
+  Float64() float64
+  //Random numbers for the current sample, for choices which
+  //are not worth a dimension of their own.
+  Rand() *rand.Rand
+}
+
+//A fast source of random numbers, splitmix64, which can be seeded
+//again for every sample at no cost.
+type splitmix struct {
+  state uint64
+}
+
+func (s *splitmix) Seed(seed int64) {
+  s.state = uint64(seed)
+}
+
+func (s *splitmix) Uint64() uint64 {
+  s.state += 0x9e3779b97f4a7c15
+  z := s.state
+  z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+  z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+  return z ^ (z >> 31)
+}
+
+func (s *splitmix) Int63() int64 {
+  return int64(s.Uint64() >> 1)
+}
+
+//Numbers which are not spread out at all.
+type randomSampler struct {
+  position
+}
+
+func (r *randomSampler) Float64() float64 {
+  return r.rand.Float64()
+}
+
+func NewRandomSampler(seed int64) Sampler {
+  return &randomSampler{newPosition(seed)}
+}
+
+//A hash of the pixel, the dimension and the seed of a sampler, which
+//is used to scramble the points differently for each of them.
+func hash(seed uint32, x, y, d int) uint32 {
+  h := seed
+  for _, k := range []int{x, y, d} {
+    h ^= uint32(k) * 0x9e3779b9
+    h ^= h >> 16
+    h *= 0x85ebca6b
+    h ^= h >> 13
+    h *= 0xc2b2ae35
+    h ^= h >> 16
+  }
+  return h
+}
+
+//A number in [0, 1) from the high bits of an integer.
+func toFloat(i uint32) float64 {
+  return float64(i) / (1 << 32)
+}
+
+//Where the sampler is in the sequence of samples, and the random
+//numbers for the current sample.
+type position struct {
+  seed uint32
+  x, y, index, dimension int
+  source *splitmix
+  rand *rand.Rand
+}
+
+func newPosition(seed int64) position {
+  source := &splitmix{}
+  source.Seed(seed)
+  return position{uint32(seed) ^ uint32(seed >> 32), 0, 0, 0, 0, source, rand.New(source)}
+}
+
+func (p *position) Start(x, y, index int) {
+  p.x, p.y, p.index, p.dimension = x, y, index, 0
+  p.source.Seed(int64(hash(p.seed, x, y, index)) << 32 | int64(hash(^p.seed, x, y, index)))
+}
+
+func (p *position) Rand() *rand.Rand {
+  return p.rand
+}
+
+//Jittered samples. The samples of a pixel are put in a grid of cells, one to
+//each, in each pair of dimensions. Each sample is also in its own row and
+//column of the grid, and the cells are shuffled differently for each pair of
+//dimensions, so that the samples are spread out in all of them. This is
+//from Kensler, Correlated Multi-Jittered Sampling, 2013. After every n
+//samples, the cells are shuffled again.
+type stratifiedSampler struct {
+  position
+  n int
+  //The rows and columns of the grid.
+  rows, columns int
+  //The second dimension of a pair, which is worked out with the first.
+  next float64
+}
+
+func (s *stratifiedSampler) Float64() float64 {
+  d := s.dimension
+  s.dimension ++
+  if d % 2 == 1 {
+    return s.next
+  }
+
+  m, n := uint32(s.columns), uint32(s.rows)
+  p := hash(s.seed + uint32(s.index / s.n), s.x, s.y, d)
+  i := permute(uint32(s.index % s.n), uint32(s.n), p * 0x51633e2d)
+
+  sx := permute(i % m, m, p * 0xa511e9b3)
+  sy := permute(i / m, n, p * 0x63d83595)
+  u := (float64(i % m) + (float64(sy) + s.rand.Float64()) / float64(n)) / float64(m)
+  s.next = (float64(i / m) + (float64(sx) + s.rand.Float64()) / float64(m)) / float64(n)
+  return u
+}
+
+//Samples which are jittered in n cells. The best n is the number of
+//samples that each pixel is expected to have.
+//
+//May return nil.
+func NewStratifiedSampler(n int, seed int64) Sampler {
+  if n <= 0 { return nil }
+  columns := int(math.Ceil(math.Sqrt(float64(n))))
+  rows := (n + columns - 1) / columns
+  return &stratifiedSampler{newPosition(seed), n, rows, columns, 0}
+}
+
+//The position of i in a random permutation of [0, l) which is chosen
+//by p. This is from Kensler, Correlated Multi-Jittered Sampling, 2013.
+func permute(i, l, p uint32) uint32 {
+  w := l - 1
+  w |= w >> 1
+  w |= w >> 2
+  w |= w >> 4
+  w |= w >> 8
+  w |= w >> 16
+
+  for {
+    i ^= p
+    i *= 0xe170893d
+    i ^= p >> 16
+    i ^= (i & w) >> 4
+    i ^= p >> 8
+    i *= 0x0929eb3f
+    i ^= p >> 23
+    i ^= (i & w) >> 1
+    i *= 1 | p >> 27
+    i *= 0x6935fa69
+    i ^= (i & w) >> 11
+    i *= 0x74dcb303
+    i ^= (i & w) >> 2
+    i *= 0x9e501cc3
+    i ^= (i & w) >> 2
+    i *= 0xc860a3df
+    i &= w
+    i ^= i >> 5
+    if i < l { break }
+  }
+
+  return (i + p) % l
+}
+
+//The first primes, which are the bases of the dimensions of the Halton sequence.
+var primes []int = []int{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53,
+  59, 61, 67, 71, 73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131}
+
+//The digits of i in base b, reversed after the point.
+func radicalInverse(i, b int) float64 {
+  var r float64
+  f := 1 / float64(b)
+  for inv := f; i > 0; i /= b {
+    r += float64(i % b) * inv
+    inv *= f
+  }
+  return r
+}
+
+//The Halton sequence, in which dimension d is the radical inverse of the
+//index in the dth prime. Each pixel shifts the sequence by a random amount
+//in each dimension, wrapping around at one.
+type haltonSampler struct {
+  position
+}
+
+func (h *haltonSampler) Float64() float64 {
+  d := h.dimension
+  h.dimension ++
+  if d >= len(primes) {
+    return h.rand.Float64()
+  }
+
+  r := radicalInverse(h.index, primes[d]) + toFloat(hash(h.seed, h.x, h.y, d))
+  if r >= 1 {
+    r -= 1
+  }
+  return r
+}
+
+func NewHaltonSampler(seed int64) Sampler {
+  return &haltonSampler{newPosition(seed)}
+}
+
+//The degree, coefficients, and first direction numbers of the primitive
+//polynomials used for dimensions after the first of the Sobol sequence.
+//These are from Joe and Kuo, Constructing Sobol sequences with better
+//two-dimensional projections, 2008.
+var sobolPolynomials = []struct {
+  s, a uint32
+  m []uint32
+}{
+  {1, 0, []uint32{1}},
+  {2, 1, []uint32{1, 3}},
+  {3, 1, []uint32{1, 3, 1}},
+  {3, 2, []uint32{1, 1, 1}},
+  {4, 1, []uint32{1, 1, 3, 3}},
+  {4, 4, []uint32{1, 3, 5, 13}},
+  {5, 2, []uint32{1, 1, 5, 5, 17}},
+  {5, 4, []uint32{1, 1, 5, 5, 5}},
+  {5, 7, []uint32{1, 1, 7, 11, 19}},
+  {5, 11, []uint32{1, 1, 5, 1, 1}},
+  {5, 13, []uint32{1, 1, 1, 3, 11}},
+  {5, 14, []uint32{1, 3, 5, 5, 31}},
+  {6, 1, []uint32{1, 3, 3, 9, 7, 49}},
+  {6, 13, []uint32{1, 1, 1, 15, 21, 21}},
+  {6, 16, []uint32{1, 3, 1, 13, 27, 49}}}
+
+//The direction numbers of each dimension of the Sobol sequence.
+var sobolDirections [][32]uint32 = newSobolDirections()
+
+func newSobolDirections() [][32]uint32 {
+  v := make([][32]uint32, len(sobolPolynomials) + 1)
+
+  //The first dimension is the radical inverse in base 2.
+  for k := 0; k < 32; k ++ {
+    v[0][k] = 1 << uint(31 - k)
+  }
+
+  for d, p := range sobolPolynomials {
+    s := int(p.s)
+    for k := 0; k < s; k ++ {
+      v[d + 1][k] = p.m[k] << uint(31 - k)
+    }
+    for k := s; k < 32; k ++ {
+      v[d + 1][k] = v[d + 1][k - s] ^ (v[d + 1][k - s] >> uint(s))
+      for j := 1; j < s; j ++ {
+        v[d + 1][k] ^= ((p.a >> uint(s - 1 - j)) & 1) * v[d + 1][k - j]
+      }
+    }
+  }
+
+  return v
+}
+
+//The Sobol sequence. Each pixel scrambles the sequence by flipping a random
+//set of the binary digits of each dimension, which keeps its points as
+//evenly spread as they were.
+type sobolSampler struct {
+  position
+}
+
+func (s *sobolSampler) Float64() float64 {
+  d := s.dimension
+  s.dimension ++
+  if d >= len(sobolDirections) {
+    return s.rand.Float64()
+  }
+
+  x := hash(s.seed, s.x, s.y, d)
+  for k, i := 0, uint32(s.index); i != 0; i >>= 1 {
+    if i & 1 == 1 {
+      x ^= sobolDirections[d][k]
+    }
+    k ++
+  }
+  return toFloat(x)
+}
+
+func NewSobolSampler(seed int64) Sampler {
+  return &sobolSampler{newPosition(seed)}
+}
+
+//A point distributed uniformly on the surface of the unit sphere.
+func UnitSphereSurfacePoint(s Sampler) []float64 {
+  z := 1 - 2 * s.Float64()
+  r := math.Sqrt(math.Max(0, 1 - z * z))
+  phi := 2 * math.Pi * s.Float64()
+  return []float64{r * math.Cos(phi), r * math.Sin(phi), z}
+}
+
+//A vector whose components are normally distributed.
+func NormallyDistributedVector(s Sampler, dim int, mean, stddev float64) []float64 {
+  vec := make([]float64, dim)
+
+  //The Box-Muller transform, which gives two numbers at a time.
+  for i := 0; i < dim; i += 2 {
+    r := stddev * math.Sqrt(-2 * math.Log(1 - s.Float64()))
+    phi := 2 * math.Pi * s.Float64()
+    vec[i] = r * math.Cos(phi) + mean
+    if i + 1 < dim {
+      vec[i + 1] = r * math.Sin(phi) + mean
+    }
+  }
+
+  return vec
+}
@@ -0,0 +1,181 @@
+package distributions
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+func TestPermute(t *testing.T) {
+  for _, l := range []uint32{1, 2, 5, 16, 100} {
+    for p := uint32(0); p < 5; p ++ {
+      seen := make([]bool, l)
+      for i := uint32(0); i < l; i ++ {
+        k := permute(i, l, p * 0x12345)
+        if k >= l || seen[k] {
+          t.Error("permute error: ", i, l, p, k)
+          break
+        }
+        seen[k] = true
+      }
+    }
+  }
+}
+
+//Whether n samples of a pixel put one point in each of n equal
+//intervals in each of the first dimensions.
+func stratified(s Sampler, n, dimensions int) bool {
+  for x := 0; x < 3; x ++ {
+    seen := make([][]bool, dimensions)
+    for d := range seen {
+      seen[d] = make([]bool, n)
+    }
+
+    for i := 0; i < n; i ++ {
+      s.Start(x, 7, i)
+      for d := 0; d < dimensions; d ++ {
+        u := s.Float64()
+        if u < 0 || u >= 1 || seen[d][int(u * float64(n))] {
+          return false
+        }
+        seen[d][int(u * float64(n))] = true
+      }
+    }
+  }
+  return true
+}
+
+func TestSamplerStratification(t *testing.T) {
+  if NewStratifiedSampler(0, 1) != nil {
+    t.Error("sampler stratification error 1")
+  }
+
+  if !stratified(NewStratifiedSampler(12, 1), 12, 40) {
+    t.Error("sampler stratification error 2")
+  }
+  if !stratified(NewHaltonSampler(1), 8, 1) {
+    t.Error("sampler stratification error 3")
+  }
+  if !stratified(NewSobolSampler(1), 32, len(sobolDirections)) {
+    t.Error("sampler stratification error 4")
+  }
+
+  //The first two dimensions of the Sobol sequence put one
+  //point in each square of a grid.
+  s := NewSobolSampler(1)
+  seen := make(map[[2]int]bool)
+  for i := 0; i < 64; i ++ {
+    s.Start(3, 4, i)
+    cell := [2]int{int(s.Float64() * 8), int(s.Float64() * 8)}
+    if seen[cell] {
+      t.Error("sampler stratification error 5: ", i, cell)
+    }
+    seen[cell] = true
+  }
+
+  //The pixels are scrambled differently.
+  s.Start(0, 0, 0)
+  a := s.Float64()
+  s.Start(1, 0, 0)
+  if a == s.Float64() {
+    t.Error("sampler stratification error 6")
+  }
+}
+
+//The error in the area of a quarter of a circle, estimated with
+//n points for each of many pixels.
+func quarterCircleError(s Sampler, n int) float64 {
+  var e float64
+  pixels := 200
+  for x := 0; x < pixels; x ++ {
+    var area float64
+    for i := 0; i < n; i ++ {
+      s.Start(x, 0, i)
+      u, v := s.Float64(), s.Float64()
+      if u * u + v * v < 1 {
+        area += 1 / float64(n)
+      }
+    }
+    e += (area - math.Pi / 4) * (area - math.Pi / 4) / float64(pixels)
+  }
+  return e
+}
+
+//Evenly spread points converge faster than random points.
+func TestSamplerConvergence(t *testing.T) {
+  random := quarterCircleError(NewRandomSampler(1), 64)
+  for i, s := range []Sampler{NewStratifiedSampler(64, 1), NewHaltonSampler(1), NewSobolSampler(1)} {
+    if e := quarterCircleError(s, 64); !(e * 3 < random) {
+      t.Error("sampler convergence error ", i, ": ", e, random)
+    }
+  }
+}
+
+func TestSampledDistributions(t *testing.T) {
+  s := NewSobolSampler(1)
+  var mean [3]float64
+  for i := 0; i < 256; i ++ {
+    s.Start(0, 0, i)
+    p := UnitSphereSurfacePoint(s)
+    if !test.CloseEnough(p[0] * p[0] + p[1] * p[1] + p[2] * p[2], 1, .000001) {
+      t.Error("sampled distributions error 1: ", p)
+    }
+    for j := range mean {
+      mean[j] += p[j] / 256
+    }
+  }
+  if !test.VectorCloseEnough(mean[:], []float64{0, 0, 0}, .02) {
+    t.Error("sampled distributions error 2: ", mean)
+  }
+
+  var sum, sum2 float64
+  n := 1000
+  for i := 0; i < n; i ++ {
+    s.Start(0, 0, i)
+    v := NormallyDistributedVector(s, 3, 1, 2)
+    if len(v) != 3 {
+      t.Error("sampled distributions error 3: ", v)
+      return
+    }
+    for _, x := range v {
+      sum += x
+      sum2 += x * x
+    }
+  }
+  m := sum / float64(3 * n)
+  if !test.CloseEnough(m, 1, .1) || !test.CloseEnough(sum2 / float64(3 * n) - m * m, 4, .3) {
+    t.Error("sampled distributions error 4: ", m, sum2 / float64(3 * n) - m * m)
+  }
+}
+
+//A sample comes out the same no matter what came before it.
+func TestSamplerRepeatable(t *testing.T) {
+  for i, s := range []Sampler{NewRandomSampler(5), NewStratifiedSampler(4, 5), NewHaltonSampler(5), NewSobolSampler(5)} {
+    var first [2][]float64
+    for k := range first {
+      //Use up some numbers on another pixel first.
+      s.Start(k, 3, 9)
+      for j := 0; j < 50 * k; j ++ {
+        s.Float64()
+        s.Rand().Float64()
+      }
+
+      s.Start(2, 3, 1)
+      for j := 0; j < 40; j ++ {
+        first[k] = append(first[k], s.Float64())
+      }
+      first[k] = append(first[k], s.Rand().Float64())
+    }
+    for j := range first[0] {
+      if first[0][j] != first[1][j] {
+        t.Error("sampler repeatable error 1: ", i, j)
+        break
+      }
+    }
+
+    //Another sampler with a different seed gives different numbers.
+    other := []Sampler{NewRandomSampler(6), NewStratifiedSampler(4, 6), NewHaltonSampler(6), NewSobolSampler(6)}[i]
+    other.Start(2, 3, 1)
+    if other.Float64() == first[0][0] {
+      t.Error("sampler repeatable error 2: ", i)
+    }
+  }
+}
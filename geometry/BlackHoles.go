@@ -0,0 +1,186 @@
+package geometry
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//A space which contains an event horizon. Anything that falls
+//through the horizon can never come back out.
+type Horizon interface {
+  //The radial coordinate of the point x given in the coordinates of a region.
+  Radius(region int, x []float64) float64
+  //The value of the radial coordinate at the horizon.
+  HorizonRadius() float64
+}
+
+//A rotating black hole of mass m and angular momentum per unit
+//mass a. It is covered by two regions.
+//  0 - "kerr-schild" (t, x, y, z), valid everywhere except on the
+//      ring singularity and the disk inside it. It is horizon-penetrating
+//      and always preferred.
+//  1 - "boyer-lindquist" (t, r, theta, phi), valid outside the horizon
+//      and away from the axis.
+//The hole rotates about the z axis.
+//
+//Because light rays are traced backwards in time from the camera, the
+//Kerr-Schild coordinates are the outgoing ones. A ray that is traced
+//backwards into the hole crosses the horizon in a finite number of
+//steps rather than piling up on it forever.
+//
+//Implements Space and Horizon
+type blackHole struct {
+  Space
+  m, a float64
+  rp, rm float64 //The outer and inner horizons.
+}
+
+func (b *blackHole) HorizonRadius() float64 {
+  return b.rp
+}
+
+func (b *blackHole) Radius(region int, x []float64) float64 {
+  if region == 1 {
+    return x[1]
+  }
+  return b.kerrSchildRadius(x)
+}
+
+//The Boyer-Lindquist radius r of a point in Kerr-Schild coordinates,
+//which satisfies
+//
+//  r^4 - (x.x - a^2) r^2 - a^2 z^2 = 0
+func (b *blackHole) kerrSchildRadius(x []float64) float64 {
+  a2 := b.a * b.a
+  q := vector.Dot(x[1:4], x[1:4]) - a2
+  return math.Sqrt((q + math.Sqrt(q * q + 4 * a2 * x[3] * x[3])) / 2)
+}
+
+//The Kerr-Schild metric is the Minkowski metric plus f l l, where l is null.
+func (b *blackHole) kerrSchildMetric(x []float64) [][]float64 {
+  r := b.kerrSchildRadius(x)
+  r2 := r * r
+  d := r2 + b.a * b.a
+  f := 2 * b.m * r2 * r / (r2 * r2 + b.a * b.a * x[3] * x[3])
+  l := []float64{1,
+    -(r * x[1] - b.a * x[2]) / d,
+    -(r * x[2] + b.a * x[1]) / d,
+    -x[3] / r}
+
+  g := DiagonalMetricTensor(-1, 1, 1, 1)
+  for i := 0; i < 4; i ++ {
+    for j := 0; j <= i; j ++ {
+      g[i][j] += f * l[i] * l[j]
+    }
+  }
+  return g
+}
+
+func (b *blackHole) boyerLindquistMetric(x []float64) [][]float64 {
+  r := x[1]
+  a2 := b.a * b.a
+  ct, st := math.Cos(x[2]), math.Sin(x[2])
+  st2 := st * st
+  sigma := r * r + a2 * ct * ct
+  delta := r * r - 2 * b.m * r + a2
+
+  g := DiagonalMetricTensor(-(1 - 2 * b.m * r / sigma), sigma / delta, sigma,
+    (r * r + a2 + 2 * b.m * a2 * r * st2 / sigma) * st2)
+  g[3][0] = -2 * b.m * b.a * r * st2 / sigma
+  return g
+}
+
+//The functions T(r) and P(r) such that dT/dr = 2 m r / delta and
+//dP/dr = a / delta, which relate the time and azimuthal coordinates
+//of the two regions.
+func (b *blackHole) shift(r float64) (T, P float64) {
+  lp := math.Log(math.Abs(r - b.rp))
+  lm := math.Log(math.Abs(r - b.rm))
+  T = 2 * b.m * (b.rp * lp - b.rm * lm) / (b.rp - b.rm)
+  P = b.a * (lp - lm) / (b.rp - b.rm)
+  return
+}
+
+func (b *blackHole) boyerLindquistToKerrSchild(x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+  r := x[1]
+  T, P := b.shift(r)
+  ct, st := math.Cos(x[2]), math.Sin(x[2])
+  phi := x[3] - P
+  cp, sp := math.Cos(phi), math.Sin(phi)
+
+  y := []float64{x[0] - T,
+    st * (r * cp + b.a * sp),
+    st * (r * sp - b.a * cp),
+    r * ct}
+  if v == nil {return y, nil, nil}
+
+  delta := r * r - 2 * b.m * r + b.a * b.a
+  dphi := v[3] - b.a * v[1] / delta
+
+  return y, []float64{v[0] - 2 * b.m * r * v[1] / delta,
+    ct * v[2] * (r * cp + b.a * sp) + st * (v[1] * cp + (b.a * cp - r * sp) * dphi),
+    ct * v[2] * (r * sp - b.a * cp) + st * (v[1] * sp + (r * cp + b.a * sp) * dphi),
+    v[1] * ct - r * st * v[2]}, nil
+}
+
+func (b *blackHole) kerrSchildToBoyerLindquist(x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+  r := b.kerrSchildRadius(x)
+  rho2 := x[1] * x[1] + x[2] * x[2]
+  if r <= b.rp || rho2 == 0 {return nil, nil, &InvalidCoordinateError{}}
+
+  T, P := b.shift(r)
+  ct := x[3] / r
+  st := math.Sqrt(1 - ct * ct)
+  y := []float64{x[0] + T, r, math.Acos(ct), math.Atan2(x[2], x[1]) + math.Atan2(b.a, r) + P}
+  if v == nil {return y, nil, nil}
+
+  a2 := b.a * b.a
+  delta := r * r - 2 * b.m * r + a2
+  dr := r * (r * r * vector.Dot(x[1:4], v[1:4]) + a2 * x[3] * v[3]) / (r * r * r * r + a2 * x[3] * x[3])
+
+  return y, []float64{v[0] + 2 * b.m * r * dr / delta,
+    dr,
+    (ct * dr - v[3]) / (r * st),
+    (x[1] * v[2] - x[2] * v[1]) / rho2 - b.a * dr / (r * r + a2) + b.a * dr / delta}, nil
+}
+
+//A rotating black hole of mass m and angular momentum per unit mass a.
+//|a| must be less than m.
+//
+//May return nil.
+func NewKerrSpace(m, a float64) *blackHole {
+  if !(m > 0) || math.IsInf(m, 0) {return nil}
+  if !(math.Abs(a) < m) {return nil}
+
+  e := math.Sqrt(m * m - a * a)
+  b := &blackHole{nil, m, a, m + e, m - e}
+
+  kerrSchild := NewCoordinateSystem("kerr-schild",
+    func(x []float64) bool {return b.kerrSchildRadius(x) > 0},
+    b.kerrSchildMetric)
+
+  boyerLindquist := NewCoordinateSystem("boyer-lindquist",
+    func(x []float64) bool {return x[1] > b.rp && x[2] > 0 && x[2] < math.Pi},
+    b.boyerLindquistMetric)
+
+  b.Space = NewSpace([]string{"kerr-schild", "boyer-lindquist"},
+    []CoordinateSystem{kerrSchild, boyerLindquist},
+    func(from, to int, x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+      if from == 0 {
+        return b.kerrSchildToBoyerLindquist(x, v)
+      } else {
+        return b.boyerLindquistToKerrSchild(x, v)
+      }
+    },
+    func(region int, x []float64) int {return 0})
+
+  return b
+}
+
+//A black hole of mass m which does not rotate. The regions are the same
+//as those of the Kerr space. For a = 0, the Boyer-Lindquist coordinates
+//are the Schwarzschild coordinates and the Kerr-Schild coordinates are
+//outgoing Eddington-Finkelstein coordinates in cartesian form.
+//
+//May return nil.
+func NewSchwarzschildSpace(m float64) *blackHole {
+  return NewKerrSpace(m, 0)
+}
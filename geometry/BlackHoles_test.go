@@ -0,0 +1,95 @@
+package geometry
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+func TestNewKerrSpace(t *testing.T) {
+  if NewKerrSpace(0, 0) != nil {t.Error("kerr space error 1")}
+  if NewKerrSpace(1, 1) != nil {t.Error("kerr space error 2")}
+  if NewKerrSpace(1, -1.5) != nil {t.Error("kerr space error 3")}
+
+  s := NewSchwarzschildSpace(1)
+  if s == nil || s.Regions() != 2 {
+    t.Error("kerr space error 4")
+    return
+  }
+
+  if !test.CloseEnough(s.HorizonRadius(), 2, geo_err) ||
+    !test.CloseEnough(s.Radius(0, []float64{0, 3, 4, 0}), 5, geo_err) ||
+    !test.CloseEnough(s.Radius(1, []float64{0, 3, 1, 1}), 3, geo_err) {
+    t.Error("kerr space error 5")
+  }
+
+  k := NewKerrSpace(1, .6)
+  if !test.CloseEnough(k.HorizonRadius(), 1.8, geo_err) {
+    t.Error("kerr space error 6")
+  }
+
+  //Far from the hole, space is flat.
+  g := k.CoordinateSystem(0).MetricTensor([]float64{0, 1e8, 2e8, -1e8})
+  h := DiagonalMetricTensor(-1, 1, 1, 1)
+  for i := 0; i < 4; i ++ {
+    if !test.VectorCloseEnough(g[i], h[i], geo_err) {
+      t.Error("kerr space error 7: got ", g)
+    }
+  }
+}
+
+//The coordinate transformations must preserve the metric.
+func TestKerrTransform(t *testing.T) {
+  for i, s := range []*blackHole{NewSchwarzschildSpace(1), NewKerrSpace(1, .6), NewKerrSpace(2, -1.5)} {
+    for j := 0; j < 20; j ++ {
+      x := []float64{test.RandFloat(-5, 5), test.RandFloat(s.HorizonRadius() + .5, 10),
+        test.RandFloat(.2, 3), test.RandFloat(-3, 3)}
+      v := test.RandFloatVector(-1, 1, 4)
+      w := test.RandFloatVector(-1, 1, 4)
+
+      y, vy, err := s.Transform(1, 0, x, v)
+      if err != nil {
+        t.Error("kerr transform error 1, case ", i, x)
+        continue
+      }
+      _, wy, _ := s.Transform(1, 0, x, w)
+
+      if !test.CloseEnough(SymmetricProduct(s.CoordinateSystem(1).MetricTensor(x), v, w),
+        SymmetricProduct(s.CoordinateSystem(0).MetricTensor(y), vy, wy), geo_err) {
+        t.Error("kerr transform error 2, case ", i, x, v, w)
+      }
+
+      if !test.CloseEnough(s.Radius(0, y), x[1], geo_err) {
+        t.Error("kerr transform error 3, case ", i, x)
+      }
+
+      z, u, err := s.Transform(0, 1, y, vy)
+      if err != nil {
+        t.Error("kerr transform error 4, case ", i, y)
+        continue
+      }
+
+      z[3] += 2 * math.Pi * math.Floor((x[3] - z[3]) / (2 * math.Pi) + .5)
+      if !test.VectorCloseEnough(x, z, geo_err) || !test.VectorCloseEnough(v, u, geo_err) {
+        t.Error("kerr transform error 5, case ", i, "\n\tgot ", z, u, "\n\texpected ", x, v)
+      }
+    }
+  }
+}
+
+//Inside the horizon, there are no Boyer-Lindquist coordinates.
+func TestKerrHorizon(t *testing.T) {
+  s := NewKerrSpace(1, .6)
+
+  if _, _, err := s.Transform(0, 1, []float64{0, 1, .5, .3}, nil); err == nil {
+    t.Error("kerr horizon error 1")
+  }
+
+  //But the Kerr-Schild metric is fine there.
+  g := s.CoordinateSystem(0).MetricTensor([]float64{0, 1, .5, .3})
+  for i := 0; i < 4; i ++ {
+    for j := 0; j <= i; j ++ {
+      if math.IsNaN(g[i][j]) || math.IsInf(g[i][j], 0) {
+        t.Error("kerr horizon error 2: got ", g)
+      }
+    }
+  }
+}
@@ -1,8 +1,50 @@
 package geometry
 
+//A coordinate system covers some region of a space. The coordinates are
+//always given as (t, x, y, z), where t is the time coordinate.
 type CoordinateSystem interface {
   Name() string
-  Metric(p *CoordinatePoint)
+  //Whether the given coordinates are inside the domain of the coordinate system.
+  Valid(x []float64) bool
+  //The metric tensor at the given coordinates. The metric is a symmetric
+  //tensor, so only the elements g[i][j] with j <= i are given.
+  MetricTensor(x []float64) [][]float64
+}
+
+//A simple implementation of CoordinateSystem.
+type coordinateSystem struct {
+  name string
+  valid func([]float64) bool
+  metric func([]float64) [][]float64
+}
+
+func (c *coordinateSystem) Name() string {
+  return c.name
+}
+
+func (c *coordinateSystem) Valid(x []float64) bool {
+  if len(x) != 4 {return false}
+  return c.valid(x)
+}
+
+func (c *coordinateSystem) MetricTensor(x []float64) [][]float64 {
+  return c.metric(x)
+}
+
+//May return nil.
+func NewCoordinateSystem(name string, valid func([]float64) bool, metric func([]float64) [][]float64) CoordinateSystem {
+  if valid == nil || metric == nil {return nil}
+  return &coordinateSystem{name, valid, metric}
+}
+
+//A metric tensor with only diagonal elements.
+func DiagonalMetricTensor(d ...float64) [][]float64 {
+  g := make([][]float64, len(d))
+  for i := 0; i < len(d); i ++ {
+    g[i] = make([]float64, i + 1)
+    g[i][i] = d[i]
+  }
+  return g
 }
 
 type CoordinatePoint interface {
@@ -21,7 +63,6 @@ type CoordinatePoint interface {
 type coordinatePoint struct {
   space Space
   region int
-  //metric Metric
   point []float64
 }
 
@@ -41,6 +82,10 @@ func (c *coordinatePoint) CoordinateSystem() CoordinateSystem {
   return c.space.CoordinateSystem(c.region)
 }
 
+func (c *coordinatePoint) Metric() Metric {
+  return &metric{c.CoordinateSystem().MetricTensor(c.point)}
+}
+
 func (c *coordinatePoint) T() float64 {
   return c.point[0]
 }
@@ -62,8 +107,13 @@ func (c *coordinatePoint) x() []float64 {
 }
 
 func NewCoordinatePoint(space Space, region int, x []float64) (*coordinatePoint, *InvalidCoordinateError) {
-  //TODO: check that the region is good, do the coordinate transformation
-  return nil, nil
+  if space == nil || x == nil {return nil, &InvalidCoordinateError{}}
+  if region < 0 || region >= space.Regions() {return nil, &InvalidCoordinateError{}}
+  if !space.CoordinateSystem(region).Valid(x) {return nil, &InvalidCoordinateError{}}
+
+  point := make([]float64, len(x))
+  copy(point, x)
+  return &coordinatePoint{space, region, point}, nil
 }
 
 type Vector interface {
@@ -76,60 +126,66 @@ type Vector interface {
   dx() []float64
 }
 
-type vector struct {
+//A tangent vector at a point.
+type tangentVector struct {
   space Space
   region int
   metric Metric
+  point, d []float64
 }
 
-func (c *vector) Space() Space {
+func (c *tangentVector) Space() Space {
   return c.space
 }
 
-func (c *vector) Region() int {
+func (c *tangentVector) Region() int {
   return c.region
 }
 
-func (c *vector) RegionName() *string {
+func (c *tangentVector) RegionName() *string {
   return c.space.RegionName(c.region)
 }
 
-func (c *vector) Metric() Metric {
+func (c *tangentVector) Metric() Metric {
   return c.metric
 }
 
-func (v *vector) Norm() float64 {
+//This is the squared norm, which may be negative.
+func (v *tangentVector) Norm() float64 {
   return v.Metric().InnerProduct(v, v)
 }
 
-func (v *vector) Location() CoordinatePoint {
-  //TODO -- it should create a new coordinate point for its location.
-  return nil;
+func (v *tangentVector) Location() CoordinatePoint {
+  p, _ := NewCoordinatePoint(v.space, v.region, v.point)
+  return p
 }
 
-func (v *vector) Dt() float64 {
-  //TODO
-  return 0
+func (v *tangentVector) Dt() float64 {
+  return v.d[0]
 }
 
-func (v *vector) Dx() float64 {
-  //TODO
-  return 0
+func (v *tangentVector) Dx() float64 {
+  return v.d[1]
 }
 
-func (v *vector) Dy() float64 {
-  //TODO
-  return 0
+func (v *tangentVector) Dy() float64 {
+  return v.d[2]
 }
 
-func (v *vector) Dz() float64 {
-  //TODO
-  return 0
+func (v *tangentVector) Dz() float64 {
+  return v.d[3]
 }
 
-func (v *vector) dx() []float64 {
-  //TODO
-  return nil
+func (v *tangentVector) dx() []float64 {
+  return v.d
+}
+
+//May return nil.
+func NewVector(p CoordinatePoint, dt, dx, dy, dz float64) Vector {
+  if p == nil {return nil}
+  point := make([]float64, len(p.x()))
+  copy(point, p.x())
+  return &tangentVector{p.Space(), p.Region(), p.Metric(), point, []float64{dt, dx, dy, dz}}
 }
 
 type CoordinateTransformation interface {
@@ -141,3 +197,25 @@ type CoordinateTransformation interface {
 type Metric interface {
   InnerProduct(v, w Vector) float64
 }
+
+//The metric at a particular point.
+type metric struct {
+  g [][]float64
+}
+
+//Assumes that v and w are at the same location.
+func (m *metric) InnerProduct(v, w Vector) float64 {
+  return SymmetricProduct(m.g, v.dx(), w.dx())
+}
+
+//Evaluate g(v, w) for a symmetric tensor g given in the
+//lower-triangular form.
+func SymmetricProduct(g [][]float64, v, w []float64) (z float64) {
+  for i := 0; i < len(g); i ++ {
+    for j := 0; j < i; j ++ {
+      z += g[i][j] * (v[i] * w[j] + v[j] * w[i])
+    }
+    z += g[i][i] * v[i] * w[i]
+  }
+  return
+}
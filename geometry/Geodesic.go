@@ -0,0 +1,168 @@
+package geometry
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//Coordinate systems which know their own Christoffel symbols can
+//implement this interface so that they do not have to be computed
+//numerically from the metric.
+type Connection interface {
+  //Given in the same form as ChristoffelSymbols.
+  ChristoffelSymbols(x []float64) [][][]float64
+}
+
+//The relative size of the step used to differentiate the metric.
+const metricDerivativeStep = 1e-5
+
+//Expand a symmetric tensor in lower-triangular form to a full matrix.
+func expandSymmetricTensor(g [][]float64) [][]float64 {
+  m := make([][]float64, len(g))
+  for i := 0; i < len(g); i ++ {
+    m[i] = make([]float64, len(g))
+  }
+  for i := 0; i < len(g); i ++ {
+    for j := 0; j <= i; j ++ {
+      m[i][j] = g[i][j]
+      m[j][i] = g[i][j]
+    }
+  }
+  return m
+}
+
+//The Christoffel symbols of the second kind at x. The result is
+//given as gamma[i][j][k] with k <= j, since it is symmetric in the
+//last two indices.
+//
+//  gamma^i_jk = 1/2 g^il (d_j g_lk + d_k g_lj - d_l g_jk)
+//
+//The derivatives of the metric are computed numerically unless the
+//coordinate system implements Connection.
+//May return nil if the metric is degenerate.
+func ChristoffelSymbols(c CoordinateSystem, x []float64) [][][]float64 {
+  if con, ok := c.(Connection); ok {
+    return con.ChristoffelSymbols(x)
+  }
+
+  n := len(x)
+  inv := vector.Inverse(expandSymmetricTensor(c.MetricTensor(x)))
+  if inv == nil {return nil}
+
+  //The derivatives of the metric, dg[m][i][j] = d_m g_ij.
+  dg := make([][][]float64, n)
+  y := make([]float64, n)
+  copy(y, x)
+  for m := 0; m < n; m ++ {
+    h := metricDerivativeStep * math.Max(1, math.Abs(x[m]))
+    y[m] = x[m] + h
+    gp := expandSymmetricTensor(c.MetricTensor(y))
+    y[m] = x[m] - h
+    gm := expandSymmetricTensor(c.MetricTensor(y))
+    y[m] = x[m]
+
+    dg[m] = make([][]float64, n)
+    for i := 0; i < n; i ++ {
+      dg[m][i] = make([]float64, n)
+      for j := 0; j < n; j ++ {
+        dg[m][i][j] = (gp[i][j] - gm[i][j]) / (2 * h)
+      }
+    }
+  }
+
+  gamma := make([][][]float64, n)
+  for i := 0; i < n; i ++ {
+    gamma[i] = make([][]float64, n)
+    for j := 0; j < n; j ++ {
+      gamma[i][j] = make([]float64, j + 1)
+      for k := 0; k <= j; k ++ {
+        for l := 0; l < n; l ++ {
+          gamma[i][j][k] += inv[i][l] * (dg[j][l][k] + dg[k][l][j] - dg[l][j][k]) / 2
+        }
+      }
+    }
+  }
+
+  return gamma
+}
+
+//The geodesic equation in a space. The state is the position
+//followed by the velocity, (x, u), so it has 8 elements.
+//
+//  dx/ds = u
+//  du/ds = - gamma(x)(u, u)
+//
+//Implements diffeq.RegionalDerivative
+type geodesic struct {
+  space Space
+  region int
+}
+
+func (g *geodesic) Dimension() int {
+  return 8
+}
+
+func (g *geodesic) Region() int {
+  return g.region
+}
+
+func (g *geodesic) SetRegion(region int) {
+  g.region = region
+}
+
+func (g *geodesic) DxDs(x []float64, v []float64) {
+  gamma := ChristoffelSymbols(g.space.CoordinateSystem(g.region), x[:4])
+  u := x[4:]
+
+  for i := 0; i < 4; i ++ {
+    v[i] = u[i]
+    if gamma == nil {
+      v[4 + i] = 0
+    } else {
+      v[4 + i] = -SymmetricProduct(gamma[i], u, u)
+    }
+  }
+}
+
+func (g *geodesic) ChangeRegion(region int, x []float64) int {
+  to := g.space.Prefer(region, x[:4])
+  if to == region {return region}
+
+  y, w, err := g.space.Transform(region, to, x[:4], x[4:])
+  if err != nil {return region}
+
+  copy(x[:4], y)
+  copy(x[4:], w)
+  return to
+}
+
+//May return nil.
+func NewGeodesicEquation(space Space, region int) *geodesic {
+  if space == nil {return nil}
+  if region < 0 || region >= space.Regions() {return nil}
+
+  return &geodesic{space, region}
+}
+
+//Given a point x and a spatial direction d, find the time component of
+//the velocity so that the vector (u0, d) is null. The solution is the
+//one that points backwards in time, which is the one that is needed
+//when a light ray is traced backwards from a camera. If there is no null
+//vector with the given spatial part, u0 is zero. For a static space,
+//that is a geodesic of the spatial part of the metric.
+func NullVelocity(c CoordinateSystem, x, d []float64) []float64 {
+  g := c.MetricTensor(x)
+  u := []float64{0, d[0], d[1], d[2]}
+
+  g00 := g[0][0]
+  var b float64
+  for i := 1; i < 4; i ++ {
+    b += g[i][0] * u[i]
+  }
+  cc := SymmetricProduct(g, u, u)
+
+  disc := b * b - g00 * cc
+  if g00 != 0 && disc >= 0 {
+    u[0] = (-b + math.Sqrt(disc)) / g00
+  }
+
+  return u
+}
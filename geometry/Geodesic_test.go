@@ -0,0 +1,66 @@
+package geometry
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+//The Christoffel symbols of flat space in spherical coordinates.
+func TestChristoffelSymbols(t *testing.T) {
+  s := NewMinkowskiSpace()
+  r, theta := 1.7, .6
+  st, ct := math.Sin(theta), math.Cos(theta)
+
+  gamma := ChristoffelSymbols(s.CoordinateSystem(1), []float64{0, r, theta, .2})
+
+  expected := map[[3]int]float64{
+    [3]int{1, 2, 2}: -r,
+    [3]int{1, 3, 3}: -r * st * st,
+    [3]int{2, 2, 1}: 1 / r,
+    [3]int{2, 3, 3}: -st * ct,
+    [3]int{3, 3, 1}: 1 / r,
+    [3]int{3, 3, 2}: ct / st}
+
+  for i := 0; i < 4; i ++ {
+    for j := 0; j < 4; j ++ {
+      for k := 0; k <= j; k ++ {
+        if !test.CloseEnough(gamma[i][j][k], expected[[3]int{i, j, k}], geo_err * 100) {
+          t.Error("christoffel symbol error ", i, j, k, ": got ", gamma[i][j][k],
+            " expected ", expected[[3]int{i, j, k}])
+        }
+      }
+    }
+  }
+}
+
+func TestGeodesicEquation(t *testing.T) {
+  if NewGeodesicEquation(nil, 0) != nil {t.Error("geodesic error 1")}
+  if NewGeodesicEquation(NewMinkowskiSpace(), 2) != nil {t.Error("geodesic error 2")}
+
+  g := NewGeodesicEquation(NewMinkowskiSpace(), 0)
+  x := []float64{0, 1, 2, 3, -1, .6, 0, .8}
+  v := make([]float64, 8)
+  g.DxDs(x, v)
+
+  if !test.VectorCloseEnough(v, []float64{-1, .6, 0, .8, 0, 0, 0, 0}, geo_err) {
+    t.Error("geodesic error 3: got ", v)
+  }
+
+  //Moving into the south region of a sphere.
+  g = NewGeodesicEquation(NewSphericalSpace(1), 0)
+  x = []float64{0, 2, 0, 0, 0, 1, 0, 0}
+  if g.ChangeRegion(0, x) != 1 || !test.VectorCloseEnough(x, []float64{0, .5, 0, 0, 0, -.25, 0, 0}, geo_err) {
+    t.Error("geodesic error 4: got ", x)
+  }
+}
+
+func TestNullVelocity(t *testing.T) {
+  for _, s := range []Space{NewMinkowskiSpace(), NewSphericalSpace(2), NewHyperbolicSpace(2)} {
+    c := s.CoordinateSystem(0)
+    x := []float64{0, .3, -.2, .5}
+    u := NullVelocity(c, x, []float64{.4, .1, -.7})
+
+    if u[0] >= 0 || !test.CloseEnough(SymmetricProduct(c.MetricTensor(x), u, u), 0, geo_err) {
+      t.Error("null velocity error: got ", u)
+    }
+  }
+}
@@ -1,7 +1,9 @@
 package geometry
 
-//There may be regions of space in which a particular coordinate system is invalid. 
-//This error is returned when that happens. 
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//There may be regions of space in which a particular coordinate system is invalid.
+//This error is returned when that happens.
 type InvalidCoordinateError struct {
 }
 
@@ -9,14 +11,17 @@ func (e *InvalidCoordinateError) Error() string {
   return "Invalid coordinates."
 }
 
-//A Space is a pseudo-Riemannian manifold. 
+//A Space is a pseudo-Riemannian manifold.
 //Because different regions of the space may be most conveniently
 //metrized with different coordinate systems, the Space struct is
 //mainly concerned with managing a set of coordinate systems.
-// 
+//
 //A space is also a Derivative for a particle that parallel
-//transports itself along its surface. 
+//transports itself along its surface.
 type Space interface {
+  //The number of regions.
+  Regions() int
+
   //The space is divided into one or more regions, which may overlap.
   //Each is given a name.
   RegionName(c int) *string
@@ -26,17 +31,161 @@ type Space interface {
 
   //Transform a coordinate point which is represented in the coordinates
   //of one region to that of another. This is only allowed when the two
-  //regions overlap at the given point. 
+  //regions overlap at the given point.
   TransformCoordinates(x CoordinatePoint, to int) (*InvalidCoordinateError)
 
   //At the CoordinatePoint x, which is the region that the space prefers to use?
   PreferredRegion(x CoordinatePoint) int
 
-  //Transforms coordinate point to the preferred region for its location. 
-  //No effect if already in the preferred region. 
+  //Transforms coordinate point to the preferred region for its location.
+  //No effect if already in the preferred region.
   TransformCoordinatesToPreferredRegion(x CoordinatePoint)
 
   //Creates a CoordinatePoint struct at a given location. Only allowed
-  //when the given coordinates are valid in the given region. 
-  CoordinatePoint(space *Space, t, x, y, z float64, region int) (CoordinatePoint, *InvalidCoordinateError) 
+  //when the given coordinates are valid in the given region.
+  CoordinatePoint(t, x, y, z float64, region int) (CoordinatePoint, *InvalidCoordinateError)
+
+  //The same as TransformCoordinates, but for raw coordinates x and
+  //a tangent vector v at x. v may be nil.
+  Transform(from, to int, x, v []float64) ([]float64, []float64, *InvalidCoordinateError)
+
+  //The same as PreferredRegion, but for raw coordinates.
+  Prefer(region int, x []float64) int
+}
+
+//Transforms the coordinates x and a tangent vector v at x from the
+//coordinates of one region to another. v may be nil. It can be assumed
+//that from and to are different.
+type Transition func(from, to int, x, v []float64) ([]float64, []float64, *InvalidCoordinateError)
+
+//Given coordinates x in some region, which region should be used at x.
+type Preference func(region int, x []float64) int
+
+//A space given by a list of coordinate systems and the transition
+//functions between them.
+type space struct {
+  names []string
+  systems []CoordinateSystem
+  transition Transition
+  preference Preference
+}
+
+func (s *space) Regions() int {
+  return len(s.systems)
+}
+
+func (s *space) RegionName(c int) *string {
+  if c < 0 || c >= len(s.names) {return nil}
+  return &s.names[c]
+}
+
+func (s *space) CoordinateSystem(c int) CoordinateSystem {
+  if c < 0 || c >= len(s.systems) {return nil}
+  return s.systems[c]
+}
+
+func (s *space) Transform(from, to int, x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+  if from < 0 || from >= len(s.systems) || to < 0 || to >= len(s.systems) {
+    return nil, nil, &InvalidCoordinateError{}
+  }
+
+  if !s.systems[from].Valid(x) {
+    return nil, nil, &InvalidCoordinateError{}
+  }
+
+  if from == to {
+    y := make([]float64, len(x))
+    copy(y, x)
+    if v == nil {return y, nil, nil}
+    w := make([]float64, len(v))
+    copy(w, v)
+    return y, w, nil
+  }
+
+  y, w, err := s.transition(from, to, x, v)
+  if err != nil {return nil, nil, err}
+  if !s.systems[to].Valid(y) {
+    return nil, nil, &InvalidCoordinateError{}
+  }
+
+  return y, w, nil
+}
+
+func (s *space) Prefer(region int, x []float64) int {
+  if s.preference == nil {return region}
+  return s.preference(region, x)
+}
+
+func (s *space) TransformCoordinates(x CoordinatePoint, to int) (*InvalidCoordinateError) {
+  p, ok := x.(*coordinatePoint)
+  if !ok || p == nil {return &InvalidCoordinateError{}}
+
+  y, _, err := s.Transform(p.region, to, p.point, nil)
+  if err != nil {return err}
+
+  p.region = to
+  p.point = y
+  return nil
+}
+
+func (s *space) PreferredRegion(x CoordinatePoint) int {
+  return s.Prefer(x.Region(), x.x())
+}
+
+func (s *space) TransformCoordinatesToPreferredRegion(x CoordinatePoint) {
+  s.TransformCoordinates(x, s.PreferredRegion(x))
+}
+
+func (s *space) CoordinatePoint(t, x, y, z float64, region int) (CoordinatePoint, *InvalidCoordinateError) {
+  p, err := NewCoordinatePoint(s, region, []float64{t, x, y, z})
+  if err != nil {return nil, err}
+  return p, nil
+}
+
+//names - the names of the regions.
+//systems - the coordinate systems of the regions.
+//transition - the coordinate transformations between regions. May be nil
+//  if there is only one region.
+//preference - the preferred region to use at a given point. May be nil.
+//
+//May return nil.
+func NewSpace(names []string, systems []CoordinateSystem,
+  transition Transition, preference Preference) Space {
+  if names == nil || systems == nil {return nil}
+  if len(names) != len(systems) || len(systems) == 0 {return nil}
+  if transition == nil && len(systems) > 1 {return nil}
+
+  for _, c := range systems {
+    if c == nil {return nil}
+  }
+
+  return &space{names, systems, transition, preference}
+}
+
+//An inversion of the spatial coordinates through a sphere centered at c.
+//
+//  x -> c + k (x - c) / |x - c|^2
+//
+//The time coordinate is unchanged.
+func inversion(c []float64, k float64, x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+  d := vector.Minus(x[1:], c)
+  d2 := vector.Dot(d, d)
+  if d2 == 0 {return nil, nil, &InvalidCoordinateError{}}
+
+  y := make([]float64, 4)
+  y[0] = x[0]
+  for i := 0; i < 3; i ++ {
+    y[i + 1] = c[i] + k * d[i] / d2
+  }
+
+  if v == nil {return y, nil, nil}
+
+  w := make([]float64, 4)
+  w[0] = v[0]
+  dv := vector.Dot(d, v[1:])
+  for i := 0; i < 3; i ++ {
+    w[i + 1] = k * (v[i + 1] - 2 * d[i] * dv / d2) / d2
+  }
+
+  return y, w, nil
 }
@@ -0,0 +1,134 @@
+package geometry
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+var geo_err float64 = .000001
+
+func TestNewSpace(t *testing.T) {
+  cs := NewCoordinateSystem("flat",
+    func(x []float64) bool {return true},
+    func(x []float64) [][]float64 {return DiagonalMetricTensor(-1, 1, 1, 1)})
+
+  if NewCoordinateSystem("flat", nil, nil) != nil {t.Error("space error 1")}
+  if NewSpace(nil, []CoordinateSystem{cs}, nil, nil) != nil {t.Error("space error 2")}
+  if NewSpace([]string{"a"}, nil, nil, nil) != nil {t.Error("space error 3")}
+  if NewSpace([]string{"a", "b"}, []CoordinateSystem{cs}, nil, nil) != nil {t.Error("space error 4")}
+  if NewSpace([]string{"a", "b"}, []CoordinateSystem{cs, cs}, nil, nil) != nil {t.Error("space error 5")}
+  if NewSpace([]string{"a"}, []CoordinateSystem{nil}, nil, nil) != nil {t.Error("space error 6")}
+
+  s := NewSpace([]string{"a"}, []CoordinateSystem{cs}, nil, nil)
+  if s == nil {
+    t.Error("space error 7")
+    return
+  }
+
+  if s.Regions() != 1 || *s.RegionName(0) != "a" || s.RegionName(1) != nil {
+    t.Error("space error 8")
+  }
+
+  if NewSphericalSpace(0) != nil || NewSphericalSpace(-1) != nil {t.Error("space error 9")}
+  if NewHyperbolicSpace(0) != nil || NewHyperbolicSpace(math.NaN()) != nil {t.Error("space error 10")}
+}
+
+func TestCoordinatePoint(t *testing.T) {
+  s := NewHyperbolicSpace(2)
+
+  if _, err := s.CoordinatePoint(0, 0, 0, 3, 0); err == nil {
+    t.Error("coordinate point error 1")
+  }
+  if _, err := s.CoordinatePoint(0, 0, 0, -1, 1); err == nil {
+    t.Error("coordinate point error 2")
+  }
+  if _, err := s.CoordinatePoint(0, 0, 0, 1, 2); err == nil {
+    t.Error("coordinate point error 3")
+  }
+
+  p, err := s.CoordinatePoint(1, .2, .3, .4, 0)
+  if err != nil {
+    t.Error("coordinate point error 4")
+    return
+  }
+
+  if p.T() != 1 || p.X() != .2 || p.Y() != .3 || p.Z() != .4 || *p.RegionName() != "ball" {
+    t.Error("coordinate point error 5")
+  }
+
+  if s.TransformCoordinates(p, 1) != nil || p.Region() != 1 {
+    t.Error("coordinate point error 6")
+  }
+
+  s.TransformCoordinatesToPreferredRegion(p)
+  if p.Region() != 0 || !test.VectorCloseEnough(p.x(), []float64{1, .2, .3, .4}, geo_err) {
+    t.Error("coordinate point error 7: got ", p.x())
+  }
+}
+
+func TestInnerProduct(t *testing.T) {
+  s := NewMinkowskiSpace()
+  p, _ := s.CoordinatePoint(0, 1, 2, 3, 0)
+
+  v := NewVector(p, 1, .6, 0, .8)
+  w := NewVector(p, 2, 1, 1, 1)
+
+  if !test.CloseEnough(v.Norm(), 0, geo_err) {
+    t.Error("inner product error 1: got ", v.Norm())
+  }
+  if !test.CloseEnough(p.Metric().InnerProduct(v, w), -.6, geo_err) {
+    t.Error("inner product error 2: got ", p.Metric().InnerProduct(v, w))
+  }
+  if !test.VectorCloseEnough(v.Location().x(), []float64{0, 1, 2, 3}, geo_err) {
+    t.Error("inner product error 3")
+  }
+}
+
+//Transforming a point and a vector to another region and back again
+//should do nothing, and the length of the vector should not change.
+func TestTransform(t *testing.T) {
+  spaces := []Space{NewMinkowskiSpace(), NewSphericalSpace(1.5), NewHyperbolicSpace(1.5)}
+  names := []string{"flat", "spherical", "hyperbolic"}
+
+  for i, s := range spaces {
+    for j := 0; j < 20; j ++ {
+      x := test.RandFloatVector(-.8, .8, 4)
+      v := test.RandFloatVector(-1, 1, 4)
+
+      y, w, err := s.Transform(0, 1, x, v)
+      if err != nil {
+        t.Error("transform error 1 ", names[i], x)
+        continue
+      }
+
+      if !test.CloseEnough(SymmetricProduct(s.CoordinateSystem(0).MetricTensor(x), v, v),
+        SymmetricProduct(s.CoordinateSystem(1).MetricTensor(y), w, w), geo_err) {
+        t.Error("transform error 2 ", names[i], x, v)
+      }
+
+      z, u, err := s.Transform(1, 0, y, w)
+      if err != nil {
+        t.Error("transform error 3 ", names[i], y)
+        continue
+      }
+
+      if !test.VectorCloseEnough(x, z, geo_err) || !test.VectorCloseEnough(v, u, geo_err) {
+        t.Error("transform error 4 ", names[i], "\n\tgot ", z, u, "\n\texpected ", x, v)
+      }
+    }
+  }
+}
+
+func TestPrefer(t *testing.T) {
+  s := NewSphericalSpace(1)
+
+  if s.Prefer(0, []float64{0, .5, 0, 0}) != 0 || s.Prefer(1, []float64{0, .5, 0, 0}) != 1 {
+    t.Error("prefer error 1")
+  }
+  if s.Prefer(0, []float64{0, 2, 0, 0}) != 1 || s.Prefer(1, []float64{0, 2, 0, 0}) != 0 {
+    t.Error("prefer error 2")
+  }
+
+  if _, _, err := s.Transform(0, 1, []float64{0, 0, 0, 0}, nil); err == nil {
+    t.Error("prefer error 3")
+  }
+}
@@ -0,0 +1,148 @@
+package geometry
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//Some spaces of constant curvature. They are all static, which means
+//that the time coordinate only appears in the metric as -dt^2 and
+//light rays follow the geodesics of the spatial part of the metric.
+
+//Flat space is covered by two regions.
+//  0 - "cartesian" (t, x, y, z), valid everywhere.
+//  1 - "spherical" (t, r, theta, phi), valid away from the z axis.
+//Cartesian coordinates are always preferred.
+func newFlatSpace(g00 float64) Space {
+  cartesian := NewCoordinateSystem("cartesian",
+    func(x []float64) bool {return true},
+    func(x []float64) [][]float64 {
+      return DiagonalMetricTensor(g00, 1, 1, 1)
+    })
+
+  spherical := NewCoordinateSystem("spherical",
+    func(x []float64) bool {return x[1] > 0 && x[2] > 0 && x[2] < math.Pi},
+    func(x []float64) [][]float64 {
+      r2 := x[1] * x[1]
+      s := math.Sin(x[2])
+      return DiagonalMetricTensor(g00, 1, r2, r2 * s * s)
+    })
+
+  return NewSpace([]string{"cartesian", "spherical"},
+    []CoordinateSystem{cartesian, spherical},
+    func(from, to int, x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+      if from == 0 {
+        return cartesianToSpherical(x, v)
+      } else {
+        return sphericalToCartesian(x, v)
+      }
+    },
+    func(region int, x []float64) int {return 0})
+}
+
+//Flat space with the Minkowski metric -dt^2 + dx^2 + dy^2 + dz^2.
+func NewMinkowskiSpace() Space {
+  return newFlatSpace(-1)
+}
+
+//Flat space with the Euclidean metric dt^2 + dx^2 + dy^2 + dz^2.
+func NewEuclideanSpace() Space {
+  return newFlatSpace(1)
+}
+
+func cartesianToSpherical(x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+  rho2 := x[1] * x[1] + x[2] * x[2]
+  if rho2 == 0 {return nil, nil, &InvalidCoordinateError{}}
+  rho := math.Sqrt(rho2)
+  r := math.Sqrt(rho2 + x[3] * x[3])
+
+  y := []float64{x[0], r, math.Acos(x[3] / r), math.Atan2(x[2], x[1])}
+  if v == nil {return y, nil, nil}
+
+  dr := vector.Dot(x[1:], v[1:]) / r
+  return y, []float64{v[0], dr,
+    (x[3] * dr - r * v[3]) / (r * rho),
+    (x[1] * v[2] - x[2] * v[1]) / rho2}, nil
+}
+
+func sphericalToCartesian(x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+  r := x[1]
+  ct, st := math.Cos(x[2]), math.Sin(x[2])
+  cp, sp := math.Cos(x[3]), math.Sin(x[3])
+
+  y := []float64{x[0], r * st * cp, r * st * sp, r * ct}
+  if v == nil {return y, nil, nil}
+
+  return y, []float64{v[0],
+    v[1] * st * cp + r * ct * cp * v[2] - r * st * sp * v[3],
+    v[1] * st * sp + r * ct * sp * v[2] + r * st * cp * v[3],
+    v[1] * ct - r * st * v[2]}, nil
+}
+
+//The sphere of radius a is covered by two stereographic projections.
+//  0 - "north", projected from the north pole.
+//  1 - "south", projected from the south pole.
+//In both, the spatial metric is 4 a^4 / (a^2 + x.x)^2 times the flat metric,
+//and they are related by an inversion in the sphere of radius a. Each
+//region is preferred on its own side of the equator.
+//
+//May return nil.
+func NewSphericalSpace(a float64) Space {
+  if !(a > 0) || math.IsInf(a, 0) {return nil}
+  a2 := a * a
+
+  metric := func(x []float64) [][]float64 {
+    d := a2 + vector.Dot(x[1:], x[1:])
+    s := 4 * a2 * a2 / (d * d)
+    return DiagonalMetricTensor(-1, s, s, s)
+  }
+  valid := func(x []float64) bool {return true}
+
+  return NewSpace([]string{"north", "south"},
+    []CoordinateSystem{
+      NewCoordinateSystem("north", valid, metric),
+      NewCoordinateSystem("south", valid, metric)},
+    func(from, to int, x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+      return inversion([]float64{0, 0, 0}, a2, x, v)
+    },
+    func(region int, x []float64) int {
+      if vector.Dot(x[1:], x[1:]) > a2 {
+        return 1 - region
+      }
+      return region
+    })
+}
+
+//Hyperbolic space with curvature radius a is covered by two regions.
+//  0 - "ball", the Poincare ball of radius a. The spatial metric
+//      is 4 a^4 / (a^2 - x.x)^2 times the flat metric.
+//  1 - "half-space", the upper half-space z > 0. The spatial
+//      metric is a^2 / z^2 times the flat metric.
+//The ball covers the whole space and is always preferred.
+//
+//May return nil.
+func NewHyperbolicSpace(a float64) Space {
+  if !(a > 0) || math.IsInf(a, 0) {return nil}
+  a2 := a * a
+
+  ball := NewCoordinateSystem("ball",
+    func(x []float64) bool {return vector.Dot(x[1:], x[1:]) < a2},
+    func(x []float64) [][]float64 {
+      d := a2 - vector.Dot(x[1:], x[1:])
+      s := 4 * a2 * a2 / (d * d)
+      return DiagonalMetricTensor(-1, s, s, s)
+    })
+
+  halfSpace := NewCoordinateSystem("half-space",
+    func(x []float64) bool {return x[3] > 0},
+    func(x []float64) [][]float64 {
+      s := a2 / (x[3] * x[3])
+      return DiagonalMetricTensor(-1, s, s, s)
+    })
+
+  return NewSpace([]string{"ball", "half-space"},
+    []CoordinateSystem{ball, halfSpace},
+    //This inversion is its own inverse.
+    func(from, to int, x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+      return inversion([]float64{0, 0, -a}, 2 * a2, x, v)
+    },
+    func(region int, x []float64) int {return 0})
+}
@@ -0,0 +1,96 @@
+package geometry
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//The Ellis wormhole with a throat of radius b has the metric
+//
+//  -dt^2 + dl^2 + (l^2 + b^2) (dtheta^2 + sin^2 theta dphi^2)
+//
+//where l goes from -infinity to infinity. There are two asymptotically
+//flat regions, one where l is large and one where it is very negative,
+//and they are connected through the throat at l = 0. It is covered by
+//three regions.
+//  0 - "upper" (t, x, y, z), isotropic coordinates on the side where l > 0.
+//  1 - "lower" (t, x, y, z), isotropic coordinates on the side where l < 0.
+//  2 - "proper" (t, l, theta, phi), valid everywhere away from the z axis.
+//In the isotropic coordinates, the spatial metric is (1 + b^2 / 4 x.x)^2
+//times the flat metric, and the throat is the sphere of radius b / 2. The
+//two are related by an inversion through the throat, so a light ray that
+//goes into the throat on one side comes out on the other. Each is preferred
+//on its own side of the throat.
+//
+//May return nil.
+func NewEllisWormhole(b float64) Space {
+  if !(b > 0) || math.IsInf(b, 0) {return nil}
+  b2 := b * b
+  k := b2 / 4
+
+  isotropic := func(x []float64) [][]float64 {
+    s := 1 + k / vector.Dot(x[1:], x[1:])
+    return DiagonalMetricTensor(-1, s * s, s * s, s * s)
+  }
+  valid := func(x []float64) bool {return vector.Dot(x[1:], x[1:]) > 0}
+
+  proper := NewCoordinateSystem("proper",
+    func(x []float64) bool {return x[2] > 0 && x[2] < math.Pi},
+    func(x []float64) [][]float64 {
+      r2 := x[1] * x[1] + b2
+      s := math.Sin(x[2])
+      return DiagonalMetricTensor(-1, 1, r2, r2 * s * s)
+    })
+
+  //From isotropic coordinates on one side to proper coordinates.
+  //The sign is 1 on the upper side and -1 on the lower side.
+  toProper := func(sign float64, x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+    y, w, err := cartesianToSpherical(x, v)
+    if err != nil {return nil, nil, err}
+
+    r := y[1]
+    y[1] = sign * (r - k / r)
+    if w != nil {
+      w[1] *= sign * (1 + k / (r * r))
+    }
+    return y, w, nil
+  }
+
+  fromProper := func(sign float64, x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+    l := sign * x[1]
+    q := math.Sqrt(l * l + b2)
+    r := (l + q) / 2
+
+    y := []float64{x[0], r, x[2], x[3]}
+    var w []float64
+    if v != nil {
+      w = []float64{v[0], sign * v[1] * r / q, v[2], v[3]}
+    }
+    return sphericalToCartesian(y, w)
+  }
+
+  side := []float64{1, -1}
+
+  return NewSpace([]string{"upper", "lower", "proper"},
+    []CoordinateSystem{
+      NewCoordinateSystem("upper", valid, isotropic),
+      NewCoordinateSystem("lower", valid, isotropic),
+      proper},
+    func(from, to int, x, v []float64) ([]float64, []float64, *InvalidCoordinateError) {
+      if to == 2 {
+        return toProper(side[from], x, v)
+      }
+      if from == 2 {
+        return fromProper(side[to], x, v)
+      }
+      return inversion([]float64{0, 0, 0}, k, x, v)
+    },
+    func(region int, x []float64) int {
+      if region == 2 {
+        if x[1] < 0 {return 1}
+        return 0
+      }
+      if vector.Dot(x[1:], x[1:]) < k {
+        return 1 - region
+      }
+      return region
+    })
+}
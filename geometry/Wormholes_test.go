@@ -0,0 +1,70 @@
+package geometry
+
+import "testing"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+func TestEllisWormhole(t *testing.T) {
+  if NewEllisWormhole(0) != nil {t.Error("wormhole error 1")}
+
+  s := NewEllisWormhole(2)
+  if s == nil || s.Regions() != 3 {
+    t.Error("wormhole error 2")
+    return
+  }
+
+  //Going through the throat.
+  if s.Prefer(0, []float64{0, 0, 0, 1.5}) != 0 || s.Prefer(0, []float64{0, 0, 0, .5}) != 1 ||
+    s.Prefer(1, []float64{0, .5, 0, 0}) != 0 || s.Prefer(2, []float64{0, -1, 1, 1}) != 1 {
+    t.Error("wormhole error 3")
+  }
+
+  y, _, err := s.Transform(0, 2, []float64{0, 1, 0, 0}, nil)
+  if err != nil || !test.CloseEnough(y[1], 0, geo_err) {
+    t.Error("wormhole error 4")
+  }
+
+  y, _, err = s.Transform(0, 1, []float64{0, 0, 0, 2}, nil)
+  if err != nil || !test.VectorCloseEnough(y, []float64{0, 0, 0, .5}, geo_err) {
+    t.Error("wormhole error 5")
+  }
+}
+
+//The coordinate transformations must preserve the metric.
+func TestEllisWormholeTransform(t *testing.T) {
+  s := NewEllisWormhole(2)
+
+  for from := 0; from < 3; from ++ {
+    for to := 0; to < 3; to ++ {
+      for j := 0; j < 10; j ++ {
+        var x []float64
+        if from == 2 {
+          x = []float64{test.RandFloat(-5, 5), test.RandFloat(-5, 5), test.RandFloat(.2, 3), test.RandFloat(-3, 3)}
+        } else {
+          x = test.RandFloatVector(-3, 3, 4)
+        }
+        v := test.RandFloatVector(-1, 1, 4)
+
+        y, w, err := s.Transform(from, to, x, v)
+        if err != nil {
+          t.Error("wormhole transform error 1 ", from, to, x)
+          continue
+        }
+
+        if !test.CloseEnough(SymmetricProduct(s.CoordinateSystem(from).MetricTensor(x), v, v),
+          SymmetricProduct(s.CoordinateSystem(to).MetricTensor(y), w, w), geo_err) {
+          t.Error("wormhole transform error 2 ", from, to, x, v)
+        }
+
+        z, u, err := s.Transform(to, from, y, w)
+        if err != nil {
+          t.Error("wormhole transform error 3 ", from, to, y)
+          continue
+        }
+
+        if !test.VectorCloseEnough(x, z, geo_err) || !test.VectorCloseEnough(v, u, geo_err) {
+          t.Error("wormhole transform error 4 ", from, to, "\n\tgot ", z, u, "\n\texpected ", x, v)
+        }
+      }
+    }
+  }
+}
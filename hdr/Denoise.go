@@ -0,0 +1,111 @@
+package hdr
+
+import "math"
+
+//A picture made with few samples is grainy. The denoiser blurs it, but
+//not across edges, which are found in guides: pictures of things about
+//each pixel other than its color which are not grainy, such as which
+//way the surface in it faces. It is the edge-avoiding a-trous wavelet
+//transform of Dammertz et al., Edge-Avoiding A-Trous Wavelet Transform
+//for fast Global Illumination Filtering, 2010. Each level blurs with a
+//5x5 kernel whose taps are twice as far apart as the level before, so
+//a few levels cover a wide area quickly.
+
+//A picture which guides the denoiser. Two pixels are blended less the
+//more they differ in it, relative to Sigma.
+type Guide struct {
+  Image *Image
+  Sigma float64
+}
+
+//The weights of the taps of the kernel, which are a B3 spline.
+var atrousKernel [5]float64 = [5]float64{1. / 16., 1. / 4., 3. / 8., 1. / 4., 1. / 16.}
+
+//Blur img over the given number of levels without blurring across the
+//edges in the guides or in img itself. Colors are compared after they
+//are compressed to between zero and one, so that sigma does not depend
+//on how bright the picture is, and sigma is halved at each level, since
+//the noise left is less. Guides whose sigma is not positive are left out.
+//
+//May return nil, if sigma is not positive or a guide
+//is not the size of img.
+func Denoise(img *Image, guides []Guide, sigma float64, levels int) *Image {
+  if !(sigma > 0) { return nil }
+
+  var used []Guide
+  for _, g := range guides {
+    if g.Image == nil || g.Image.Width != img.Width || g.Image.Height != img.Height { return nil }
+    if g.Sigma > 0 {
+      used = append(used, g)
+    }
+  }
+
+  out := &Image{img.Width, img.Height, append([]float64(nil), img.Pix...)}
+  for l := 0; l < levels; l ++ {
+    out = atrous(out, used, sigma / math.Pow(2, float64(l)), 1 << uint(l))
+  }
+  return out
+}
+
+//One level of the transform, with the taps step pixels apart.
+func atrous(img *Image, guides []Guide, sigma float64, step int) *Image {
+  out := NewImage(img.Width, img.Height)
+  if out == nil { return img }
+  compressed := compress(img)
+
+  for y := 0; y < img.Height; y ++ {
+    for x := 0; x < img.Width; x ++ {
+      var sum [3]float64
+      var total float64
+
+      for j := -2; j <= 2; j ++ {
+        v := y + j * step
+        if v < 0 || v >= img.Height { continue }
+
+        for i := -2; i <= 2; i ++ {
+          u := x + i * step
+          if u < 0 || u >= img.Width { continue }
+
+          e := distance(compressed.At(x, y), compressed.At(u, v)) / (sigma * sigma)
+          for _, g := range guides {
+            e += distance(g.Image.At(x, y), g.Image.At(u, v)) / (g.Sigma * g.Sigma)
+          }
+
+          w := atrousKernel[i + 2] * atrousKernel[j + 2] * math.Exp(-e)
+          q := img.At(u, v)
+          for k := 0; k < 3; k ++ {
+            sum[k] += w * q[k]
+          }
+          total += w
+        }
+      }
+
+      //The weight of the pixel itself is never zero.
+      for k := 0; k < 3; k ++ {
+        out.Pix[3 * (y * img.Width + x) + k] = sum[k] / total
+      }
+    }
+  }
+
+  return out
+}
+
+//The picture with each value x made into x / (1 + x),
+//and negative values made zero.
+func compress(img *Image) *Image {
+  out := &Image{img.Width, img.Height, make([]float64, len(img.Pix))}
+  for i, x := range img.Pix {
+    x = math.Max(x, 0)
+    out.Pix[i] = x / (1 + x)
+  }
+  return out
+}
+
+//The square of the distance between two colors.
+func distance(a, b []float64) float64 {
+  var d float64
+  for k := 0; k < 3; k ++ {
+    d += (a[k] - b[k]) * (a[k] - b[k])
+  }
+  return d
+}
@@ -0,0 +1,59 @@
+package hdr
+
+import "testing"
+import "math/rand"
+
+func TestDenoise(t *testing.T) {
+  //A gray picture with noise, whose left half is lit and right half is
+  //dark, and a guide which shows where the halves meet.
+  r := rand.New(rand.NewSource(1))
+  img := NewImage(32, 16)
+  guide := NewImage(32, 16)
+  for y := 0; y < 16; y ++ {
+    for x := 0; x < 32; x ++ {
+      c := .1
+      if x < 16 {
+        c = .6
+        guide.Set(x, y, []float64{1, 1, 1})
+      }
+      n := c * 2 * r.Float64()
+      img.Set(x, y, []float64{n, n, n})
+    }
+  }
+
+  guides := []Guide{{guide, .1}}
+  if Denoise(img, guides, 0, 3) != nil || Denoise(img, []Guide{{NewImage(3, 3), 1}}, 1, 3) != nil {
+    t.Error("denoise error 1")
+  }
+
+  //The noise is less, and neither half bleeds into the other.
+  out := Denoise(img, guides, 1.5, 4)
+  var before, after float64
+  for y := 0; y < 16; y ++ {
+    for x := 0; x < 32; x ++ {
+      c := .1
+      if x < 16 {
+        c = .6
+      }
+      a, b := img.At(x, y)[0] - c, out.At(x, y)[0] - c
+      before += a * a
+      after += b * b
+    }
+  }
+  if !(after * 10 < before) {
+    t.Error("denoise error 2: ", before, after)
+  }
+  if c := out.At(16, 8)[0]; c > .2 {
+    t.Error("denoise error 3: ", c)
+  }
+
+  //Guides with no sigma are left out.
+  a := Denoise(img, guides, 1.5, 2)
+  b := Denoise(img, []Guide{{guide, .1}, {img, 0}}, 1.5, 2)
+  for i := range a.Pix {
+    if a.Pix[i] != b.Pix[i] {
+      t.Error("denoise error 4")
+      break
+    }
+  }
+}
@@ -0,0 +1,92 @@
+package hdr
+
+//OpenEXR files, written as uncompressed scan lines of 32-bit floats.
+
+import "bytes"
+import "encoding/binary"
+import "io"
+import "math"
+
+//The first bytes of every OpenEXR file, followed by the version,
+//which is 2 for a file of scan lines.
+var exrMagic []byte = []byte{0x76, 0x2f, 0x31, 0x01, 2, 0, 0, 0}
+
+//The kinds of pixels that OpenEXR can have.
+const exrFloat int32 = 2
+
+//An attribute of the header is its name, its type, its size, and its value.
+func exrAttribute(h *bytes.Buffer, name, kind string, value []byte) {
+  h.WriteString(name)
+  h.WriteByte(0)
+  h.WriteString(kind)
+  h.WriteByte(0)
+  binary.Write(h, binary.LittleEndian, int32(len(value)))
+  h.Write(value)
+}
+
+func exrValue(v ...interface{}) []byte {
+  b := new(bytes.Buffer)
+  for _, x := range v {
+    binary.Write(b, binary.LittleEndian, x)
+  }
+  return b.Bytes()
+}
+
+func WriteEXR(w io.Writer, img *Image) error {
+  h := new(bytes.Buffer)
+  h.Write(exrMagic)
+
+  //The channels must be in alphabetical order, which is also the
+  //order in which they are written in each scan line.
+  channels := new(bytes.Buffer)
+  for _, name := range []string{"B", "G", "R"} {
+    channels.WriteString(name)
+    channels.WriteByte(0)
+    channels.Write(exrValue(exrFloat, uint8(0), [3]uint8{}, int32(1), int32(1)))
+  }
+  channels.WriteByte(0)
+
+  window := exrValue(int32(0), int32(0), int32(img.Width - 1), int32(img.Height - 1))
+
+  exrAttribute(h, "channels", "chlist", channels.Bytes())
+  exrAttribute(h, "compression", "compression", []byte{0})
+  exrAttribute(h, "dataWindow", "box2i", window)
+  exrAttribute(h, "displayWindow", "box2i", window)
+  exrAttribute(h, "lineOrder", "lineOrder", []byte{0})
+  exrAttribute(h, "pixelAspectRatio", "float", exrValue(float32(1)))
+  exrAttribute(h, "screenWindowCenter", "v2f", exrValue(float32(0), float32(0)))
+  exrAttribute(h, "screenWindowWidth", "float", exrValue(float32(1)))
+  h.WriteByte(0)
+
+  //The table of where each scan line starts in the file.
+  lineSize := 3 * 4 * img.Width
+  start := uint64(h.Len() + 8 * img.Height)
+  for y := 0; y < img.Height; y ++ {
+    binary.Write(h, binary.LittleEndian, start + uint64(y * (8 + lineSize)))
+  }
+
+  if _, err := w.Write(h.Bytes()); err != nil {
+    return err
+  }
+
+  line := make([]byte, 8 + lineSize)
+  for y := 0; y < img.Height; y ++ {
+    binary.LittleEndian.PutUint32(line[0:], uint32(y))
+    binary.LittleEndian.PutUint32(line[4:], uint32(lineSize))
+    for c := 0; c < 3; c ++ {
+      for x := 0; x < img.Width; x ++ {
+        v := float32(img.At(x, y)[2 - c])
+        binary.LittleEndian.PutUint32(line[8 + 4 * (c * img.Width + x):], math.Float32bits(v))
+      }
+    }
+    if _, err := w.Write(line); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+func SaveEXR(filename string, img *Image) error {
+  return save(filename, img, WriteEXR)
+}
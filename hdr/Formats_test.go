@@ -0,0 +1,199 @@
+package hdr
+
+import "testing"
+import "bytes"
+import "encoding/binary"
+import "math"
+import "os"
+import "path/filepath"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+//A picture with a wide range of colors, and some runs of the same color.
+func testImage(width, height int) *Image {
+  img := NewImage(width, height)
+  for y := 0; y < height; y ++ {
+    for x := 0; x < width; x ++ {
+      if x > width / 2 {
+        img.Set(x, y, []float64{1, .5, .25})
+      } else {
+        img.Set(x, y, []float64{float64(x) * 100 + .1, float64(y) / 7, math.Pow(2, -float64(x))})
+      }
+    }
+  }
+  return img
+}
+
+func TestPFM(t *testing.T) {
+  img := testImage(5, 3)
+
+  b := new(bytes.Buffer)
+  if err := WritePFM(b, img); err != nil {
+    t.Error("pfm error 1: ", err)
+  }
+  if !bytes.HasPrefix(b.Bytes(), []byte("PF\n5 3\n-1.0\n")) || b.Len() != 12 + 4 * 3 * 15 {
+    t.Error("pfm error 2: ", b.Len())
+  }
+
+  read, err := ReadPFM(b)
+  if err != nil {
+    t.Error("pfm error 3: ", err)
+    return
+  }
+  if read.Width != 5 || read.Height != 3 || !test.VectorCloseEnough(read.Pix, img.Pix, .00001) {
+    t.Error("pfm error 4: ", read.Pix)
+  }
+
+  //A big-endian grayscale file.
+  gray := []byte("Pf\n1 2\n1.0\n")
+  gray = append(gray, 0x3f, 0x80, 0, 0, 0x40, 0, 0, 0)
+  read, err = ReadPFM(bytes.NewReader(gray))
+  if err != nil {
+    t.Error("pfm error 5: ", err)
+    return
+  }
+  if !test.VectorCloseEnough(read.Pix, []float64{2, 2, 2, 1, 1, 1}, hdr_err) {
+    t.Error("pfm error 6: ", read.Pix)
+  }
+
+  for i, bad := range []string{"", "P6\n1 1\n-1.0\n", "PF\n0 1\n-1.0\n", "PF\n2 2\n-1.0\nabc"} {
+    if _, err := ReadPFM(bytes.NewReader([]byte(bad))); err == nil {
+      t.Error("pfm error 7, case ", i)
+    }
+  }
+}
+
+func TestHDR(t *testing.T) {
+  //Rows which are too short to encode and rows which are encoded.
+  for _, width := range []int{5, 40, 300} {
+    img := testImage(width, 4)
+
+    b := new(bytes.Buffer)
+    if err := WriteHDR(b, img); err != nil {
+      t.Error("hdr error 1: ", err)
+    }
+
+    read, err := ReadHDR(b)
+    if err != nil {
+      t.Error("hdr error 2: ", width, err)
+      continue
+    }
+    if read.Width != width || read.Height != 4 {
+      t.Error("hdr error 3: ", read.Width, read.Height)
+      continue
+    }
+
+    //Each color is stored to within about one part in 256 of the brightest channel.
+    for i := 0; i < len(img.Pix); i += 3 {
+      max := math.Max(img.Pix[i], math.Max(img.Pix[i + 1], img.Pix[i + 2]))
+      for j := i; j < i + 3; j ++ {
+        if math.Abs(read.Pix[j] - img.Pix[j]) > max / 128 {
+          t.Error("hdr error 4: ", width, i, img.Pix[i : i + 3], read.Pix[i : i + 3])
+          break
+        }
+      }
+    }
+  }
+
+  //Long runs are encoded in a few bytes.
+  img := NewImage(1000, 1)
+  b := new(bytes.Buffer)
+  WriteHDR(b, img)
+  if b.Len() > 150 {
+    t.Error("hdr error 5: ", b.Len())
+  }
+
+  for i, bad := range []string{"", "P6\n", "#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n\x00\x00\x00\x00",
+    "#?RADIANCE\n\n+Y 1 +X 1\n\x00\x00\x00\x00", "#?RADIANCE\n\n-Y 1 +X 1\n\x00\x00"} {
+    if _, err := ReadHDR(bytes.NewReader([]byte(bad))); err == nil {
+      t.Error("hdr error 6, case ", i)
+    }
+  }
+}
+
+func TestEXR(t *testing.T) {
+  img := testImage(3, 2)
+
+  b := new(bytes.Buffer)
+  if err := WriteEXR(b, img); err != nil {
+    t.Error("exr error 1: ", err)
+  }
+  data := b.Bytes()
+
+  if !bytes.HasPrefix(data, exrMagic) {
+    t.Error("exr error 2")
+  }
+
+  //Read the attributes of the header.
+  attributes := make(map[string][]byte)
+  i := len(exrMagic)
+  for data[i] != 0 {
+    name := data[i : i + bytes.IndexByte(data[i:], 0)]
+    i += len(name) + 1
+    kind := data[i : i + bytes.IndexByte(data[i:], 0)]
+    i += len(kind) + 1
+    size := int(binary.LittleEndian.Uint32(data[i:]))
+    i += 4
+    attributes[string(name)] = data[i : i + size]
+    i += size
+  }
+  i ++
+
+  for _, name := range []string{"channels", "compression", "dataWindow", "displayWindow",
+    "lineOrder", "pixelAspectRatio", "screenWindowCenter", "screenWindowWidth"} {
+    if _, ok := attributes[name]; !ok {
+      t.Error("exr error 3: ", name)
+    }
+  }
+  if !bytes.Equal(attributes["dataWindow"], []byte{0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0}) {
+    t.Error("exr error 4: ", attributes["dataWindow"])
+  }
+
+  //Each scan line is found from the table of offsets.
+  for y := 0; y < 2; y ++ {
+    offset := int(binary.LittleEndian.Uint64(data[i + 8 * y:]))
+    if int(binary.LittleEndian.Uint32(data[offset:])) != y || binary.LittleEndian.Uint32(data[offset + 4:]) != 36 {
+      t.Error("exr error 5: ", y)
+      continue
+    }
+
+    for x := 0; x < 3; x ++ {
+      //The channels are blue, green, red.
+      for c := 0; c < 3; c ++ {
+        v := math.Float32frombits(binary.LittleEndian.Uint32(data[offset + 8 + 4 * (c * 3 + x):]))
+        if !test.CloseEnough(float64(v), img.At(x, y)[2 - c], .0001) {
+          t.Error("exr error 6: ", x, y, c, v)
+        }
+      }
+    }
+  }
+
+  if len(data) != i + 16 + 2 * (8 + 36) {
+    t.Error("exr error 7: ", len(data))
+  }
+}
+
+func TestSave(t *testing.T) {
+  dir, err := os.MkdirTemp("", "hdr")
+  if err != nil {
+    t.Error("save error 1: ", err)
+    return
+  }
+  defer os.RemoveAll(dir)
+
+  img := testImage(9, 2)
+  for _, name := range []string{"a.exr", "a.hdr", "a.pfm"} {
+    if err := Save(filepath.Join(dir, name), img); err != nil {
+      t.Error("save error 2: ", name, err)
+    }
+  }
+  if err := Save(filepath.Join(dir, "a.png"), img); err == nil {
+    t.Error("save error 3")
+  }
+
+  if read, err := LoadPFM(filepath.Join(dir, "a.pfm")); err != nil || !test.VectorCloseEnough(read.Pix, img.Pix, .00001) {
+    t.Error("save error 4: ", err)
+  }
+  if read, err := LoadHDR(filepath.Join(dir, "a.hdr")); err != nil || read.Width != 9 {
+    t.Error("save error 5: ", err)
+  }
+}
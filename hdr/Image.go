@@ -0,0 +1,64 @@
+package hdr
+
+//Pictures of high dynamic range, which store the radiance that reaches
+//each pixel before it is turned into something that can be displayed.
+//They can be written as OpenEXR, Radiance HDR, or PFM files, or made
+//into ordinary images with a tone mapper.
+
+import "fmt"
+import "math"
+import "path/filepath"
+import "strings"
+
+//A picture in red, green, and blue floating point values.
+type Image struct {
+  Width, Height int
+  //The colors of the pixels, three values for each, row by row from the top.
+  Pix []float64
+}
+
+//May return nil.
+func NewImage(width, height int) *Image {
+  if width <= 0 || height <= 0 {return nil}
+  return &Image{width, height, make([]float64, 3 * width * height)}
+}
+
+//The color of a pixel. The slice returned is part of the image.
+func (img *Image) At(x, y int) []float64 {
+  i := 3 * (y * img.Width + x)
+  return img.Pix[i : i + 3 : i + 3]
+}
+
+func (img *Image) Set(x, y int, c []float64) {
+  copy(img.At(x, y), c)
+}
+
+//The luminance of a linear rgb color, with the weights of Rec. 709.
+func Luminance(c []float64) float64 {
+  return .2126 * c[0] + .7152 * c[1] + .0722 * c[2]
+}
+
+//The geometric mean of the luminance of every pixel, which is used
+//to find how bright a picture looks overall. A small value is added
+//to every luminance so that black pixels do not make it zero.
+func (img *Image) LogAverageLuminance() float64 {
+  var sum float64
+  for i := 0; i < len(img.Pix); i += 3 {
+    sum += math.Log(1e-4 + math.Max(Luminance(img.Pix[i : i + 3]), 0))
+  }
+  return math.Exp(sum / float64(img.Width * img.Height))
+}
+
+//Save a picture as an OpenEXR, Radiance HDR, or PFM file,
+//depending on whether the name ends in .exr, .hdr, or .pfm.
+func Save(filename string, img *Image) error {
+  switch strings.ToLower(filepath.Ext(filename)) {
+  case ".exr":
+    return SaveEXR(filename, img)
+  case ".hdr", ".pic":
+    return SaveHDR(filename, img)
+  case ".pfm":
+    return SavePFM(filename, img)
+  }
+  return fmt.Errorf("unknown high dynamic range format %s", filename)
+}
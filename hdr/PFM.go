@@ -0,0 +1,112 @@
+package hdr
+
+//Portable float maps, which are a header followed by 32-bit floats,
+//row by row from the bottom. A negative scale in the header means
+//the floats are little-endian.
+
+import "bufio"
+import "encoding/binary"
+import "fmt"
+import "io"
+import "math"
+import "os"
+
+func WritePFM(w io.Writer, img *Image) error {
+  b := bufio.NewWriter(w)
+  if _, err := fmt.Fprintf(b, "PF\n%d %d\n-1.0\n", img.Width, img.Height); err != nil {
+    return err
+  }
+
+  buf := make([]byte, 4)
+  for y := img.Height - 1; y >= 0; y -- {
+    for x := 0; x < img.Width; x ++ {
+      for _, v := range img.At(x, y) {
+        binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(v)))
+        if _, err := b.Write(buf); err != nil {
+          return err
+        }
+      }
+    }
+  }
+
+  return b.Flush()
+}
+
+//Read a PFM file in color (PF) or grayscale (Pf).
+func ReadPFM(r io.Reader) (*Image, error) {
+  b := bufio.NewReader(r)
+
+  var format string
+  var width, height int
+  var scale float64
+  if _, err := fmt.Fscan(b, &format, &width, &height, &scale); err != nil {
+    return nil, fmt.Errorf("pfm header: %s", err)
+  }
+  //A single whitespace character comes before the data.
+  if _, err := b.ReadByte(); err != nil {
+    return nil, fmt.Errorf("pfm header: %s", err)
+  }
+
+  var channels int
+  switch format {
+  case "PF":
+    channels = 3
+  case "Pf":
+    channels = 1
+  default:
+    return nil, fmt.Errorf("not a pfm file")
+  }
+
+  img := NewImage(width, height)
+  if img == nil || scale == 0 {
+    return nil, fmt.Errorf("pfm header: invalid size")
+  }
+
+  var order binary.ByteOrder = binary.BigEndian
+  if scale < 0 {
+    order = binary.LittleEndian
+  }
+
+  buf := make([]byte, 4 * channels)
+  for y := height - 1; y >= 0; y -- {
+    for x := 0; x < width; x ++ {
+      if _, err := io.ReadFull(b, buf); err != nil {
+        return nil, fmt.Errorf("pfm data: %s", err)
+      }
+      c := img.At(x, y)
+      for i := 0; i < 3; i ++ {
+        c[i] = float64(math.Float32frombits(order.Uint32(buf[4 * (i % channels):])))
+      }
+    }
+  }
+
+  return img, nil
+}
+
+func SavePFM(filename string, img *Image) error {
+  return save(filename, img, WritePFM)
+}
+
+func LoadPFM(filename string) (*Image, error) {
+  file, err := os.Open(filename)
+  if err != nil {
+    return nil, err
+  }
+  defer file.Close()
+
+  return ReadPFM(file)
+}
+
+func save(filename string, img *Image, write func(io.Writer, *Image) error) error {
+  file, err := os.Create(filename)
+  if err != nil {
+    return err
+  }
+
+  if err := write(file, img); err != nil {
+    file.Close()
+    return err
+  }
+
+  return file.Close()
+}
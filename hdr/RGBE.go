@@ -0,0 +1,229 @@
+package hdr
+
+//Radiance HDR files, in which each pixel is stored in four bytes:
+//a mantissa for red, green, and blue, and an exponent which they share.
+//Rows are written with the run-length encoding of newer Radiance files.
+
+import "bufio"
+import "fmt"
+import "io"
+import "math"
+import "os"
+import "strings"
+
+//The widths of rows which can be run-length encoded.
+const rgbeMinEncoded, rgbeMaxEncoded int = 8, 0x7fff
+
+func toRGBE(c []float64) [4]byte {
+  v := math.Max(c[0], math.Max(c[1], c[2]))
+  if !(v > 1e-32) {
+    return [4]byte{0, 0, 0, 0}
+  }
+
+  m, e := math.Frexp(v)
+  s := m * 256 / v
+  return [4]byte{byte(math.Max(c[0], 0) * s), byte(math.Max(c[1], 0) * s), byte(math.Max(c[2], 0) * s), byte(e + 128)}
+}
+
+func fromRGBE(b []byte, c []float64) {
+  if b[3] == 0 {
+    c[0], c[1], c[2] = 0, 0, 0
+    return
+  }
+
+  //Half is added to each mantissa since it was rounded down.
+  f := math.Ldexp(1, int(b[3]) - (128 + 8))
+  c[0], c[1], c[2] = (float64(b[0]) + .5) * f, (float64(b[1]) + .5) * f, (float64(b[2]) + .5) * f
+}
+
+func WriteHDR(w io.Writer, img *Image) error {
+  b := bufio.NewWriter(w)
+  if _, err := fmt.Fprintf(b, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", img.Height, img.Width); err != nil {
+    return err
+  }
+
+  row := make([][4]byte, img.Width)
+  component := make([]byte, img.Width)
+  for y := 0; y < img.Height; y ++ {
+    for x := 0; x < img.Width; x ++ {
+      row[x] = toRGBE(img.At(x, y))
+    }
+
+    if img.Width < rgbeMinEncoded || img.Width > rgbeMaxEncoded {
+      for _, p := range row {
+        if _, err := b.Write(p[:]); err != nil {
+          return err
+        }
+      }
+      continue
+    }
+
+    if _, err := b.Write([]byte{2, 2, byte(img.Width >> 8), byte(img.Width & 0xff)}); err != nil {
+      return err
+    }
+    for i := 0; i < 4; i ++ {
+      for x, p := range row {
+        component[x] = p[i]
+      }
+      if _, err := b.Write(encodeRLE(component)); err != nil {
+        return err
+      }
+    }
+  }
+
+  return b.Flush()
+}
+
+//A run of the same byte is written as 128 plus its length followed by
+//the byte, and anything else as its length followed by the bytes.
+func encodeRLE(data []byte) []byte {
+  const minRun, maxRun int = 4, 127
+  out := make([]byte, 0, len(data) + len(data) / 64 + 2)
+
+  runAt := func(i int) int {
+    n := 1
+    for i + n < len(data) && n < maxRun && data[i + n] == data[i] {
+      n ++
+    }
+    return n
+  }
+
+  for i := 0; i < len(data); {
+    if n := runAt(i); n >= minRun {
+      out = append(out, byte(128 + n), data[i])
+      i += n
+      continue
+    }
+
+    //Bytes which are not part of a run, up to the next run.
+    j := i
+    for j < len(data) && j - i < 128 && runAt(j) < minRun {
+      j ++
+    }
+    out = append(out, byte(j - i))
+    out = append(out, data[i:j]...)
+    i = j
+  }
+
+  return out
+}
+
+//Read a Radiance HDR file whose rows go from the top down and from left
+//to right, with or without run-length encoding.
+func ReadHDR(r io.Reader) (*Image, error) {
+  b := bufio.NewReader(r)
+
+  first, err := b.ReadString('\n')
+  if err != nil || !strings.HasPrefix(first, "#?") {
+    return nil, fmt.Errorf("not a radiance hdr file")
+  }
+
+  //The header goes until a blank line.
+  for {
+    line, err := b.ReadString('\n')
+    if err != nil {
+      return nil, fmt.Errorf("hdr header: %s", err)
+    }
+    line = strings.TrimSpace(line)
+    if line == "" {
+      break
+    }
+    if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+      return nil, fmt.Errorf("hdr header: unsupported %s", line)
+    }
+  }
+
+  var width, height int
+  if _, err := fmt.Fscanf(b, "-Y %d +X %d\n", &height, &width); err != nil {
+    return nil, fmt.Errorf("hdr resolution: %s", err)
+  }
+
+  img := NewImage(width, height)
+  if img == nil {
+    return nil, fmt.Errorf("hdr resolution: invalid size")
+  }
+
+  row := make([]byte, 4 * width)
+  for y := 0; y < height; y ++ {
+    if err := readRGBERow(b, row, width); err != nil {
+      return nil, fmt.Errorf("hdr row %d: %s", y, err)
+    }
+    for x := 0; x < width; x ++ {
+      fromRGBE(row[4 * x : 4 * x + 4], img.At(x, y))
+    }
+  }
+
+  return img, nil
+}
+
+//Read a row into the rgbe values of each pixel.
+func readRGBERow(b *bufio.Reader, row []byte, width int) error {
+  start, err := b.Peek(4)
+  if err != nil {
+    return err
+  }
+
+  if width < rgbeMinEncoded || width > rgbeMaxEncoded || start[0] != 2 || start[1] != 2 || start[2] & 0x80 != 0 {
+    _, err := io.ReadFull(b, row)
+    return err
+  }
+
+  if int(start[2]) << 8 | int(start[3]) != width {
+    return fmt.Errorf("wrong width")
+  }
+  b.Discard(4)
+
+  for i := 0; i < 4; i ++ {
+    for x := 0; x < width; {
+      n, err := b.ReadByte()
+      if err != nil {
+        return err
+      }
+
+      if n > 128 {
+        count := int(n) - 128
+        if x + count > width {
+          return fmt.Errorf("run is too long")
+        }
+        v, err := b.ReadByte()
+        if err != nil {
+          return err
+        }
+        for ; count > 0; count -- {
+          row[4 * x + i] = v
+          x ++
+        }
+        continue
+      }
+
+      count := int(n)
+      if count == 0 || x + count > width {
+        return fmt.Errorf("bad run length")
+      }
+      for ; count > 0; count -- {
+        v, err := b.ReadByte()
+        if err != nil {
+          return err
+        }
+        row[4 * x + i] = v
+        x ++
+      }
+    }
+  }
+
+  return nil
+}
+
+func SaveHDR(filename string, img *Image) error {
+  return save(filename, img, WriteHDR)
+}
+
+func LoadHDR(filename string) (*Image, error) {
+  file, err := os.Open(filename)
+  if err != nil {
+    return nil, err
+  }
+  defer file.Close()
+
+  return ReadHDR(file)
+}
@@ -0,0 +1,117 @@
+package hdr
+
+import "image"
+import "image/color"
+import "math"
+
+//Turns a linear color of any brightness into one whose values are
+//between zero and one, which can be displayed.
+type ToneMapper func(c []float64) []float64
+
+//Values greater than one are cut off.
+func Clamp() ToneMapper {
+  return func(c []float64) []float64 {
+    return []float64{clamp(c[0]), clamp(c[1]), clamp(c[2])}
+  }
+}
+
+func clamp(x float64) float64 {
+  return math.Max(0, math.Min(x, 1))
+}
+
+//Multiply the color by a scale and then tone map it. If then is nil, it is clamped.
+func Scale(s float64, then ToneMapper) ToneMapper {
+  if then == nil {
+    then = Clamp()
+  }
+
+  return func(c []float64) []float64 {
+    return then([]float64{s * c[0], s * c[1], s * c[2]})
+  }
+}
+
+//Make the picture brighter or dimmer by a number of stops, then clamp it.
+func Exposure(stops float64) ToneMapper {
+  return Scale(math.Pow(2, stops), nil)
+}
+
+//The operator of Reinhard et al., which compresses the luminance L to
+//L (1 + L / white^2) / (1 + L), so that white and anything brighter is
+//shown as white. If white is not positive, it is L / (1 + L), which
+//never reaches white.
+func Reinhard(white float64) ToneMapper {
+  w2 := white * white
+
+  return func(c []float64) []float64 {
+    l := Luminance(c)
+    if !(l > 0) {
+      return []float64{0, 0, 0}
+    }
+
+    m := l / (1 + l)
+    if white > 0 {
+      m *= 1 + l / w2
+    }
+
+    s := m / l
+    return []float64{clamp(s * c[0]), clamp(s * c[1]), clamp(s * c[2])}
+  }
+}
+
+//The filmic curve of John Hable, from Uncharted 2, which has a
+//toe and a shoulder like film does.
+func Filmic() ToneMapper {
+  const a, b, c, d, e, f float64 = .15, .5, .1, .2, .02, .3
+  const exposureBias, white float64 = 2, 11.2
+
+  curve := func(x float64) float64 {
+    return ((x * (a * x + c * b) + d * e) / (x * (a * x + b) + d * f)) - e / f
+  }
+  w := curve(white)
+
+  return func(col []float64) []float64 {
+    m := make([]float64, 3)
+    for i := 0; i < 3; i ++ {
+      m[i] = clamp(curve(exposureBias * math.Max(col[i], 0)) / w)
+    }
+    return m
+  }
+}
+
+//Scale the picture so that its log-average luminance is key, and then
+//tone map it. A key of .18 is middle gray. If then is nil, it is clamped.
+func AutoExposure(img *Image, key float64, then ToneMapper) ToneMapper {
+  return Scale(key / img.LogAverageLuminance(), then)
+}
+
+//Make an ordinary picture. The tone mapper is applied to every pixel
+//and the result is raised to the power 1 / gamma. If the tone mapper
+//is nil, the picture is clamped, and if gamma is not positive, it is one.
+func (img *Image) ToneMap(t ToneMapper, gamma float64) *image.NRGBA {
+  if t == nil {
+    t = Clamp()
+  }
+  if !(gamma > 0) {
+    gamma = 1
+  }
+
+  out := image.NewNRGBA(image.Rect(0, 0, img.Width, img.Height))
+  for y := 0; y < img.Height; y ++ {
+    for x := 0; x < img.Width; x ++ {
+      c := t(img.At(x, y))
+      if gamma != 1 {
+        for i := 0; i < 3; i ++ {
+          c[i] = math.Pow(c[i], 1 / gamma)
+        }
+      }
+
+      out.SetNRGBA(x, y, color.NRGBA{byteValue(c[0]), byteValue(c[1]), byteValue(c[2]), 255})
+    }
+  }
+
+  return out
+}
+
+func byteValue(x float64) uint8 {
+  return uint8(math.Min(255 * math.Max(x, 0), 255))
+}
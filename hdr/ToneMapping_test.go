@@ -0,0 +1,120 @@
+package hdr
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+var hdr_err float64 = .000001
+
+func TestImage(t *testing.T) {
+  if NewImage(0, 3) != nil || NewImage(3, -1) != nil {
+    t.Error("image error 1")
+  }
+
+  img := NewImage(3, 2)
+  img.Set(2, 1, []float64{1, 2, 3})
+  if !test.VectorCloseEnough(img.Pix[15:18], []float64{1, 2, 3}, hdr_err) {
+    t.Error("image error 2: ", img.Pix)
+  }
+  if !test.VectorCloseEnough(img.At(2, 1), []float64{1, 2, 3}, hdr_err) {
+    t.Error("image error 3: ", img.At(2, 1))
+  }
+
+  //The log average of a picture whose pixels have the same luminance is that luminance.
+  for i := 0; i < len(img.Pix); i ++ {
+    img.Pix[i] = 2
+  }
+  if !test.CloseEnough(img.LogAverageLuminance(), 2 + 1e-4, hdr_err) {
+    t.Error("image error 4: ", img.LogAverageLuminance())
+  }
+
+  //Half the pixels at 4 and half at 1 average to 2.
+  for x := 0; x < 3; x ++ {
+    img.Set(x, 0, []float64{4, 4, 4})
+    img.Set(x, 1, []float64{1, 1, 1})
+  }
+  if !test.CloseEnough(img.LogAverageLuminance(), 2, .001) {
+    t.Error("image error 5: ", img.LogAverageLuminance())
+  }
+}
+
+func TestToneMappers(t *testing.T) {
+  bright := []float64{2, 4, 8}
+  dim := []float64{.1, .2, .05}
+
+  if c := Clamp()(bright); !test.VectorCloseEnough(c, []float64{1, 1, 1}, hdr_err) {
+    t.Error("tone mapper error 1: ", c)
+  }
+  if c := Clamp()(dim); !test.VectorCloseEnough(c, dim, hdr_err) {
+    t.Error("tone mapper error 2: ", c)
+  }
+  if c := Exposure(1)(dim); !test.VectorCloseEnough(c, []float64{.2, .4, .1}, hdr_err) {
+    t.Error("tone mapper error 3: ", c)
+  }
+  if c := Exposure(-3)(bright); !test.VectorCloseEnough(c, []float64{.25, .5, 1}, hdr_err) {
+    t.Error("tone mapper error 4: ", c)
+  }
+
+  //Reinhard keeps the hue and compresses the luminance.
+  gray := []float64{3, 3, 3}
+  if c := Reinhard(0)(gray); !test.VectorCloseEnough(c, []float64{.75, .75, .75}, hdr_err) {
+    t.Error("tone mapper error 5: ", c)
+  }
+  if c := Reinhard(3)(gray); !test.VectorCloseEnough(c, []float64{1, 1, 1}, hdr_err) {
+    t.Error("tone mapper error 6: ", c)
+  }
+  if c := Reinhard(0)(dim); !(c[1] / c[0] > 1.99 && c[1] / c[0] < 2.01) || c[1] >= dim[1] {
+    t.Error("tone mapper error 7: ", c)
+  }
+  if c := Reinhard(0)([]float64{0, 0, 0}); !test.VectorCloseEnough(c, []float64{0, 0, 0}, hdr_err) {
+    t.Error("tone mapper error 8: ", c)
+  }
+
+  //The filmic curve goes from black to white and increases in between.
+  f := Filmic()
+  if c := f([]float64{0, 0, 0}); !test.VectorCloseEnough(c, []float64{0, 0, 0}, hdr_err) {
+    t.Error("tone mapper error 9: ", c)
+  }
+  if c := f([]float64{5.6, 5.6, 5.6}); !test.VectorCloseEnough(c, []float64{1, 1, 1}, hdr_err) {
+    t.Error("tone mapper error 10: ", c)
+  }
+  var last float64
+  for x := .01; x < 5.6; x += .01 {
+    c := f([]float64{x, x, x})
+    if !(c[0] > last) {
+      t.Error("tone mapper error 11: ", x, c)
+      break
+    }
+    last = c[0]
+  }
+
+  //Auto exposure scales a picture so that it is middle gray on average.
+  img := NewImage(2, 2)
+  for i := 0; i < len(img.Pix); i ++ {
+    img.Pix[i] = 40
+  }
+  if c := AutoExposure(img, .18, nil)(img.At(0, 0)); !test.VectorCloseEnough(c, []float64{.18, .18, .18}, .0001) {
+    t.Error("tone mapper error 12: ", c)
+  }
+}
+
+func TestToneMap(t *testing.T) {
+  img := NewImage(2, 1)
+  img.Set(0, 0, []float64{.5, 2, -1})
+  img.Set(1, 0, []float64{.25, .25, .25})
+
+  out := img.ToneMap(nil, 0)
+  if c := out.NRGBAAt(0, 0); c.R != 127 || c.G != 255 || c.B != 0 || c.A != 255 {
+    t.Error("tone map error 1: ", c)
+  }
+
+  out = img.ToneMap(Clamp(), 2)
+  if c := out.NRGBAAt(1, 0); c.R != 127 {
+    t.Error("tone map error 2: ", c)
+  }
+
+  out = img.ToneMap(Exposure(1), 1)
+  if c := out.NRGBAAt(1, 0); c.R != uint8(math.Floor(127.5)) {
+    t.Error("tone map error 3: ", c)
+  }
+}
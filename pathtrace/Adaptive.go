@@ -0,0 +1,223 @@
+package pathtrace
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/hdr"
+
+//Adaptive sampling spends a fixed number of rays on the whole picture,
+//giving more of them to the parts where the noise is easiest to see.
+//Noise is measured relative to how bright a pixel is, because the eye
+//sees the same noise more easily in a dark part of the picture than in
+//a bright one. The picture is divided into tiles, and each tile is given
+//rays in proportion to the error of its pixels and of the pixels around
+//it, so that one lucky pixel with little variance among noisy neighbors
+//is not left behind.
+
+//The width and height of the tiles, in pixels.
+const adaptiveTile = 8
+
+//A pixel's error is relative to its luminance plus this, so that noise
+//in nearly black pixels, which can hardly be seen, does not use up
+//the whole budget.
+const errorFloor = .05
+
+//The weight of each channel in the luminance of a pixel.
+var luminanceWeights []float64 = []float64{
+  hdr.Luminance([]float64{1, 0, 0}),
+  hdr.Luminance([]float64{0, 1, 0}),
+  hdr.Luminance([]float64{0, 0, 1})}
+
+//The standard deviation of the luminance of the mean of the samples,
+//relative to the luminance. The channels of a pixel usually go up and
+//down together, so their standard deviations are added as though they
+//always did, which is the most that it can be. A pixel with fewer than
+//two samples, whose variance is not known, has an error of one.
+func (s *pixelSamples) relativeError() float64 {
+  if s.n < 2 { return 1 }
+
+  var d float64
+  for l, w := range luminanceWeights {
+    d += w * math.Sqrt(s.monitor[l].MeanVariance())
+  }
+  return d / (math.Max(hdr.Luminance(s.mean()), 0) + errorFloor)
+}
+
+//The number of rays that have been traced for the picture.
+func (p *Progressive) Spent() int {
+  var n int
+  for i := range p.pixels {
+    n += p.pixels[i].n
+  }
+  return n
+}
+
+//The error of each tile, in rows from the top, along with the number of
+//columns of tiles. It is the mean relative error of the pixels in the
+//tile and of those next to it. Pixels with more than maxp samples, which
+//can have no more, count as having no error.
+func (p *Progressive) tileErrors(maxp int) ([]float64, int) {
+  columns := (p.Width + adaptiveTile - 1) / adaptiveTile
+  rows := (p.Height + adaptiveTile - 1) / adaptiveTile
+
+  pixels := make([]float64, len(p.pixels))
+  for i := range p.pixels {
+    if p.pixels[i].n <= maxp {
+      pixels[i] = p.pixels[i].relativeError()
+    }
+  }
+
+  tiles := make([]float64, columns * rows)
+  for ty := 0; ty < rows; ty ++ {
+    for tx := 0; tx < columns; tx ++ {
+      var sum float64
+      var n int
+      for y := ty * adaptiveTile - 1; y <= (ty + 1) * adaptiveTile; y ++ {
+        for x := tx * adaptiveTile - 1; x <= (tx + 1) * adaptiveTile; x ++ {
+          if x < 0 || y < 0 || x >= p.Width || y >= p.Height { continue }
+          sum += pixels[y * p.Width + x]
+          n ++
+        }
+      }
+      tiles[ty * columns + tx] = sum / float64(n)
+    }
+  }
+  return tiles, columns
+}
+
+//Make passes over the picture over several goroutines, each with its own
+//scene, until budget rays have been traced for the whole picture or the
+//error of every tile is no more than maxError. The first pass gives every
+//pixel minp + 1 samples, but no more than half the budget so that there is
+//some left to share out, and at least two so that its error can be known.
+//Each pass after that shares up to samples rays for each pixel of the
+//picture among the tiles, in proportion to their error. A pixel gets no
+//more than maxp + 1 samples, and no more once its samples are all the
+//same. After each pass, pass is called with the picture and the number of
+//rays left in the budget. Stops early if pass returns false. pass may be nil.
+func (p *Progressive) RenderAdaptive(sceneBuild func() *Scene, cam_func GenerateRay, depth, minp, maxp int,
+  budget int, maxError float64, samples, routines int, pass func(p *Progressive, remaining int) bool) {
+  if samples <= 0 { samples = 1 }
+  if minp < 1 { minp = 1 }
+  first := minp + 1
+  if len(p.pixels) > 0 && first > budget / (2 * len(p.pixels)) {
+    first = budget / (2 * len(p.pixels))
+  }
+  if first < 2 { first = 2 }
+
+  scenes := buildScenes(sceneBuild, routines)
+  quota := make([]int, len(p.pixels))
+
+  for {
+    spent := p.Spent()
+    if spent >= budget { return }
+
+    tiles, columns := p.tileErrors(maxp)
+    var start bool
+    for i := range p.pixels {
+      quota[i] = 0
+      if p.pixels[i].n < first {
+        quota[i] = first - p.pixels[i].n
+        start = true
+      }
+    }
+
+    //Once every pixel has been started, share the rays among the tiles.
+    if !start {
+      tile := func(x, y int) float64 {
+        return tiles[(y / adaptiveTile) * columns + x / adaptiveTile]
+      }
+
+      //The sum of the error of the tile of each pixel that needs more rays.
+      var total float64
+      for y := 0; y < p.Height; y ++ {
+        for x := 0; x < p.Width; x ++ {
+          if e := tile(x, y); e > maxError {
+            total += e
+          }
+        }
+      }
+      if total == 0 { return }
+
+      batch := budget - spent
+      if batch > samples * len(p.pixels) {
+        batch = samples * len(p.pixels)
+      }
+
+      //The parts of a ray that are left over from each pixel are
+      //carried to the next, so that the whole batch is given out.
+      var carry float64
+      for y := 0; y < p.Height; y ++ {
+        for x := 0; x < p.Width; x ++ {
+          if e := tile(x, y); e > maxError {
+            carry += float64(batch) * e / total
+            quota[y * p.Width + x] = int(carry)
+            carry -= float64(int(carry))
+          }
+        }
+      }
+    }
+
+    p.sampleRows(scenes, cam_func, depth, minp, maxp, 0, func(i int) int { return quota[i] })
+    p.Passes ++
+
+    //Every pixel that needs more rays may have all it can have.
+    if p.Spent() == spent { return }
+
+    remaining := budget - p.Spent()
+    if remaining < 0 { remaining = 0 }
+    if pass != nil && !pass(p, remaining) {
+      return
+    }
+  }
+}
+
+//The least and greatest number of samples of any pixel.
+func (p *Progressive) SampleRange() (min, max int) {
+  for i := range p.pixels {
+    n := p.pixels[i].n
+    if i == 0 || n < min {
+      min = n
+    }
+    if n > max {
+      max = n
+    }
+  }
+  return
+}
+
+//A picture of the number of samples of each pixel, from black for the
+//fewest, through blue, red and yellow, to white for the most.
+func (p *Progressive) HeatMap() *hdr.Image {
+  min, max := p.SampleRange()
+
+  img := hdr.NewImage(p.Width, p.Height)
+  for y := 0; y < p.Height; y ++ {
+    for x := 0; x < p.Width; x ++ {
+      var f float64
+      if max > min {
+        f = float64(p.Samples(x, y) - min) / float64(max - min)
+      }
+      img.Set(x, y, heat(f))
+    }
+  }
+  return img
+}
+
+//The colors of the heat map, evenly spaced from zero to one.
+var heatColors [][]float64 = [][]float64{
+  {0, 0, 0}, {0, 0, 1}, {1, 0, 0}, {1, 1, 0}, {1, 1, 1}}
+
+//The color of f in [0, 1] on the heat map.
+func heat(f float64) []float64 {
+  f = math.Max(0, math.Min(1, f)) * float64(len(heatColors) - 1)
+  i := int(f)
+  if i == len(heatColors) - 1 {
+    i --
+  }
+  t := f - float64(i)
+
+  c := make([]float64, 3)
+  for l := range c {
+    c[l] = (1 - t) * heatColors[i][l] + t * heatColors[i + 1][l]
+  }
+  return c
+}
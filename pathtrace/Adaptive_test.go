@@ -0,0 +1,108 @@
+package pathtrace
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+func TestRelativeError(t *testing.T) {
+  var constant, dark, bright pixelSamples
+  for i, c := range []float64{0, 1, 0, 1} {
+    for l := 0; l < 3; l ++ {
+      constant.monitor[l].AddVariable(.5)
+      dark.monitor[l].AddVariable(c)
+      bright.monitor[l].AddVariable(c + 10)
+      constant.sum[l] += .5
+      dark.sum[l] += c
+      bright.sum[l] += c + 10
+    }
+    constant.n, dark.n, bright.n = i + 1, i + 1, i + 1
+  }
+
+  if e := constant.relativeError(); e != 0 {
+    t.Error("relative error error 1: ", e)
+  }
+
+  //The same noise is worse in a dark pixel.
+  if d, b := dark.relativeError(), bright.relativeError(); !(d > 10 * b) || !test.CloseEnough(d, math.Sqrt(1. / 12) / .55, mat_err) {
+    t.Error("relative error error 2: ", d, b)
+  }
+
+  var empty pixelSamples
+  if e := empty.relativeError(); e != 1 {
+    t.Error("relative error error 3: ", e)
+  }
+}
+
+//The sky above the horizon, which has no noise, and a white floor under
+//it lit by one side of the sky, which does.
+func horizonScene() *Scene {
+  return floorScene([]float64{1, 1, 1}, []float64{.2, .2, .2}, distributions.NewSobolSampler(4))
+}
+
+//Looks along the horizon, with the sky in the upper half of the picture.
+func horizonCamera(s distributions.Sampler, i, j int) ([]float64, []float64) {
+  u, v := CameraCoordinates(s, i, j, 24, 16, 1.5, 1)
+  return []float64{0, 0, 1}, []float64{u, 1, v}
+}
+
+func TestRenderAdaptive(t *testing.T) {
+  budget := 24 * 16 * 20
+
+  p := NewProgressive(24, 16)
+  var passes int
+  p.RenderAdaptive(horizonScene, horizonCamera, 2, 1, 1000, budget, 0, 4, 3, func(p *Progressive, remaining int) bool {
+    passes ++
+    if remaining != budget - p.Spent() {
+      t.Error("render adaptive error 1: ", remaining, p.Spent())
+    }
+    return true
+  })
+
+  if p.Spent() != budget || passes != p.Passes {
+    t.Error("render adaptive error 2: ", p.Spent(), passes, p.Passes)
+  }
+
+  //The sky gets only the first samples, and the floor gets the rest.
+  if n := p.Samples(3, 2); n != 2 {
+    t.Error("render adaptive error 3: ", n)
+  }
+  if n := p.Samples(3, 13); n <= 20 {
+    t.Error("render adaptive error 4: ", n)
+  }
+
+  //The heat map goes from black for the fewest samples to white.
+  if min, max := p.SampleRange(); min != 2 || max <= 20 {
+    t.Error("render adaptive error 8: ", min, max)
+  }
+  heat := p.HeatMap()
+  if c := heat.At(3, 2); !test.VectorCloseEnough(c, []float64{0, 0, 0}, mat_err) {
+    t.Error("render adaptive error 5: ", c)
+  }
+
+  //The same picture is made with another number of goroutines.
+  q := NewProgressive(24, 16)
+  q.RenderAdaptive(horizonScene, horizonCamera, 2, 1, 1000, budget, 0, 4, 1, nil)
+  a, b := p.Image(), q.Image()
+  for i := range a.Pix {
+    if a.Pix[i] != b.Pix[i] {
+      t.Error("render adaptive error 6: ", i)
+      break
+    }
+  }
+
+  //If every tile is good enough after the first pass, there are no more.
+  r := NewProgressive(24, 16)
+  r.RenderAdaptive(horizonScene, horizonCamera, 2, 3, 1000, budget, 100, 4, 2, nil)
+  if r.Spent() != 24 * 16 * 4 || r.Passes != 1 {
+    t.Error("render adaptive error 7: ", r.Spent(), r.Passes)
+  }
+}
+
+func TestHeat(t *testing.T) {
+  for i, c := range [][]float64{{0, 0, 0, 0}, {.25, 0, 0, 1}, {.375, .5, 0, .5}, {1, 1, 1, 1}, {2, 1, 1, 1}} {
+    if h := heat(c[0]); !test.VectorCloseEnough(h, c[1:], mat_err) {
+      t.Error("heat error ", i, ": ", h)
+    }
+  }
+}
@@ -0,0 +1,157 @@
+package pathtrace
+
+import "math"
+import "sort"
+import "math/rand"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+
+//A bounding volume hierarchy allows a ray to be tested only against
+//the objects that it comes near. Each node is an axis-aligned box
+//that contains either its children or a few objects.
+
+//The largest number of objects in a leaf of the hierarchy.
+const maxLeafObjects = 4
+
+type boundingVolume struct {
+  min, max []float64
+  left, right *boundingVolume
+  //The indices of the objects in a leaf.
+  objects []int
+}
+
+//The parameter at which the line x + u v enters the box, or infinity if it
+//does not hit the box for any positive u.
+func rayBoxEntry(min, max, x, v []float64) float64 {
+  var enter, exit float64 = 0, math.Inf(1)
+
+  for i := 0; i < len(x); i ++ {
+    if v[i] == 0 {
+      if x[i] < min[i] || x[i] > max[i] { return math.Inf(1) }
+      continue
+    }
+
+    a := (min[i] - x[i]) / v[i]
+    b := (max[i] - x[i]) / v[i]
+    if a > b { a, b = b, a }
+    if a > enter { enter = a }
+    if b < exit { exit = b }
+    if enter > exit { return math.Inf(1) }
+  }
+
+  return enter
+}
+
+//A box and its center for each object to be put in the hierarchy.
+type boxedObject struct {
+  index int
+  min, max, center []float64
+}
+
+//Build a hierarchy by splitting the objects in half along the
+//axis in which their centers are the most spread out.
+func newBoundingVolume(boxes []*boxedObject) *boundingVolume {
+  min, max := boxes[0].min, boxes[0].max
+  for _, b := range boxes[1:] {
+    min, max = surface.BoxUnion(min, max, b.min, b.max)
+  }
+
+  if len(boxes) <= maxLeafObjects {
+    objects := make([]int, len(boxes))
+    for i, b := range boxes {
+      objects[i] = b.index
+    }
+    return &boundingVolume{min, max, nil, nil, objects}
+  }
+
+  var axis int
+  var spread float64 = -1
+  for i := 0; i < len(min); i ++ {
+    lo, hi := math.Inf(1), math.Inf(-1)
+    for _, b := range boxes {
+      lo = math.Min(lo, b.center[i])
+      hi = math.Max(hi, b.center[i])
+    }
+    if hi - lo > spread {
+      spread = hi - lo
+      axis = i
+    }
+  }
+
+  sort.Slice(boxes, func(i, j int) bool {
+    return boxes[i].center[axis] < boxes[j].center[axis]
+  })
+
+  half := len(boxes) / 2
+  return &boundingVolume{min, max,
+    newBoundingVolume(boxes[:half]), newBoundingVolume(boxes[half:]), nil}
+}
+
+//Find the nearest intersection with the objects in the hierarchy that is
+//closer than u. Objects in skip are not tested. Random numbers for
+//stochastic surfaces come from r.
+func (b *boundingVolume) intersection(r *rand.Rand, objects []*ExtendedObject, x, v []float64, skip int,
+  u float64, selected int) (float64, int) {
+  if rayBoxEntry(b.min, b.max, x, v) >= u { return u, selected }
+
+  if b.objects != nil {
+    for _, l := range b.objects {
+      if l != skip {
+        u, selected = nearestIntersection(r, objects[l], l, x, v, u, selected)
+      }
+    }
+    return u, selected
+  }
+
+  //Look in the nearer child first so that the farther one can
+  //more often be skipped.
+  first, second := b.left, b.right
+  if rayBoxEntry(second.min, second.max, x, v) < rayBoxEntry(first.min, first.max, x, v) {
+    first, second = second, first
+  }
+
+  u, selected = first.intersection(r, objects, x, v, skip, u, selected)
+  return second.intersection(r, objects, x, v, skip, u, selected)
+}
+
+//Check whether object l is hit closer than u.
+func nearestIntersection(r *rand.Rand, object *ExtendedObject, l int, x, v []float64, u float64, selected int) (float64, int) {
+  intersection := surface.RandomIntersection(r, object.surf, x, v)
+
+  //An object can return several intersection parameters, so we have to check each one.
+  for m := 0; m < len(intersection); m ++ {
+    if intersection[m] < u && intersection[m] > 0 {
+      u = intersection[m]
+      selected = l
+    }
+  }
+
+  return u, selected
+}
+
+//Sort the objects of a scene into those which can be put in a
+//hierarchy and those which are unbounded and must always be checked.
+//The hierarchy is nil if there are no bounded objects.
+func newSceneHierarchy(objects []*ExtendedObject) (*boundingVolume, []int) {
+  var boxes []*boxedObject = make([]*boxedObject, 0, len(objects))
+  var unbounded []int = make([]int, 0)
+
+  for l, object := range objects {
+    min, max := surface.BoundingBox(object.surf)
+    if !surface.FiniteBox(min, max) {
+      unbounded = append(unbounded, l)
+      continue
+    }
+
+    center := make([]float64, len(min))
+    for i := range center {
+      center[i] = (min[i] + max[i]) / 2
+    }
+    boxes = append(boxes, &boxedObject{l, min, max, center})
+  }
+
+  if len(boxes) == 0 {
+    return nil, unbounded
+  }
+
+  return newBoundingVolume(boxes), unbounded
+}
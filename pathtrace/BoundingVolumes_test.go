@@ -0,0 +1,66 @@
+package pathtrace
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+func TestRayBoxEntry(t *testing.T) {
+  min := []float64{1, 1, 1}
+  max := []float64{2, 2, 2}
+
+  if !test.CloseEnough(rayBoxEntry(min, max, []float64{0, 1.5, 1.5}, []float64{1, 0, 0}), 1, .00001) {
+    t.Error("ray box entry error 1")
+  }
+  if !math.IsInf(rayBoxEntry(min, max, []float64{0, 1.5, 1.5}, []float64{-1, 0, 0}), 1) {
+    t.Error("ray box entry error 2")
+  }
+  if !math.IsInf(rayBoxEntry(min, max, []float64{0, 0, 0}, []float64{1, 0, 0}), 1) {
+    t.Error("ray box entry error 3")
+  }
+  if rayBoxEntry(min, max, []float64{1.5, 1.5, 1.5}, []float64{0, 1, 0}) != 0 {
+    t.Error("ray box entry error 4")
+  }
+  if !test.CloseEnough(rayBoxEntry(min, max, []float64{0, 0, 0}, []float64{1, 1, 1}), 1, .00001) {
+    t.Error("ray box entry error 5")
+  }
+}
+
+//A scene with a hierarchy should find the same intersections
+//as one in which every object is checked.
+func TestBoundingVolumeHierarchy(t *testing.T) {
+  objects := make([]*ExtendedObject, 0)
+  for i := 0; i < 50; i ++ {
+    objects = append(objects, NewExtendedObject(
+      polynomialsurfaces.NewSphere(test.RandFloatVector(-10, 10, 3), test.RandFloat(.2, 2)),
+      NewGlowingObject([]float64{1, 1, 1})))
+  }
+  objects = append(objects, NewExtendedObject(
+    polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, -8}, []float64{0, 0, 1}, false),
+    NewGlowingObject([]float64{1, 1, 1})))
+
+  fast := NewScene(objects, testBackground)
+  slow := NewScene(objects, testBackground)
+  slow.bvh, slow.unbounded = nil, nil
+
+  if fast.bvh == nil || len(fast.unbounded) != 1 || fast.unbounded[0] != 50 {
+    t.Error("bounding volume hierarchy error 1")
+  }
+
+  for i := 0; i < 500; i ++ {
+    pos := test.RandFloatVector(-12, 12, 3)
+    dir := test.RandFloatVector(-1, 1, 3)
+    last := test.RandInt(-1, 50)
+
+    a := &LightRay{0, 0, append([]float64{}, pos...), append([]float64{}, dir...),
+      []float64{4, 5, 6}, []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+    b := &LightRay{0, 0, append([]float64{}, pos...), append([]float64{}, dir...),
+      []float64{4, 5, 6}, []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+
+    sa := fast.nextIntersection(a, last)
+    sb := slow.nextIntersection(b, last)
+    if sa != sb || !test.VectorCloseEnough(a.position, b.position, .00001) {
+      t.Error("bounding volume hierarchy error 2, case ", i, ": ", sa, " ", sb)
+    }
+  }
+}
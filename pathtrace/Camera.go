@@ -1,36 +1,171 @@
 package pathtrace
 
 import "math"
-import "math/rand"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
 import "github.com/DanielKrawisz/CurvedSpace/vector"
 
-//TODO allow the cameras to have a focal point. 
+//The cameras are given by a position and a matrix whose rows are the
+//directions forward, up, and right, and which may have more rows for the
+//other directions of a space of more than three dimensions. The rays of
+//a camera stay in the three-dimensional slice of the space through its
+//position which is spanned by the first three rows, so in more dimensions
+//the picture is of that slice, and the rest of the scene is seen only by
+//rays which are bent or reflected out of it. Moving the camera along the
+//other rows moves the slice through the scene, and turning the first three
+//rows toward them tilts it.
 
 func CameraMatrix(pos, look, up, right []float64) [][]float64 {
   return vector.Orthonormalize([][]float64{vector.Minus(look, pos), up, right})
 }
 
-func CameraStochastic() float64 {
-  return rand.Float64() - .5
+//Whether the camera has a position of at least three dimensions and
+//directions forward, up, and right of the same dimension.
+func validCamera(pos []float64, mtrx [][]float64) bool {
+  if len(pos) < 3 || len(mtrx) < 3 { return false }
+  for k := 0; k < 3; k ++ {
+    if len(mtrx[k]) != len(pos) { return false }
+  }
+  return true
+}
+
+//The orientation of a camera in any number of dimensions, as a square
+//matrix whose rows are orthonormal. The first three are the directions
+//forward, toward look, up, and right, as for CameraMatrix, and the rest
+//complete them with directions chosen from the axes of the space.
+//
+//May return nil, if the vectors are not all of the same dimension of at
+//least three, or if the directions forward, up, and right are not
+//independent.
+func CameraOrientation(pos, look, up, right []float64) [][]float64 {
+  n := len(pos)
+  if n < 3 || len(look) != n || len(up) != n || len(right) != n { return nil }
+
+  //The part of a vector which is perpendicular to the rows given so far.
+  var mtrx [][]float64
+  perpendicular := func(v []float64) []float64 {
+    for _, row := range mtrx {
+      v = vector.LinearSum(1, -vector.Dot(v, row), v, row)
+    }
+    return v
+  }
+
+  for _, v := range [][]float64{vector.Minus(look, pos), up, right} {
+    p := perpendicular(v)
+    l := vector.Length(p)
+    if !(l > 1e-9 * vector.Length(v)) { return nil }
+    mtrx = append(mtrx, vector.Times(1 / l, p))
+  }
+
+  //Each direction added is the axis which is farthest from
+  //those already given, which is never very near them.
+  for len(mtrx) < n {
+    var next []float64
+    var length float64
+    for i := 0; i < n; i ++ {
+      axis := make([]float64, n)
+      axis[i] = 1
+      p := perpendicular(axis)
+      if l := vector.Length(p); l > length {
+        next, length = p, l
+      }
+    }
+    mtrx = append(mtrx, vector.Times(1 / length, next))
+  }
+
+  return mtrx
+}
+
+//Turn an orientation by the given angle in radians in the plane of two of
+//its rows, turning row a toward row b. Returns a new matrix.
+//
+//May return nil, if a and b are not different rows of the matrix.
+func RotateOrientation(mtrx [][]float64, a, b int, angle float64) [][]float64 {
+  if a < 0 || b < 0 || a >= len(mtrx) || b >= len(mtrx) || a == b { return nil }
+
+  rotated := make([][]float64, len(mtrx))
+  for i, row := range mtrx {
+    rotated[i] = append([]float64{}, row...)
+  }
+
+  c, s := math.Cos(angle), math.Sin(angle)
+  rotated[a] = vector.LinearSum(c, s, mtrx[a], mtrx[b])
+  rotated[b] = vector.LinearSum(-s, c, mtrx[a], mtrx[b])
+  return rotated
 }
 
-var camJitter func() float64 = CameraStochastic
+func CameraStochastic(s distributions.Sampler) float64 {
+  return s.Float64() - .5
+}
+
+var camJitter func(distributions.Sampler) float64 = CameraStochastic
+
+func CameraCoordinates(s distributions.Sampler, i, j, pix_u, pix_v int, fov_u, fov_v float64) (float64, float64){
+  return 2 * fov_u * (float64(i) - float64(pix_u - 1)/2. + camJitter(s)) / float64(pix_u - 1),
+    -2 * fov_v * (float64(j) - float64(pix_v - 1)/2. + camJitter(s)) / float64(pix_v - 1)
+}
+
+//Where the ray of the current sample goes through its pixel, in pixels
+//to the right and down from the center of the pixel. These are the
+//offsets that CameraCoordinates takes from the first two dimensions of
+//the sampler, so the sampler must be started again before the ray is made.
+func PixelOffset(s distributions.Sampler) (float64, float64) {
+  return camJitter(s), camJitter(s)
+}
+
+//Gives the ray for pixel i, j. Where the ray goes through
+//the pixel is chosen by the sampler. A camera may stop a ray, such
+//as one which hits the edge of a lens, by giving it no direction,
+//in which case it sees black.
+type GenerateRay func(distributions.Sampler, int, int) ([]float64, []float64)
+
+//When a picture is taken, the sampler given to the camera can also choose
+//the wavelength of the ray, for cameras whose rays go a different way for
+//each wavelength, such as those which look through glass. Like
+//LightRay.SelectWavelength, it returns the wavelength in nanometers, and
+//the ray then carries only light of that wavelength.
+type WavelengthSampler interface {
+  distributions.Sampler
+  SelectWavelength() float64
+}
+
+//The sampler given to the camera can also be told the time at which the
+//ray is traced, for cameras with a shutter, so that the ray sees moving
+//objects where they are at that time.
+type TimeSampler interface {
+  distributions.Sampler
+  SetTime(t float64)
+}
 
-func CameraCoordinates(i, j, pix_u, pix_v int, fov_u, fov_v float64) (float64, float64){
-  return 2 * fov_u * (float64(i) - float64(pix_u - 1)/2. + camJitter()) / float64(pix_u - 1),
-    -2 * fov_v * (float64(j) - float64(pix_v - 1)/2. + camJitter()) / float64(pix_v - 1)
+//The sampler given to the camera for a sample of a scene. The ray
+//is made when the camera chooses its wavelength or its time.
+type cameraSampler struct {
+  distributions.Sampler
+  scene *Scene
+  ray *LightRay
 }
 
-type GenerateRay func(int, int) ([]float64, []float64)
+func (c *cameraSampler) SelectWavelength() float64 {
+  if c.ray == nil {
+    c.ray = c.scene.newRay(nil, nil)
+  }
+  return c.ray.SelectWavelength()
+}
+
+func (c *cameraSampler) SetTime(t float64) {
+  if c.ray == nil {
+    c.ray = c.scene.newRay(nil, nil)
+  }
+  c.ray.time = t
+}
 
 //The camera rays are given by evenly-spaced points on a grid on a plane. 
 func IsometricCamera(pos []float64, mtrx [][]float64, pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
-    for k := 0; k < 3; k ++ {
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
+    for k := 0; k < len(pos); k ++ {
       ray_pos[k] = pos[k] + ov * mtrx[1][k] + ou * mtrx[2][k]
       ray_dir[k] = mtrx[0][k] + ov * mtrx[1][k] + ou * mtrx[2][k]
     }
@@ -40,12 +175,12 @@ func IsometricCamera(pos []float64, mtrx [][]float64, pix_u, pix_v int, fov_u, f
 
 //The camera rays are given by evenly-spaced points on a grid on a plane. 
 func FlatCamera(pos []float64, mtrx [][]float64, pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
-    for k := 0; k < 3; k ++ {
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
+    for k := 0; k < len(pos); k ++ {
       ray_pos[k] = pos[k]
       ray_dir[k] = mtrx[0][k] + ov * mtrx[1][k] + ou * mtrx[2][k]
     }
@@ -57,12 +192,12 @@ func FlatCamera(pos []float64, mtrx [][]float64, pix_u, pix_v int, fov_u, fov_v
 //(Simulates a pinhole camera)
 func InverseFlatCamera(pos []float64, mtrx [][]float64,
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
-    for k := 0; k < 3; k ++ {
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
+    for k := 0; k < len(pos); k ++ {
       ray_dir[k] = -mtrx[0][k] - ov * mtrx[1][k] - ou * mtrx[2][k]
       ray_pos[k] = pos[k] - ray_dir[k]
     }
@@ -74,12 +209,12 @@ func InverseFlatCamera(pos []float64, mtrx [][]float64,
 //and equal distances up and down it. 
 func CylindricalCamera(pos []float64, mtrx [][]float64,
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
-    for k := 0; k < 3; k ++ {
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
+    for k := 0; k < len(pos); k ++ {
       ray_pos[k] = pos[k]
       ray_dir[k] = math.Cos(ou) * mtrx[0][k] + ov * mtrx[1][k] + math.Sin(ou) * mtrx[2][k]
     }
@@ -90,12 +225,12 @@ func CylindricalCamera(pos []float64, mtrx [][]float64,
 //Inverse of the cylindrical camera. 
 func InverseCylindricalCamera(pos []float64, mtrx [][]float64,
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
-    for k := 0; k < 3; k ++ {
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
+    for k := 0; k < len(pos); k ++ {
       ray_dir[k] = -math.Cos(ou) * mtrx[0][k] - ov * mtrx[1][k] - math.Sin(ou) * mtrx[2][k]
       ray_pos[k] = pos[k] - ray_dir[k]
     }
@@ -107,12 +242,12 @@ func InverseCylindricalCamera(pos []float64, mtrx [][]float64,
 //and equal distances up and down it. 
 func IsometricCylindricalCamera(pos []float64, mtrx [][]float64,
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
-    for k := 0; k < 3; k ++ {
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
+    for k := 0; k < len(pos); k ++ {
       ray_pos[k] = pos[k] + ov * mtrx[1][k]
       ray_dir[k] = math.Cos(ou) * mtrx[0][k] + math.Sin(ou) * mtrx[2][k]
     }
@@ -122,12 +257,12 @@ func IsometricCylindricalCamera(pos []float64, mtrx [][]float64,
 
 func InverseIsometricCylindricalCamera(pos []float64, mtrx [][]float64,
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
-    for k := 0; k < 3; k ++ {
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
+    for k := 0; k < len(pos); k ++ {
       ray_dir[k] = - math.Cos(ou) * mtrx[0][k] - math.Sin(ou) * mtrx[2][k]
       ray_pos[k] = -ray_dir[k] + pos[k] + ov * mtrx[1][k]
     }
@@ -139,13 +274,13 @@ func InverseIsometricCylindricalCamera(pos []float64, mtrx [][]float64,
 //spaced lines of lattitude and longetude. 
 func PolarSphericalCamera(pos []float64, mtrx [][]float64,
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
     c := math.Cos(ov)
-    for k := 0; k < 3; k ++ {
+    for k := 0; k < len(pos); k ++ {
       ray_pos[k] = pos[k]
       ray_dir[k] = math.Cos(ou) * c * mtrx[0][k] +
         math.Sin(ov) * mtrx[1][k] + math.Sin(ou) * c * mtrx[2][k]
@@ -156,13 +291,13 @@ func PolarSphericalCamera(pos []float64, mtrx [][]float64,
 
 func InversePolarSphericalCamera(pos []float64, mtrx [][]float64,
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
     c := math.Cos(ov)
-    for k := 0; k < 3; k ++ {
+    for k := 0; k < len(pos); k ++ {
       ray_dir[k] = -(math.Cos(ou) * c * mtrx[0][k] +
         math.Sin(ov) * mtrx[1][k] + math.Sin(ou) * c * mtrx[2][k])
       ray_pos[k] = -ray_dir[k] + pos[k]
@@ -178,18 +313,18 @@ func InversePolarSphericalCamera(pos []float64, mtrx [][]float64,
 //the direction of the camera ray. 
 func SphericalCamera(pos []float64, mtrx [][]float64, 
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
     cv  := math.Cos(ov)
     cu  := math.Cos(ou)
     sv  := math.Sin(ov)
     su  := math.Sin(ou)
     gz  := math.Sqrt(cu * cu * cv * cv + su * su)
 
-    for k := 0; k < 3; k ++ {
+    for k := 0; k < len(pos); k ++ {
       ray_pos[k] = pos[k]
       ray_dir[k] = (cu * cv * mtrx[0][k] + su * sv * mtrx[1][k] + cv * su * mtrx[2][k]) / gz
     }
@@ -201,18 +336,18 @@ func SphericalCamera(pos []float64, mtrx [][]float64,
 //pinhole camera. 
 func InverseSphericalCamera(pos []float64, mtrx [][]float64, 
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
-  if pos == nil || mtrx == nil { return nil }
+  if !validCamera(pos, mtrx) { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
     cv  := math.Cos(ov)
     cu  := math.Cos(ou)
     sv  := math.Sin(ov)
     su  := math.Sin(ou)
     gz  := math.Sqrt(cu * cu * cv * cv + su * su)
 
-    for k := 0; k < 3; k ++ {
+    for k := 0; k < len(pos); k ++ {
       ray_dir[k] = -(cu * cv * mtrx[0][k] + su * sv * mtrx[1][k] + cv * su * mtrx[2][k]) / gz
       ray_pos[k] = pos[k] - ray_dir[k]
     }
@@ -247,8 +382,8 @@ func ToroidialCamera(pos []float64, R [][]float64, r [][]float64, pix_u, pix_v i
   R1r0 := vector.Dot(R[1], r[0]) / norm
   R2r0 := vector.Dot(R2, r[0]) / quad
 
-  return func(i, j int) ([]float64, []float64) {
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u + 1, pix_v + 1, math.Pi, math.Pi)
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u + 1, pix_v + 1, math.Pi, math.Pi)
 
     cu := math.Cos(ou)
     su := math.Sin(ou)
@@ -282,8 +417,8 @@ func InverseToroidialCamera(pos []float64, R [][]float64, r [][]float64, pix_u,
   R1r0 := vector.Dot(R[1], r[0]) / norm
   R2r0 := vector.Dot(R2, r[0]) / quad
 
-  return func(i, j int) ([]float64, []float64) {
-    var ou, ov float64 = CameraCoordinates(i, j, pix_u + 1, pix_v + 1, math.Pi, math.Pi)
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    var ou, ov float64 = CameraCoordinates(s, i, j, pix_u + 1, pix_v + 1, math.Pi, math.Pi)
 
     cu := math.Cos(ou)
     su := math.Sin(ou)
@@ -301,12 +436,102 @@ func InverseToroidialCamera(pos []float64, R [][]float64, r [][]float64, pix_u,
   }
 }
 
+//A thin lens in front of any camera, which blurs what is not in focus.
+//The ray that the camera gives for each sample is made to start from a
+//point on the lens chosen by the sampler, and to go through the point
+//that it reaches after going focus times its direction, which stays in
+//focus. For the flat camera, whose rays go one unit forward for each
+//unit of their direction, these points make a plane at distance focus in
+//front of the camera. For the others, they are curved as the picture is.
+//
+//The lens is a disk with the given radius, or if blades is three or more,
+//a regular polygon with that many sides inscribed in the disk, like the
+//aperture of a real lens, which gives the shape of the polygon to points
+//of light that are out of focus. The polygon is turned by rotation radians
+//from right toward up, which are made perpendicular to each ray to give
+//the directions on the lens, so neither may be parallel to any ray.
+//
+//May return nil.
+func ThinLens(cam GenerateRay, up, right []float64, aperture, focus float64, blades int, rotation float64) GenerateRay {
+  if cam == nil || up == nil || right == nil || len(up) != len(right) { return nil }
+  if aperture < 0 || !(focus > 0) { return nil }
+  if aperture == 0 { return cam }
+
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    ray_pos, ray_dir := cam(s, i, j)
+    if ray_dir == nil { return ray_pos, nil }
+    a, b := LensPoint(s, blades, rotation)
+
+    //Directions on the lens, which are perpendicular to the ray.
+    lens := vector.Orthonormalize([][]float64{vector.Normalize(append([]float64{}, ray_dir...)),
+      append([]float64{}, right...), append([]float64{}, up...)})
+
+    //The ray goes to the same point at focus times its direction
+    //from where it started, and is scaled back to its length.
+    offset := vector.LinearSum(aperture * a, aperture * b, lens[1], lens[2])
+    return vector.Plus(ray_pos, offset), vector.LinearSum(1, -1 / focus, ray_dir, offset)
+  }
+}
+
+//A camera whose shutter is open from time open to time close, which
+//blurs what moves while it is open. Each ray is given a time chosen
+//uniformly between them by the next dimension of the sampler, if the
+//sampler is a TimeSampler. Otherwise the rays are the camera's.
+//
+//May return nil.
+func Shutter(cam GenerateRay, open, close float64) GenerateRay {
+  if cam == nil || !(close >= open) { return nil }
+
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    ray_pos, ray_dir := cam(s, i, j)
+    t := open + (close - open) * s.Float64()
+    if ts, ok := s.(TimeSampler); ok {
+      ts.SetTime(t)
+    }
+    return ray_pos, ray_dir
+  }
+}
+
+//A point chosen uniformly from the unit disk by the next two dimensions of
+//the sampler, or if blades is three or more, from the regular polygon with
+//that many sides inscribed in the disk and turned by rotation radians.
+func LensPoint(s distributions.Sampler, blades int, rotation float64) (float64, float64) {
+  u, v := s.Float64(), s.Float64()
+
+  if blades < 3 {
+    //The concentric mapping of Shirley and Chiu, A Low Distortion Map
+    //Between Disk and Square, 1997, which keeps evenly spread points
+    //evenly spread.
+    a, b := 2 * u - 1, 2 * v - 1
+    if a == 0 && b == 0 { return 0, 0 }
+
+    var r, phi float64
+    if math.Abs(a) > math.Abs(b) {
+      r, phi = a, math.Pi / 4 * b / a
+    } else {
+      r, phi = b, math.Pi / 2 - math.Pi / 4 * a / b
+    }
+    return r * math.Cos(phi), r * math.Sin(phi)
+  }
+
+  //The polygon is made of triangles which meet in the middle. One is
+  //chosen by u, and the rest of u and v choose a point in it.
+  n := float64(blades)
+  k := math.Floor(u * n)
+  u = u * n - k
+  r := math.Sqrt(v)
+
+  a0 := rotation + 2 * math.Pi * k / n
+  a1 := a0 + 2 * math.Pi / n
+  return r * ((1 - u) * math.Cos(a0) + u * math.Cos(a1)), r * ((1 - u) * math.Sin(a0) + u * math.Sin(a1))
+}
+
 //Another crazy concept. (requires polygonal surface first)
 /*func TrapezoidalCamera(pos []float64, mtrx [][]float64,
   pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
   if pos == nil || mtrx == nil { return nil }
 
-  return func(i, j int) ([]float64, []float64) {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
     ray_pos, ray_dir := make([]float64, len(pos)), make([]float64, len(pos))
     var ou, ov float64 = camCoordinates(i, j, pix_u, pix_v, fov_u, fov_v)
     for k := 0; k < 3; k ++ {
@@ -1,6 +1,8 @@
 package pathtrace
 
 import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
 import "github.com/DanielKrawisz/CurvedSpace/test"
 import "github.com/DanielKrawisz/CurvedSpace/vector"
 
@@ -14,7 +16,7 @@ type camTestCase struct {
 }
 
 //A mocking function to make testing easier. 
-func MockCameraStochastic() float64 {
+func MockCameraStochastic(s distributions.Sampler) float64 {
   return 0
 }
 
@@ -23,8 +25,8 @@ func cameraTest(kind string, test_cases []*camTestCase, cam, cam_inv GenerateRay
   zero := []float64{0, 0, 0}
 
   for i, test_case := range test_cases {
-    pos, dir := cam(test_case.i, test_case.j) 
-    pos_inv, dir_inv := cam_inv(test_case.i, test_case.j)
+    pos, dir := cam(nil, test_case.i, test_case.j) 
+    pos_inv, dir_inv := cam_inv(nil, test_case.i, test_case.j)
 
     if !test.VectorCloseEnough(test_case.pos, pos, cam_err) ||
       !test.VectorCloseEnough(test_case.dir, dir, cam_err) {
@@ -46,19 +48,19 @@ func TestCameraCoordinates(t *testing.T) {
   var ou, ov float64
   camJitter = MockCameraStochastic
 
-  ou, ov = CameraCoordinates(1, 1, 3, 3, 1, 1)
+  ou, ov = CameraCoordinates(nil, 1, 1, 3, 3, 1, 1)
   if !(test.CloseEnough(ou, 0, cam_err) && test.CloseEnough(ov, 0, cam_err)) {
     t.Error("camera coordinates error, case 1, got ", ou, ov)
   }
-  ou, ov = CameraCoordinates(0, 1, 3, 3, 1, 1)
+  ou, ov = CameraCoordinates(nil, 0, 1, 3, 3, 1, 1)
   if !(test.CloseEnough(ou, -1, cam_err) && test.CloseEnough(ov, 0, cam_err)) {
     t.Error("camera coordinates error, case 2, got ", ou, ov)
   }
-  ou, ov = CameraCoordinates(1, 0, 3, 3, 1, 1)
+  ou, ov = CameraCoordinates(nil, 1, 0, 3, 3, 1, 1)
   if !(test.CloseEnough(ou, 0, cam_err) && test.CloseEnough(ov, 1, cam_err)) {
     t.Error("camera coordinates error, case 3, got ", ou, ov)
   }
-  ou, ov = CameraCoordinates(2, 2, 3, 3, 1.3, 1.7)
+  ou, ov = CameraCoordinates(nil, 2, 2, 3, 3, 1.3, 1.7)
   if !(test.CloseEnough(ou, 1.3, cam_err) && test.CloseEnough(ov, -1.7, cam_err)) {
     t.Error("camera coordinates error, case 3, got ", ou, ov)
   }
@@ -91,7 +93,7 @@ func TestIsometricCamera(t *testing.T) {
     &camTestCase{2, 2, []float64{0.1, -1.84, 2.06}, []float64{1.3, -2.04, 1.76}}}
 
   for i, test_case := range test_cases {
-    pos, dir := cam(test_case.i, test_case.j) 
+    pos, dir := cam(nil, test_case.i, test_case.j) 
 
     if !test.VectorCloseEnough(test_case.pos, pos, cam_err) ||
       !test.VectorCloseEnough(test_case.dir, dir, cam_err) {
@@ -339,3 +341,214 @@ func TestToroidialCamera(t *testing.T) {
 /*func TestTrapezoidalCamera(t *testing.T) {
   
 }*/
+
+func TestThinLens(t *testing.T) {
+  camJitter = MockCameraStochastic
+
+  pos  := []float64{.1, .2, .3}
+  mtrx := [][]float64{[]float64{1, 0, 0}, []float64{0, 1, 0}, []float64{0, 0, 1}}
+  up, right := []float64{0, 1, 0}, []float64{0, 0, 1}
+  cam := FlatCamera(pos, mtrx, 3, 3, 1.6, 1.7)
+
+  if ThinLens(nil, up, right, .1, 2, 0, 0) != nil { t.Error("thin lens error 1") }
+  if ThinLens(cam, nil, right, .1, 2, 0, 0) != nil { t.Error("thin lens error 2") }
+  if ThinLens(cam, up, []float64{0, 1}, .1, 2, 0, 0) != nil { t.Error("thin lens error 3") }
+  if ThinLens(cam, up, right, -.1, 2, 0, 0) != nil { t.Error("thin lens error 4") }
+  if ThinLens(cam, up, right, .1, 0, 0, 0) != nil { t.Error("thin lens error 5") }
+
+  //With no aperture, the camera is a pinhole.
+  pinhole := ThinLens(cam, up, right, 0, 2, 0, 0)
+  if p, d := pinhole(nil, 0, 0); !test.VectorCloseEnough(p, pos, cam_err) ||
+    !test.VectorCloseEnough(d, []float64{1, 1.7, -1.6}, cam_err) {
+    t.Error("thin lens error 6: ", p, d)
+  }
+
+  //Every ray starts on the lens, which is perpendicular
+  //to the ray of the pinhole, and goes through the point in focus.
+  _, dir := cam(nil, 0, 0)
+  s := distributions.NewRandomSampler(1)
+  for _, blades := range []int{0, 6} {
+    lens := ThinLens(cam, up, right, .1, 2, blades, .3)
+    for k := 0; k < 100; k ++ {
+      p, d := lens(s, 0, 0)
+      offset := vector.Minus(p, pos)
+      if !test.CloseEnough(vector.Dot(offset, dir), 0, cam_err) || vector.Length(offset) > .1 + cam_err {
+        t.Error("thin lens error 7: ", blades, p)
+      }
+      if !test.VectorCloseEnough(vector.LinearSum(1, 2, p, d), []float64{2.1, 3.6, -2.9}, cam_err) {
+        t.Error("thin lens error 8: ", blades, p, d)
+      }
+    }
+  }
+
+  camJitter = CameraStochastic
+}
+
+func TestShutter(t *testing.T) {
+  cam := FlatCamera([]float64{0, 0, 0}, [][]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}, 3, 3, 1, 1)
+  if Shutter(nil, 0, 1) != nil || Shutter(cam, 1, 0) != nil {
+    t.Error("shutter error 1")
+  }
+
+  //The rays are the camera's, and are given times while the shutter is open.
+  camJitter = MockCameraStochastic
+  shutter := Shutter(cam, .5, 1.5)
+  scene := progressiveScene()
+  var mean float64
+  for k := 0; k < 1000; k ++ {
+    cs := &cameraSampler{scene.sampler, scene, nil}
+    p, d := shutter(cs, 1, 1)
+    if !test.VectorCloseEnough(p, []float64{0, 0, 0}, cam_err) || !test.VectorCloseEnough(d, []float64{1, 0, 0}, cam_err) {
+      t.Error("shutter error 2: ", p, d)
+    }
+    if cs.ray == nil || !(cs.ray.Time() >= .5 && cs.ray.Time() <= 1.5) {
+      t.Error("shutter error 3")
+      break
+    }
+    mean += cs.ray.Time() / 1000
+  }
+  if !test.CloseEnough(mean, 1, .05) {
+    t.Error("shutter error 4: ", mean)
+  }
+  camJitter = CameraStochastic
+}
+
+func TestLensPoint(t *testing.T) {
+  s := distributions.NewRandomSampler(1)
+
+  for _, blades := range []int{0, 2, 3, 5, 8} {
+    //The corners of the polygon are at radius one, so its sides
+    //are cos(pi / n) from the center.
+    n := float64(blades)
+    side := math.Cos(math.Pi / n)
+
+    var mean [2]float64
+    for k := 0; k < 1000; k ++ {
+      a, b := LensPoint(s, blades, .4)
+      mean[0] += a / 1000
+      mean[1] += b / 1000
+
+      if a * a + b * b > 1 + cam_err {
+        t.Error("lens point error 1: ", blades, a, b)
+      }
+      if blades < 3 { continue }
+      for i := 0; i < blades; i ++ {
+        phi := .4 + math.Pi * (2 * float64(i) + 1) / n
+        if a * math.Cos(phi) + b * math.Sin(phi) > side + cam_err {
+          t.Error("lens point error 2: ", blades, a, b)
+        }
+      }
+    }
+
+    if math.Abs(mean[0]) > .1 || math.Abs(mean[1]) > .1 {
+      t.Error("lens point error 3: ", blades, mean)
+    }
+  }
+}
+
+func TestCameraOrientation(t *testing.T) {
+  if CameraOrientation([]float64{0, 0}, []float64{1, 0}, []float64{0, 1}, []float64{1, 1}) != nil ||
+    CameraOrientation([]float64{0, 0, 0, 0}, []float64{1, 0, 0}, []float64{0, 1, 0}, []float64{0, 0, 1}) != nil ||
+    CameraOrientation([]float64{0, 0, 0, 0}, []float64{2, 0, 0, 0}, []float64{1, 0, 0, 0}, []float64{0, 0, 1, 0}) != nil ||
+    CameraOrientation([]float64{0, 0, 0, 0}, []float64{1, 0, 0, 0}, []float64{0, 1, 0, 0}, []float64{1, 1, 0, 0}) != nil {
+    t.Error("camera orientation error 1")
+  }
+
+  //In three dimensions, it is the camera matrix.
+  pos, look, up, right := []float64{1, 2, 3}, []float64{3, 1, 2}, []float64{.2, 1, .1}, []float64{.1, .3, 1}
+  expected := CameraMatrix(pos, look, append([]float64{}, up...), append([]float64{}, right...))
+  mtrx := CameraOrientation(pos, look, up, right)
+  if len(mtrx) != 3 {
+    t.Error("camera orientation error 2: ", mtrx)
+    return
+  }
+  for k := 0; k < 3; k ++ {
+    if !test.VectorCloseEnough(mtrx[k], expected[k], cam_err) {
+      t.Error("camera orientation error 3: ", k, mtrx[k], expected[k])
+    }
+  }
+
+  //In more dimensions, it is completed with the other axes.
+  pos, look = []float64{1, 2, 3, 4, 5}, []float64{1, 2, 3, 7, 5}
+  up, right = []float64{0, 2, 0, 0, 0}, []float64{1, 1, 0, 1, 0}
+  mtrx = CameraOrientation(pos, look, up, right)
+  if len(mtrx) != 5 {
+    t.Error("camera orientation error 4: ", mtrx)
+    return
+  }
+  for i := 0; i < 5; i ++ {
+    for j := 0; j < 5; j ++ {
+      d := 0.
+      if i == j {
+        d = 1
+      }
+      if !test.CloseEnough(vector.Dot(mtrx[i], mtrx[j]), d, cam_err) {
+        t.Error("camera orientation error 5: ", i, j, mtrx)
+      }
+    }
+  }
+  for k, expected := range [][]float64{{0, 0, 0, 1, 0}, {0, 1, 0, 0, 0}, {1, 0, 0, 0, 0}} {
+    if !test.VectorCloseEnough(mtrx[k], expected, cam_err) {
+      t.Error("camera orientation error 6: ", k, mtrx[k])
+    }
+  }
+}
+
+func TestRotateOrientation(t *testing.T) {
+  mtrx := [][]float64{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+  if RotateOrientation(mtrx, 1, 1, 1) != nil || RotateOrientation(mtrx, 0, 4, 1) != nil ||
+    RotateOrientation(mtrx, -1, 2, 1) != nil {
+    t.Error("rotate orientation error 1")
+  }
+
+  r := RotateOrientation(mtrx, 0, 3, math.Pi / 2)
+  for k, expected := range [][]float64{{0, 0, 0, 1}, {0, 1, 0, 0}, {0, 0, 1, 0}, {-1, 0, 0, 0}} {
+    if !test.VectorCloseEnough(r[k], expected, cam_err) {
+      t.Error("rotate orientation error 2: ", k, r[k])
+    }
+  }
+  if mtrx[0][0] != 1 || mtrx[3][3] != 1 {
+    t.Error("rotate orientation error 3: ", mtrx)
+  }
+}
+
+//In more than three dimensions, the rays of the cameras stay
+//in the slice through the camera spanned by its first three rows.
+func TestCameraDimensions(t *testing.T) {
+  cameras := []func([]float64, [][]float64, int, int, float64, float64) GenerateRay{
+    IsometricCamera, FlatCamera, InverseFlatCamera, CylindricalCamera, InverseCylindricalCamera,
+    IsometricCylindricalCamera, InverseIsometricCylindricalCamera, PolarSphericalCamera,
+    InversePolarSphericalCamera, SphericalCamera, InverseSphericalCamera}
+
+  pos := []float64{.1, .2, .3, .4}
+  mtrx := RotateOrientation([][]float64{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}, 2, 3, .3)
+  s := distributions.NewRandomSampler(1)
+  for i, camera := range cameras {
+    if camera(pos, mtrx[:2], 3, 3, .7, .5) != nil || camera(pos[:2], mtrx, 3, 3, .7, .5) != nil ||
+      camera(pos, [][]float64{mtrx[0], mtrx[1], {0, 0, 1}}, 3, 3, .7, .5) != nil {
+      t.Error("camera dimensions error 1: ", i)
+    }
+
+    cam := camera(pos, mtrx, 3, 3, .7, .5)
+    if cam == nil {
+      t.Error("camera dimensions error 2: ", i)
+      continue
+    }
+
+    for k := 0; k < 9; k ++ {
+      p, d := cam(s, k % 3, k / 3)
+      if len(p) != 4 || len(d) != 4 || !test.CloseEnough(vector.Dot(vector.Minus(p, pos), mtrx[3]), 0, cam_err) ||
+        !test.CloseEnough(vector.Dot(d, mtrx[3]), 0, cam_err) {
+        t.Error("camera dimensions error 3: ", i, p, d)
+      }
+    }
+  }
+
+  //The slice is tilted toward the fourth dimension.
+  camJitter = MockCameraStochastic
+  _, d := FlatCamera(pos, mtrx, 3, 3, .7, .5)(nil, 2, 1)
+  if !test.VectorCloseEnough(d, []float64{1, 0, .7 * math.Cos(.3), .7 * math.Sin(.3)}, cam_err) {
+    t.Error("camera dimensions error 4: ", d)
+  }
+  camJitter = CameraStochastic
+}
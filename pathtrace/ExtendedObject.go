@@ -3,6 +3,7 @@ package pathtrace
 import "math"
 import "github.com/DanielKrawisz/CurvedSpace/surface"
 import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
 
 type InteractionFunction func([]float64) Interactor
 
@@ -10,18 +11,25 @@ type InteractionFunction func([]float64) Interactor
 type ExtendedObject struct {
   surf surface.Surface
   interactor InteractionFunction
+  //The color of an object that just glows, which can be used as a light.
+  glow []float64
 }
 
 func NewExtendedObject(surf surface.Surface, interactor Interactor) *ExtendedObject {
   if surf == nil || interactor == nil { return nil }
 
-  return &ExtendedObject{surf, func ([]float64) Interactor { return interactor }}
+  var glow []float64
+  if g, ok := interactor.(*glowEmitter); ok {
+    glow = g.glow
+  }
+
+  return &ExtendedObject{surf, func ([]float64) Interactor { return interactor }, glow}
 }
 
 func NewTexturedExtendedObject(surf surface.Surface, interactor InteractionFunction) *ExtendedObject {
   if surf == nil || interactor == nil { return nil }
 
-  return &ExtendedObject{surf, interactor}
+  return &ExtendedObject{surf, interactor, nil}
 }
 
 //A set of objects of which a picture can be taken. 
@@ -29,67 +37,279 @@ func NewTexturedExtendedObject(surf surface.Surface, interactor InteractionFunct
 type Scene struct {
   objects []*ExtendedObject
   background color.SphericalColorFunction
+  //If the scene is in a curved space, light rays follow geodesics.
+  //Otherwise they go in straight lines.
+  curved *geodesicTracer
+  //A hierarchy of the objects which have bounding boxes, and a list of
+  //those which do not. If both are nil, every object is checked.
+  bvh *boundingVolume
+  unbounded []int
+  //The number of wavelengths that each ray tracks, or zero for rgb.
+  wavelengths int
+  //The glowing objects toward which shadow rays are sent.
+  lights []*light
+  //The number of bounces after which paths are ended by Russian
+  //roulette, or zero if they are not.
+  roulette int
+  //Gives the random numbers used to trace rays.
+  sampler distributions.Sampler
+  //How the samples are splatted onto the pixels of a picture.
+  filter *Filter
+  //The objects which move, which are put where they are
+  //at the time of each ray before it is traced.
+  moving []surface.Moving
+}
+
+//Trace each ray with n wavelengths rather than in rgb. Spectral rays show
+//things like dispersion, but each wavelength adds to the noise in the color
+//of a pixel. If n is zero, rays are traced in rgb.
+func (scene *Scene) SetSpectral(n int) {
+  if n < 0 {n = 0}
+  scene.wavelengths = n
+}
+
+//After minDepth bounces, end paths at random with a chance that goes up as
+//the light they carry goes down, and make up for it by brightening the paths
+//that go on. This is unbiased, unlike ending every path once it carries little
+//light, so mirrors and glass are not made darker than they should be. Paths
+//still end after the greatest depth given to TracePath. If minDepth is zero,
+//paths are not ended by roulette.
+func (scene *Scene) SetRussianRoulette(minDepth int) {
+  if minDepth < 0 {minDepth = 0}
+  scene.roulette = minDepth
 }
 
+//Trace rays with numbers from s, such as a Sobol sampler, which are spread
+//out more evenly than random numbers so that pictures are less grainy. A
+//scene should only be used by one goroutine at a time, because a sampler
+//remembers where it is. When a picture is taken, the sampler is started
+//again for each sample of each pixel, so the picture depends only on the
+//seed of the sampler. By default, the scene uses random numbers with a
+//seed of zero.
+func (scene *Scene) SetSampler(s distributions.Sampler) {
+  if s == nil {
+    s = distributions.NewRandomSampler(0)
+  }
+  scene.sampler = s
+}
+
+//Splat the samples of a picture onto the pixels around them with f. If f
+//is nil, the box filter is used, and each pixel is the mean of its own
+//samples, as it is by default.
+func (scene *Scene) SetFilter(f *Filter) {
+  if f == nil {
+    f = NewFilter("box", 0)
+  }
+  scene.filter = f
+}
+
+//The objects which have bounding boxes are put into a bounding volume
+//hierarchy so that a ray is only checked against the objects it comes near.
 func NewScene(objects []*ExtendedObject, background color.SphericalColorFunction) *Scene {
   if objects == nil || background == nil { return nil }
 
-  return &Scene{objects, background}
+  bvh, unbounded := newSceneHierarchy(objects)
+  return &Scene{objects: objects, background: background, bvh: bvh, unbounded: unbounded,
+    lights: findLights(objects), sampler: distributions.NewRandomSampler(0), filter: NewFilter("box", 0),
+    moving: findMoving(objects)}
+}
+
+//The surfaces which move, including those inside other surfaces.
+func findMoving(objects []*ExtendedObject) []surface.Moving {
+  var moving []surface.Moving
+  for _, object := range objects {
+    moving = append(moving, surface.MovingParts(object.surf)...)
+  }
+  return moving
+}
+
+//Find the next object that the ray hits along a straight line and move
+//the ray there. Returns -1 if there is no such object.
+func (scene *Scene) nextIntersection(ray *LightRay, last int) int {
+  var u float64 = math.Inf(1)
+  var selected int = -1
+  r := ray.random()
+
+  //check every shape for intersection, except not the last one,
+  //since the ray is right on the surface.
+  if scene.bvh == nil && scene.unbounded == nil {
+    for l, object := range scene.objects {
+      if l != last {
+        u, selected = nearestIntersection(r, object, l, ray.position, ray.direction, u, selected)
+      }
+    }
+  } else {
+    for _, l := range scene.unbounded {
+      if l != last {
+        u, selected = nearestIntersection(r, scene.objects[l], l, ray.position, ray.direction, u, selected)
+      }
+    }
+
+    if scene.bvh != nil {
+      u, selected = scene.bvh.intersection(r, scene.objects, ray.position, ray.direction, last, u, selected)
+    }
+  }
+
+  if selected != -1 {
+    ray.Trace(u)
+  }
+
+  return selected
 }
 
 //Traces a light ray through a scene. 
 func (scene *Scene) TracePath(pos, dir []float64, max_depth int, receptor_tolerance float64) []float64 {
+  c, _ := scene.tracePath(pos, dir, max_depth, receptor_tolerance, nil)
+  return c
+}
+
+//Traces a light ray through a scene and returns its color along
+//with the features of what it hit first.
+func (scene *Scene) TraceFeatures(pos, dir []float64, max_depth int, receptor_tolerance float64) ([]float64, *Features) {
+  f := &Features{}
+  c, _ := scene.tracePath(pos, dir, max_depth, receptor_tolerance, f)
+  return c, f
+}
+
+//Traces a light ray through a scene and returns its color along with
+//the number of objects that it hit. If features is not nil, the features
+//of what the ray hit first are written to it.
+func (scene *Scene) tracePath(pos, dir []float64, max_depth int, receptor_tolerance float64, features *Features) ([]float64, int) {
+  return scene.trace(scene.newRay(pos, dir), max_depth, receptor_tolerance, features)
+}
+
+//A ray which has not yet interacted with anything, which carries
+//the wavelengths of the scene.
+func (scene *Scene) newRay(pos, dir []float64) *LightRay {
+  ray := &LightRay{0, 0, pos, dir, color.RGBReceptor, []float64{1, 1, 1}, []float64{0, 0, 0}, 1, scene.sampler, 0}
+  if scene.wavelengths > 0 {
+    ray.receptor = color.SampleWavelengths(scene.wavelengths, scene.sampler.Float64())
+    ray.color = make([]float64, scene.wavelengths)
+    ray.emission = make([]float64, scene.wavelengths)
+    for i := range ray.color {
+      ray.color[i] = 1
+    }
+  }
+  if scene.curved != nil {
+    ray.region = scene.curved.region
+  }
+  return ray
+}
+
+//Like tracePath, but for a ray which has already been made. A ray with
+//no direction was stopped by the camera, and sees nothing.
+func (scene *Scene) trace(ray *LightRay, max_depth int, receptor_tolerance float64, features *Features) ([]float64, int) {
   var last int = - 1
+  var length int
 
-  ray := &LightRay{0, pos, dir, []float64{4, 5, 6}, []float64{1, 1, 1}, []float64{0, 0, 0}, 1}
+  //Where the ray starts, since the ray is moved as it is traced.
+  var start []float64
+  if features != nil {
+    *features = Features{[]float64{0, 0, 0}, []float64{0, 0, 0}, 0, -1}
+    start = append([]float64(nil), ray.position...)
+  }
+
+  if ray.direction == nil {
+    return []float64{0, 0, 0}, 0
+  }
+
+  for _, m := range scene.moving {
+    m.SetTime(ray.time)
+  }
 
-  var u float64
   var s Interactor
   var selected int
 
+  //The density with which the last interaction chose the direction of
+  //the ray if it was a Scatterer, so that light the ray finds can be
+  //weighted against light found by shadow rays, and where it was.
+  var pdf float64
+  var from []float64
+
   //Follow the ray for max_depth bounces. 
-  //TODO make each bounce a separate function call.
   for ray.depth = 0; ray.depth < max_depth; ray.depth ++ {
-    u = math.Inf(1)
-    selected = -1
-
-    //check every shape for intersection. 
-    for l, object := range scene.objects {
-      if l != last { //Except not the last one, since the ray is right on the surface.
-        intersection := object.surf.Intersection(ray.position, ray.direction)
-
-        //An object can return several intersection parameters, so we have to check each one.
-        for m := 0; m < len(intersection); m ++ {
-          if intersection[m] < u && intersection[m] > 0 {
-            u = intersection[m]
-            selected = l
-          }
-        }
-      }
+    if scene.curved == nil {
+      selected = scene.nextIntersection(ray, last)
+    } else {
+      selected = scene.nextGeodesicIntersection(ray, last)
     }
 
     if selected == -1 { //The ray has diverged to infinity.
       last = -1
-      bg := scene.background(ray.direction)(ray.receptor)
-      for i := 0; i < 3; i ++ {
+      bg := scene.backgroundIn(ray.region)(ray.direction)(ray.receptor)
+      if features != nil && ray.depth == 0 {
+        features.Albedo = scene.rgb(ray, bg)
+      }
+      for i := 0; i < len(ray.color); i ++ {
         ray.color[i] *= bg[i]
       }
       break
     }
 
+    if selected == absorbed { //The ray has fallen into a black hole.
+      for i := 0; i < len(ray.color); i ++ {
+        ray.color[i] = 0
+      }
+      break
+    }
+
     //The ray has interacted with something.
-    ray.Trace(u)
     s = scene.objects[selected].interactor(ray.position)
     last = selected
 
+    if pdf > 0 {
+      if p := scene.lightPdf(selected, from); p > 0 {
+        w := misWeight(pdf, p)
+        for i := 0; i < len(ray.color); i ++ {
+          ray.color[i] *= w
+        }
+      }
+    }
+
+    in := make([]float64, len(ray.direction))
+    copy(in, ray.direction)
+
+    if features != nil && ray.depth == 0 {
+      features.hit(scene.objects[selected].surf, selected, start, ray.position, in)
+    }
+
     //Interact with the object that the ray intersected first.
     ray = s.Interact(ray)
+    length ++
+
+    //The albedo is the color of the ray after it first interacts,
+    //before any light is found by a shadow ray.
+    if features != nil && ray.depth == 0 {
+      features.Albedo = scene.rgb(ray, ray.DeriveColor())
+    }
+
+    //A shadow ray is only sent if the scattered ray could
+    //also have gone on to find the light.
+    pdf = 0
+    if sc, ok := s.(Scatterer); ok && scene.lights != nil && ray.depth + 1 < max_depth {
+      scene.sampleLight(ray, sc, in, selected)
+      pdf = sc.Pdf(ray.position, in, ray.direction)
+      from = make([]float64, len(ray.position))
+      copy(from, ray.position)
+    }
 
     //check if we should bother continuing to bounce the ray.
-    if ray.redirected <= receptor_tolerance {break}
+    if scene.roulette == 0 {
+      if ray.redirected <= receptor_tolerance {break}
+    } else if ray.redirected == 0 || (ray.depth + 1 >= scene.roulette && !ray.survive()) {
+      break
+    }
   }
 
-  return ray.DeriveColor()
+  return scene.rgb(ray, ray.DeriveColor()), length
+}
+
+//The rgb of values of the wavelengths of the ray.
+func (scene *Scene) rgb(ray *LightRay, values []float64) []float64 {
+  if scene.wavelengths > 0 {
+    return color.SampledSpectrumToRGB(ray.receptor, values)
+  }
+  return values
 }
 
 
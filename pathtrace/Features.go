@@ -0,0 +1,97 @@
+package pathtrace
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/hdr"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//Besides its color, the film keeps pictures of what the rays of each
+//pixel hit first: its color, which way it faces, how far away it is, and
+//which object it is. These are hardly grainy even with few samples, so
+//they show the denoiser where the edges of the picture are.
+
+//What a ray hit first.
+type Features struct {
+  //The color of the ray after it first interacted, which is the
+  //color of the object, or of the background if it hit nothing.
+  Albedo []float64
+  //The first three components of the normal of the surface, which
+  //faces back toward the ray. Zero if the ray hit nothing.
+  Normal []float64
+  //The distance to the object, or zero if the ray hit nothing. In
+  //curved space, it is the distance in the coordinates of the space.
+  Depth float64
+  //The index of the object in the scene, or -1 if the ray hit nothing.
+  Object int
+}
+
+//Record that the ray from start hit object at x, having arrived going in direction in.
+func (f *Features) hit(surf surface.Surface, object int, start, x, in []float64) {
+  f.Object = object
+  f.Depth = vector.Length(vector.Minus(x, start))
+
+  n := surface.SurfaceNormal(surf, x)
+  s := 1.
+  if vector.Dot(n, in) > 0 {
+    s = -1
+  }
+  for i := 0; i < 3 && i < len(n); i ++ {
+    f.Normal[i] = s * n[i]
+  }
+}
+
+//Pictures of the features of each pixel. The albedo, normal and depth
+//are the means of those of the samples of a pixel, and the object is
+//the one hit by its first sample, with one added to its index so that
+//a pixel whose first ray hit nothing is zero.
+type FeatureImages struct {
+  Albedo, Normal, Depth, Object *hdr.Image
+}
+
+//How different two pixels must be in each feature, and in color,
+//before the denoiser does not blend them.
+const (
+  albedoSigma = .1
+  normalSigma = .3
+  //The depth is compared by the logarithm of its ratio.
+  depthSigma = .2
+  objectSigma = .1
+  colorSigma = 1.5
+)
+
+//Denoise the picture with the features as guides, over the given number
+//of levels, each of which blurs twice as far as the one before. The color
+//of each pixel is divided by its albedo first and multiplied by it again
+//afterward, so that what is blurred is the light that falls on the
+//objects rather than their colors, which are not grainy.
+//
+//May return nil, if the picture is not the size of the features.
+func (f *FeatureImages) Denoise(img *hdr.Image, levels int) *hdr.Image {
+  if img == nil || img.Width != f.Albedo.Width || img.Height != f.Albedo.Height { return nil }
+
+  //An albedo which is nearly black is not divided by.
+  albedo := func(a float64) float64 {
+    if a < .01 { return 1 }
+    return a
+  }
+
+  light := hdr.NewImage(img.Width, img.Height)
+  depth := hdr.NewImage(img.Width, img.Height)
+  for i := range img.Pix {
+    light.Pix[i] = img.Pix[i] / albedo(f.Albedo.Pix[i])
+    if d := f.Depth.Pix[i]; d > 0 {
+      depth.Pix[i] = math.Log(d)
+    }
+  }
+
+  out := hdr.Denoise(light, []hdr.Guide{
+    {Image: f.Albedo, Sigma: albedoSigma},
+    {Image: f.Normal, Sigma: normalSigma},
+    {Image: depth, Sigma: depthSigma},
+    {Image: f.Object, Sigma: objectSigma}}, colorSigma, levels)
+
+  for i := range out.Pix {
+    out.Pix[i] *= albedo(f.Albedo.Pix[i])
+  }
+  return out
+}
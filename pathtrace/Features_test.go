@@ -0,0 +1,98 @@
+package pathtrace
+
+import "testing"
+import "bytes"
+import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+import "github.com/DanielKrawisz/CurvedSpace/hdr"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+func TestTraceFeatures(t *testing.T) {
+  floor := polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 0}, []float64{0, 0, 1}, true)
+  sky := func(dir []float64) color.Color {
+    return color.PresetColor([]float64{.2, .3, .4})
+  }
+  scene := NewScene([]*ExtendedObject{NewExtendedObject(floor,
+    NewLambertianReflector(floor, Absorb([]float64{.5, .6, .7})))}, sky)
+
+  cases := []struct {
+    pos, dir []float64
+    expected Features
+  }{
+    {[]float64{0, 0, 2}, []float64{0, 0, -1}, Features{[]float64{.5, .6, .7}, []float64{0, 0, 1}, 2, 0}},
+    {[]float64{0, 0, -1}, []float64{0, .6, .8}, Features{[]float64{.5, .6, .7}, []float64{0, 0, -1}, 1.25, 0}},
+    {[]float64{0, 0, 2}, []float64{0, 0, 1}, Features{[]float64{.2, .3, .4}, []float64{0, 0, 0}, 0, -1}}}
+
+  for i, c := range cases {
+    _, f := scene.TraceFeatures(c.pos, c.dir, 3, 1./256.)
+    if !test.VectorCloseEnough(f.Albedo, c.expected.Albedo, .000001) ||
+      !test.VectorCloseEnough(f.Normal, c.expected.Normal, .000001) ||
+      !test.CloseEnough(f.Depth, c.expected.Depth, .000001) || f.Object != c.expected.Object {
+      t.Error("trace features error ", i, ": ", f)
+    }
+  }
+}
+
+//The features of a picture are kept on its film and in checkpoints, and
+//guide the denoiser to a picture which is closer to the right one.
+func TestFeatureImages(t *testing.T) {
+  film, _ := SnapshotFilm(progressiveScene, progressiveCamera, 12, 10, 2, 1, 1, 0, 2, nil)
+  f := film.Features()
+  if c := f.Object.At(3, 4); c[0] != 1 {
+    t.Error("feature images error 1: ", c)
+  }
+  if c := f.Albedo.At(3, 4); !test.VectorCloseEnough(c, []float64{1, 1, 1}, .000001) {
+    t.Error("feature images error 2: ", c)
+  }
+  if c := f.Normal.At(3, 4); !test.VectorCloseEnough(c, []float64{0, 0, 1}, .000001) {
+    t.Error("feature images error 3: ", c)
+  }
+  if c := f.Depth.At(3, 4); !test.CloseEnough(c[0], 1, .000001) {
+    t.Error("feature images error 4: ", c)
+  }
+
+  p := NewProgressive(12, 10)
+  p.Render(progressiveScene, progressiveCamera, 2, 1, 1, 0, 2, 2, nil)
+  var b bytes.Buffer
+  p.WriteCheckpoint(&b)
+  q, err := ReadCheckpoint(&b)
+  if err != nil {
+    t.Error("feature images error 5: ", err)
+    return
+  }
+  g := q.Features()
+  for i, images := range [][2]*hdr.Image{{f.Albedo, g.Albedo}, {f.Depth, g.Depth}, {f.Object, g.Object}} {
+    if a, b := images[0].At(7, 2), images[1].At(7, 2); !test.VectorCloseEnough(a, b, .000001) {
+      t.Error("feature images error 6: ", i, a, b)
+    }
+  }
+
+  //Every pixel sees a floor which is lit by half of the sky.
+  random := func() *Scene {
+    scene := progressiveScene()
+    scene.SetSampler(distributions.NewRandomSampler(3))
+    return scene
+  }
+  film, _ = SnapshotFilm(random, progressiveCamera, 12, 10, 2, 1, 1, 0, 2, nil)
+  expected := []float64{.5, .4, .35}
+  img := film.Image()
+  denoised := film.Features().Denoise(img, 4)
+  var before, after float64
+  for y := 0; y < 10; y ++ {
+    for x := 0; x < 12; x ++ {
+      for l := 0; l < 3; l ++ {
+        a, b := img.At(x, y)[l] - expected[l], denoised.At(x, y)[l] - expected[l]
+        before += a * a
+        after += b * b
+      }
+    }
+  }
+  if !(after * 4 < before) {
+    t.Error("feature images error 7: ", before, after)
+  }
+
+  if f.Denoise(NewFilm(3, 3, nil).Image(), 4) != nil {
+    t.Error("feature images error 8")
+  }
+}
@@ -0,0 +1,216 @@
+package pathtrace
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/hdr"
+
+//The film is where the samples of a picture are collected. Each sample is
+//splatted onto every pixel whose center is within the radius of the filter
+//of where its ray went through the picture, weighted by the filter, and the
+//color of a pixel is the weighted mean of the samples splatted onto it.
+//
+//A film can also be a section of a picture, so that rows which are sampled
+//by different goroutines can be splatted onto films of their own and added
+//together afterward.
+type Film struct {
+  Width, Height int
+  filter *Filter
+  //The row of the picture which is the first row of the film.
+  top int
+  //The samples splatted onto each pixel, in rows from the top.
+  pixels []filmPixel
+}
+
+//The weighted sum of the colors of the samples
+//splatted onto a pixel, and the sum of their weights.
+type filmPixel struct {
+  sum [3]float64
+  weight float64
+  //The sums of the features of the samples of the pixel itself, which
+  //are not filtered, the number of them, and the object hit by the first.
+  albedo, normal [3]float64
+  depth float64
+  features int
+  object int
+}
+
+//A film for a picture of the given size. If filter
+//is nil, a box filter is used.
+func NewFilm(width, height int, filter *Filter) *Film {
+  if width < 0 { width = 0 }
+  if height < 0 { height = 0 }
+  return newFilm(width, 0, height, filter)
+}
+
+func newFilm(width, top, height int, filter *Filter) *Film {
+  if filter == nil {
+    filter = NewFilter("box", 0)
+  }
+  return &Film{width, height, filter, top, make([]filmPixel, width * height)}
+}
+
+//A film for the samples of rows v_min to v_max of a picture, with
+//room for the rows around them onto which they may be splatted.
+func newFilmSection(width, v_min, v_max int, filter *Filter) *Film {
+  if filter == nil {
+    filter = NewFilter("box", 0)
+  }
+  m := filter.margin()
+  return newFilm(width, v_min - m, v_max - v_min + 2 * m, filter)
+}
+
+func (f *Film) Filter() *Filter {
+  return f.filter
+}
+
+//Splat a sample of color c onto the film. Its ray went through
+//pixel x, y of the picture at dx, dy from the center of the pixel.
+func (f *Film) Splat(x, y int, dx, dy float64, c []float64) {
+  r := f.filter.Radius
+
+  //The pixels are those which are no more than the radius from the
+  //sample, except that those exactly the radius above or to the left
+  //are left out, so that with a box filter a sample is only ever
+  //splatted onto its own pixel.
+  for k := int(math.Floor(dy - r)) + 1; float64(k) <= dy + r; k ++ {
+    v := y + k - f.top
+    if v < 0 || v >= f.Height { continue }
+    wy := f.filter.weight(dy - float64(k))
+    if wy == 0 { continue }
+
+    for j := int(math.Floor(dx - r)) + 1; float64(j) <= dx + r; j ++ {
+      u := x + j
+      if u < 0 || u >= f.Width { continue }
+
+      w := wy * f.filter.weight(dx - float64(j))
+      pix := &f.pixels[v * f.Width + u]
+      for l := 0; l < 3; l ++ {
+        pix.sum[l] += w * c[l]
+      }
+      pix.weight += w
+    }
+  }
+}
+
+//Add the features of a sample of pixel x, y of the picture.
+func (f *Film) addFeatures(x, y int, features *Features) {
+  v := y - f.top
+  if v < 0 || v >= f.Height || x < 0 || x >= f.Width { return }
+
+  pix := &f.pixels[v * f.Width + x]
+  if pix.features == 0 {
+    pix.object = features.Object
+  }
+  for l := 0; l < 3; l ++ {
+    pix.albedo[l] += features.Albedo[l]
+    pix.normal[l] += features.Normal[l]
+  }
+  pix.depth += features.Depth
+  pix.features ++
+}
+
+//Add a section of the same picture onto the film. The rows of the
+//section which are not on the film are left out.
+func (f *Film) add(section *Film) {
+  for v := 0; v < section.Height; v ++ {
+    row := section.top + v - f.top
+    if row < 0 || row >= f.Height { continue }
+
+    for u := 0; u < f.Width; u ++ {
+      a, b := &f.pixels[row * f.Width + u], &section.pixels[v * section.Width + u]
+      for l := 0; l < 3; l ++ {
+        a.sum[l] += b.sum[l]
+      }
+      a.weight += b.weight
+
+      if b.features == 0 { continue }
+      if a.features == 0 {
+        a.object = b.object
+      }
+      for l := 0; l < 3; l ++ {
+        a.albedo[l] += b.albedo[l]
+        a.normal[l] += b.normal[l]
+      }
+      a.depth += b.depth
+      a.features += b.features
+    }
+  }
+}
+
+//The weighted mean of the samples of each pixel. Pixels with no samples
+//are black. Filters which are negative in places can make a pixel next to
+//a bright one come out negative, which is made black too.
+func (f *Film) Image() *hdr.Image {
+  img := hdr.NewImage(f.Width, f.Height)
+  for y := 0; y < f.Height; y ++ {
+    for x := 0; x < f.Width; x ++ {
+      pix := &f.pixels[y * f.Width + x]
+      if pix.weight <= 0 { continue }
+
+      c := make([]float64, 3)
+      for l := 0; l < 3; l ++ {
+        c[l] = math.Max(0, pix.sum[l] / pix.weight)
+      }
+      img.Set(x, y, c)
+    }
+  }
+  return img
+}
+
+//Pictures of the features of the samples of each pixel. Pixels
+//with no samples are black.
+func (f *Film) Features() *FeatureImages {
+  images := &FeatureImages{hdr.NewImage(f.Width, f.Height), hdr.NewImage(f.Width, f.Height),
+    hdr.NewImage(f.Width, f.Height), hdr.NewImage(f.Width, f.Height)}
+
+  for y := 0; y < f.Height; y ++ {
+    for x := 0; x < f.Width; x ++ {
+      pix := &f.pixels[y * f.Width + x]
+      if pix.features == 0 { continue }
+
+      n := float64(pix.features)
+      depth, object := pix.depth / n, float64(pix.object + 1)
+      images.Albedo.Set(x, y, []float64{pix.albedo[0] / n, pix.albedo[1] / n, pix.albedo[2] / n})
+      images.Normal.Set(x, y, []float64{pix.normal[0] / n, pix.normal[1] / n, pix.normal[2] / n})
+      images.Depth.Set(x, y, []float64{depth, depth, depth})
+      images.Object.Set(x, y, []float64{object, object, object})
+    }
+  }
+  return images
+}
+
+//Adds sections of a picture onto a film in the order of their rows,
+//whatever order they are finished in, so that the sums of each pixel
+//are added up in the same order and the picture comes out the same
+//no matter how the rows were shared among goroutines.
+type filmMerger struct {
+  film *Film
+  //The first row of the next section to be added.
+  next int
+  //Sections which are finished but not yet added, by their first row.
+  pending map[int]*filmSection
+}
+
+//The samples of rows v_min to v_max. film may be nil if there are none.
+type filmSection struct {
+  v_min, v_max int
+  film *Film
+}
+
+func newFilmMerger(film *Film) *filmMerger {
+  return &filmMerger{film, 0, make(map[int]*filmSection)}
+}
+
+//Add the section, along with any which were waiting for it.
+func (m *filmMerger) merge(s *filmSection) {
+  m.pending[s.v_min] = s
+  for {
+    s, ok := m.pending[m.next]
+    if !ok { return }
+    delete(m.pending, m.next)
+
+    if s.film != nil {
+      m.film.add(s.film)
+    }
+    m.next = s.v_max
+  }
+}
@@ -0,0 +1,165 @@
+package pathtrace
+
+import "testing"
+import "bytes"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+func TestFilter(t *testing.T) {
+  if NewFilter("blur", 0) != nil || NewFilter("box", -1) != nil {
+    t.Error("filter error 1")
+  }
+
+  for _, name := range []string{"box", "tent", "gaussian", "mitchell", "lanczos"} {
+    f := NewFilter(name, 0)
+    if f == nil || f.Name != name || f.Radius != defaultFilterRadius[name] {
+      t.Error("filter error 2: ", name)
+      continue
+    }
+
+    //Every filter is symmetric, greatest in the middle, and nothing
+    //beyond its radius, apart from the box filter which is flat.
+    if f.Weight(0, 0) <= 0 || f.Weight(.3, -.2) != f.Weight(-.3, .2) || f.Weight(.3, 0) > f.Weight(0, 0) {
+      t.Error("filter error 3: ", name)
+    }
+    if name != "box" && !test.CloseEnough(f.Weight(f.Radius, 0), 0, .000001) {
+      t.Error("filter error 4: ", name, f.Weight(f.Radius, 0))
+    }
+  }
+
+  //The Mitchell and Lanczos filters are negative in places.
+  if NewFilter("mitchell", 0).Weight(1.5, 0) >= 0 || NewFilter("lanczos", 0).Weight(1.5, 0) >= 0 {
+    t.Error("filter error 5")
+  }
+
+  if f := NewFilter("tent", 3); f.Radius != 3 || !test.CloseEnough(f.Weight(1.5, 0), .5, .000001) {
+    t.Error("filter error 6")
+  }
+
+  if m := []int{NewFilter("box", 0).margin(), NewFilter("tent", 0).margin(), NewFilter("lanczos", 0).margin()};
+    m[0] != 0 || m[1] != 1 || m[2] != 2 {
+    t.Error("filter error 7: ", m)
+  }
+}
+
+func TestFilm(t *testing.T) {
+  //With a box filter, a sample only counts toward its own pixel,
+  //even at the edge of it.
+  box := NewFilm(3, 3, nil)
+  box.Splat(1, 1, -.5, -.5, []float64{1, 2, 3})
+  box.Splat(1, 1, .49, .49, []float64{3, 2, 1})
+  img := box.Image()
+  if c := img.At(1, 1); c[0] != 2 || c[1] != 2 || c[2] != 2 {
+    t.Error("film error 1: ", c)
+  }
+  if c := img.At(0, 0); c[0] != 0 || c[1] != 0 || c[2] != 0 {
+    t.Error("film error 2: ", c)
+  }
+
+  //A tent filter splats a sample onto the pixels around it, but
+  //the pixels come out the color of the sample.
+  tent := NewFilm(3, 3, NewFilter("tent", 0))
+  tent.Splat(1, 1, .25, 0, []float64{1, 1, 1})
+  for _, p := range [][2]int{{1, 1}, {2, 1}} {
+    if c := tent.Image().At(p[0], p[1]); !test.VectorCloseEnough(c, []float64{1, 1, 1}, .000001) {
+      t.Error("film error 3: ", p, c)
+    }
+  }
+  if w := []float64{tent.pixels[4].weight, tent.pixels[5].weight, tent.pixels[3].weight}; w[0] != .75 || w[1] != .25 || w[2] != 0 {
+    t.Error("film error 4: ", w)
+  }
+
+  //A section of the picture is added onto the rows it covers.
+  section := newFilmSection(3, 2, 3, tent.filter)
+  if section.top != 1 || section.Height != 3 {
+    t.Error("film error 5: ", section.top, section.Height)
+  }
+  section.Splat(0, 2, 0, .5, []float64{3, 3, 3})
+  tent.add(section)
+  if c := tent.Image().At(0, 2); !test.VectorCloseEnough(c, []float64{3, 3, 3}, .000001) {
+    t.Error("film error 6: ", c)
+  }
+
+  //Negative colors, which a negative filter can make, are black.
+  lanczos := NewFilm(3, 1, NewFilter("lanczos", 0))
+  lanczos.Splat(0, 0, 0, 0, []float64{-1, 1, 0})
+  if c := lanczos.Image().At(0, 0); c[0] != 0 || c[1] != 1 {
+    t.Error("film error 7: ", c)
+  }
+}
+
+//Sections are added in order, whatever order they are merged in.
+func TestFilmMerger(t *testing.T) {
+  film := NewFilm(1, 3, nil)
+  m := newFilmMerger(film)
+  var sections []*filmSection
+  for v := 0; v < 3; v ++ {
+    s := newFilmSection(1, v, v + 1, nil)
+    s.Splat(0, v, 0, 0, []float64{float64(v), 0, 0})
+    sections = append(sections, &filmSection{v, v + 1, s})
+  }
+
+  m.merge(sections[2])
+  m.merge(sections[1])
+  if m.next != 0 || film.pixels[2].weight != 0 {
+    t.Error("film merger error 1")
+  }
+  m.merge(sections[0])
+  if m.next != 3 || len(m.pending) != 0 || film.pixels[2].sum[0] != 2 {
+    t.Error("film merger error 2: ", m.next, len(m.pending))
+  }
+}
+
+//A picture with a wide filter is repeatable, and comes out nearly the
+//same when it is made in passes and saved partway through.
+func TestFilteredSnapshot(t *testing.T) {
+  filtered := func() *Scene {
+    scene := progressiveScene()
+    scene.SetFilter(NewFilter("mitchell", 0))
+    return scene
+  }
+
+  a := SnapshotHDR(filtered, progressiveCamera, 12, 10, 2, 3, 20, .002, 1, nil)
+  b := SnapshotHDR(filtered, progressiveCamera, 12, 10, 2, 3, 20, .002, 4, nil)
+  box := SnapshotHDR(progressiveScene, progressiveCamera, 12, 10, 2, 3, 20, .002, 4, nil)
+
+  p := NewProgressive(12, 10)
+  p.Render(filtered, progressiveCamera, 2, 3, 20, .002, 2, 3, func(p *Progressive, r int) bool {
+    return p.Passes < 2
+  })
+  if f := p.Filter(); f.Name != "mitchell" {
+    t.Error("filtered snapshot error 1: ", f.Name)
+  }
+
+  var buf bytes.Buffer
+  p.WriteCheckpoint(&buf)
+  q, err := ReadCheckpoint(&buf)
+  if err != nil || q.Filter().Name != "mitchell" || q.Filter().Radius != 2 {
+    t.Error("filtered snapshot error 2: ", err)
+    return
+  }
+  q.Render(filtered, progressiveCamera, 2, 3, 20, .002, 5, 2, nil)
+  c := q.Image()
+
+  same, differ := true, false
+  for y := 0; y < 10; y ++ {
+    for x := 0; x < 12; x ++ {
+      p, q, r, s := a.At(x, y), b.At(x, y), box.At(x, y), c.At(x, y)
+      for i := range p {
+        if p[i] != q[i] { same = false }
+        if p[i] != r[i] { differ = true }
+        if math.Abs(p[i] - s[i]) > .000001 {
+          t.Error("filtered snapshot error 3: ", x, y, p, s)
+          return
+        }
+      }
+    }
+  }
+
+  if !same {
+    t.Error("filtered snapshot error 4")
+  }
+  if !differ {
+    t.Error("filtered snapshot error 5")
+  }
+}
@@ -0,0 +1,90 @@
+package pathtrace
+
+import "math"
+
+//A reconstruction filter says how much a sample counts toward each pixel
+//near where its ray went through the picture. With a box filter, which is
+//the default, a sample counts only toward its own pixel, and the color of
+//a pixel is the mean of its samples. Wider filters blend each sample into
+//the pixels around it, which makes edges smoother and the picture softer
+//or sharper, depending on the filter.
+type Filter struct {
+  Name string
+  //The distance in pixels from the center of a pixel beyond
+  //which samples do not count toward it.
+  Radius float64
+  //The weight of a sample at a distance along one axis.
+  weight func(x float64) float64
+}
+
+//The radius of each kind of filter when none is given.
+var defaultFilterRadius map[string]float64 = map[string]float64{
+  "box": .5, "tent": 1, "gaussian": 1.5, "mitchell": 2, "lanczos": 2}
+
+//A filter of the given kind, which may be box, tent, gaussian,
+//mitchell, or lanczos. If radius is zero, the usual radius for
+//that kind of filter is used.
+//
+//May return nil.
+func NewFilter(name string, radius float64) *Filter {
+  if radius == 0 {
+    radius = defaultFilterRadius[name]
+  }
+  if !(radius > 0) { return nil }
+
+  var weight func(x float64) float64
+  switch name {
+  case "box":
+    weight = func(x float64) float64 { return 1 }
+  case "tent":
+    weight = func(x float64) float64 { return math.Max(0, 1 - math.Abs(x) / radius) }
+  case "gaussian":
+    //The radius is three standard deviations, and the curve is lowered
+    //so that it comes to zero there.
+    a := 4.5 / (radius * radius)
+    edge := math.Exp(-a * radius * radius)
+    weight = func(x float64) float64 { return math.Max(0, math.Exp(-a * x * x) - edge) }
+  case "mitchell":
+    weight = func(x float64) float64 { return mitchell(2 * x / radius) }
+  case "lanczos":
+    weight = func(x float64) float64 {
+      if math.Abs(x) >= radius { return 0 }
+      return sinc(x) * sinc(x / radius)
+    }
+  default:
+    return nil
+  }
+
+  return &Filter{name, radius, weight}
+}
+
+//The weight of a sample dx, dy pixels from the center of a pixel.
+func (f *Filter) Weight(dx, dy float64) float64 {
+  return f.weight(dx) * f.weight(dy)
+}
+
+//The number of rows above and below its own onto which
+//the samples of a pixel may be splatted.
+func (f *Filter) margin() int {
+  return int(math.Ceil(f.Radius - .5))
+}
+
+//The Mitchell-Netravali filter with B = C = 1/3, which is zero
+//beyond two. From Mitchell and Netravali, Reconstruction Filters
+//in Computer Graphics, 1988.
+func mitchell(x float64) float64 {
+  const b, c = 1. / 3., 1. / 3.
+  x = math.Abs(x)
+  switch {
+  case x < 1:
+    return ((12 - 9 * b - 6 * c) * x * x * x + (-18 + 12 * b + 6 * c) * x * x + (6 - 2 * b)) / 6
+  case x < 2:
+    return ((-b - 6 * c) * x * x * x + (6 * b + 30 * c) * x * x + (-12 * b - 48 * c) * x + (8 * b + 24 * c)) / 6
+  }
+  return 0
+}
+
+func sinc(x float64) float64 {
+  if x == 0 { return 1 }
+  return math.Sin(math.Pi * x) / (math.Pi * x)
+}
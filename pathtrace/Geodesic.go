@@ -0,0 +1,212 @@
+package pathtrace
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/diffeq"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+import "github.com/DanielKrawisz/CurvedSpace/geometry"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//In a curved space, a light ray is a null geodesic. It is traced by
+//integrating the geodesic equation, and it hits a surface when the
+//surface function changes sign between two steps. Each object is
+//given in the spatial coordinates of one region of the space, and
+//its surface and interactor work in those coordinates the same way
+//as they do in flat space.
+//In a conformally flat space, such as the spherical and hyperbolic
+//spaces, angles in the coordinates are the true angles, so
+//reflection and refraction are exactly right.
+
+//The parameters for tracing a light ray along a geodesic.
+type geodesicTracer struct {
+  space geometry.Space
+  region int //The region in which the camera is given.
+  ds, err float64
+  //A light ray farther than this from the origin has
+  //escaped to the background.
+  escape float64
+  maxsteps int
+  //The region in which each object is given.
+  regions []int
+  //The background to use in each region. May be nil, in
+  //which case the background of the scene is used everywhere.
+  backgrounds []color.SphericalColorFunction
+}
+
+//A scene in a curved space.
+//  space    - the space in which the scene exists.
+//  region   - the region in whose spatial coordinates the objects and the camera are given.
+//  ds       - the largest step size used to integrate the geodesic equation. Objects
+//             smaller than this may be missed.
+//  err      - the error allowed per step.
+//  escape   - a ray which gets this far from the origin has gone to the background.
+//             May be infinity in a space that is finite. If the space has a horizon,
+//             a ray that falls through it is black, and the distance is given by the
+//             radial coordinate of the space.
+//  maxsteps - the largest number of steps for each ray between two objects.
+//
+//May return nil.
+func NewCurvedScene(objects []*ExtendedObject, background color.SphericalColorFunction,
+  space geometry.Space, region int, ds, err, escape float64, maxsteps int) *Scene {
+  if objects == nil || background == nil || space == nil { return nil }
+  if region < 0 || region >= space.Regions() { return nil }
+  if !(ds > 0) || !(err > 0) || !(escape > 0) || maxsteps <= 0 { return nil }
+  if h, ok := space.(geometry.Horizon); ok && !(escape > h.HorizonRadius()) { return nil }
+
+  regions := make([]int, len(objects))
+  for i := range regions {
+    regions[i] = region
+  }
+
+  return &Scene{objects: objects, background: background,
+    curved: &geodesicTracer{space, region, ds, err, escape, maxsteps, regions, nil},
+    sampler: distributions.NewRandomSampler(0), filter: NewFilter("box", 0), moving: findMoving(objects)}
+}
+
+//A scene in a curved space with several regions, such as a wormhole,
+//in which each region has its own objects and its own background.
+//  objects     - the objects in each region, given in the coordinates of that region.
+//  backgrounds - the background seen by a ray which escapes in each region.
+//  region      - the region in whose coordinates the camera is given.
+//The other parameters are the same as for NewCurvedScene.
+//
+//May return nil.
+func NewRegionalScene(objects [][]*ExtendedObject, backgrounds []color.SphericalColorFunction,
+  space geometry.Space, region int, ds, err, escape float64, maxsteps int) *Scene {
+  if objects == nil || backgrounds == nil || space == nil { return nil }
+  if len(objects) != space.Regions() || len(backgrounds) != space.Regions() { return nil }
+  for _, bg := range backgrounds {
+    if bg == nil { return nil }
+  }
+  if region < 0 || region >= space.Regions() { return nil }
+
+  var all []*ExtendedObject = make([]*ExtendedObject, 0)
+  var regions []int = make([]int, 0)
+  for i := 0; i < len(objects); i ++ {
+    for _, object := range objects[i] {
+      all = append(all, object)
+      regions = append(regions, i)
+    }
+  }
+
+  scene := NewCurvedScene(all, backgrounds[region], space, region, ds, err, escape, maxsteps)
+  if scene == nil { return nil }
+
+  scene.curved.regions = regions
+  scene.curved.backgrounds = backgrounds
+  return scene
+}
+
+//The background seen by a ray that escapes in a given region.
+func (scene *Scene) backgroundIn(region int) color.SphericalColorFunction {
+  if scene.curved == nil || scene.curved.backgrounds == nil {
+    return scene.background
+  }
+  return scene.curved.backgrounds[region]
+}
+
+//Evaluate a surface function given in the coordinates of one region
+//at a point given in the coordinates of any region.
+func (g *geodesicTracer) surfaceEvent(home int, f func([]float64) float64) diffeq.Event {
+  return func(region int, x []float64) float64 {
+    if region == home {
+      return f(x[1:4])
+    }
+
+    //Where the point cannot be given in the coordinates of the region
+    //of the surface, the surface is on neither side of it.
+    y, _, err := g.space.Transform(region, home, x[:4], nil)
+    if err != nil {
+      return math.NaN()
+    }
+
+    return f(y[1:4])
+  }
+}
+
+//Integrate a geodesic from the ray's position until it hits an object and
+//move the ray there, in the coordinates of the region of the object. Returns
+//-1 if there is no such object, in which case the ray is left in the region
+//where it escaped.
+func (scene *Scene) nextGeodesicIntersection(ray *LightRay, last int) int {
+  g := scene.curved
+
+  x := make([]float64, 8)
+  copy(x[1:4], ray.position)
+  //The direction is normalized so that the step size is about the
+  //same as the distance in coordinates.
+  copy(x[4:], geometry.NullVelocity(g.space.CoordinateSystem(ray.region), x[:4], vector.Normalize(ray.direction)))
+
+  st := diffeq.NewState(ray.region, 0, g.ds, x)
+  f := geometry.NewGeodesicEquation(g.space, ray.region)
+  if st == nil || f == nil {
+    return -1
+  }
+
+  //The ray starts on the last surface that it hit. All the surfaces
+  //are watched together so that the nearest is found even if the
+  //ray crosses several in one step.
+  surfaces := make([]diffeq.Event, len(scene.objects))
+  skip := make([]bool, len(scene.objects))
+  for l, object := range scene.objects {
+    surfaces[l] = g.surfaceEvent(g.regions[l], object.surf.F)
+    skip[l] = l == last
+  }
+  events := diffeq.NewUntilFirstEvent(st, surfaces, g.err * g.ds, skip)
+
+  radius, inner := g.radius()
+  horizon := diffeq.NewHorizonMonitor(st, radius, inner, g.escape)
+  monitor := []diffeq.Monitor{events,
+    horizon,
+    diffeq.NewMaxStepSize(st, g.ds),
+    diffeq.NewRegionMonitor(st, f)}
+
+  solver := diffeq.NewSolver(st, f, diffeq.NewRungeKuttaSolverMethodDormandPrince(8, g.err),
+    monitor, g.maxsteps, math.Inf(1))
+  if solver == nil {
+    return -1
+  }
+
+  end, _ := solver.Run()
+
+  selected := events.First()
+
+  to := end.Region
+  if selected != -1 {
+    to = g.regions[selected]
+  }
+
+  y, w, err := g.space.Transform(end.Region, to, end.X[:4], end.X[4:])
+  if err == nil {
+    ray.region = to
+    copy(ray.position, y[1:4])
+    copy(ray.direction, w[1:4])
+  } else {
+    ray.region = end.Region
+    copy(ray.position, end.X[1:4])
+    copy(ray.direction, end.X[5:])
+  }
+
+  if selected == -1 && horizon.Absorbed() {
+    return absorbed
+  }
+
+  return selected
+}
+
+//Returned by nextGeodesicIntersection when the ray falls into a black hole.
+const absorbed = -2
+
+//The distance of a point from the center of the scene and the distance
+//below which a ray has fallen through the horizon. If the space has no
+//horizon, the distance is measured in the coordinates of the region
+//that the ray is in.
+func (g *geodesicTracer) radius() (func(int, []float64) float64, float64) {
+  if h, ok := g.space.(geometry.Horizon); ok {
+    return h.Radius, h.HorizonRadius()
+  }
+
+  return func(region int, x []float64) float64 {
+    return vector.Length(x[1:4])
+  }, -1
+}
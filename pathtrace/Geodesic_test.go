@@ -0,0 +1,194 @@
+package pathtrace
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/geometry"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+var geo_err float64 = .0001
+
+func glowingSphereObjects(p []float64, r float64) []*ExtendedObject {
+  return []*ExtendedObject{NewExtendedObject(polynomialsurfaces.NewSphere(p, r),
+    NewGlowingObject([]float64{.5, .7, .9}))}
+}
+
+var testBackground = color.ConstantColorFunction(color.PresetColor([]float64{.1, .1, .1}))
+
+func TestNewCurvedScene(t *testing.T) {
+  objects := glowingSphereObjects([]float64{0, 0, 5}, 1)
+  space := geometry.NewMinkowskiSpace()
+
+  if NewCurvedScene(nil, testBackground, space, 0, .1, .001, 10, 100) != nil {t.Error("curved scene error 1")}
+  if NewCurvedScene(objects, nil, space, 0, .1, .001, 10, 100) != nil {t.Error("curved scene error 2")}
+  if NewCurvedScene(objects, testBackground, nil, 0, .1, .001, 10, 100) != nil {t.Error("curved scene error 3")}
+  if NewCurvedScene(objects, testBackground, space, 2, .1, .001, 10, 100) != nil {t.Error("curved scene error 4")}
+  if NewCurvedScene(objects, testBackground, space, 0, 0, .001, 10, 100) != nil {t.Error("curved scene error 5")}
+  if NewCurvedScene(objects, testBackground, space, 0, .1, .001, 10, 0) != nil {t.Error("curved scene error 6")}
+}
+
+//In flat space, geodesics should give the same result as straight lines.
+func TestFlatGeodesic(t *testing.T) {
+  objects := glowingSphereObjects([]float64{0, 0, 5}, 1)
+  flat := NewScene(objects, testBackground)
+  curved := NewCurvedScene(objects, testBackground, geometry.NewMinkowskiSpace(), 0, .1, .000001, 10, 10000)
+
+  ray := &LightRay{0, 0, []float64{0, 0, 0}, []float64{0, 0, 1}, []float64{4, 5, 6},
+    []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+  if curved.nextGeodesicIntersection(ray, -1) != 0 ||
+    !test.VectorCloseEnough(ray.position, []float64{0, 0, 4}, geo_err) {
+    t.Error("flat geodesic error 1: got ", ray.position)
+  }
+
+  for i, dir := range [][]float64{{0, 0, 1}, {0, 0, -1}, {.1, .1, 1}, {0, 1, 1}} {
+    a := flat.TracePath([]float64{0, 0, 0}, []float64{dir[0], dir[1], dir[2]}, 4, 1./256.)
+    b := curved.TracePath([]float64{0, 0, 0}, []float64{dir[0], dir[1], dir[2]}, 4, 1./256.)
+
+    if !test.VectorCloseEnough(a, b, geo_err) {
+      t.Error("flat geodesic error 2, case ", i, ": flat ", a, " curved ", b)
+    }
+  }
+}
+
+//When a step crosses two surfaces, the ray hits the nearer
+//one, whichever comes first in the list of objects.
+func TestNearestGeodesicIntersection(t *testing.T) {
+  objects := []*ExtendedObject{
+    NewExtendedObject(polynomialsurfaces.NewSphere([]float64{0, 0, 5.3}, 1), NewGlowingObject([]float64{1, 0, 0})),
+    NewExtendedObject(polynomialsurfaces.NewSphere([]float64{0, 0, 5}, 1), NewGlowingObject([]float64{0, 1, 0}))}
+  flat := NewScene(objects, testBackground)
+  curved := NewCurvedScene(objects, testBackground, geometry.NewMinkowskiSpace(), 0, .5, .000001, 10, 10000)
+
+  for i, scene := range []*Scene{flat, curved} {
+    if c := scene.TracePath([]float64{0, 0, 0}, []float64{0, 0, 1}, 4, 1./256.);
+      !test.VectorCloseEnough(c, []float64{0, 1, 0}, geo_err) {
+      t.Error("nearest geodesic intersection error, case ", i, ": got ", c)
+    }
+  }
+}
+
+//In a spherical space, a light ray goes all the way around
+//and sees what is behind it.
+func TestSphericalGeodesic(t *testing.T) {
+  objects := glowingSphereObjects([]float64{-.5, 0, 0}, .2)
+  flat := NewScene(objects, testBackground)
+  curved := NewCurvedScene(objects, testBackground, geometry.NewSphericalSpace(1),
+    0, .05, .000001, math.Inf(1), 10000)
+
+  if !test.VectorCloseEnough(flat.TracePath([]float64{0, 0, 0}, []float64{1, 0, 0}, 4, 1./256.),
+    []float64{.1, .1, .1}, geo_err) {
+    t.Error("spherical geodesic error 1")
+  }
+
+  ray := &LightRay{0, 0, []float64{0, 0, 0}, []float64{1, 0, 0}, []float64{4, 5, 6},
+    []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+  if curved.nextGeodesicIntersection(ray, -1) != 0 ||
+    !test.VectorCloseEnough(ray.position, []float64{-.7, 0, 0}, geo_err) {
+    t.Error("spherical geodesic error 2: got ", ray.position)
+  }
+}
+
+//A black hole with an accretion disk around it.
+func TestBlackHoleGeodesic(t *testing.T) {
+  hole := geometry.NewSchwarzschildSpace(1)
+  objects := []*ExtendedObject{NewExtendedObject(
+    polynomialsurfaces.NewTorus([]float64{0, 0, 0}, []float64{0, 0, 1}, 8, 1.5),
+    NewGlowingObject([]float64{.5, .7, .9}))}
+
+  if NewCurvedScene(objects, testBackground, hole, 0, .5, .000001, 1.5, 10000) != nil {
+    t.Error("black hole geodesic error 1")
+  }
+
+  scene := NewCurvedScene(objects, testBackground, hole, 0, .5, .000001, 50, 10000)
+
+  //The last ray would miss the disk if it were not bent by the hole.
+  expected := [][]float64{{0, 0, 0}, {.1, .1, .1}, {.5, .7, .9}}
+  for i, dir := range [][]float64{{0, 0, -1}, {0, 0, 1}, {11, 0, -20}} {
+    c := scene.TracePath([]float64{0, 0, 20}, dir, 4, 1./256.)
+
+    if !test.VectorCloseEnough(c, expected[i], geo_err) {
+      t.Error("black hole geodesic error 2, case ", i, ": got ", c)
+    }
+  }
+}
+
+//A wormhole with different objects and backgrounds on each side.
+func TestWormholeGeodesic(t *testing.T) {
+  wormhole := geometry.NewEllisWormhole(2)
+  upper := color.ConstantColorFunction(color.PresetColor([]float64{.1, .1, .1}))
+  lower := color.ConstantColorFunction(color.PresetColor([]float64{.2, .3, .4}))
+  objects := [][]*ExtendedObject{{}, glowingSphereObjects([]float64{0, 0, 6}, 1), {}}
+  backgrounds := []color.SphericalColorFunction{upper, lower, upper}
+
+  if NewRegionalScene(objects[:2], backgrounds, wormhole, 0, .1, .000001, 50, 10000) != nil {
+    t.Error("wormhole geodesic error 1")
+  }
+  if NewRegionalScene(objects, []color.SphericalColorFunction{upper, nil, upper},
+    wormhole, 0, .1, .000001, 50, 10000) != nil {
+    t.Error("wormhole geodesic error 2")
+  }
+
+  scene := NewRegionalScene(objects, backgrounds, wormhole, 0, .1, .000001, 50, 10000)
+  empty := NewRegionalScene([][]*ExtendedObject{{}, {}, {}}, backgrounds, wormhole, 0, .1, .000001, 50, 10000)
+
+  //A ray that goes into the throat along the z axis comes out the
+  //other side going the other way in the coordinates of that side.
+  ray := &LightRay{0, 0, []float64{0, 0, 10}, []float64{0, 0, -1}, []float64{4, 5, 6},
+    []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+  if scene.nextGeodesicIntersection(ray, -1) != 0 || ray.region != 1 ||
+    !test.VectorCloseEnough(ray.position, []float64{0, 0, 5}, geo_err) {
+    t.Error("wormhole geodesic error 3: got ", ray.region, ray.position)
+  }
+
+  cases := []struct {
+    scene *Scene
+    dir, expected []float64
+  }{
+    {scene, []float64{0, 0, 1}, []float64{.1, .1, .1}},
+    {scene, []float64{0, 0, -1}, []float64{.5, .7, .9}},
+    {empty, []float64{0, 0, -1}, []float64{.2, .3, .4}}}
+  for i, c := range cases {
+    if col := c.scene.TracePath([]float64{0, 0, 10}, c.dir, 4, 1./256.); !test.VectorCloseEnough(col, c.expected, geo_err) {
+      t.Error("wormhole geodesic error 4, case ", i, ": got ", col)
+    }
+  }
+}
+
+//A flat space with two regions, in which points beyond z = 2
+//cannot be moved from one region to the other.
+type brokenChart struct {
+  geometry.Space
+}
+
+func (b *brokenChart) Regions() int {
+  return 2
+}
+
+func (b *brokenChart) Transform(from, to int, x, v []float64) ([]float64, []float64, *geometry.InvalidCoordinateError) {
+  if from != to && x[3] > 2 {
+    return nil, nil, &geometry.InvalidCoordinateError{}
+  }
+  return x, v, nil
+}
+
+//Where a point cannot be given in the coordinates of the region of
+//a surface, it is on neither side of the surface, rather than outside.
+func TestBrokenChartGeodesic(t *testing.T) {
+  space := &brokenChart{geometry.NewMinkowskiSpace()}
+  g := &geodesicTracer{space: space, region: 0}
+  e := g.surfaceEvent(1, polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 100).F)
+
+  if !(e(0, []float64{0, 0, 0, 1}) > 0) || !math.IsNaN(e(0, []float64{0, 0, 0, 3})) {
+    t.Error("broken chart geodesic error 1: got ", e(0, []float64{0, 0, 0, 1}), e(0, []float64{0, 0, 0, 3}))
+  }
+
+  //The ray starts inside a sphere in the other region and
+  //escapes before it could reach it.
+  scene := NewRegionalScene([][]*ExtendedObject{{}, glowingSphereObjects([]float64{0, 0, 0}, 100)},
+    []color.SphericalColorFunction{testBackground, testBackground}, space, 0, .1, .000001, 10, 10000)
+  if c := scene.TracePath([]float64{0, 0, 0}, []float64{0, 0, 1}, 4, 1./256.);
+    !test.VectorCloseEnough(c, []float64{.1, .1, .1}, geo_err) {
+    t.Error("broken chart geodesic error 2: got ", c)
+  }
+}
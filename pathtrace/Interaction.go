@@ -1,7 +1,8 @@
 package pathtrace
 
-import "math/rand"
+import "math"
 import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
 
 type ColorInteraction func(ray *LightRay) 
 
@@ -47,6 +48,21 @@ type Interactor interface {
   //Trace(scene *Scene, ray *LightRay) []float64 TODO
 }
 
+//An interactor which can say how it scatters light, so that light
+//can be sampled directly rather than found by chance. A ray is at
+//x on the surface, having arrived going in direction in. 
+type Scatterer interface {
+  Interactor
+  //The density, with respect to solid angle, with which Interact
+  //sends the ray off in direction out.
+  Pdf(x, in, out []float64) float64
+  //The BRDF times the cosine of the angle of out to the normal.
+  //This is the factor by which light that comes from direction out
+  //is scaled on being scattered back along in, relative to the
+  //color that Interact gives the ray.
+  BRDF(x, in, out []float64) float64
+}
+
 //An object that just glows.
 type glowEmitter struct {
   glow []float64
@@ -75,10 +91,22 @@ type lambertianReflector struct {
 
 func (l *lambertianReflector) Interact(ray *LightRay) *LightRay {
   l.color(ray)
-  ray.direction = LambertianReflection(ray.direction, surface.SurfaceNormal(l.surf, ray.position))
+  ray.direction = LambertianReflection(ray.sampler, ray.direction, surface.SurfaceNormal(l.surf, ray.position))
   return ray
 }
 
+//Directions are chosen with a density proportional to the cosine of
+//the angle to the normal, which is the same as the BRDF times the cosine.
+func (l *lambertianReflector) Pdf(x, in, out []float64) float64 {
+  d := vector.Dot(surface.SurfaceNormal(l.surf, x), out) / vector.Length(out)
+  if d <= 0 { return 0 }
+  return d / math.Pi
+}
+
+func (l *lambertianReflector) BRDF(x, in, out []float64) float64 {
+  return l.Pdf(x, in, out)
+}
+
 /*func (l *lambertianReflector) Trace(scene *Scene, ray *LightRay, u float64) []float64 {
   return DeriveColor(g.glow(ray.color, ray.emission, ray.redirected))
 }*/
@@ -96,7 +124,7 @@ type mirrorReflector struct {
 
 func (l *mirrorReflector) Interact(ray *LightRay) *LightRay {
   l.color(ray)
-  ray.direction = MirrorReflection(ray.direction, surface.SurfaceNormal(l.surf, ray.position))
+  ray.direction = MirrorReflection(ray.sampler, ray.direction, surface.SurfaceNormal(l.surf, ray.position))
   return ray
 }
 
@@ -114,7 +142,7 @@ type redirectorInteractor struct {
 
 func (l *redirectorInteractor) Interact(ray *LightRay) *LightRay {
   l.color(ray)
-  ray.direction = l.redirect(ray.direction, surface.SurfaceNormal(l.surf, ray.position))
+  ray.direction = l.redirect(ray.sampler, ray.direction, surface.SurfaceNormal(l.surf, ray.position))
   return ray
 }
 
@@ -141,7 +169,7 @@ type scatterInteractor struct {
 
 func (l *scatterInteractor) Interact(ray *LightRay) *LightRay {
   l.color(ray)
-  ray.direction = l.redirect(ray.direction, nil)
+  ray.direction = l.redirect(ray.sampler, ray.direction, nil)
   return ray
 }
 
@@ -161,15 +189,15 @@ type multipleInteractor struct {
 }
 
 func (s *multipleInteractor) Interact(ray *LightRay) *LightRay {
-  spin := rand.Float64()
+  spin := ray.random().Float64()
   s.color(ray)
 
   for i, p := range s.probabilities {
     if spin < p {
-      for j := 0; j < 3; j ++ {
+      for j := 0; j < len(ray.color); j ++ {
         ray.color[j] *= s.factors[i]
       }
-      ray.direction = s.redirects[i](ray.direction, surface.SurfaceNormal(s.surf, ray.position))
+      ray.direction = s.redirects[i](ray.sampler, ray.direction, surface.SurfaceNormal(s.surf, ray.position))
       break 
     } else {
       spin -= p
@@ -210,3 +238,50 @@ func NewGlassInteractor(surf surface.Surface, color ColorInteraction, index, a,
   return NewMultipleInteractor(surf, color,  []float64{a / ab, b / ab}, []float64{ab, ab}, 
     []Redirection{BasicRefraction(index), MirrorReflection})
 }
+
+//Glass whose refractive index depends on the wavelength, so that white
+//light is split into colors. A ray which is refracted keeps only one of
+//its wavelengths. Rays are traced in rgb unless the scene is spectral,
+//in which case the colors are continuous. 
+type dispersiveInteractor struct {
+  surf surface.Surface
+  color ColorInteraction
+  index Dispersion
+  //The chance that a ray is refracted rather than reflected.
+  transmit float64
+  //The factor by which the color of the ray is multiplied.
+  factor float64
+}
+
+func (d *dispersiveInteractor) Interact(ray *LightRay) *LightRay {
+  d.color(ray)
+  normal := surface.SurfaceNormal(d.surf, ray.position)
+
+  if ray.random().Float64() < d.transmit {
+    ray.direction = BasicRefraction(d.index(ray.SelectWavelength()))(ray.sampler, ray.direction, normal)
+  } else {
+    ray.direction = MirrorReflection(ray.sampler, ray.direction, normal)
+  }
+
+  for i := 0; i < len(ray.color); i ++ {
+    ray.color[i] *= d.factor
+  }
+  return ray
+}
+
+//Like NewGlassInteractor, but with an index that depends on the wavelength.
+//
+//May return nil.
+func NewDispersiveGlassInteractor(surf surface.Surface, color ColorInteraction, index Dispersion, a, b float64) Interactor {
+  if surf == nil || color == nil || index == nil {return nil}
+  ab := a + b
+  if !(a >= 0) || !(b >= 0) || !(ab > 0) {return nil}
+  return &dispersiveInteractor{surf, color, index, a / ab, ab}
+}
+
+//Like NewBasicRefractiveTransmitor, but with an index that depends on the wavelength.
+//
+//May return nil.
+func NewDispersiveTransmitter(surf surface.Surface, color ColorInteraction, index Dispersion) Interactor {
+  return NewDispersiveGlassInteractor(surf, color, index, 1, 0)
+}
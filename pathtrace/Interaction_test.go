@@ -1,7 +1,7 @@
 package pathtrace
 
 import "testing"
-import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
 import "github.com/DanielKrawisz/CurvedSpace/test"
 
 func TestGlowInteractor(t *testing.T) {
@@ -13,7 +13,7 @@ func TestGlowInteractor(t *testing.T) {
     return
   }
 
-  ray := &LightRay{0, []float64{}, []float64{}, []float64{4, 5, 6}, []float64{1, 1, 1}, []float64{1, 1, 1}, 1}
+  ray := &LightRay{0, 0, []float64{}, []float64{}, []float64{4, 5, 6}, []float64{1, 1, 1}, []float64{1, 1, 1}, 1, nil, 0}
   glow.Interact(ray)
   if !(test.VectorCloseEnough(ray.color, []float64{1, 1, 1}, mat_err) && 
        test.VectorCloseEnough(ray.emission, []float64{1.5, 1.7, 1.9}, mat_err) && 
@@ -34,23 +34,23 @@ func TestGlowInteractor(t *testing.T) {
 func TestLambertianReflector(t *testing.T) {
   if NewLambertianReflector(nil, Absorb([]float64{0, 0, 0})) != nil {
     t.Error("lambertian error 1") }
-  if NewLambertianReflector(surface.NewSphere([]float64{0, 0, 0}, 1), nil) != nil {
+  if NewLambertianReflector(polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 1), nil) != nil {
     t.Error("lambertian error 2") }
 }
 
 func TestMirrorReflector(t *testing.T) {
   if NewMirrorReflector(nil, Absorb([]float64{0, 0, 0})) != nil {
     t.Error("lambertian error 1") }
-  if NewMirrorReflector(surface.NewSphere([]float64{0, 0, 0}, 1), nil) != nil {
+  if NewMirrorReflector(polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 1), nil) != nil {
     t.Error("lambertian error 2") }
 }
 
 func TestRedirectorReflector(t *testing.T) {
   if NewRedirectorInteractor(nil, Absorb([]float64{0, 0, 0}), MirrorReflection) != nil {
     t.Error("redirector error 1") }
-  if NewRedirectorInteractor(surface.NewSphere([]float64{0, 0, 0}, 1), nil, MirrorReflection) != nil {
+  if NewRedirectorInteractor(polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 1), nil, MirrorReflection) != nil {
     t.Error("redirector error 2") }
-  if NewRedirectorInteractor(surface.NewSphere([]float64{0, 0, 0}, 1), Absorb([]float64{0, 0, 0}), nil) != nil {
+  if NewRedirectorInteractor(polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 1), Absorb([]float64{0, 0, 0}), nil) != nil {
     t.Error("redirector error 3") }
 }
 
@@ -0,0 +1,240 @@
+package pathtrace
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//A lens system is a camera's stack of lenses, given by a prescription as
+//lenses are in books on optics: a list of the surfaces from the front of
+//the camera, where light comes in, to the back, where the film is. Rays
+//are traced from the film out through the surfaces, which are spheres,
+//and are refracted at each of them. A ray which misses the opening of
+//a surface is stopped, which darkens the corners of the picture, and if
+//the glass disperses light, each wavelength goes its own way, which gives
+//colored fringes at the edges of things.
+//
+//The lens is built around the z axis, along which light goes from the
+//front toward the film, which is at the origin.
+
+//A surface of a lens system and what lies behind it.
+type LensElement struct {
+  //The radius of curvature of the surface, which is positive if its
+  //center is behind it, toward the film, and zero if it is flat.
+  Radius float64
+  //The distance along the axis to the next surface, or
+  //from the last surface to the film.
+  Thickness float64
+  //The glass behind the surface, or nil if it is air. A flat surface
+  //with air on both sides is an aperture stop.
+  Index Dispersion
+  //The radius of the opening of the surface.
+  Aperture float64
+}
+
+type LensSystem struct {
+  elements []LensElement
+  //The surfaces, and how far each is in front of the film.
+  surfaces []surface.Surface
+  z []float64
+  //Whether any of the glass disperses light.
+  dispersive bool
+}
+
+//May return nil, if the surfaces are not in order, if an opening is wider
+//than its surface, or if there is no room for the film.
+func NewLensSystem(elements []LensElement) *LensSystem {
+  if len(elements) == 0 { return nil }
+
+  l := &LensSystem{elements: append([]LensElement(nil), elements...)}
+  for i, e := range l.elements {
+    if !(e.Aperture > 0) || e.Thickness < 0 { return nil }
+    if e.Radius != 0 && math.Abs(e.Radius) < e.Aperture { return nil }
+    if i == len(l.elements) - 1 && !(e.Thickness > 0) { return nil }
+
+    if e.Index != nil && e.Index(400) != e.Index(700) {
+      l.dispersive = true
+    }
+  }
+
+  l.build()
+  return l
+}
+
+//Find where the surfaces are.
+func (l *LensSystem) build() {
+  n := len(l.elements)
+  l.surfaces = make([]surface.Surface, n)
+  l.z = make([]float64, n)
+
+  var z float64
+  for i := n - 1; i >= 0; i -- {
+    e := l.elements[i]
+    z += e.Thickness
+    l.z[i] = z
+
+    if e.Radius == 0 {
+      l.surfaces[i] = polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, z}, []float64{0, 0, 1}, true)
+    } else {
+      l.surfaces[i] = polynomialsurfaces.NewSphere([]float64{0, 0, z - e.Radius}, math.Abs(e.Radius))
+    }
+  }
+}
+
+//The index of refraction of glass at a wavelength.
+func refractiveIndex(glass Dispersion, wavelength float64) float64 {
+  if glass == nil { return 1 }
+  return glass(wavelength)
+}
+
+//Trace a ray from the film out through the lens for light of the given
+//wavelength. Returns nil if the ray is stopped.
+func (l *LensSystem) trace(pos, dir []float64, wavelength float64) ([]float64, []float64) {
+  pos, dir = append([]float64(nil), pos...), append([]float64(nil), dir...)
+
+  for i := len(l.elements) - 1; i >= 0; i -- {
+    e := l.elements[i]
+
+    //A sphere is hit twice; the surface is the part near the axis.
+    var u float64
+    var hit bool
+    nearest := math.Inf(1)
+    for _, t := range l.surfaces[i].Intersection(pos, dir) {
+      if d := math.Abs(pos[2] + t * dir[2] - l.z[i]); d < nearest {
+        u, nearest, hit = t, d, true
+      }
+    }
+    if !hit || !(u > 0) { return nil, nil }
+
+    pos = vector.LinearSum(1, u, pos, dir)
+    if pos[0] * pos[0] + pos[1] * pos[1] > e.Aperture * e.Aperture { return nil, nil }
+
+    in := refractiveIndex(e.Index, wavelength)
+    out := 1.
+    if i > 0 {
+      out = refractiveIndex(l.elements[i - 1].Index, wavelength)
+    }
+    if in == out { continue }
+
+    //The normal faces back toward where the ray came from. If the ray
+    //is reflected rather than refracted, it does not get out.
+    normal := surface.SurfaceNormal(l.surfaces[i], pos)
+    if vector.Dot(normal, dir) > 0 {
+      normal = vector.Negative(normal)
+    }
+    dir = BasicRefraction(out / in)(nil, dir, normal)
+    if vector.Dot(dir, normal) >= 0 { return nil, nil }
+  }
+
+  return pos, dir
+}
+
+//How far in front of the front of the lens a ray from the middle of the
+//film which goes through the lens just off the axis crosses the axis, or
+//infinity if it does not.
+func (l *LensSystem) conjugate() float64 {
+  back := len(l.elements) - 1
+  pos, dir := l.trace([]float64{0, 0, 0}, []float64{l.elements[back].Aperture / 100, 0, l.z[back]}, FraunhoferD)
+  if dir == nil || !(dir[0] < 0) { return math.Inf(1) }
+
+  return pos[2] - pos[0] * dir[2] / dir[0] - l.z[0]
+}
+
+//Move the film so that what is the given distance in front of the front
+//of the lens is in focus for the d line. Returns false, leaving the film
+//where it was, if the lens cannot focus there.
+func (l *LensSystem) Focus(distance float64) bool {
+  if !(distance > 0) { return false }
+
+  back := len(l.elements) - 1
+  old := l.elements[back].Thickness
+  set := func(t float64) float64 {
+    l.elements[back].Thickness = t
+    l.build()
+    return l.conjugate()
+  }
+
+  //The farther the film is from the lens, the nearer is what is in
+  //focus, until it is nearer than the front focal point.
+  lo, hi := 0., old
+  for k := 0; set(hi) > distance; k ++ {
+    if k == 64 {
+      set(old)
+      return false
+    }
+    lo, hi = hi, 2 * hi
+  }
+
+  for k := 0; k < 64; k ++ {
+    mid := (lo + hi) / 2
+    if set(mid) > distance {
+      lo = mid
+    } else {
+      hi = mid
+    }
+  }
+  set(hi)
+  return true
+}
+
+//The distance from the back of the lens to the film.
+func (l *LensSystem) FilmDistance() float64 {
+  return l.elements[len(l.elements) - 1].Thickness
+}
+
+//The distance from the film to the front of the lens.
+func (l *LensSystem) Length() float64 {
+  return l.z[0]
+}
+
+//A camera which looks through a lens system. The film is at pos, facing
+//mtrx[0], and is 2 fov_u wide and 2 fov_v high, in the same units as the
+//lens, with up mtrx[1] and right mtrx[2] as for the other cameras. Each
+//ray is aimed from its pixel on the film at a point on the back of the
+//lens, and rays which the lens stops see black. The picture on the film
+//is upside down, so it is turned the right way up.
+//
+//The lens is shared with the camera, so if it is focused again, so is
+//the camera. If the lens disperses light and the sampler is a
+//WavelengthSampler, each ray is traced for a wavelength that it chooses.
+//
+//May return nil.
+func LensCamera(lens *LensSystem, pos []float64, mtrx [][]float64, pix_u, pix_v int, fov_u, fov_v float64) GenerateRay {
+  if lens == nil || !validCamera(pos, mtrx) { return nil }
+
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    ray_pos := make([]float64, len(pos))
+    copy(ray_pos, pos)
+
+    ou, ov := CameraCoordinates(s, i, j, pix_u, pix_v, fov_u, fov_v)
+    film := []float64{-ou, -ov, 0}
+
+    back := len(lens.elements) - 1
+    a, b := LensPoint(s, 0, 0)
+    r := lens.elements[back].Aperture
+    dir := vector.Minus([]float64{a * r, b * r, lens.z[back]}, film)
+
+    //Light which comes to the film at a slant is spread more thinly, and
+    //the lens looks smaller from there, so the film gets less of it by the
+    //fourth power of the cosine of the angle. Rays are stopped at random
+    //to make up for it.
+    cos := dir[2] / vector.Length(dir)
+    if s.Float64() > cos * cos * cos * cos { return ray_pos, nil }
+
+    wavelength := FraunhoferD
+    if w, ok := s.(WavelengthSampler); ok && lens.dispersive {
+      wavelength = w.SelectWavelength()
+    }
+
+    p, d := lens.trace(film, dir, wavelength)
+    if d == nil { return ray_pos, nil }
+
+    ray_dir := make([]float64, len(pos))
+    for k := 0; k < len(pos); k ++ {
+      ray_pos[k] += p[2] * mtrx[0][k] + p[1] * mtrx[1][k] + p[0] * mtrx[2][k]
+      ray_dir[k] = d[2] * mtrx[0][k] + d[1] * mtrx[1][k] + d[0] * mtrx[2][k]
+    }
+    return ray_pos, ray_dir
+  }
+}
@@ -0,0 +1,160 @@
+package pathtrace
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+//A plano-convex lens of glass, with a stop behind it.
+func planoConvexLens(glass Dispersion) []LensElement {
+  return []LensElement{
+    {50, 2, glass, 10},
+    {0, 1, nil, 10},
+    {0, 100, nil, 5}}
+}
+
+//Where a ray from the middle of the film which goes through
+//the back of the lens at height h crosses the axis.
+func lensCrossing(l *LensSystem, h, wavelength float64) float64 {
+  pos, dir := l.trace([]float64{0, 0, 0}, []float64{h, 0, l.z[len(l.z) - 1]}, wavelength)
+  if dir == nil { return math.NaN() }
+  return pos[2] - pos[0] * dir[2] / dir[0]
+}
+
+func TestLensSystem(t *testing.T) {
+  glass := Cauchy(1.5)
+  for i, elements := range [][]LensElement{nil,
+    {{50, 2, glass, 60}},
+    {{50, 2, glass, 0}},
+    {{50, -2, glass, 10}, {0, 100, nil, 10}},
+    {{50, 2, glass, 10}, {0, 0, nil, 10}}} {
+    if NewLensSystem(elements) != nil {
+      t.Error("lens system error 1: ", i)
+    }
+  }
+
+  l := NewLensSystem(planoConvexLens(glass))
+  if l == nil {
+    t.Error("lens system error 2")
+    return
+  }
+  if l.dispersive {
+    t.Error("lens system error 3")
+  }
+
+  //The focal length is R / (n - 1) = 100 from the middle of the lens,
+  //which is where the back of the lens is if it is thin, but it is two
+  //thick, so it is a little nearer the back.
+  if l.Length() != 103 || !l.Focus(1e12) || !test.CloseEnough(l.FilmDistance(), 100 - 2 / 1.5 - 1, .05) {
+    t.Error("lens system error 4: ", l.FilmDistance())
+  }
+
+  //Then rays near the axis cross it where they are in focus.
+  if !l.Focus(200) {
+    t.Error("lens system error 5")
+  }
+  for _, h := range []float64{.01, .1, .5} {
+    if z := lensCrossing(l, h, FraunhoferD) - l.z[0]; !test.CloseEnough(z, 200, 1) {
+      t.Error("lens system error 6: ", h, z)
+    }
+  }
+
+  //The film cannot be anywhere to focus on what is nearer than the focal point.
+  film := l.FilmDistance()
+  if l.Focus(50) || l.Focus(-1) || l.FilmDistance() != film {
+    t.Error("lens system error 7: ", l.FilmDistance())
+  }
+
+  //Rays outside the opening of a surface are stopped.
+  if _, dir := l.trace([]float64{0, 0, 0}, []float64{6, 0, l.z[2]}, FraunhoferD); dir != nil {
+    t.Error("lens system error 8: ", dir)
+  }
+
+  //Blue light is bent more than red, so it is in focus nearer the lens.
+  l = NewLensSystem(planoConvexLens(Abbe(1.5, 40)))
+  if !l.dispersive {
+    t.Error("lens system error 9")
+  }
+  l.Focus(200)
+  if blue, red := lensCrossing(l, .1, 450), lensCrossing(l, .1, 650); !(blue < red) {
+    t.Error("lens system error 10: ", blue, red)
+  }
+}
+
+func TestLensCamera(t *testing.T) {
+  l := NewLensSystem(planoConvexLens(Cauchy(1.5)))
+  l.Focus(200)
+
+  pos := []float64{1, 2, 3}
+  mtrx := [][]float64{{0, 0, 1}, {0, 1, 0}, {1, 0, 0}}
+  if LensCamera(nil, pos, mtrx, 11, 11, 40, 40) != nil || LensCamera(l, nil, mtrx, 11, 11, 40, 40) != nil ||
+    LensCamera(l, pos, nil, 11, 11, 40, 40) != nil {
+    t.Error("lens camera error 1")
+  }
+
+  //Every ray from the middle pixel comes out of the front
+  //of the lens toward the point in focus.
+  camJitter = MockCameraStochastic
+  cam := LensCamera(l, pos, mtrx, 11, 11, 40, 40)
+  s := distributions.NewRandomSampler(1)
+  focus := []float64{1, 2, 3 + l.z[0] + 200}
+  var stopped [2]int
+  for k := 0; k < 1000; k ++ {
+    p, d := cam(s, 5, 5)
+    if d == nil {
+      stopped[0] ++
+      continue
+    }
+    if !test.CloseEnough(p[2], 3 + l.z[0], 1) {
+      t.Error("lens camera error 2: ", p)
+    }
+
+    u := (focus[2] - p[2]) / d[2]
+    if !test.CloseEnough(p[0] + u * d[0], 1, .5) || !test.CloseEnough(p[1] + u * d[1], 2, .5) {
+      t.Error("lens camera error 3: ", p, d)
+    }
+
+    //The picture is turned the right way up, so the top left corner
+    //of the picture is in focus to the left of and above the middle.
+    p, d = cam(s, 0, 0)
+    if d == nil {
+      stopped[1] ++
+    } else if u := (focus[2] - p[2]) / d[2]; !(p[0] + u * d[0] < 1 - 20 && p[1] + u * d[1] > 2 + 20) {
+      t.Error("lens camera error 4: ", p, d)
+    }
+  }
+  camJitter = CameraStochastic
+
+  //More rays are stopped from the corners.
+  if stopped[0] > 50 || !(stopped[1] > stopped[0] + 100) {
+    t.Error("lens camera error 5: ", stopped)
+  }
+}
+
+//A camera can choose the wavelength of its ray, which
+//then carries only light of that wavelength.
+func TestCameraSampler(t *testing.T) {
+  scene := progressiveScene()
+  for _, n := range []int{0, 4} {
+    scene.SetSpectral(n)
+    scene.sampler.Start(0, 0, 0)
+    s := &cameraSampler{scene.sampler, scene, nil}
+    var w WavelengthSampler = s
+    l := w.SelectWavelength()
+    if s.ray == nil || !(l >= color.MinWavelength && l <= color.MaxWavelength) {
+      t.Error("camera sampler error 1: ", n, l)
+      continue
+    }
+
+    var live int
+    for _, c := range s.ray.color {
+      if c != 0 {
+        live ++
+      }
+    }
+    if live != 1 {
+      t.Error("camera sampler error 2: ", n, s.ray.color)
+    }
+  }
+}
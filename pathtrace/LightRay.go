@@ -1,12 +1,19 @@
 package pathtrace
 
+import "math"
+import "math/rand"
+import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+
 //This might be updated to be more of an interface or whatever. 
 type LightRay struct {
   //The number of steps the ray has taken.
   depth int
+  //The region of space in whose coordinates the ray is given.
+  region int
   //The ray. 
   position, direction []float64
-  //The wavelengths of light that this ray tracks.
+  //The wavelengths of light that this ray tracks, or color.RGBReceptor.
   receptor []float64
   //The intensities of those wavelengths. 
   color []float64
@@ -15,10 +22,29 @@ type LightRay struct {
   //adjusted to take these earlier interactions into account.
   emission []float64
   redirected float64 
+  //The sampler that gives the random numbers used to trace the ray.
+  sampler distributions.Sampler
+  //The time at which the ray is traced, when the camera's
+  //shutter is open, which says where moving objects are.
+  time float64
+}
+
+//The time at which the ray is traced.
+func (r *LightRay) Time() float64 {
+  return r.time
+}
+
+//The random numbers for the ray. A ray which has no sampler is given
+//one of its own the first time that it needs random numbers.
+func (r *LightRay) random() *rand.Rand {
+  if r.sampler == nil {
+    r.sampler = distributions.NewRandomSampler(0)
+  }
+  return r.sampler.Rand()
 }
 
 func (r *LightRay) Trace(u float64) {
-  for i := 0; i < 3; i ++ {
+  for i := 0; i < len(r.position); i ++ {
     r.position[i] = r.position[i] + u * r.direction[i]
   }
 }
@@ -32,9 +58,19 @@ func (r *LightRay) DeriveColor() (c []float64) {
   return 
 }
 
+//Colors are given in rgb. If the ray is spectral, they are
+//turned into spectra at the wavelengths of the ray.
+func (r *LightRay) values(c []float64) []float64 {
+  if color.IsSpectral(r.receptor) {
+    return color.RGBToSpectrum(c, r.receptor)
+  }
+  return c
+}
+
 //A function to make an object glow.
 func (r *LightRay) Glow(c []float64) {
-  for i := 0; i < 3; i ++ {
+  c = r.values(c)
+  for i := 0; i < len(r.color); i ++ {
     r.emission[i] = r.color[i] * c[i] * r.redirected + r.emission[i]
   }
   r.redirected = 0
@@ -42,16 +78,70 @@ func (r *LightRay) Glow(c []float64) {
 
 //A function to make an object absorb light.
 func (r *LightRay) Absorb(c []float64) {
-  for l := 0; l < 3; l ++ {
+  c = r.values(c)
+  for l := 0; l < len(r.color); l ++ {
     r.color[l] *= c[l]
   }
 }
 
 //A function for both.
 func (r *LightRay) GlowAbsorbAverage(glow_color, transmit_color []float64, absorb float64) {
-  for i := 0; i < 3; i ++ {
+  glow_color, transmit_color = r.values(glow_color), r.values(transmit_color)
+  for i := 0; i < len(r.color); i ++ {
     r.emission[i] += r.redirected * absorb * glow_color[i]
     r.color[i] *= transmit_color[i]
   }
   r.redirected *= (1 - absorb)
 }
+
+//Russian roulette. The ray goes on with a chance equal to the greatest
+//fraction of any wavelength that it still carries, and its color is
+//divided by that chance so that it is right on average. If it does
+//not go on, it carries no more light.
+func (r *LightRay) survive() bool {
+  var q float64
+  for _, c := range r.color {
+    q = math.Max(q, c * r.redirected)
+  }
+  if q >= 1 {
+    return true
+  }
+
+  if !(r.random().Float64() < q) {
+    r.redirected = 0
+    return false
+  }
+
+  for i := 0; i < len(r.color); i ++ {
+    r.color[i] /= q
+  }
+  return true
+}
+
+//Things like refraction send each wavelength in a different direction, so
+//the ray must choose one of its wavelengths to follow. One of those which
+//still carry light is chosen at random and the others are dropped. Returns
+//the wavelength in nanometers.
+func (r *LightRay) SelectWavelength() float64 {
+  wavelengths := color.Wavelengths(r.receptor)
+
+  var live []int
+  for i, c := range r.color {
+    if c != 0 {
+      live = append(live, i)
+    }
+  }
+  if len(live) == 0 {
+    return wavelengths[0]
+  }
+
+  k := live[r.random().Intn(len(live))]
+  for i := range r.color {
+    if i == k {
+      r.color[i] *= float64(len(live))
+    } else {
+      r.color[i] = 0
+    }
+  }
+  return wavelengths[k]
+}
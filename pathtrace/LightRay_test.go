@@ -2,17 +2,25 @@ package pathtrace
 
 import "testing"
 import "github.com/DanielKrawisz/CurvedSpace/vector"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/surface/booleans"
 import "github.com/DanielKrawisz/CurvedSpace/test"
+import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
 
 var mat_err float64 = .00001
 
+//Gives the random numbers for rays in tests.
+var testSampler distributions.Sampler = distributions.NewRandomSampler(1)
+
 //Just testing some particular conditions for each of the functions. 
 
 func TestDeriveColor(t *testing.T) {
   color := []float64{.1, .2, .3}
   emission := []float64{.4, .5, .6}
   redirected := .7
-  ray := &LightRay{0, []float64{}, []float64{}, []float64{4, 5, 6}, color, emission, redirected}
+  ray := &LightRay{0, 0, []float64{}, []float64{}, []float64{4, 5, 6}, color, emission, redirected, nil, 0}
 
   c := ray.DeriveColor()
 
@@ -21,11 +29,22 @@ func TestDeriveColor(t *testing.T) {
   }
 }
 
+//A ray moves in every dimension of its space.
+func TestTrace(t *testing.T) {
+  ray := &LightRay{0, 0, []float64{1, 2, 3, 4}, []float64{0, 1, 0, -1}, []float64{4, 5, 6},
+    []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+
+  ray.Trace(2)
+  if !test.VectorCloseEnough(ray.position, []float64{1, 4, 3, 2}, mat_err) {
+    t.Error("trace error: ", ray.position)
+  }
+}
+
 func TestGlow(t *testing.T) {
   rec := []float64{.1, .2, .3}
   em  := []float64{.4, .5, .6}
   red := .7
-  ray := &LightRay{0, []float64{}, []float64{}, []float64{4, 5, 6}, rec, em, red}
+  ray := &LightRay{0, 0, []float64{}, []float64{}, []float64{4, 5, 6}, rec, em, red, nil, 0}
 
   ray.Glow([]float64{.4, .7, .9})
 
@@ -44,7 +63,7 @@ func TestAbsorb(t *testing.T) {
   rec := []float64{.1, .2, .3}
   em  := []float64{.4, .5, .6}
   red := .7
-  ray := &LightRay{0, []float64{}, []float64{}, []float64{4, 5, 6}, rec, em, red}
+  ray := &LightRay{0, 0, []float64{}, []float64{}, []float64{4, 5, 6}, rec, em, red, nil, 0}
 
   ray.Absorb([]float64{.4, .7, .9})
 
@@ -64,7 +83,7 @@ func TestGlowAbsorbAverage(t *testing.T) {
   rec := []float64{.1, .2, .3}
   em  := []float64{.4, .5, .6}
   red := .7
-  ray := &LightRay{0, []float64{}, []float64{}, []float64{4, 5, 6}, rec, em, red}
+  ray := &LightRay{0, 0, []float64{}, []float64{}, []float64{4, 5, 6}, rec, em, red, nil, 0}
 
   ray.GlowAbsorbAverage([]float64{.4, .7, .9}, []float64{.5, .6, .8}, .3)
 
@@ -78,3 +97,224 @@ func TestGlowAbsorbAverage(t *testing.T) {
     t.Error("Average error 5")
   }
 }
+
+//Colors are given in rgb and turned into spectra for spectral rays.
+func TestSpectralRay(t *testing.T) {
+  w := []float64{450, 550, 650}
+  ray := &LightRay{0, 0, []float64{}, []float64{}, w, []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+
+  ray.Absorb([]float64{1, 0, 0})
+  if !(ray.color[2] > .9 && ray.color[0] < .1) {
+    t.Error("spectral ray error 1: ", ray.color)
+  }
+
+  ray = &LightRay{0, 0, []float64{}, []float64{}, w, []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+  ray.Glow([]float64{.5, .5, .5})
+  if !test.VectorCloseEnough(ray.DeriveColor(), []float64{.5, .5, .5}, mat_err) {
+    t.Error("spectral ray error 2: ", ray.DeriveColor())
+  }
+}
+
+func TestSelectWavelength(t *testing.T) {
+  //An rgb ray gives the wavelength of the channel it keeps.
+  for i := 0; i < 20; i ++ {
+    ray := &LightRay{0, 0, []float64{}, []float64{}, []float64{4, 5, 6}, []float64{1, 0, 1}, []float64{0, 0, 0}, 1, testSampler, 0}
+    l := ray.SelectWavelength()
+
+    switch l {
+    case 610:
+      if !test.VectorCloseEnough(ray.color, []float64{2, 0, 0}, mat_err) {
+        t.Error("select wavelength error 1: ", ray.color)
+      }
+    case 465:
+      if !test.VectorCloseEnough(ray.color, []float64{0, 0, 2}, mat_err) {
+        t.Error("select wavelength error 2: ", ray.color)
+      }
+    default:
+      t.Error("select wavelength error 3: ", l)
+    }
+
+    //Once a wavelength is chosen, it stays.
+    if m := ray.SelectWavelength(); m != l {
+      t.Error("select wavelength error 4: ", l, m)
+    }
+  }
+
+  ray := &LightRay{0, 0, []float64{}, []float64{}, []float64{400, 700}, []float64{0, 3}, []float64{0, 0}, 1, testSampler, 0}
+  if l := ray.SelectWavelength(); l != 700 || ray.color[1] != 3 {
+    t.Error("select wavelength error 5: ", l, ray.color)
+  }
+}
+
+func TestSurvive(t *testing.T) {
+  //A ray that carries all its light always goes on.
+  ray := &LightRay{0, 0, []float64{}, []float64{}, []float64{4, 5, 6}, []float64{1, .2, 0}, []float64{0, 0, 0}, 1, nil, 0}
+  if !ray.survive() || !test.VectorCloseEnough(ray.color, []float64{1, .2, 0}, mat_err) {
+    t.Error("survive error 1: ", ray.color)
+  }
+
+  //Otherwise it is brightened if it survives and darkened if it does not.
+  var survived int
+  for i := 0; i < 1000; i ++ {
+    ray = &LightRay{0, 0, []float64{}, []float64{}, []float64{4, 5, 6}, []float64{.5, .1, 0}, []float64{0, 0, 0}, .5, testSampler, 0}
+    if ray.survive() {
+      survived ++
+      if !test.VectorCloseEnough(ray.color, []float64{2, .4, 0}, mat_err) || ray.redirected != .5 {
+        t.Error("survive error 2: ", ray.color, ray.redirected)
+      }
+    } else if ray.redirected != 0 {
+      t.Error("survive error 3: ", ray.redirected)
+    }
+  }
+  if survived < 200 || survived > 300 {
+    t.Error("survive error 4: ", survived)
+  }
+
+  //A ray without a sampler is given random numbers of its own.
+  ray.sampler = nil
+  ray.color, ray.redirected = []float64{.5, .1, 0}, .5
+  ray.survive()
+  if ray.sampler == nil {
+    t.Error("survive error 5")
+  }
+}
+
+//A gray mirror reflects the ray into a light. Russian roulette ends
+//some paths at the mirror, but the light is the same on average.
+func TestRussianRoulette(t *testing.T) {
+  floor := polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 0}, []float64{0, 0, 1}, true)
+  scene := NewScene([]*ExtendedObject{
+    NewExtendedObject(floor, NewMirrorReflector(floor, Absorb([]float64{.5, .5, .5}))),
+    NewExtendedObject(polynomialsurfaces.NewSphere([]float64{0, 0, 5}, 1), NewGlowingObject([]float64{1, 1, 1}))},
+    color.ConstantColorFunction(color.PresetColor([]float64{0, 0, 0})))
+
+  c, length := scene.tracePath([]float64{0, 0, 1}, []float64{0, 0, -1}, 5, 1./256., nil)
+  if !test.VectorCloseEnough(c, []float64{.5, .5, .5}, mat_err) || length != 2 {
+    t.Error("russian roulette error 1: ", c, length)
+  }
+
+  scene.SetRussianRoulette(1)
+  var mean float64
+  var lengths [3]int
+  n := 10000
+  for i := 0; i < n; i ++ {
+    c, length := scene.tracePath([]float64{0, 0, 1}, []float64{0, 0, -1}, 5, 1./256., nil)
+    mean += c[0] / float64(n)
+    lengths[length] ++
+  }
+
+  if !test.CloseEnough(mean, .5, .03) {
+    t.Error("russian roulette error 2: ", mean)
+  }
+  if lengths[0] != 0 || lengths[1] < n / 3 || lengths[2] < n / 3 {
+    t.Error("russian roulette error 3: ", lengths)
+  }
+
+  //Roulette does not begin until the minimum depth.
+  scene.SetRussianRoulette(2)
+  for i := 0; i < 100; i ++ {
+    if c, length := scene.tracePath([]float64{0, 0, 1}, []float64{0, 0, -1}, 5, 1./256., nil); c[0] != .5 || length != 2 {
+      t.Error("russian roulette error 4: ", c, length)
+    }
+  }
+}
+
+//A white light seen through a spectral camera is still white on average.
+func TestSpectralScene(t *testing.T) {
+  sphere := polynomialsurfaces.NewSphere([]float64{0, 0, 5}, 1)
+  scene := NewScene([]*ExtendedObject{NewExtendedObject(sphere, NewGlowingObject([]float64{.8, .8, .8}))},
+    color.ConstantColorFunction(color.PresetColor([]float64{.2, .4, .6})))
+  scene.SetSpectral(4)
+
+  sum := make([]float64, 3)
+  sky := make([]float64, 3)
+  n := 5000
+  for i := 0; i < n; i ++ {
+    c := scene.TracePath([]float64{0, 0, 0}, []float64{0, 0, 1}, 4, 1./256.)
+    s := scene.TracePath([]float64{0, 0, 0}, []float64{0, 0, -1}, 4, 1./256.)
+    for j := 0; j < 3; j ++ {
+      sum[j] += c[j] / float64(n)
+      sky[j] += s[j] / float64(n)
+    }
+  }
+
+  if !test.VectorCloseEnough(sum, []float64{.8, .8, .8}, .02) {
+    t.Error("spectral scene error 1: ", sum)
+  }
+  if !test.VectorCloseEnough(sky, []float64{.2, .4, .6}, .02) {
+    t.Error("spectral scene error 2: ", sky)
+  }
+}
+
+//A ray sees a moving object where it is at the time of the ray.
+func TestMovingObject(t *testing.T) {
+  sphere := surface.NewMovingSurface(polynomialsurfaces.NewSphere([]float64{0, 0, 5}, 1),
+    func(time float64) ([][]float64, []float64) { return nil, []float64{3 * time, 0, 0} })
+  scene := NewScene([]*ExtendedObject{NewExtendedObject(sphere, NewGlowingObject([]float64{1, 1, 1}))},
+    color.ConstantColorFunction(color.PresetColor([]float64{0, 0, 0})))
+
+  for i, time := range []float64{0, .2, .4, 1} {
+    ray := scene.newRay([]float64{0, 0, 0}, []float64{0, 0, 1})
+    ray.time = time
+    c, _ := scene.trace(ray, 4, 1./256., nil)
+    if hit := c[0] > .5; hit != (time < 1./3.) {
+      t.Error("moving object error: ", i, c)
+    }
+  }
+}
+
+//A moving surface inside another surface moves too.
+func TestNestedMovingObject(t *testing.T) {
+  sphere := surface.NewMovingSurface(polynomialsurfaces.NewSphere([]float64{0, 0, 5}, 1),
+    func(time float64) ([][]float64, []float64) { return nil, []float64{3 * time, 0, 0} })
+  half := booleans.NewIntersection(sphere, polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 0}, []float64{0, 1, 0}, true))
+  scene := NewScene([]*ExtendedObject{NewExtendedObject(half, NewGlowingObject([]float64{1, 1, 1}))},
+    color.ConstantColorFunction(color.PresetColor([]float64{0, 0, 0})))
+
+  for i, time := range []float64{1, 0} {
+    ray := scene.newRay([]float64{0, -.5, 0}, []float64{0, 0, 1})
+    ray.time = time
+    c, _ := scene.trace(ray, 4, 1./256., nil)
+    if hit := c[0] > .5; hit != (time == 0) {
+      t.Error("nested moving object error: ", i, c)
+    }
+  }
+}
+
+//A ray through a prism comes out in a direction that depends on its color.
+func TestDispersiveGlass(t *testing.T) {
+  sphere := polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 1)
+  index := Cauchy(1.5, .05)
+  if NewDispersiveGlassInteractor(nil, Absorb([]float64{1, 1, 1}), index, 1, 0) != nil ||
+    NewDispersiveGlassInteractor(sphere, Absorb([]float64{1, 1, 1}), nil, 1, 0) != nil ||
+    NewDispersiveGlassInteractor(sphere, Absorb([]float64{1, 1, 1}), index, 0, 0) != nil {
+    t.Error("dispersive glass error 1")
+  }
+
+  glass := NewDispersiveTransmitter(sphere, Absorb([]float64{1, 1, 1}), index)
+
+  //The ray comes in at an angle, so that its wavelengths are bent differently.
+  directions := make(map[float64][]float64)
+  for i := 0; i < 50; i ++ {
+    ray := &LightRay{0, 0, []float64{-.6, .8, 0}, []float64{1, 0, 0}, []float64{4, 5, 6},
+      []float64{1, 1, 1}, []float64{0, 0, 0}, 1, testSampler, 0}
+    ray = glass.Interact(ray)
+
+    var l float64
+    for j, c := range ray.color {
+      if c != 0 {
+        l = []float64{610, 550, 465}[j]
+      }
+    }
+    directions[l] = vector.Normalize(ray.direction)
+
+    expected := BasicRefraction(index(l))(nil, []float64{1, 0, 0}, []float64{-.6, .8, 0})
+    if !test.VectorCloseEnough(directions[l], vector.Normalize(expected), mat_err) {
+      t.Error("dispersive glass error 2: ", l, directions[l])
+    }
+  }
+
+  if len(directions) != 3 || !(directions[465][1] < directions[550][1] && directions[550][1] < directions[610][1]) {
+    t.Error("dispersive glass error 3: ", directions)
+  }
+}
@@ -0,0 +1,149 @@
+package pathtrace
+
+import "math"
+import "math/rand"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//A small light is rarely found by a ray which bounces at random, so
+//the picture is grainy. Instead, whenever a ray is scattered by an
+//interactor that can say how it scatters light, a shadow ray is sent
+//toward one of the lights as well. The light found by the shadow ray
+//and by the scattered ray are weighted by multiple importance sampling
+//so that each counts most where it is the more likely to find the light.
+//
+//A direction is chosen uniformly within the cone that contains the
+//sphere around the light's bounding box, so any bounded glowing object
+//can be a light, and the chance of choosing the direction in which a
+//scattered ray hit the light can always be worked out afterward.
+//
+//Light is only sampled in flat three-dimensional space, where shadow
+//rays are straight lines.
+
+//A glowing object toward which shadow rays are sent.
+type light struct {
+  //The index of the object in the scene.
+  index int
+  glow []float64
+  //A sphere which contains the object.
+  center []float64
+  radius float64
+}
+
+//Find the objects which can be used as lights.
+func findLights(objects []*ExtendedObject) []*light {
+  var lights []*light
+  for i, object := range objects {
+    if object.glow == nil || object.surf.Dimension() != 3 { continue }
+
+    min, max := surface.BoundingBox(object.surf)
+    if !surface.FiniteBox(min, max) { continue }
+
+    center := vector.LinearSum(.5, .5, min, max)
+    lights = append(lights, &light{i, object.glow, center, vector.Length(vector.Minus(max, center))})
+  }
+  return lights
+}
+
+//Send shadow rays toward the lights of the scene, or not. Light
+//sampling is on by default in flat space and cannot be used in
+//curved space.
+func (scene *Scene) SetLightSampling(on bool) {
+  if on && scene.curved == nil {
+    scene.lights = findLights(scene.objects)
+  } else {
+    scene.lights = nil
+  }
+}
+
+//The solid angle of the cone from x which contains the light, and the
+//cosine of its half angle. If x is within the sphere around the light,
+//the cone is every direction.
+func (l *light) cone(x []float64) (omega, cos float64) {
+  d := vector.Length(vector.Minus(l.center, x))
+  if d <= l.radius {
+    return 4 * math.Pi, -1
+  }
+
+  s := l.radius / d
+  cos = math.Sqrt(1 - s * s)
+  return 2 * math.Pi * (1 - cos), cos
+}
+
+//A direction chosen uniformly from the cone from x which contains
+//the light, along with the density with which it was chosen.
+func (l *light) sample(r *rand.Rand, x []float64) ([]float64, float64) {
+  omega, cosmax := l.cone(x)
+
+  w := vector.Minus(l.center, x)
+  if cosmax == -1 {
+    w = []float64{0, 0, 1}
+  }
+  vector.Normalize(w)
+
+  //Two vectors which make an orthonormal basis with w.
+  a := []float64{1, 0, 0}
+  if math.Abs(w[0]) > .5 {
+    a = []float64{0, 1, 0}
+  }
+  u := vector.Normalize([]float64{
+    a[1] * w[2] - a[2] * w[1],
+    a[2] * w[0] - a[0] * w[2],
+    a[0] * w[1] - a[1] * w[0]})
+  v := []float64{
+    w[1] * u[2] - w[2] * u[1],
+    w[2] * u[0] - w[0] * u[2],
+    w[0] * u[1] - w[1] * u[0]}
+
+  cos := 1 - r.Float64() * (1 - cosmax)
+  sin := math.Sqrt(math.Max(0, 1 - cos * cos))
+  phi := 2 * math.Pi * r.Float64()
+
+  dir := make([]float64, 3)
+  for i := 0; i < 3; i ++ {
+    dir[i] = cos * w[i] + sin * (math.Cos(phi) * u[i] + math.Sin(phi) * v[i])
+  }
+  return dir, 1 / omega
+}
+
+//The density with which a direction toward the object selected would
+//have been chosen by sampling the lights from x. Zero if it is not a light.
+func (scene *Scene) lightPdf(selected int, x []float64) float64 {
+  for _, l := range scene.lights {
+    if l.index == selected {
+      omega, _ := l.cone(x)
+      return 1 / (omega * float64(len(scene.lights)))
+    }
+  }
+  return 0
+}
+
+//The power heuristic for combining two ways of sampling the same light.
+func misWeight(p, q float64) float64 {
+  return p * p / (p * p + q * q)
+}
+
+//Send a shadow ray toward one of the lights from a ray which has just been
+//scattered by s, having arrived going in direction in, and add the light
+//that it finds to the emission of the ray. last is the object that
+//the ray is on.
+func (scene *Scene) sampleLight(ray *LightRay, s Scatterer, in []float64, last int) {
+  r := ray.random()
+  l := scene.lights[r.Intn(len(scene.lights))]
+  dir, p := l.sample(r, ray.position)
+  p /= float64(len(scene.lights))
+
+  f := s.BRDF(ray.position, in, dir)
+  if f <= 0 { return }
+
+  pos := make([]float64, 3)
+  copy(pos, ray.position)
+  shadow := &LightRay{0, ray.region, pos, dir, nil, nil, nil, 0, ray.sampler, ray.time}
+  if scene.nextIntersection(shadow, last) != l.index { return }
+
+  w := misWeight(p, s.Pdf(ray.position, in, dir)) * f / p
+  glow := ray.values(l.glow)
+  for i := 0; i < len(ray.color); i ++ {
+    ray.emission[i] += ray.color[i] * glow[i] * ray.redirected * w
+  }
+}
@@ -0,0 +1,118 @@
+package pathtrace
+
+import "testing"
+import "math"
+import "math/rand"
+import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/geometry"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+
+var black = color.ConstantColorFunction(color.PresetColor([]float64{0, 0, 0}))
+
+//A white floor lit by a small sphere above it.
+func litFloor() []*ExtendedObject {
+  return []*ExtendedObject{
+    NewExtendedObject(polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 0}, []float64{0, 0, 1}, true),
+      NewLambertianReflector(polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 0}, []float64{0, 0, 1}, true),
+        Absorb([]float64{1, 1, 1}))),
+    NewExtendedObject(polynomialsurfaces.NewSphere([]float64{0, 0, 2}, .2), NewGlowingObject([]float64{10, 10, 10}))}
+}
+
+func TestFindLights(t *testing.T) {
+  objects := append(litFloor(),
+    //A glowing plane cannot be a light because it has no bounds.
+    NewExtendedObject(polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 10}, []float64{0, 0, 1}, false),
+      NewGlowingObject([]float64{1, 1, 1})))
+
+  scene := NewScene(objects, black)
+  if len(scene.lights) != 1 || scene.lights[0].index != 1 {
+    t.Error("find lights error 1: ", scene.lights)
+    return
+  }
+
+  if !test.VectorCloseEnough(scene.lights[0].center, []float64{0, 0, 2}, mat_err) || scene.lights[0].radius < .2 {
+    t.Error("find lights error 2: ", scene.lights[0])
+  }
+
+  scene.SetLightSampling(false)
+  if scene.lights != nil {
+    t.Error("find lights error 3")
+  }
+
+  scene.SetLightSampling(true)
+  if len(scene.lights) != 1 {
+    t.Error("find lights error 4")
+  }
+
+  curved := NewCurvedScene(objects, black, geometry.NewMinkowskiSpace(), 0, .1, .001, 20, 1000)
+  curved.SetLightSampling(true)
+  if curved.lights != nil {
+    t.Error("find lights error 5")
+  }
+}
+
+//Directions are chosen within the cone which contains the light.
+func TestLightCone(t *testing.T) {
+  l := &light{0, []float64{1, 1, 1}, []float64{3, 0, 4}, 1}
+  x := []float64{0, 0, 0}
+
+  omega, cos := l.cone(x)
+  if !test.CloseEnough(cos, math.Sqrt(24) / 5, mat_err) || !test.CloseEnough(omega, 2 * math.Pi * (1 - cos), mat_err) {
+    t.Error("light cone error 1: ", omega, cos)
+  }
+
+  r := rand.New(rand.NewSource(1))
+  axis := []float64{.6, 0, .8}
+  for i := 0; i < 100; i ++ {
+    dir, p := l.sample(r, x)
+    if !test.CloseEnough(vector.Length(dir), 1, mat_err) || vector.Dot(dir, axis) < cos - mat_err ||
+      !test.CloseEnough(p, 1 / omega, mat_err) {
+      t.Error("light cone error 2: ", dir, p)
+    }
+  }
+
+  //Inside the sphere, any direction may be chosen.
+  if omega, _ := l.cone([]float64{3, .5, 4}); !test.CloseEnough(omega, 4 * math.Pi, mat_err) {
+    t.Error("light cone error 3: ", omega)
+  }
+}
+
+//With light sampling, the floor should be as bright as it really is, and less
+//grainy than without. The brightness without light sampling is not checked
+//because the points on the sphere used for Lambertian reflection are not quite
+//uniform near the normal.
+func TestLightSampling(t *testing.T) {
+  sampled := NewScene(litFloor(), black)
+  unsampled := NewScene(litFloor(), black)
+  unsampled.SetLightSampling(false)
+
+  //The sphere covers a cone whose half angle has a sine of .1.
+  expected := 10 * .1 * .1
+
+  n := 40000
+  var mean, variance [2]float64
+  for k, scene := range []*Scene{sampled, unsampled} {
+    for i := 0; i < n; i ++ {
+      c := scene.TracePath([]float64{0, 0, 1}, []float64{0, 0, -1}, 3, 1./256.)[0]
+      mean[k] += c / float64(n)
+      variance[k] += c * c / float64(n)
+    }
+    variance[k] -= mean[k] * mean[k]
+  }
+
+  if !test.CloseEnough(mean[0], expected, .005) {
+    t.Error("light sampling error 1: ", mean)
+  }
+
+  if !(variance[0] * 10 < variance[1]) {
+    t.Error("light sampling error 2: ", variance)
+  }
+
+  //With only one bounce, the light cannot be found either way, so no shadow ray is sent.
+  if c := sampled.TracePath([]float64{0, 0, 1}, []float64{0, 0, -1}, 1, 1./256.);
+    !test.VectorCloseEnough(c, []float64{1, 1, 1}, mat_err) {
+    t.Error("light sampling error 3: ", c)
+  }
+}
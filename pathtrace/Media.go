@@ -0,0 +1,114 @@
+package pathtrace
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/diffeq"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//A refractive index which may vary over space and with the wavelength
+//of light, which is given in nanometers.
+type IndexField func(x []float64, wavelength float64) float64
+
+//The number of times a ray may be reflected back into a medium
+//from the inside before it is considered to be trapped.
+const maxInternalReflections = 16
+
+//A medium whose refractive index varies continuously, such as a mirage
+//over hot ground or a gradient-index lens. It is bounded by a surface,
+//where light is refracted as it goes in and comes out. Inside, light
+//follows the eikonal equation. The medium is treated as though nothing
+//else were inside it.
+type gradientIndexMedium struct {
+  surf surface.Surface
+  color ColorInteraction
+  index IndexField
+  //Whether the index depends on the wavelength.
+  dispersive bool
+  ds, err float64
+  maxsteps int
+}
+
+//Choose the wavelength at which the index is evaluated. If the medium is
+//dispersive, then every wavelength in the ray goes a different way, so one
+//of them is chosen to continue and the others are dropped.
+func (m *gradientIndexMedium) wavelength(ray *LightRay) float64 {
+  if !m.dispersive {
+    return color.Wavelengths(ray.receptor)[0]
+  }
+
+  return ray.SelectWavelength()
+}
+
+//Refract the ray through the surface. Returns true if the ray has come
+//out on the outside.
+func (m *gradientIndexMedium) refract(ray *LightRay, n func([]float64) float64) bool {
+  normal := surface.SurfaceNormal(m.surf, ray.position)
+  ray.direction = BasicRefraction(n(ray.position))(ray.sampler, ray.direction, normal)
+  return vector.Dot(ray.direction, normal) > 0
+}
+
+func (m *gradientIndexMedium) Interact(ray *LightRay) *LightRay {
+  m.color(ray)
+
+  wavelength := m.wavelength(ray)
+  n := func(x []float64) float64 {
+    return m.index(x, wavelength)
+  }
+
+  //The ray may be reflected rather than go inside.
+  if m.refract(ray, n) {
+    return ray
+  }
+
+  dim := len(ray.position)
+  f := diffeq.NewEikonalEquation(dim, n)
+  boundary := func(region int, x []float64) float64 {
+    return m.surf.F(x[:dim])
+  }
+
+  for i := 0; i < maxInternalReflections; i ++ {
+    x := make([]float64, 2 * dim)
+    copy(x, ray.position)
+    copy(x[dim:], vector.Times(n(ray.position), vector.Normalize(ray.direction)))
+
+    st := diffeq.NewState(0, 0, m.ds, x)
+    if st == nil {break}
+
+    //The ray starts on the boundary.
+    exit := diffeq.NewUntilEvent(st, boundary, m.err * m.ds, true)
+    solver := diffeq.NewSolver(st, f, diffeq.NewRungeKuttaSolverMethodDormandPrince(2 * dim, m.err),
+      []diffeq.Monitor{exit, diffeq.NewMaxStepSize(st, m.ds)}, m.maxsteps, math.Inf(1))
+    if solver == nil {break}
+
+    end, _ := solver.Run()
+    copy(ray.position, end.X[:dim])
+    ray.direction = end.X[dim:]
+
+    if !exit.Triggered() {break}
+
+    if m.refract(ray, n) {
+      return ray
+    }
+  }
+
+  //The ray is trapped inside.
+  ray.redirected = 0
+  return ray
+}
+
+//A medium bounded by surf with a refractive index that varies.
+//  index      - the refractive index, which is 1 outside the medium.
+//  dispersive - whether the index depends on the wavelength.
+//  ds         - the largest step used to integrate the path of a ray.
+//  err        - the error allowed per step.
+//  maxsteps   - the largest number of steps that a ray can take inside.
+//
+//May return nil.
+func NewGradientIndexMedium(surf surface.Surface, color ColorInteraction, index IndexField,
+  dispersive bool, ds, err float64, maxsteps int) Interactor {
+  if surf == nil || color == nil || index == nil {return nil}
+  if !(ds > 0) || !(err > 0) || maxsteps <= 0 {return nil}
+
+  return &gradientIndexMedium{surf, color, index, dispersive, ds, err, maxsteps}
+}
@@ -0,0 +1,95 @@
+package pathtrace
+
+import "testing"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+var media_err float64 = .0001
+
+func uniformIndex(n float64) IndexField {
+  return func(x []float64, wavelength float64) float64 {return n}
+}
+
+func TestNewGradientIndexMedium(t *testing.T) {
+  sphere := polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 1)
+  white := Absorb([]float64{1, 1, 1})
+
+  if NewGradientIndexMedium(nil, white, uniformIndex(1.5), false, .1, .0001, 100) != nil {
+    t.Error("gradient index medium error 1")
+  }
+  if NewGradientIndexMedium(sphere, white, nil, false, .1, .0001, 100) != nil {
+    t.Error("gradient index medium error 2")
+  }
+  if NewGradientIndexMedium(sphere, white, uniformIndex(1.5), false, 0, .0001, 100) != nil {
+    t.Error("gradient index medium error 3")
+  }
+}
+
+//A uniform medium should act the same way as a refracting surface.
+func TestUniformMedium(t *testing.T) {
+  sphere := polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 1)
+  medium := NewGradientIndexMedium(sphere, Absorb([]float64{1, 1, 1}), uniformIndex(1.5), false, .05, .000001, 1000)
+
+  for i, p := range [][]float64{{-1, 0, 0}, {-.8, .6, 0}, {-.6, 0, .8}} {
+    //The expected result, with a straight line through the medium.
+    in := BasicRefraction(1.5)(nil, []float64{1, 0, 0}, surface.SurfaceNormal(sphere, p))
+    u := sphere.Intersection(p, in)
+    var far float64
+    for _, v := range u {
+      if v > far {far = v}
+    }
+    exit := vector.LinearSum(1, far, p, in)
+    out := vector.Normalize(BasicRefraction(1.5)(nil, in, surface.SurfaceNormal(sphere, exit)))
+
+    ray := &LightRay{0, 0, []float64{p[0], p[1], p[2]}, []float64{1, 0, 0}, []float64{4, 5, 6},
+      []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+    ray = medium.Interact(ray)
+
+    if !test.VectorCloseEnough(ray.position, exit, media_err) ||
+      !test.VectorCloseEnough(vector.Normalize(ray.direction), out, media_err) {
+      t.Error("uniform medium error, case ", i, ": got ", ray.position, ray.direction,
+        " expected ", exit, out)
+    }
+  }
+}
+
+//A medium whose index decreases downward bends a ray back
+//up and out of the top again, like a mirage.
+func TestMirage(t *testing.T) {
+  ground := polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 1}, []float64{0, 0, 1}, true)
+  index := func(x []float64, wavelength float64) float64 {return 1.4 + .1 * x[2]}
+  medium := NewGradientIndexMedium(ground, Absorb([]float64{1, 1, 1}), index, false, .05, .000001, 10000)
+
+  ray := &LightRay{0, 0, []float64{0, 0, 1}, []float64{1, 0, -.2}, []float64{4, 5, 6},
+    []float64{1, 1, 1}, []float64{0, 0, 0}, 1, nil, 0}
+  ray = medium.Interact(ray)
+
+  if ray.redirected != 1 || !test.CloseEnough(ray.position[2], 1, media_err) || ray.position[0] < 1 ||
+    !test.CloseEnough(vector.Normalize(ray.direction)[2], vector.Normalize([]float64{1, 0, .2})[2], media_err) {
+    t.Error("mirage error: got ", ray.position, ray.direction)
+  }
+}
+
+//A dispersive medium keeps only one wavelength.
+func TestDispersiveMedium(t *testing.T) {
+  sphere := polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 1)
+  index := func(x []float64, wavelength float64) float64 {return 1 + wavelength / 10}
+  medium := NewGradientIndexMedium(sphere, Absorb([]float64{1, 1, 1}), index, true, .05, .000001, 1000)
+
+  for i := 0; i < 10; i ++ {
+    ray := &LightRay{0, 0, []float64{-1, 0, 0}, []float64{1, 0, 0}, []float64{4, 5, 6},
+      []float64{1, 1, 1}, []float64{0, 0, 0}, 1, testSampler, 0}
+    ray = medium.Interact(ray)
+
+    var nonzero int
+    for _, c := range ray.color {
+      if c != 0 {
+        nonzero ++
+        if c != 3 {t.Error("dispersive medium error 1: got ", ray.color)}
+      }
+    }
+    if nonzero != 1 {t.Error("dispersive medium error 2: got ", ray.color)}
+  }
+}
@@ -0,0 +1,262 @@
+package pathtrace
+
+import "bufio"
+import "encoding/binary"
+import "errors"
+import "io"
+import "math"
+import "sync"
+import "github.com/DanielKrawisz/CurvedSpace/hdr"
+
+//A picture which is made in passes, each of which adds a few samples to
+//every pixel that needs them. It can be looked at after any pass, and
+//saved to a checkpoint and taken up again later, so that a long render
+//which is stopped does not lose its work. Because the samples of a pixel
+//depend only on the seed of the sampler and where they are in the pixel,
+//a picture made in many passes, stopped and resumed any number of times,
+//comes out the same as if it had been made with Snapshot. With a filter
+//which splats samples onto the rows around them, the sums of the pixels
+//are added up in another order, so it is only the same up to rounding.
+type Progressive struct {
+  Width, Height int
+  //The number of passes that have been made.
+  Passes int
+  //The samples of each pixel, in rows from the top.
+  pixels []pixelSamples
+  //The film onto which the samples are splatted. It takes the
+  //filter of the scene when the first samples are traced.
+  film *Film
+}
+
+func NewProgressive(width, height int) *Progressive {
+  if width < 0 { width = 0 }
+  if height < 0 { height = 0 }
+  return &Progressive{width, height, 0, make([]pixelSamples, width * height), NewFilm(width, height, nil)}
+}
+
+//The number of samples of pixel x, y.
+func (p *Progressive) Samples(x, y int) int {
+  return p.pixels[y * p.Width + x].n
+}
+
+//The picture so far, as it is on the film. Pixels
+//with no samples are black.
+func (p *Progressive) Image() *hdr.Image {
+  return p.film.Image()
+}
+
+//Pictures of the features of what the rays of each pixel hit first.
+func (p *Progressive) Features() *FeatureImages {
+  return p.film.Features()
+}
+
+//The filter with which the samples are splatted.
+func (p *Progressive) Filter() *Filter {
+  return p.film.filter
+}
+
+//The number of pixels which need more samples.
+func (p *Progressive) Remaining(minp, maxp int, maxMeanVariance float64) int {
+  var r int
+  for i := range p.pixels {
+    if !p.pixels[i].done(minp, maxp, maxMeanVariance) {
+      r ++
+    }
+  }
+  return r
+}
+
+//Make passes over the picture over several goroutines, each with its own
+//scene, which add up to samples more to every pixel until it is done, as
+//with Snapshot. After each pass, pass is called with the picture and the
+//number of pixels that still need samples. Stops when every pixel is done
+//or when pass returns false. pass may be nil.
+func (p *Progressive) Render(sceneBuild func() *Scene, cam_func GenerateRay, depth, minp, maxp int,
+  maxMeanVariance float64, samples, routines int, pass func(p *Progressive, remaining int) bool) {
+  if samples <= 0 { samples = 1 }
+  scenes := buildScenes(sceneBuild, routines)
+
+  for p.Remaining(minp, maxp, maxMeanVariance) > 0 {
+    p.sampleRows(scenes, cam_func, depth, minp, maxp, maxMeanVariance, func(int) int { return samples })
+    p.Passes ++
+
+    if pass != nil && !pass(p, p.Remaining(minp, maxp, maxMeanVariance)) {
+      return
+    }
+  }
+}
+
+//Build a scene for each goroutine.
+func buildScenes(sceneBuild func() *Scene, routines int) []*Scene {
+  if routines <= 0 { routines = 1 }
+  scenes := make([]*Scene, routines)
+  for i := range scenes {
+    scenes[i] = sceneBuild()
+  }
+  return scenes
+}
+
+//Trace up to quota(i) more samples for each pixel i until it is done, over
+//a goroutine for each scene. Each row is only touched by one goroutine.
+func (p *Progressive) sampleRows(scenes []*Scene, cam_func GenerateRay, depth, minp, maxp int,
+  maxMeanVariance float64, quota func(i int) int) {
+  if p.Spent() == 0 {
+    p.film = NewFilm(p.Width, p.Height, scenes[0].filter)
+  }
+
+  //If the filter reaches no further than the row of a pixel, the samples
+  //are splatted straight onto the film. Otherwise, each row is splatted
+  //onto a section of its own, and the sections are added in order.
+  direct := p.film.filter.margin() == 0
+  merger := newFilmMerger(p.film)
+  var lock sync.Mutex
+
+  rows := make(chan int, p.Height)
+  for v := 0; v < p.Height; v ++ {
+    rows <- v
+  }
+  close(rows)
+
+  var wg sync.WaitGroup
+  for _, scene := range scenes {
+    wg.Add(1)
+    go func(scene *Scene) {
+      defer wg.Done()
+      for v := range rows {
+        film := p.film
+        if !direct {
+          film = newFilmSection(p.Width, v, v + 1, p.film.filter)
+        }
+
+        for u := 0; u < p.Width; u ++ {
+          i := v * p.Width + u
+          if k := quota(i); k > 0 {
+            p.pixels[i].sample(scene, cam_func, film, u, v, k, depth, minp, maxp, maxMeanVariance, nil, 0)
+          }
+        }
+
+        if !direct {
+          lock.Lock()
+          merger.merge(&filmSection{v, v + 1, film})
+          lock.Unlock()
+        }
+      }
+    } (scene)
+  }
+  wg.Wait()
+}
+
+//The first bytes of a checkpoint file, followed by its version.
+var checkpointMagic []byte = []byte("CurvedSpace checkpoint\n\x03")
+
+//The number of bytes of each pixel in a checkpoint: the number of
+//samples, the sum of each channel, the statistics of each channel, the
+//weighted sum of each channel on the film and its weight, the sums of
+//the albedo, the normal and the depth, the number of samples whose
+//features were added, and the object hit by the first.
+const checkpointPixelSize = 8 + 3 * 8 + 3 * 32 + 4 * 8 + 7 * 8 + 2 * 8
+
+//Save the samples so far, so that the picture can be taken up again
+//with ReadCheckpoint. The size of the picture is followed by the filter,
+//as the length of its name, its name, and its radius, and then by the
+//pixels. All numbers are little-endian.
+func (p *Progressive) WriteCheckpoint(w io.Writer) error {
+  b := bufio.NewWriter(w)
+  b.Write(checkpointMagic)
+  binary.Write(b, binary.LittleEndian, []int64{int64(p.Width), int64(p.Height), int64(p.Passes)})
+  b.WriteByte(byte(len(p.film.filter.Name)))
+  b.WriteString(p.film.filter.Name)
+  binary.Write(b, binary.LittleEndian, p.film.filter.Radius)
+
+  pix := make([]byte, checkpointPixelSize)
+  for i := range p.pixels {
+    s := &p.pixels[i]
+    binary.LittleEndian.PutUint64(pix, uint64(s.n))
+    for l := 0; l < 3; l ++ {
+      binary.LittleEndian.PutUint64(pix[8 + 8 * l:], math.Float64bits(s.sum[l]))
+      m, _ := s.monitor[l].MarshalBinary()
+      copy(pix[32 + 32 * l:], m)
+    }
+    f := &p.film.pixels[i]
+    for l := 0; l < 3; l ++ {
+      binary.LittleEndian.PutUint64(pix[128 + 8 * l:], math.Float64bits(f.sum[l]))
+    }
+    binary.LittleEndian.PutUint64(pix[152:], math.Float64bits(f.weight))
+    for l, x := range []float64{f.albedo[0], f.albedo[1], f.albedo[2], f.normal[0], f.normal[1], f.normal[2], f.depth} {
+      binary.LittleEndian.PutUint64(pix[160 + 8 * l:], math.Float64bits(x))
+    }
+    binary.LittleEndian.PutUint64(pix[216:], uint64(f.features))
+    binary.LittleEndian.PutUint64(pix[224:], uint64(f.object))
+    b.Write(pix)
+  }
+
+  return b.Flush()
+}
+
+//Take up a picture that was saved with WriteCheckpoint.
+func ReadCheckpoint(r io.Reader) (*Progressive, error) {
+  b := bufio.NewReader(r)
+
+  magic := make([]byte, len(checkpointMagic))
+  if _, err := io.ReadFull(b, magic); err != nil || string(magic) != string(checkpointMagic) {
+    return nil, errors.New("pathtrace: not a checkpoint")
+  }
+
+  var size [3]int64
+  if err := binary.Read(b, binary.LittleEndian, size[:]); err != nil {
+    return nil, err
+  }
+  if size[0] < 0 || size[1] < 0 || size[2] < 0 || size[0] > 1 << 16 || size[1] > 1 << 16 || size[0] * size[1] > 1 << 26 {
+    return nil, errors.New("pathtrace: checkpoint has an invalid size")
+  }
+
+  n, err := b.ReadByte()
+  if err != nil {
+    return nil, errors.New("pathtrace: checkpoint is too short")
+  }
+  name := make([]byte, n)
+  var radius float64
+  if _, err := io.ReadFull(b, name); err != nil {
+    return nil, errors.New("pathtrace: checkpoint is too short")
+  }
+  if err := binary.Read(b, binary.LittleEndian, &radius); err != nil {
+    return nil, errors.New("pathtrace: checkpoint is too short")
+  }
+  filter := NewFilter(string(name), radius)
+  if filter == nil {
+    return nil, errors.New("pathtrace: checkpoint has an unknown filter")
+  }
+
+  p := NewProgressive(int(size[0]), int(size[1]))
+  p.Passes = int(size[2])
+  p.film = NewFilm(p.Width, p.Height, filter)
+
+  pix := make([]byte, checkpointPixelSize)
+  for i := range p.pixels {
+    if _, err := io.ReadFull(b, pix); err != nil {
+      return nil, errors.New("pathtrace: checkpoint is too short")
+    }
+
+    s := &p.pixels[i]
+    s.n = int(int64(binary.LittleEndian.Uint64(pix)))
+    for l := 0; l < 3; l ++ {
+      s.sum[l] = math.Float64frombits(binary.LittleEndian.Uint64(pix[8 + 8 * l:]))
+      s.monitor[l].UnmarshalBinary(pix[32 + 32 * l:64 + 32 * l])
+    }
+    f := &p.film.pixels[i]
+    for l := 0; l < 3; l ++ {
+      f.sum[l] = math.Float64frombits(binary.LittleEndian.Uint64(pix[128 + 8 * l:]))
+    }
+    f.weight = math.Float64frombits(binary.LittleEndian.Uint64(pix[152:]))
+    for l, x := range []*float64{&f.albedo[0], &f.albedo[1], &f.albedo[2], &f.normal[0], &f.normal[1], &f.normal[2], &f.depth} {
+      *x = math.Float64frombits(binary.LittleEndian.Uint64(pix[160 + 8 * l:]))
+    }
+    f.features = int(int64(binary.LittleEndian.Uint64(pix[216:])))
+    f.object = int(int64(binary.LittleEndian.Uint64(pix[224:])))
+    if s.n < 0 || s.monitor[0].Count() != s.n || f.features != s.n {
+      return nil, errors.New("pathtrace: checkpoint is corrupt")
+    }
+  }
+
+  return p, nil
+}
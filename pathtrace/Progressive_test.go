@@ -0,0 +1,83 @@
+package pathtrace
+
+import "testing"
+import "bytes"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+
+//A white floor under a sky which is bright on one side.
+func progressiveScene() *Scene {
+  return floorScene([]float64{1, .8, .6}, []float64{0, 0, .1}, distributions.NewSobolSampler(3))
+}
+
+var progressiveCamera = floorCamera(12, 10)
+
+//A picture made in passes, which is saved and taken up again
+//partway through, is the same as one made all at once.
+func TestProgressive(t *testing.T) {
+  expected := SnapshotHDR(progressiveScene, progressiveCamera, 12, 10, 2, 3, 20, .002, 2, nil)
+
+  p := NewProgressive(12, 10)
+  var remaining []int
+  p.Render(progressiveScene, progressiveCamera, 2, 3, 20, .002, 2, 3, func(p *Progressive, r int) bool {
+    remaining = append(remaining, r)
+    return p.Passes < 4
+  })
+  if p.Passes != 4 || len(remaining) != 4 || remaining[3] == 0 {
+    t.Error("progressive error 1: ", p.Passes, remaining)
+    return
+  }
+  if n := p.Samples(0, 0); n != 8 {
+    t.Error("progressive error 2: ", n)
+  }
+
+  var b bytes.Buffer
+  if err := p.WriteCheckpoint(&b); err != nil {
+    t.Error("progressive error 3: ", err)
+    return
+  }
+  q, err := ReadCheckpoint(&b)
+  if err != nil {
+    t.Error("progressive error 4: ", err)
+    return
+  }
+  if q.Width != 12 || q.Height != 10 || q.Passes != 4 {
+    t.Error("progressive error 5: ", q.Width, q.Height, q.Passes)
+  }
+
+  q.Render(progressiveScene, progressiveCamera, 2, 3, 20, .002, 5, 2, nil)
+  if q.Remaining(3, 20, .002) != 0 {
+    t.Error("progressive error 6")
+  }
+
+  img := q.Image()
+  for y := 0; y < 10; y ++ {
+    for x := 0; x < 12; x ++ {
+      a, b := img.At(x, y), expected.At(x, y)
+      if a[0] != b[0] || a[1] != b[1] || a[2] != b[2] {
+        t.Error("progressive error 7: ", x, y, a, b)
+        return
+      }
+    }
+  }
+}
+
+func TestBadCheckpoint(t *testing.T) {
+  var b bytes.Buffer
+  NewProgressive(3, 2).WriteCheckpoint(&b)
+  good := b.Bytes()
+
+  if _, err := ReadCheckpoint(bytes.NewReader(good)); err != nil {
+    t.Error("bad checkpoint error 1: ", err)
+  }
+
+  bad := [][]byte{
+    {},
+    []byte("not a checkpoint at all, but long enough"),
+    good[:len(good) - 1],
+    good[:len(checkpointMagic) + 4]}
+  for i, c := range bad {
+    if _, err := ReadCheckpoint(bytes.NewReader(c)); err == nil {
+      t.Error("bad checkpoint error 2: ", i)
+    }
+  }
+}
@@ -1,6 +1,5 @@
 package pathtrace
 
-import "math/rand"
 import "math"
 import "github.com/DanielKrawisz/CurvedSpace/distributions"
 import "github.com/DanielKrawisz/CurvedSpace/vector"
@@ -8,25 +7,25 @@ import "github.com/DanielKrawisz/CurvedSpace/vector"
 //TODO turn these back into objects so that they can trace multiple rays.
 
 //Functions can be mocked out for testing purposes. 
-var randomUnitSphereSurfacePoint func() *[3]float64 = distributions.RandomUnitSphereSurfacePoint
+var randomUnitSphereSurfacePoint func(distributions.Sampler) []float64 = distributions.UnitSphereSurfacePoint
 
-var randomNormallyDistributedVector func(int, float64, float64) []float64 =
-  distributions.RandomNormallyDistributedVector
+var randomNormallyDistributedVector func(distributions.Sampler, int, float64, float64) []float64 =
+  distributions.NormallyDistributedVector
 
-//An function that redirects a ray
-type Redirection func([]float64, []float64) []float64 
+//An function that redirects a ray. Redirections which are random
+//take their random numbers from the sampler. 
+type Redirection func(distributions.Sampler, []float64, []float64) []float64 
 
 //The Lambertian reflectance algorithm used here works as follows.
 //  1. generate a vector v uniformly distributed on the surface of a unit sphere.
 //  2. Add this vector to the normal vector. 
 //This should generate the correct distribution of vectors. 
-func LambertianReflection(direction, normal []float64) []float64 {
+func LambertianReflection(s distributions.Sampler, direction, normal []float64) []float64 {
   reflect := make([]float64, len(normal))
   if len(normal) == 3 {
-    var dir *[3]float64
-    dir = randomUnitSphereSurfacePoint()
+    dir := randomUnitSphereSurfacePoint(s)
     for i := 0; i < len(dir); i ++ {
-      reflect[i] = normal[i] + (*dir)[i]
+      reflect[i] = normal[i] + dir[i]
     }
   } else {
     var dir []float64
@@ -36,11 +35,13 @@ func LambertianReflection(direction, normal []float64) []float64 {
     for {
       r2 = 0
       for i := 0; i < len(dir); i ++ {
-        dir[i] = 2 * rand.Float64() - 1
+        dir[i] = 2 * s.Float64() - 1
         r2 += dir[i] * dir[i]
       }
 
-      if r2 >= 0 {
+      //Points outside the ball would not be evenly spread
+      //over the sphere once they are normalized.
+      if r2 > 0 && r2 <= 1 {
         break
       }
     }
@@ -54,7 +55,7 @@ func LambertianReflection(direction, normal []float64) []float64 {
   return reflect
 }
 
-func MirrorReflection(direction, normal []float64) []float64 {
+func MirrorReflection(s distributions.Sampler, direction, normal []float64) []float64 {
   reflect := make([]float64, len(normal))
   //Find the dot product of the normal with the incoming ray.
   d := vector.Dot(normal, direction)
@@ -68,7 +69,7 @@ func MirrorReflection(direction, normal []float64) []float64 {
 
 //TODO Try this with my other idea for doing specular reflection.
 func SpecularReflection(scatter float64) Redirection {
-  return func (direction, normal []float64) []float64 {
+  return func (s distributions.Sampler, direction, normal []float64) []float64 {
     reflect := make([]float64, len(normal))
     //Find the dot product of the normal with the incoming ray.
     d := vector.Dot(normal, direction)
@@ -82,7 +83,7 @@ func SpecularReflection(scatter float64) Redirection {
     vector.Normalize(reflect)
 
     //Add a random jostling. 
-    spec := randomNormallyDistributedVector(len(normal), 0, scatter)
+    spec := randomNormallyDistributedVector(s, len(normal), 0, scatter)
     for l := 0; l < len(normal); l ++ {
       reflect[l] += spec[l]
     }
@@ -103,10 +104,10 @@ func SpecularReflection(scatter float64) Redirection {
 }
 
 //This refraction does not take into account the way that refraction
-//changes with color. 
+//changes with color. For that, see Dispersion. 
 func BasicRefraction(index float64) Redirection {
   inv := 1/index
-  return func(direction, normal []float64) []float64 {
+  return func(s distributions.Sampler, direction, normal []float64) []float64 {
     //Find the dot product of the normal with the incoming ray.
     vector.Normalize(direction)
     c := -vector.Dot(normal, direction)
@@ -125,23 +126,79 @@ func BasicRefraction(index float64) Redirection {
     rad := 1 - r * r * (1 - c * c)
 
     if rad < 0 {
-      return MirrorReflection(direction, normal)
+      return MirrorReflection(s, direction, normal)
     } else {
       return vector.LinearSum(r, r * c + sign * math.Sqrt(rad), direction, normal)
     }
   }
 }
 
+//The refractive index of a material at a wavelength in nanometers. 
+type Dispersion func(wavelength float64) float64
+
+//Cauchy's equation, n = a + b / λ^2 + c / λ^4 + ..., where the
+//wavelength λ is in micrometers, as the coefficients are usually given. 
+//
+//May return nil.
+func Cauchy(coefficients ...float64) Dispersion {
+  if len(coefficients) == 0 {return nil}
+  return func(wavelength float64) float64 {
+    l2 := wavelength * wavelength / 1e6
+    var n, p float64 = 0, 1
+    for _, c := range coefficients {
+      n += c / p
+      p *= l2
+    }
+    return n
+  }
+}
+
+//The Sellmeier equation, n^2 = 1 + sum b λ^2 / (λ^2 - c), where the
+//wavelength λ is in micrometers and c is in square micrometers. 
+//
+//May return nil.
+func Sellmeier(b, c []float64) Dispersion {
+  if b == nil || c == nil || len(b) != len(c) {return nil}
+  return func(wavelength float64) float64 {
+    l2 := wavelength * wavelength / 1e6
+    n2 := 1.
+    for i := range b {
+      n2 += b[i] * l2 / (l2 - c[i])
+    }
+    return math.Sqrt(n2)
+  }
+}
+
+//The wavelengths in nanometers of the Fraunhofer d, F and C lines, at which
+//glass is measured for its index and Abbe number.
+const FraunhoferD, FraunhoferF, FraunhoferC float64 = 587.6, 486.1, 656.3
+
+//Glass given by its index at the d line and its Abbe number,
+//V = (n_d - 1) / (n_F - n_C), as glass is listed in catalogues and
+//prescriptions of lenses. The smaller the Abbe number, the more the glass
+//disperses light. It is made into Cauchy's equation with two terms. If
+//the Abbe number is zero, the glass does not disperse light at all.
+//
+//May return nil.
+func Abbe(index, v float64) Dispersion {
+  if !(index > 0) || v < 0 {return nil}
+  if v == 0 {return Cauchy(index)}
+
+  d, f, c := FraunhoferD / 1e3, FraunhoferF / 1e3, FraunhoferC / 1e3
+  b := (index - 1) / v / (1 / (f * f) - 1 / (c * c))
+  return Cauchy(index - b / (d * d), b)
+}
+
 //Scatters a ray in a random direction.
 func ScatterRedirector(degree float64) Redirection {
   //Independent of the normal vector given it--this could even be nil! 
-  return func (direction, normal []float64) []float64 {
+  return func (s distributions.Sampler, direction, normal []float64) []float64 {
 
     //Normalize the outgoing ray. 
     vector.Normalize(direction)
 
     //Add a random jostling. 
-    scatter := randomNormallyDistributedVector(len(normal), 0, degree)
+    scatter := randomNormallyDistributedVector(s, len(normal), 0, degree)
     for l := 0; l < len(normal); l ++ {
       direction[l] += scatter[l]
     }
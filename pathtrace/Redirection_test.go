@@ -12,11 +12,11 @@ var red_err float64 = .00001
 var sphereSurfacePoint [3]float64
 var normallyDistributedVector []float64
 
-func mockRandomSphereSurfacePoint() *[3]float64 {
-  return &sphereSurfacePoint
+func mockRandomSphereSurfacePoint(s distributions.Sampler) []float64 {
+  return sphereSurfacePoint[:]
 }
 
-func mockRandomNormallyDistributedVector(n int, mean, sigma float64) []float64 {
+func mockRandomNormallyDistributedVector(s distributions.Sampler, n int, mean, sigma float64) []float64 {
   return normallyDistributedVector
 }
 
@@ -60,7 +60,7 @@ func TestLambertianReflection(t *testing.T) {
 
     vector.Normalize(expected)
 
-    output := vector.Normalize(l([]float64{1,0,0}, normal))
+    output := vector.Normalize(l(nil, []float64{1,0,0}, normal))
     if !test.VectorCloseEnough(expected, output, .000001) {
       t.Error("Lambertian error: sphere surface point ",
         sphereSurfacePoint, ", expected ", expected, " output ", output)
@@ -68,7 +68,34 @@ func TestLambertianReflection(t *testing.T) {
   }
 
   //Chage function back to how it was. 
-  randomUnitSphereSurfacePoint = distributions.RandomUnitSphereSurfacePoint
+  randomUnitSphereSurfacePoint = distributions.UnitSphereSurfacePoint
+}
+
+//In other dimensions, the random direction is spread evenly over the
+//sphere, so that the mean of the fourth power of each of its components
+//is 3 / n (n + 2).
+func TestLambertianReflectionDimensions(t *testing.T) {
+  s := distributions.NewRandomSampler(1)
+  for _, dim := range []int{2, 4, 5} {
+    normal := make([]float64, dim)
+    normal[0] = 1
+
+    var mean float64
+    for k := 0; k < 20000; k ++ {
+      r := LambertianReflection(s, normal, normal)
+      r[0] -= 1
+      if !test.CloseEnough(vector.Length(r), 1, .000001) {
+        t.Error("Lambertian dimensions error 1: ", dim, r)
+        break
+      }
+      mean += r[1] * r[1] * r[1] * r[1] / 20000
+    }
+
+    n := float64(dim)
+    if !test.CloseEnough(mean, 3 / (n * (n + 2)), .005) {
+      t.Error("Lambertian dimensions error 2: ", dim, mean, 3 / (n * (n + 2)))
+    }
+  }
 }
 
 func TestMirrorReflection(t *testing.T) {
@@ -76,7 +103,7 @@ func TestMirrorReflection(t *testing.T) {
   incoming := getRandomIncoming(norm)
 
   rf := MirrorReflection
-  outgoing := rf(incoming, norm)
+  outgoing := rf(nil, incoming, norm)
   d := vector.Dot(incoming, norm)
   test_vector := make([]float64, len(norm))
   for i := 0; i < len(norm); i++ {
@@ -95,9 +122,9 @@ func TestBasicRefraction(t *testing.T) {
 
   v := []float64{0, 1}
   n := []float64{0.70710678118654752440, -0.70710678118654752440}
-  vin_s := refract_s(v, n) //Refraction case from out to in.
-  vin_q := refract_q(v, n) //Reflection case.
-  vout  := refract_s(vin_s, vector.Negative(n)) //Refraction from in to out.
+  vin_s := refract_s(nil, v, n) //Refraction case from out to in.
+  vin_q := refract_q(nil, v, n) //Reflection case.
+  vout  := refract_s(nil, vin_s, vector.Negative(n)) //Refraction from in to out.
 
   if !test.VectorCloseEnough([]float64{-0.41143782776614764763, 0.91143782776614764763}, vin_s, .00001) {
     t.Error("refraction error 1")
@@ -123,8 +150,8 @@ func TestSpecularReflection(t *testing.T) {
 
   sf := SpecularReflection(1)
 
-  outgoing_exp  := MirrorReflection(incoming, norm)
-  outgoing_test := sf(incoming, norm)
+  outgoing_exp  := MirrorReflection(nil, incoming, norm)
+  outgoing_test := sf(nil, incoming, norm)
 
   if !test.VectorCloseEnough(vector.Minus(outgoing_test, outgoing_exp), normallyDistributedVector, red_err) {
     t.Error("specular reflection error: ", outgoing_test, outgoing_exp)
@@ -144,5 +171,47 @@ func TestSpecularReflection(t *testing.T) {
   }*/
 
   //Chage function back to how it was. 
-  randomNormallyDistributedVector = distributions.RandomNormallyDistributedVector
+  randomNormallyDistributedVector = distributions.NormallyDistributedVector
+}
+
+func TestDispersion(t *testing.T) {
+  if Cauchy() != nil || Sellmeier([]float64{1}, []float64{1, 2}) != nil || Sellmeier(nil, nil) != nil {
+    t.Error("dispersion error 1")
+  }
+
+  //Cauchy's coefficients for fused silica.
+  silica := Cauchy(1.4580, .00354)
+  if !test.CloseEnough(silica(500), 1.4580 + .00354 / .25, red_err) {
+    t.Error("dispersion error 2: ", silica(500))
+  }
+
+  //Sellmeier's coefficients for BK7 glass, which has an index of 1.5168 at 587.6 nm.
+  bk7 := Sellmeier([]float64{1.03961212, .231792344, 1.01046945},
+    []float64{.00600069867, .0200179144, 103.560653})
+  if !test.CloseEnough(bk7(587.6), 1.5168, .0001) {
+    t.Error("dispersion error 3: ", bk7(587.6))
+  }
+
+  //Blue light is bent more than red.
+  for _, n := range []Dispersion{silica, bk7} {
+    if !(n(450) > n(650)) {
+      t.Error("dispersion error 4: ", n(450), n(650))
+    }
+  }
+
+  //BK7 has an Abbe number of 64.17.
+  if Abbe(0, 64) != nil || Abbe(1.5, -1) != nil {
+    t.Error("dispersion error 5")
+  }
+  abbe := Abbe(1.5168, 64.17)
+  if !test.CloseEnough(abbe(FraunhoferD), 1.5168, .000001) ||
+    !test.CloseEnough((abbe(FraunhoferD) - 1) / (abbe(FraunhoferF) - abbe(FraunhoferC)), 64.17, .000001) {
+    t.Error("dispersion error 6: ", abbe(FraunhoferD), abbe(FraunhoferF), abbe(FraunhoferC))
+  }
+  if !test.CloseEnough(abbe(450), bk7(450), .001) {
+    t.Error("dispersion error 7: ", abbe(450), bk7(450))
+  }
+  if n := Abbe(1.5, 0); n(450) != 1.5 || n(650) != 1.5 {
+    t.Error("dispersion error 8")
+  }
 }
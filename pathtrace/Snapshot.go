@@ -1,102 +1,154 @@
 package pathtrace
 
 import "image"
-import "image/color"
-import "math"
+import "github.com/DanielKrawisz/CurvedSpace/hdr"
 import "github.com/DanielKrawisz/CurvedSpace/distributions"
-import "fmt"
-import "time"
 
-//Create a section of a photo. 
-func snapSegment(scene *Scene, cam_func GenerateRay,
-  size_u, v_min, v_max, depth, minp, maxp int, maxMeanVariance float64) [][][]float64 {
+//The samples of a pixel so far.
+type pixelSamples struct {
+  //The sum of the colors of the samples and the number of them.
+  sum [3]float64
+  n int
+  //The variance of each channel.
+  monitor [3]distributions.SampleStatistics
+}
 
-  section := make([][][]float64, v_max - v_min)
+//Whether the pixel needs no more samples. It has at least one more than minp
+//samples, after which it is done when the variance of its mean is low
+//enough, and no more than one more than maxp.
+func (s *pixelSamples) done(minp, maxp int, maxMeanVariance float64) bool {
+  if s.n == 0 { return false }
+  if s.n > maxp { return true }
+  if s.n <= minp { return false }
+
+  for l := 0; l < 3; l ++ {
+    if s.monitor[l].MeanVariance() > maxMeanVariance {
+      return false
+    }
+  }
+  return true
+}
 
-  var ray_pos, ray_dir []float64
+//Trace up to k more samples for pixel x, y until it is done, and splat
+//them and their features onto film. If k is negative, there is no limit. The lengths of the
+//paths are added to stats in row y - v_min if stats is not nil.
+func (s *pixelSamples) sample(scene *Scene, cam_func GenerateRay, film *Film, x, y, k, depth, minp, maxp int,
+  maxMeanVariance float64, stats *PathStatistics, v_min int) {
+  for i := 0; i != k && !s.done(minp, maxp, maxMeanVariance); i ++ {
+    //Find where the ray goes through the pixel, then start
+    //the sample again so that the camera finds the same.
+    scene.sampler.Start(x, y, s.n)
+    dx, dy := PixelOffset(scene.sampler)
+
+    //Set up the ray. The camera may choose its wavelength.
+    scene.sampler.Start(x, y, s.n)
+    cs := &cameraSampler{scene.sampler, scene, nil}
+    ray_pos, ray_dir := cam_func(cs, x, y)
+    ray := cs.ray
+    if ray == nil {
+      ray = scene.newRay(ray_pos, ray_dir)
+    } else {
+      ray.position, ray.direction = ray_pos, ray_dir
+    }
 
-  for i := v_min; i < v_max; i ++ {
-    section[i - v_min] = make([][]float64, size_u)
+    //Trace the path.
+    var features Features
+    c, length := scene.trace(ray, depth, 1./256., &features)
+    if stats != nil {
+      stats.Add(x, y - v_min, length)
+    }
 
-    for j := 0; j < size_u; j ++ {
-      pix := make([]float64, 3)
-
-      var p int = 0
-      var variance_check bool
-
-      //Set up the variance monitor.
-      var monitor []*distributions.SampleStatistics = []*distributions.SampleStatistics{
-        distributions.NewSampleStatistics(), 
-        distributions.NewSampleStatistics(), 
-        distributions.NewSampleStatistics()}
-
-      for {
-        //Set up the ray.
-        ray_pos, ray_dir = cam_func(j, i)
-
-        //Trace the path.
-        c := scene.TracePath(ray_pos, ray_dir, depth, 1./256.)
-
-        p ++
-        //iterations ++
-        //iteration_count ++
-
-        //Add the pixel to the variance monitor.
-        for l := 0; l < 3; l ++ {
-          pix[l] += c[l]
-          monitor[l].AddVariable(c[l])
-        }
-
-        if p > maxp {
-          break
-        }
-
-        //Check variance
-        if p > minp {
-          variance_check = true
-          for l := 0; l < 3; l ++ {
-            if monitor[l].MeanVariance() > maxMeanVariance {
-              variance_check = false
-            }
-          }
-          if variance_check {
-            break
-          }
-        }
-      }
+    s.n ++
+    for l := 0; l < 3; l ++ {
+      s.sum[l] += c[l]
+      s.monitor[l].AddVariable(c[l])
+    }
+    film.Splat(x, y, dx, dy, c)
+    film.addFeatures(x, y, &features)
+  }
+}
 
-      //Generate the pixel. 
-      for l := 0; l < 3; l ++ {
-        pix[l] = math.Min(255 * pix[l] / float64(p), 255)
-      }
+//The mean color of the samples.
+func (s *pixelSamples) mean() []float64 {
+  pix := make([]float64, 3)
+  for l := 0; l < 3; l ++ {
+    pix[l] = s.sum[l] / float64(s.n)
+  }
+  return pix
+}
+
+//Create a section of a photo on a film of its own, with the scene's filter,
+//along with the lengths of the paths traced for it. 
+func snapSegment(scene *Scene, cam_func GenerateRay,
+  size_u, v_min, v_max, depth, minp, maxp int, maxMeanVariance float64) (*Film, *PathStatistics) {
 
-      section[i - v_min][j] = pix
+  film := newFilmSection(size_u, v_min, v_max, scene.filter)
+  stats := NewPathStatistics(size_u, v_max - v_min)
+
+  for i := v_min; i < v_max; i ++ {
+    for j := 0; j < size_u; j ++ {
+      var pix pixelSamples
+      pix.sample(scene, cam_func, film, j, i, -1, depth, minp, maxp, maxMeanVariance, stats, v_min)
     }
   }
 
-  return section
+  return film, stats
 }
 
 //A data structure used to pass information over a channel from
 //one goroutine to another. 
 type image_slice struct {
   v_min, v_max int
-  pix [][][]float64
+  film *Film
+  stats *PathStatistics
 }
 
-//Snap a photo! 
+//Called as the rows of a picture are finished, with the number of
+//rows that are done and the number of rows in the picture. 
+type Progress func(done, total int)
+
+//Snap a photo! The notification parameters are not used. Use
+//SnapshotWithProgress to follow the progress of the picture. 
 func Snapshot(sceneBuild func() *Scene, cam_func GenerateRay, size_u, size_v, depth, minp, maxp int,
   maxMeanVariance float64, minPercentNotification float64,
   minIterationNotification, routines int) *image.NRGBA {
-  img := image.NewNRGBA(image.Rect(0, 0, size_u, size_v))
+  return SnapshotWithProgress(sceneBuild, cam_func, size_u, size_v, depth, minp, maxp,
+    maxMeanVariance, routines, nil)
+}
+
+//Snap a photo over several goroutines, each with its own scene. The
+//samples are splatted onto the picture with the filter of the scene.
+//Colors brighter than white are clipped. progress may be nil. 
+func SnapshotWithProgress(sceneBuild func() *Scene, cam_func GenerateRay, size_u, size_v, depth, minp, maxp int,
+  maxMeanVariance float64, routines int, progress Progress) *image.NRGBA {
+  return SnapshotHDR(sceneBuild, cam_func, size_u, size_v, depth, minp, maxp,
+    maxMeanVariance, routines, progress).ToneMap(hdr.Clamp(), 1)
+}
+
+//Snap a photo without tone mapping it, so that it can be saved
+//in a high dynamic range format or tone mapped later. 
+func SnapshotHDR(sceneBuild func() *Scene, cam_func GenerateRay, size_u, size_v, depth, minp, maxp int,
+  maxMeanVariance float64, routines int, progress Progress) *hdr.Image {
+  img, _ := SnapshotStatistics(sceneBuild, cam_func, size_u, size_v, depth, minp, maxp,
+    maxMeanVariance, routines, progress)
+  return img
+}
+
+//Like SnapshotHDR, but also returns the lengths of the paths that were traced. 
+func SnapshotStatistics(sceneBuild func() *Scene, cam_func GenerateRay, size_u, size_v, depth, minp, maxp int,
+  maxMeanVariance float64, routines int, progress Progress) (*hdr.Image, *PathStatistics) {
+  film, stats := SnapshotFilm(sceneBuild, cam_func, size_u, size_v, depth, minp, maxp,
+    maxMeanVariance, routines, progress)
+  return film.Image(), stats
+}
 
-  //Write to the screen information about the progress of the picture. 
-  /*notify := func() {
-    fmt.Println(percent, " complete. ", pixels, " pixels ", iterations, "iterations",
-      float64(iterations) / float64(pixels), "iterations per pixel.")
-    iteration_count = 0
-    percent_monitor = 0
-  }*/
+//Like SnapshotStatistics, but returns the film that the samples were
+//splatted onto, from which the features of the picture can be had too.
+func SnapshotFilm(sceneBuild func() *Scene, cam_func GenerateRay, size_u, size_v, depth, minp, maxp int,
+  maxMeanVariance float64, routines int, progress Progress) (*Film, *PathStatistics) {
+  film := NewFilm(size_u, size_v, nil)
+  merger := newFilmMerger(film)
+  stats := NewPathStatistics(size_u, size_v)
 
   //Set up the wait group and channels. 
   height := 5
@@ -115,64 +167,47 @@ func Snapshot(sceneBuild func() *Scene, cam_func GenerateRay, size_u, size_v, de
     } else {
       param[1] = i + height
     }
-    fmt.Println("slice ", i)
 
     ch_in <- param
   }
-  fmt.Println("got this far - ugh", routines)
+  close(ch_in)
 
   //Start running the go routines.
   for i := 0; i < routines; i ++ {
-
-    fmt.Println("about to start a routine.")
     go func(scene *Scene, ch_in chan []int, ch_out chan *image_slice) {
-      fmt.Println("++ go routine started.")
       for param := range ch_in {
-        fmt.Println("++ about to run slice", param[0], param[1])
-        time.Sleep(time.Millisecond * 250)
-
-        ch_out <- &image_slice{param[0], param[1],
-          snapSegment(scene, cam_func, size_u, param[0], param[1], depth, minp, maxp, maxMeanVariance)}
-        //ch_out <- &image_slice{param[0], param[1], [][][]float64{}}
+        film, stats := snapSegment(scene, cam_func, size_u, param[0], param[1], depth, minp, maxp, maxMeanVariance)
+        ch_out <- &image_slice{param[0], param[1], film, stats}
       }
     } (sceneBuild(), ch_in, ch_out)
   }
 
-  fmt.Println("got this far")
-
   //Create the image over several go routines. 
   received := 0
+  done := 0
   for received < slices {
-    select {
-    case slice := <-ch_out :
-      fmt.Println("got slice ", slice.v_min, slice.v_max)
-      for i := slice.v_min; i < slice.v_max; i ++ {
-        for j := 0; j < size_u; j ++ {
-          pix := slice.pix[i - slice.v_min][j]
-          img.Set(j, i, &color.NRGBA{uint8(pix[0]), uint8(pix[1]), uint8(pix[2]), 255})
-        }
-      }
-      received ++
+    slice := <-ch_out
+    merger.merge(&filmSection{slice.v_min, slice.v_max, slice.film})
+    stats.merge(slice.stats, slice.v_min)
+    received ++
+
+    done += slice.v_max - slice.v_min
+    if progress != nil {
+      progress(done, size_v)
     }
   }
 
-  return img
+  return film, stats
 }
 
 //Snap a photo! This is the old version, without multithreading. 
 func SnapshotNoThreads(scene *Scene, cam_func GenerateRay, size_u, size_v,
   depth, minp, maxp int, maxMeanVariance float64,
   minPercentNotification float64, minIterationNotification int) *image.NRGBA {
-  img := image.NewNRGBA(image.Rect(0, 0, size_u, size_v))                                           
+  film := NewFilm(size_u, size_v, nil)
 
-  slice := snapSegment(scene, cam_func, size_u, 0, size_v, depth, minp, maxp, maxMeanVariance)
+  section, _ := snapSegment(scene, cam_func, size_u, 0, size_v, depth, minp, maxp, maxMeanVariance)
+  film.add(section)
 
-  for i := 0; i < size_v; i ++ {
-    for j := 0; j < size_u; j ++ {
-      pix := slice[i][j]
-      img.Set(j, i, &color.NRGBA{uint8(pix[0]), uint8(pix[1]), uint8(pix[2]), 255})
-    }
-  }
-
-  return img
+  return film.Image().ToneMap(hdr.Clamp(), 1)
 }
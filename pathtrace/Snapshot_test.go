@@ -0,0 +1,223 @@
+package pathtrace
+
+import "testing"
+import "github.com/DanielKrawisz/CurvedSpace/color"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+import "github.com/DanielKrawisz/CurvedSpace/hdr"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/surface/booleans"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+func TestSnapshotProgress(t *testing.T) {
+  //A glowing sphere on the left side of the picture.
+  build := func() *Scene {
+    sphere := polynomialsurfaces.NewSphere([]float64{-2, 0, 0}, 1)
+    return NewScene([]*ExtendedObject{NewExtendedObject(sphere, NewGlowingObject([]float64{1, 1, 1}))},
+      color.ConstantColorFunction(color.PresetColor([]float64{0, 0, 0})))
+  }
+
+  //Rays go straight up from the plane z = -5.
+  cam := func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    return []float64{float64(i) / 4 - 1.875, float64(j) / 4 - .375, -5}, []float64{0, 0, 1}
+  }
+
+  var calls, last int
+  img := SnapshotWithProgress(build, cam, 8, 13, 1, 1, 1, 1, 3, func(done, total int) {
+    if total != 13 || done <= last || done > total {
+      t.Error("snapshot progress error 1: ", done, total)
+    }
+    last = done
+    calls ++
+  })
+
+  if last != 13 || calls != 3 {
+    t.Error("snapshot progress error 2: ", last, calls)
+  }
+
+  if img.Bounds().Dx() != 8 || img.Bounds().Dy() != 13 {
+    t.Error("snapshot progress error 3: ", img.Bounds())
+  }
+
+  if c := img.NRGBAAt(1, 1); c.R != 255 || c.G != 255 || c.B != 255 {
+    t.Error("snapshot progress error 4: ", c)
+  }
+  if c := img.NRGBAAt(6, 1); c.R != 0 || c.G != 0 || c.B != 0 {
+    t.Error("snapshot progress error 5: ", c)
+  }
+
+  //Snapshot does the same thing without progress.
+  img = Snapshot(build, cam, 8, 13, 1, 1, 1, 1, 1, 100000, 2)
+  if c := img.NRGBAAt(1, 1); c.R != 255 {
+    t.Error("snapshot progress error 6: ", c)
+  }
+}
+
+func TestSnapshotHDR(t *testing.T) {
+  build := func() *Scene {
+    return NewScene([]*ExtendedObject{},
+      color.ConstantColorFunction(color.PresetColor([]float64{4, .5, 0})))
+  }
+  cam := func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    return []float64{0, 0, 0}, []float64{0, 0, 1}
+  }
+
+  //Colors brighter than white are kept.
+  img := SnapshotHDR(build, cam, 3, 2, 1, 1, 1, 1, 2, nil)
+  if img.Width != 3 || img.Height != 2 {
+    t.Error("snapshot hdr error 1: ", img.Width, img.Height)
+  }
+  for y := 0; y < 2; y ++ {
+    for x := 0; x < 3; x ++ {
+      if c := img.At(x, y); c[0] != 4 || c[1] != .5 || c[2] != 0 {
+        t.Error("snapshot hdr error 2: ", x, y, c)
+      }
+    }
+  }
+}
+
+func TestSnapshotStatistics(t *testing.T) {
+  build := func() *Scene {
+    sphere := polynomialsurfaces.NewSphere([]float64{-2, 0, 0}, 1)
+    return NewScene([]*ExtendedObject{NewExtendedObject(sphere, NewGlowingObject([]float64{1, 1, 1}))},
+      color.ConstantColorFunction(color.PresetColor([]float64{0, 0, 0})))
+  }
+  cam := func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    return []float64{float64(i) / 4 - 1.875, float64(j) / 4 - .375, -5}, []float64{0, 0, 1}
+  }
+
+  //Paths hit the sphere on the left and nothing on the right.
+  img, stats := SnapshotStatistics(build, cam, 8, 13, 3, 1, 1, 1, 3, nil)
+  if img.Width != 8 || stats.Width != 8 || stats.Height != 13 {
+    t.Error("snapshot statistics error 1: ", stats.Width, stats.Height)
+    return
+  }
+
+  if mean, max, paths := stats.At(1, 1); mean != 1 || max != 1 || paths != 2 {
+    t.Error("snapshot statistics error 2: ", mean, max, paths)
+  }
+  if mean, max, paths := stats.At(6, 1); mean != 0 || max != 0 || paths != 2 {
+    t.Error("snapshot statistics error 3: ", mean, max, paths)
+  }
+
+  if len(stats.Lengths) != 2 || stats.Lengths[0] + stats.Lengths[1] != 8 * 13 * 2 || stats.Lengths[1] == 0 {
+    t.Error("snapshot statistics error 4: ", stats.Lengths)
+  }
+
+  mean, max := stats.Summary()
+  if max != 1 || mean != float64(stats.Lengths[1]) / float64(8 * 13 * 2) {
+    t.Error("snapshot statistics error 5: ", mean, max)
+  }
+
+  if c := stats.Image().At(1, 1); c[0] != 1 || c[1] != 1 || c[2] != 2 {
+    t.Error("snapshot statistics error 6: ", c)
+  }
+}
+
+//A white floor under a sky which has the color bright on one side and
+//dark on the other, sampled with the given sampler.
+func floorScene(bright, dark []float64, sampler distributions.Sampler) *Scene {
+  sky := func(dir []float64) color.Color {
+    if dir[0] > 0 {
+      return color.PresetColor(bright)
+    }
+    return color.PresetColor(dark)
+  }
+
+  floor := polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 0}, []float64{0, 0, 1}, true)
+  scene := NewScene([]*ExtendedObject{NewExtendedObject(floor,
+    NewLambertianReflector(floor, Absorb([]float64{1, 1, 1})))}, sky)
+  scene.SetSampler(sampler)
+  return scene
+}
+
+//A camera which looks straight down on the floor from above.
+func floorCamera(width, height int) GenerateRay {
+  return func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    u, v := CameraCoordinates(s, i, j, width, height, 5, 5)
+    return []float64{u, v, 1}, []float64{0, 0, -1}
+  }
+}
+
+//A white floor under a sky which is bright on one side. Every pixel is half
+//as bright as the sky, and an even sampler finds that with fewer rays.
+func TestSamplerConvergence(t *testing.T) {
+  cam := floorCamera(20, 20)
+
+  errors := make([]float64, 2)
+  for k, sampler := range []func(int64) distributions.Sampler{distributions.NewRandomSampler, distributions.NewSobolSampler} {
+    build := func() *Scene {
+      return floorScene([]float64{1, 1, 1}, []float64{0, 0, 0}, sampler(1))
+    }
+
+    img := SnapshotHDR(build, cam, 20, 20, 2, 15, 15, 0, 2, nil)
+    for y := 0; y < 20; y ++ {
+      for x := 0; x < 20; x ++ {
+        c := img.At(x, y)[0] - .5
+        errors[k] += c * c / 400
+      }
+    }
+  }
+
+  if !(errors[1] * 4 < errors[0]) {
+    t.Error("sampler convergence error: ", errors)
+  }
+}
+
+//A picture depends only on the seed of the sampler, not on how its rows
+//are shared among goroutines, even with mist, glass which splits light
+//into colors, Russian roulette and shadow rays.
+func TestSnapshotRepeatable(t *testing.T) {
+  cam := func(s distributions.Sampler, i, j int) ([]float64, []float64) {
+    u, v := CameraCoordinates(s, i, j, 16, 16, 4, 4)
+    return []float64{0, -6, 1}, vector.Normalize([]float64{u, 4, v - 1})
+  }
+
+  sky := func(dir []float64) color.Color {
+    return color.PresetColor([]float64{.2, .3, .5 + .5 * dir[2]})
+  }
+
+  snap := func(seed int64, routines int) (*hdr.Image, *PathStatistics) {
+    build := func() *Scene {
+      floor := polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0, 0}, []float64{0, 0, 1}, true)
+      glass := polynomialsurfaces.NewSphere([]float64{-1, 0, 1}, 1)
+      mist := booleans.NewOpenBounding(polynomialsurfaces.NewSphere([]float64{1.5, 0, 1}, 1),
+        surface.NewInsubstantialSurface(3, .5))
+      scene := NewScene([]*ExtendedObject{
+        NewExtendedObject(floor, NewShineyInteractor(floor, Absorb([]float64{.8, .8, .8}), .3, .1)),
+        NewExtendedObject(glass, NewDispersiveGlassInteractor(glass, Absorb([]float64{1, 1, 1}), Cauchy(1.5, 8000), .1, .9)),
+        NewExtendedObject(mist, NewScatterTransmitter(Absorb([]float64{.9, .9, .9}), .5)),
+        NewExtendedObject(polynomialsurfaces.NewSphere([]float64{0, 2, 4}, .5), NewGlowingObject([]float64{8, 8, 8}))}, sky)
+      scene.SetSpectral(4)
+      scene.SetRussianRoulette(2)
+      scene.SetSampler(distributions.NewSobolSampler(seed))
+      return scene
+    }
+    return SnapshotStatistics(build, cam, 16, 16, 8, 2, 6, .001, routines, nil)
+  }
+
+  a, astats := snap(7, 1)
+  b, bstats := snap(7, 4)
+  c, _ := snap(8, 4)
+
+  same, differ := true, false
+  for y := 0; y < 16; y ++ {
+    for x := 0; x < 16; x ++ {
+      p, q, r := a.At(x, y), b.At(x, y), c.At(x, y)
+      for i := range p {
+        if p[i] != q[i] { same = false }
+        if p[i] != r[i] { differ = true }
+      }
+      if _, max, paths := astats.At(x, y); max != bstats.Max[y * 16 + x] || paths != bstats.Paths[y * 16 + x] {
+        same = false
+      }
+    }
+  }
+
+  if !same {
+    t.Error("snapshot repeatable error 1")
+  }
+  if !differ {
+    t.Error("snapshot repeatable error 2")
+  }
+}
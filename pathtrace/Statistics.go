@@ -0,0 +1,88 @@
+package pathtrace
+
+import "github.com/DanielKrawisz/CurvedSpace/hdr"
+
+//The lengths of the paths traced for a picture, which show where a scene
+//needs more depth or where paths go on longer than they are worth.
+//The length of a path is the number of objects that it hit.
+type PathStatistics struct {
+  Width, Height int
+  //The mean and greatest length of the paths traced for each
+  //pixel and the number of paths, in rows from the top.
+  Mean []float64
+  Max, Paths []int
+  //The number of paths of each length over the whole picture.
+  Lengths []int
+}
+
+func NewPathStatistics(width, height int) *PathStatistics {
+  if width < 0 { width = 0 }
+  if height < 0 { height = 0 }
+  n := width * height
+  return &PathStatistics{width, height, make([]float64, n), make([]int, n), make([]int, n), make([]int, 0)}
+}
+
+//Add a path traced for pixel x, y.
+func (s *PathStatistics) Add(x, y, length int) {
+  i := y * s.Width + x
+  s.Paths[i] ++
+  s.Mean[i] += (float64(length) - s.Mean[i]) / float64(s.Paths[i])
+  if length > s.Max[i] {
+    s.Max[i] = length
+  }
+
+  for len(s.Lengths) <= length {
+    s.Lengths = append(s.Lengths, 0)
+  }
+  s.Lengths[length] ++
+}
+
+//The mean and greatest length of the paths for pixel x, y, and the number of paths.
+func (s *PathStatistics) At(x, y int) (mean float64, max, paths int) {
+  i := y * s.Width + x
+  return s.Mean[i], s.Max[i], s.Paths[i]
+}
+
+//The mean and greatest length of all the paths.
+func (s *PathStatistics) Summary() (mean float64, max int) {
+  var n int
+  for l, k := range s.Lengths {
+    n += k
+    mean += float64(l * k)
+    if k > 0 {
+      max = l
+    }
+  }
+  if n > 0 {
+    mean /= float64(n)
+  }
+  return
+}
+
+//A picture of the statistics which can be saved in a high dynamic
+//range format. Red is the mean length of the paths of each pixel,
+//green is the greatest, and blue is the number of paths.
+func (s *PathStatistics) Image() *hdr.Image {
+  img := hdr.NewImage(s.Width, s.Height)
+  for y := 0; y < s.Height; y ++ {
+    for x := 0; x < s.Width; x ++ {
+      mean, max, paths := s.At(x, y)
+      img.Set(x, y, []float64{mean, float64(max), float64(paths)})
+    }
+  }
+  return img
+}
+
+//Put the statistics of a group of rows into these, starting at row v.
+func (s *PathStatistics) merge(rows *PathStatistics, v int) {
+  copy(s.Mean[v * s.Width:], rows.Mean)
+  copy(s.Max[v * s.Width:], rows.Max)
+  copy(s.Paths[v * s.Width:], rows.Paths)
+
+  for len(s.Lengths) < len(rows.Lengths) {
+    s.Lengths = append(s.Lengths, 0)
+  }
+  for l, k := range rows.Lengths {
+    s.Lengths[l] += k
+  }
+}
@@ -0,0 +1,304 @@
+package scenes
+
+import (
+	"fmt"
+	"github.com/DanielKrawisz/CurvedSpace/surface"
+)
+
+//A scene may be animated by giving keyframes for its camera and objects:
+//
+// "animation": {
+//   "frames": 48, "rate": 24,
+//   "camera":  [{"time": 0, "position": [0, 0, 3]}, {"time": 2, "position": [3, 0, 0], "look": [0, 0, 1]}],
+//   "objects": [{"object": 1, "keys": [{"time": 0, "translate": [0, 0, 0]}, {"time": 2, "translate": [0, 0, 1]}]}]
+// }
+//
+//Times are in seconds, and frame i is at time i / rate. Each value goes
+//along a smooth curve through the keyframes which give it, and stays where
+//it is before the first of them and after the last, so keyframes need not
+//give every value. The camera's position, look, up, right, and fov replace
+//those of the camera, so that it can be turned all the way around. An object is given by its index in the list of objects, and
+//its transform and translate are applied after its own, as they are for
+//surfaces. If the camera has a shutter which is open for a while, the
+//objects move while it is open, which blurs them.
+
+//An animation of a scene.
+type AnimationSpec struct {
+	Frames int `json:"frames"`
+	//The number of frames each second. If this is zero, it is 24.
+	Rate    float64            `json:"rate"`
+	Camera  []*CameraKey       `json:"camera"`
+	Objects []*ObjectAnimation `json:"objects"`
+}
+
+//Where the camera is at a time.
+type CameraKey struct {
+	Time     float64   `json:"time"`
+	Position []float64 `json:"position"`
+	Look     []float64 `json:"look"`
+	Up       []float64 `json:"up"`
+	Right    []float64 `json:"right"`
+	Fov      []float64 `json:"fov"`
+}
+
+//How an object moves.
+type ObjectAnimation struct {
+	Object int          `json:"object"`
+	Keys   []*MotionKey `json:"keys"`
+}
+
+//Where an object is at a time.
+type MotionKey struct {
+	Time      float64     `json:"time"`
+	Transform [][]float64 `json:"transform"`
+	Translate []float64   `json:"translate"`
+}
+
+//The default number of frames each second.
+const DefaultRate = 24
+
+//A curve through values given at increasing times, which is a
+//Catmull-Rom spline, going through each value in the direction from
+//the one before it to the one after it.
+type Spline struct {
+	times  []float64
+	values [][]float64
+}
+
+//May return nil, if there are no values, if the times do not
+//increase, or if the values are not all the same length.
+func NewSpline(times []float64, values [][]float64) *Spline {
+	if len(times) == 0 || len(times) != len(values) {
+		return nil
+	}
+	for i := range times {
+		if len(values[i]) != len(values[0]) || (i > 0 && !(times[i] > times[i-1])) {
+			return nil
+		}
+	}
+
+	return &Spline{append([]float64{}, times...), copyVectors(values)}
+}
+
+//The value at a time. Before the first time and after the
+//last, it is the first or last value.
+func (s *Spline) At(t float64) []float64 {
+	n := len(s.times) - 1
+	if t <= s.times[0] {
+		return copyVector(s.values[0])
+	}
+	if t >= s.times[n] {
+		return copyVector(s.values[n])
+	}
+
+	i := 0
+	for t >= s.times[i+1] {
+		i++
+	}
+
+	//The curve between two keyframes is the cubic which has the values
+	//and the slopes that are given at each end.
+	h := s.times[i+1] - s.times[i]
+	u := (t - s.times[i]) / h
+	u2, u3 := u*u, u*u*u
+	a, b := 2*u3-3*u2+1, -2*u3+3*u2
+	c, d := h*(u3-2*u2+u), h*(u3-u2)
+
+	m0, m1 := s.slope(i), s.slope(i+1)
+	v := make([]float64, len(s.values[i]))
+	for k := range v {
+		v[k] = a*s.values[i][k] + b*s.values[i+1][k] + c*m0[k] + d*m1[k]
+	}
+	return v
+}
+
+//The slope of the curve at keyframe i.
+func (s *Spline) slope(i int) []float64 {
+	lo, hi := i-1, i+1
+	if lo < 0 {
+		lo = 0
+	}
+	if hi >= len(s.times) {
+		hi = len(s.times) - 1
+	}
+
+	m := make([]float64, len(s.values[i]))
+	if lo == hi {
+		return m
+	}
+	for k := range m {
+		m[k] = (s.values[hi][k] - s.values[lo][k]) / (s.times[hi] - s.times[lo])
+	}
+	return m
+}
+
+//A spline through the keyframes which give a value, or nil if none do.
+func keySpline(name string, times []float64, values [][]float64) (*Spline, error) {
+	var t []float64
+	var v [][]float64
+	for i := range times {
+		if values[i] != nil {
+			t = append(t, times[i])
+			v = append(v, values[i])
+		}
+	}
+	if t == nil {
+		return nil, nil
+	}
+
+	s := NewSpline(t, v)
+	if s == nil {
+		return nil, fmt.Errorf("scene: the keyframes of %s must be in order and of the same size", name)
+	}
+	return s, nil
+}
+
+//The rate of the animation.
+func (a *AnimationSpec) rate() float64 {
+	if a.Rate == 0 {
+		return DefaultRate
+	}
+	return a.Rate
+}
+
+//The time of a frame, in seconds.
+func (a *AnimationSpec) Time(frame int) float64 {
+	return float64(frame) / a.rate()
+}
+
+//Returns an error if the animation cannot be used.
+func (a *AnimationSpec) Check() error {
+	if a.Frames <= 0 || a.Rate < 0 {
+		return fmt.Errorf("scene: an animation needs a positive number of frames and rate")
+	}
+	if _, err := a.cameraSplines(); err != nil {
+		return err
+	}
+	for i, o := range a.Objects {
+		if o == nil || len(o.Keys) == 0 {
+			return fmt.Errorf("scene: animation of object %d needs keys", i)
+		}
+		if _, _, err := o.splines(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//The splines of the camera's position, look, up, right, and fov.
+func (a *AnimationSpec) cameraSplines() ([]*Spline, error) {
+	names := []string{"position", "look", "up", "right", "fov"}
+	times := make([]float64, len(a.Camera))
+	values := make([][][]float64, len(names))
+	for i := range values {
+		values[i] = make([][]float64, len(a.Camera))
+	}
+	for i, k := range a.Camera {
+		if k == nil {
+			return nil, fmt.Errorf("scene: camera keyframe %d is empty", i)
+		}
+		times[i] = k.Time
+		values[0][i], values[1][i], values[2][i], values[3][i], values[4][i] = k.Position, k.Look, k.Up, k.Right, k.Fov
+	}
+
+	splines := make([]*Spline, len(names))
+	for i, name := range names {
+		var err error
+		if splines[i], err = keySpline("the camera "+name, times, values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return splines, nil
+}
+
+//The camera at a time, which is a copy of the camera
+//with the values given by the keyframes.
+func (a *AnimationSpec) CameraAt(c *CameraSpec, t float64) (*CameraSpec, error) {
+	splines, err := a.cameraSplines()
+	if err != nil {
+		return nil, err
+	}
+
+	at := *c
+	for i, v := range []*[]float64{&at.Position, &at.Look, &at.Up, &at.Right, &at.Fov} {
+		if splines[i] != nil {
+			*v = splines[i].At(t)
+		}
+	}
+	return &at, nil
+}
+
+//The transform is made into a vector of its rows, one after another.
+func (o *ObjectAnimation) splines() (transform, translate *Spline, err error) {
+	n := len(o.Keys)
+	times := make([]float64, n)
+	transforms, translations := make([][]float64, n), make([][]float64, n)
+	for i, k := range o.Keys {
+		if k == nil {
+			return nil, nil, fmt.Errorf("scene: keyframe %d of object %d is empty", i, o.Object)
+		}
+		times[i], translations[i] = k.Time, k.Translate
+		if k.Transform != nil {
+			for _, row := range k.Transform {
+				if len(row) != len(k.Transform) {
+					return nil, nil, fmt.Errorf("scene: transform of object %d is not square", o.Object)
+				}
+				transforms[i] = append(transforms[i], row...)
+			}
+		}
+	}
+
+	name := fmt.Sprintf("object %d", o.Object)
+	if transform, err = keySpline("the transform of "+name, times, transforms); err != nil {
+		return
+	}
+	translate, err = keySpline("the translation of "+name, times, translations)
+	return
+}
+
+//Where the object is at each time, for a surface of dimension n.
+func (o *ObjectAnimation) motion(n int) (surface.Motion, error) {
+	transform, translate, err := o.splines()
+	if err != nil {
+		return nil, err
+	}
+
+	//Every keyframe has the size of the first.
+	if transform != nil && len(transform.values[0]) != n*n {
+		return nil, fmt.Errorf("animated transform has the wrong size")
+	}
+	if translate != nil && len(translate.values[0]) != n {
+		return nil, fmt.Errorf("animated translation has the wrong size")
+	}
+
+	return func(t float64) (m [][]float64, x []float64) {
+		if transform != nil {
+			v := transform.At(t)
+			m = make([][]float64, n)
+			for i := range m {
+				m[i] = v[i*n : (i+1)*n]
+			}
+		}
+		if translate != nil {
+			x = translate.At(t)
+		}
+		return
+	}, nil
+}
+
+//Move a surface to where the object is at a time.
+func (o *ObjectAnimation) move(s surface.Surface, t float64) error {
+	motion, err := o.motion(s.Dimension())
+	if err != nil {
+		return err
+	}
+
+	m, x := motion(t)
+	if m != nil {
+		s.CoordinateShift(m)
+	}
+	if x != nil {
+		s.Translate(x)
+	}
+	return nil
+}
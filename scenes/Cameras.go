@@ -0,0 +1,281 @@
+package scenes
+
+import (
+	"fmt"
+	"github.com/DanielKrawisz/CurvedSpace/pathtrace"
+	"github.com/DanielKrawisz/CurvedSpace/vector"
+)
+
+//The camera. The type is one of the cameras in pathtrace, such as flat,
+//isometric, cylindrical, or inverse_spherical, which are given by a
+//position, a point to look at, the directions which are up and right,
+//and the field of view in the horizontal and vertical directions.
+//The toroidal cameras are given by a position, major and minor.
+//
+//In more than three dimensions, the position, look, up, and right have
+//as many components as the space, and the picture is of the slice of the
+//space through the position which they span. The camera can be turned
+//further by rotations, each of which turns the first of two of its
+//directions toward the second by an angle in radians. The directions are
+//numbered forward, up, right, and then the rest of the directions of the
+//space, which are made from its axes to be perpendicular to them.
+//
+//Any camera can have a lens, which blurs what is not in focus, by giving
+//it an aperture. Then it needs up and right, and focus is the distance
+//which is in focus, which is the distance to look if it is zero. The lens
+//is round unless it has three or more blades, which make it a polygon
+//turned by blade_rotation radians.
+//
+//The lens camera looks through a system of lenses, given by their
+//elements or by the name of a prescription, whose lengths are multiplied
+//by scale. Its position is the middle of the film, whose width and height
+//are given by film, and the lens is focused on what is focus in front of
+//it, or on look if focus is zero. Its aperture, if it is given, is the
+//radius of its aperture stops.
+//
+//Any camera can have a shutter, given by the times at which it opens and
+//closes, in seconds from the time of the frame. Each ray is traced at a
+//time while it is open, so that objects which move then are blurred.
+type CameraSpec struct {
+	Type          string      `json:"type"`
+	Position      []float64   `json:"position"`
+	Look          []float64   `json:"look"`
+	Up            []float64   `json:"up"`
+	Right         []float64   `json:"right"`
+	Fov           []float64   `json:"fov"`
+	Major         [][]float64 `json:"major"`
+	Minor         [][]float64 `json:"minor"`
+	Aperture      float64     `json:"aperture"`
+	Focus         float64     `json:"focus"`
+	Blades        int         `json:"blades"`
+	BladeRotation float64     `json:"blade_rotation"`
+	Elements      []LensSpec  `json:"elements"`
+	Prescription  string      `json:"prescription"`
+	Scale         float64     `json:"scale"`
+	Film          []float64   `json:"film"`
+	Rotations     []Rotation  `json:"rotations"`
+	Shutter       []float64   `json:"shutter"`
+}
+
+//A turn of the camera by an angle from one of its directions toward another.
+type Rotation struct {
+	Plane []int   `json:"plane"`
+	Angle float64 `json:"angle"`
+}
+
+//A surface of a lens, from the front of the lens to the back. The radius
+//is positive if the center of the surface is behind it, and zero if it is
+//flat. The thickness is the distance to the next surface, or from the last
+//to the film, and the aperture is the radius of the opening of the surface.
+//Behind it is glass with the given index and Abbe number, or air if the
+//index is zero or one. Glass whose Abbe number is zero does not disperse
+//light.
+type LensSpec struct {
+	Radius    float64 `json:"radius"`
+	Thickness float64 `json:"thickness"`
+	Index     float64 `json:"index"`
+	Abbe      float64 `json:"abbe"`
+	Aperture  float64 `json:"aperture"`
+}
+
+//Prescriptions of lenses, in millimeters.
+var lenses map[string][]LensSpec = map[string][]LensSpec{
+	//A double Gauss lens of 50 millimeters at f/2, from Tronnier's
+	//US patent 2,673,491, as given in Smith, Modern Lens Design, p. 312.
+	"double_gauss": {
+		{29.475, 3.76, 1.67, 47.2, 12.6},
+		{84.83, .12, 1, 0, 12.6},
+		{19.275, 4.025, 1.67, 47.2, 11.5},
+		{40.77, 3.275, 1.699, 30.1, 11.5},
+		{12.75, 5.705, 1, 0, 9},
+		{0, 4.5, 1, 0, 8.55},
+		{-14.495, 1.18, 1.603, 38, 8.5},
+		{40.77, 6.065, 1.658, 50.9, 10},
+		{-20.385, .19, 1, 0, 10},
+		{437.065, 3.22, 1.717, 47.9, 10},
+		{-39.73, 40, 1, 0, 10}}}
+
+type cameraFunction func(pos []float64, mtrx [][]float64, pix_u, pix_v int, fov_u, fov_v float64) pathtrace.GenerateRay
+
+var cameras map[string]cameraFunction = map[string]cameraFunction{
+	"flat":                          pathtrace.FlatCamera,
+	"isometric":                     pathtrace.IsometricCamera,
+	"inverse_flat":                  pathtrace.InverseFlatCamera,
+	"cylindrical":                   pathtrace.CylindricalCamera,
+	"inverse_cylindrical":           pathtrace.InverseCylindricalCamera,
+	"isometric_cylindrical":         pathtrace.IsometricCylindricalCamera,
+	"inverse_isometric_cylindrical": pathtrace.InverseIsometricCylindricalCamera,
+	"polar_spherical":               pathtrace.PolarSphericalCamera,
+	"inverse_polar_spherical":       pathtrace.InversePolarSphericalCamera,
+	"spherical":                     pathtrace.SphericalCamera,
+	"inverse_spherical":             pathtrace.InverseSphericalCamera}
+
+//The function which gives the rays that come from the camera
+//for a picture of the given size.
+func (c *CameraSpec) Build(width, height int) (pathtrace.GenerateRay, error) {
+	var ray pathtrace.GenerateRay
+	up, right := c.Up, c.Right
+
+	switch c.Type {
+	case "lens":
+		ray, err := c.lensCamera(width, height)
+		if err != nil {
+			return nil, err
+		}
+		return c.shutter(ray)
+	case "toroidal":
+		ray = pathtrace.ToroidialCamera(copyVector(c.Position), copyVectors(c.Major), copyVectors(c.Minor), width, height)
+	case "inverse_toroidal":
+		ray = pathtrace.InverseToroidialCamera(copyVector(c.Position), copyVectors(c.Major), copyVectors(c.Minor), width, height)
+	default:
+		f, ok := cameras[c.Type]
+		if !ok {
+			return nil, fmt.Errorf("scene: unknown camera type %q", c.Type)
+		}
+
+		if len(c.Fov) != 2 {
+			return nil, fmt.Errorf("scene: %s camera needs a position, look, up, right, and fov", c.Type)
+		}
+
+		mtrx, err := c.orientation()
+		if err != nil {
+			return nil, err
+		}
+
+		ray = f(copyVector(c.Position), mtrx, width, height, c.Fov[0], c.Fov[1])
+		up, right = mtrx[1], mtrx[2]
+	}
+
+	if ray == nil {
+		return nil, fmt.Errorf("scene: invalid parameters for %s camera", c.Type)
+	}
+
+	if c.Aperture == 0 && c.Focus == 0 {
+		return c.shutter(ray)
+	}
+
+	focus := c.Focus
+	if focus == 0 && len(c.Look) == len(c.Position) {
+		focus = vector.Length(vector.Minus(c.Look, c.Position))
+	}
+	if len(up) != len(c.Position) || len(right) != len(c.Position) {
+		return nil, fmt.Errorf("scene: a camera with a lens needs up and right")
+	}
+
+	ray = pathtrace.ThinLens(ray, copyVector(up), copyVector(right), c.Aperture, focus, c.Blades, c.BladeRotation)
+	if ray == nil {
+		return nil, fmt.Errorf("scene: invalid lens for %s camera", c.Type)
+	}
+	return c.shutter(ray)
+}
+
+//Gives the rays of a camera times while its shutter is open.
+func (c *CameraSpec) shutter(ray pathtrace.GenerateRay) (pathtrace.GenerateRay, error) {
+	if c.Shutter == nil {
+		return ray, nil
+	}
+
+	if len(c.Shutter) == 2 {
+		ray = pathtrace.Shutter(ray, c.Shutter[0], c.Shutter[1])
+	}
+	if len(c.Shutter) != 2 || ray == nil {
+		return nil, fmt.Errorf("scene: the shutter needs a time to open and a later time to close")
+	}
+	return ray, nil
+}
+
+//Whether the shutter is open for a while, so that
+//objects move while it is open.
+func (c *CameraSpec) open() bool {
+	return c != nil && len(c.Shutter) == 2 && c.Shutter[1] > c.Shutter[0]
+}
+
+//The camera which looks through a system of lenses.
+func (c *CameraSpec) lensCamera(width, height int) (pathtrace.GenerateRay, error) {
+	elements := c.Elements
+	if c.Prescription != "" {
+		p, ok := lenses[c.Prescription]
+		if !ok {
+			return nil, fmt.Errorf("scene: unknown lens %q", c.Prescription)
+		}
+		elements = p
+	}
+
+	if len(c.Position) < 3 || len(c.Film) != 2 || len(elements) == 0 {
+		return nil, fmt.Errorf("scene: lens camera needs a position, look, up, right, film, and elements or a prescription")
+	}
+
+	//The orientation checks that look has the dimension of the
+	//position before the distance to it is used to focus.
+	mtrx, err := c.orientation()
+	if err != nil {
+		return nil, err
+	}
+
+	scale := c.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	if !(scale > 0) || c.Aperture < 0 {
+		return nil, fmt.Errorf("scene: invalid parameters for lens camera")
+	}
+
+	e := make([]pathtrace.LensElement, len(elements))
+	for i, l := range elements {
+		var glass pathtrace.Dispersion
+		if l.Index != 0 && l.Index != 1 {
+			glass = pathtrace.Abbe(l.Index, l.Abbe)
+			if glass == nil {
+				return nil, fmt.Errorf("scene: invalid glass in lens element %d", i)
+			}
+		}
+		e[i] = pathtrace.LensElement{Radius: l.Radius * scale, Thickness: l.Thickness * scale, Index: glass, Aperture: l.Aperture * scale}
+
+		//The aperture stops are flat with air on both sides.
+		if c.Aperture > 0 && l.Radius == 0 && glass == nil && (i == 0 || e[i-1].Index == nil) {
+			e[i].Aperture = c.Aperture
+		}
+	}
+
+	lens := pathtrace.NewLensSystem(e)
+	if lens == nil {
+		return nil, fmt.Errorf("scene: invalid lens elements")
+	}
+
+	focus := c.Focus
+	if focus == 0 {
+		focus = vector.Length(vector.Minus(c.Look, c.Position)) - lens.Length()
+	}
+	if !lens.Focus(focus) {
+		return nil, fmt.Errorf("scene: the lens cannot focus %v in front of it", focus)
+	}
+
+	ray := pathtrace.LensCamera(lens, copyVector(c.Position), mtrx, width, height, c.Film[0]/2, c.Film[1]/2)
+	if ray == nil {
+		return nil, fmt.Errorf("scene: invalid parameters for lens camera")
+	}
+	return ray, nil
+}
+
+//The directions of the camera, turned by its rotations.
+func (c *CameraSpec) orientation() ([][]float64, error) {
+	mtrx := pathtrace.CameraOrientation(c.Position, c.Look, c.Up, c.Right)
+	if mtrx == nil {
+		return nil, fmt.Errorf("scene: %s camera needs a position, look, up, and right of the same dimension", c.Type)
+	}
+
+	for i, r := range c.Rotations {
+		if len(r.Plane) == 2 {
+			mtrx = pathtrace.RotateOrientation(mtrx, r.Plane[0], r.Plane[1], r.Angle)
+		}
+		if len(r.Plane) != 2 || mtrx == nil {
+			return nil, fmt.Errorf("scene: invalid camera rotation %d", i)
+		}
+	}
+	return mtrx, nil
+}
+
+//Normalizes a copy of a vector.
+func normalized(v []float64) []float64 {
+	return vector.Normalize(append([]float64{}, v...))
+}
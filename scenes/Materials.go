@@ -0,0 +1,169 @@
+package scenes
+
+import (
+	"fmt"
+	"github.com/DanielKrawisz/CurvedSpace/color"
+	"github.com/DanielKrawisz/CurvedSpace/pathtrace"
+	"github.com/DanielKrawisz/CurvedSpace/surface"
+)
+
+//What an object does to light. The fields which are used depend on the type.
+//
+//  glow       - glow
+//  lambertian - the color
+//  mirror     - the color
+//  refractive - the color, index
+//  specular   - the color, scatter
+//  scatter    - the color, scatter
+//  shiny      - the color, shine, scatter
+//  glass      - the color, index, transmit, reflect
+//  dispersive - the color, transmit, reflect, and either cauchy
+//               or sellmeier_b and sellmeier_c
+//
+//A dispersive material has an index which depends on the wavelength,
+//given by Cauchy's equation or the Sellmeier equation. See
+//pathtrace.Cauchy and pathtrace.Sellmeier.
+//
+//The color of an object is given by glow, absorb, and absorption. An
+//object which only absorbs multiplies the color of light by absorb. An
+//object that only glows adds glow to it. If both are given, absorption
+//says how much of the light is absorbed and replaced by the glow.
+type MaterialSpec struct {
+	Type       string    `json:"type"`
+	Glow       []float64 `json:"glow"`
+	Absorb     []float64 `json:"absorb"`
+	Absorption float64   `json:"absorption"`
+	Index      float64   `json:"index"`
+	Scatter    float64   `json:"scatter"`
+	//The chance that light is reflected diffusely from a shiny object.
+	Shine float64 `json:"shine"`
+	//The relative strengths of refraction and reflection in glass.
+	Transmit float64 `json:"transmit"`
+	Reflect  float64 `json:"reflect"`
+	//The coefficients of the index of a dispersive material.
+	Cauchy     []float64 `json:"cauchy"`
+	SellmeierB []float64 `json:"sellmeier_b"`
+	SellmeierC []float64 `json:"sellmeier_c"`
+}
+
+func (m *MaterialSpec) color() (pathtrace.ColorInteraction, error) {
+	switch {
+	case m.Glow != nil && m.Absorb != nil:
+		return pathtrace.GlowAbsorbAverage(m.Glow, m.Absorb, m.Absorption), nil
+	case m.Glow != nil:
+		return pathtrace.Glow(m.Glow), nil
+	case m.Absorb != nil:
+		return pathtrace.Absorb(m.Absorb), nil
+	}
+	return nil, fmt.Errorf("%s material needs a color", m.Type)
+}
+
+func (m *MaterialSpec) interactor(s surface.Surface) (pathtrace.Interactor, error) {
+	if m.Type == "glow" {
+		if m.Glow == nil {
+			return nil, fmt.Errorf("glow material needs a color")
+		}
+		return pathtrace.NewGlowingObject(m.Glow), nil
+	}
+
+	c, err := m.color()
+	if err != nil {
+		return nil, err
+	}
+
+	var i pathtrace.Interactor
+	switch m.Type {
+	case "lambertian":
+		i = pathtrace.NewLambertianReflector(s, c)
+	case "mirror":
+		i = pathtrace.NewMirrorReflector(s, c)
+	case "refractive":
+		i = pathtrace.NewBasicRefractiveTransmitor(s, c, m.Index)
+	case "specular":
+		i = pathtrace.NewSpecularReflector(s, c, m.Scatter)
+	case "scatter":
+		i = pathtrace.NewScatterTransmitter(c, m.Scatter)
+	case "shiny":
+		i = pathtrace.NewShineyInteractor(s, c, m.Shine, m.Scatter)
+	case "glass":
+		if !(m.Transmit+m.Reflect > 0) {
+			return nil, fmt.Errorf("glass needs transmit and reflect")
+		}
+		i = pathtrace.NewGlassInteractor(s, c, m.Index, m.Transmit, m.Reflect)
+	case "dispersive":
+		var index pathtrace.Dispersion
+		if m.Cauchy != nil {
+			index = pathtrace.Cauchy(m.Cauchy...)
+		} else {
+			index = pathtrace.Sellmeier(m.SellmeierB, m.SellmeierC)
+		}
+		if index == nil {
+			return nil, fmt.Errorf("dispersive material needs cauchy or sellmeier coefficients")
+		}
+		i = pathtrace.NewDispersiveGlassInteractor(s, c, index, m.Transmit, m.Reflect)
+	default:
+		return nil, fmt.Errorf("unknown material type %q", m.Type)
+	}
+
+	if i == nil {
+		return nil, fmt.Errorf("invalid parameters for %s material", m.Type)
+	}
+	return i, nil
+}
+
+//A spotlight in the background, which lights up every direction
+//whose dot product with the given direction is greater than spread.
+type SpotlightSpec struct {
+	Direction []float64 `json:"direction"`
+	Spread    float64   `json:"spread"`
+	Color     []float64 `json:"color"`
+}
+
+//What is seen by a ray which goes off to infinity.
+//
+//  constant   - color
+//  spotlights - color, which may be left out, and lights
+type BackgroundSpec struct {
+	Type   string           `json:"type"`
+	Color  []float64        `json:"color"`
+	Lights []*SpotlightSpec `json:"lights"`
+}
+
+func (b *BackgroundSpec) background() (color.SphericalColorFunction, error) {
+	if b == nil {
+		return nil, fmt.Errorf("scene: missing background")
+	}
+
+	switch b.Type {
+	case "constant":
+		if b.Color == nil {
+			return nil, fmt.Errorf("scene: constant background needs a color")
+		}
+		return color.ConstantColorFunction(color.PresetColor(b.Color)), nil
+	case "spotlights":
+		v := make([][]float64, 0, len(b.Lights)+1)
+		d := make([]float64, 0, len(b.Lights)+1)
+		f := make([]color.Color, 0, len(b.Lights)+1)
+		for _, l := range b.Lights {
+			if l == nil || l.Direction == nil || l.Color == nil {
+				return nil, fmt.Errorf("scene: a spotlight needs a direction and a color")
+			}
+			v = append(v, normalized(l.Direction))
+			d = append(d, l.Spread)
+			f = append(f, color.PresetColor(l.Color))
+		}
+
+		//The color everywhere else is given by a light that covers everything.
+		if b.Color != nil {
+			v = append(v, []float64{1, 0, 0})
+			d = append(d, -2)
+			f = append(f, color.PresetColor(b.Color))
+		}
+
+		if s := color.Spotlights(v, d, f); s != nil {
+			return s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("scene: invalid background %q", b.Type)
+}
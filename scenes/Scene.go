@@ -0,0 +1,446 @@
+package scenes
+
+//Scenes can be described in JSON files so that they can be written
+//without changing the program. A file looks like
+//
+// {
+//   "render":     {"width": 640, "height": 480, "depth": 10, ...},
+//   "camera":     {"type": "flat", "position": [0, 0, 3], "look": [0, 0, 0], ...},
+//   "background": {"type": "constant", "color": [0, 0, 0]},
+//   "space":      {"type": "spherical", "radius": 1, ...},
+//   "objects":    [{"surface": {...}, "material": {...}}, ...],
+//   "animation":  {"frames": 48, "camera": [...], "objects": [...]}
+// }
+//
+//The space may be left out, in which case light goes in straight lines,
+//and the animation may be left out, in which case there is one frame.
+//See the Spec types for all the fields that each part can have.
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DanielKrawisz/CurvedSpace/distributions"
+	"github.com/DanielKrawisz/CurvedSpace/hdr"
+	"github.com/DanielKrawisz/CurvedSpace/pathtrace"
+	"github.com/DanielKrawisz/CurvedSpace/surface"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+//The size of the picture and how hard to work on it.
+type RenderSpec struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	//The greatest number of times a ray may bounce.
+	Depth int `json:"depth"`
+	//The number of rays per pixel, which goes between the minimum
+	//and maximum depending on the variance of the pixel.
+	MinSamples      int     `json:"min_samples"`
+	MaxSamples      int     `json:"max_samples"`
+	MaxMeanVariance float64 `json:"max_mean_variance"`
+	//The number of goroutines.
+	Routines int `json:"routines"`
+	//The number of wavelengths that each ray tracks. If
+	//this is zero, rays are traced in red, green, and blue.
+	Wavelengths int `json:"wavelengths"`
+	//The number of bounces after which paths may be ended by
+	//Russian roulette. If this is zero, they are not.
+	Roulette int `json:"roulette"`
+	//How to choose the random numbers used to trace rays: random,
+	//stratified, halton, or sobol. The default is sobol.
+	Sampler string `json:"sampler"`
+	//The seed of the random numbers. A picture made with the
+	//same seed comes out the same every time.
+	Seed int64 `json:"seed"`
+	//The mean number of rays per pixel that adaptive sampling may
+	//spend over the whole picture, giving more to the parts where
+	//noise is easiest to see. If this is zero, each pixel is sampled
+	//on its own until its variance is low enough.
+	Budget int `json:"budget"`
+	//Adaptive sampling stops early when the noise of every part of
+	//the picture relative to its brightness is below this.
+	MaxError float64 `json:"max_error"`
+	//How each sample counts toward the pixels around it: box, tent,
+	//gaussian, mitchell, or lanczos. The default is box, with which
+	//each pixel is the mean of its own samples.
+	Filter string `json:"filter"`
+	//The radius of the filter in pixels. If this is zero,
+	//the usual radius for the filter is used.
+	FilterRadius float64 `json:"filter_radius"`
+}
+
+//Something in the scene, made of a surface and what it does to light.
+type ObjectSpec struct {
+	Surface  *SurfaceSpec  `json:"surface"`
+	Material *MaterialSpec `json:"material"`
+	//The region of a curved space which the object is in.
+	Region int `json:"region"`
+}
+
+//A scene as it is written in a file.
+type Description struct {
+	Render     RenderSpec      `json:"render"`
+	Camera     *CameraSpec     `json:"camera"`
+	Background *BackgroundSpec `json:"background"`
+	//The backgrounds of each region of a space with several regions.
+	//If these are given, the background above is not used.
+	Backgrounds []*BackgroundSpec `json:"backgrounds"`
+	Space       *SpaceSpec        `json:"space"`
+	Objects     []*ObjectSpec     `json:"objects"`
+	Animation   *AnimationSpec    `json:"animation"`
+	//The time of the frame which is built, in seconds.
+	time float64
+	//The directory that other files named in the
+	//description are found relative to.
+	dir string
+}
+
+//Returns an error if the render parameters cannot be used.
+func (r RenderSpec) Check() error {
+	if r.Width <= 0 || r.Height <= 0 {
+		return fmt.Errorf("scene: the picture must have a positive size")
+	}
+	if r.Depth <= 0 || r.MinSamples <= 0 || r.MaxSamples < r.MinSamples || r.Routines <= 0 || r.Wavelengths < 0 || r.Roulette < 0 ||
+		r.Budget < 0 || r.MaxError < 0 || r.FilterRadius < 0 {
+		return fmt.Errorf("scene: invalid render parameters")
+	}
+	if r.NewSampler() == nil {
+		return fmt.Errorf("scene: unknown sampler %q", r.Sampler)
+	}
+	if r.NewFilter() == nil {
+		return fmt.Errorf("scene: unknown filter %q", r.Filter)
+	}
+	return nil
+}
+
+//A new sampler of the kind given with the seed given, or nil if there
+//is no such kind.
+//A stratified sampler is stratified for the least number of samples.
+func (r RenderSpec) NewSampler() distributions.Sampler {
+	switch r.Sampler {
+	case "random":
+		return distributions.NewRandomSampler(r.Seed)
+	case "stratified":
+		return distributions.NewStratifiedSampler(r.MinSamples, r.Seed)
+	case "halton":
+		return distributions.NewHaltonSampler(r.Seed)
+	case "sobol", "":
+		return distributions.NewSobolSampler(r.Seed)
+	}
+	return nil
+}
+
+//The reconstruction filter of the picture, or nil if there is no such kind.
+func (r RenderSpec) NewFilter() *pathtrace.Filter {
+	if r.Filter == "" {
+		return pathtrace.NewFilter("box", r.FilterRadius)
+	}
+	return pathtrace.NewFilter(r.Filter, r.FilterRadius)
+}
+
+//Default render parameters, used for anything not given.
+var DefaultRender RenderSpec = RenderSpec{640, 480, 10, 1, 100, .0001, 1, 0, 0, "", 0, 0, 0, "", 0}
+
+//Read a scene description.
+func Read(r io.Reader) (*Description, error) {
+	d := &Description{Render: DefaultRender}
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(d); err != nil {
+		return nil, fmt.Errorf("scene: %s", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("scene: unexpected data after the description")
+	}
+
+	if err := d.Render.Check(); err != nil {
+		return nil, err
+	}
+	if d.Camera == nil {
+		return nil, fmt.Errorf("scene: no camera")
+	}
+	if d.Background == nil && d.Backgrounds == nil {
+		return nil, fmt.Errorf("scene: no background")
+	}
+	if d.Animation != nil {
+		if err := d.Animation.Check(); err != nil {
+			return nil, err
+		}
+		for _, o := range d.Animation.Objects {
+			if o.Object < 0 || o.Object >= len(d.Objects) {
+				return nil, fmt.Errorf("scene: animation of object %d, which does not exist", o.Object)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+//Load a scene description from a file. Meshes are
+//found relative to the directory of the file.
+func Load(filename string) (*Description, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	d, err := Read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	d.dir = filepath.Dir(filename)
+	return d, nil
+}
+
+//Build the scene. Each call builds a new scene, so that
+//different goroutines need not share anything.
+func (d *Description) BuildScene() (*pathtrace.Scene, error) {
+	regions := 1
+	if d.Space != nil {
+		space, err := d.Space.space()
+		if err != nil {
+			return nil, err
+		}
+		regions = space.Regions()
+	}
+
+	//All objects must have the same dimension.
+	dimension := 0
+
+	objects := make([][]*pathtrace.ExtendedObject, regions)
+	for i := range objects {
+		objects[i] = make([]*pathtrace.ExtendedObject, 0)
+	}
+
+	for i, o := range d.Objects {
+		if o == nil || o.Surface == nil || o.Material == nil {
+			return nil, fmt.Errorf("scene: object %d needs a surface and a material", i)
+		}
+		if o.Region < 0 || o.Region >= regions {
+			return nil, fmt.Errorf("scene: object %d is in region %d, which does not exist", i, o.Region)
+		}
+
+		s, err := o.Surface.surface(d.dir)
+		if err != nil {
+			return nil, fmt.Errorf("scene: object %d: %s", i, err)
+		}
+		if d.Animation != nil {
+			for _, a := range d.Animation.Objects {
+				if a.Object != i {
+					continue
+				}
+				if !d.Camera.open() {
+					if err := a.move(s, d.time); err != nil {
+						return nil, fmt.Errorf("scene: object %d: %s", i, err)
+					}
+					continue
+				}
+
+				motion, err := a.motion(s.Dimension())
+				if err != nil {
+					return nil, fmt.Errorf("scene: object %d: %s", i, err)
+				}
+				t := d.time
+				s = surface.NewMovingSurface(s, func(dt float64) ([][]float64, []float64) {
+					return motion(t + dt)
+				})
+			}
+		}
+
+		if dimension == 0 {
+			dimension = s.Dimension()
+		} else if s.Dimension() != dimension {
+			return nil, fmt.Errorf("scene: object %d has dimension %d but others have %d", i, s.Dimension(), dimension)
+		}
+
+		m, err := o.Material.interactor(s)
+		if err != nil {
+			return nil, fmt.Errorf("scene: object %d: %s", i, err)
+		}
+
+		objects[o.Region] = append(objects[o.Region], pathtrace.NewExtendedObject(s, m))
+	}
+
+	var scene *pathtrace.Scene
+	if d.Space == nil {
+		background, err := d.Background.background()
+		if err != nil {
+			return nil, err
+		}
+		scene = pathtrace.NewScene(objects[0], background)
+	} else {
+		var err error
+		if scene, err = d.Space.scene(objects, d.Background, d.Backgrounds); err != nil {
+			return nil, err
+		}
+	}
+
+	scene.SetSpectral(d.Render.Wavelengths)
+	scene.SetRussianRoulette(d.Render.Roulette)
+	scene.SetSampler(d.Render.NewSampler())
+	scene.SetFilter(d.Render.NewFilter())
+	return scene, nil
+}
+
+//The function which gives the rays that come from the camera.
+func (d *Description) BuildCamera() (pathtrace.GenerateRay, error) {
+	if d.Animation == nil {
+		return d.Camera.Build(d.Render.Width, d.Render.Height)
+	}
+
+	c, err := d.Animation.CameraAt(d.Camera, d.time)
+	if err != nil {
+		return nil, err
+	}
+	return c.Build(d.Render.Width, d.Render.Height)
+}
+
+//The number of frames of the scene, which is one if it is not animated.
+func (d *Description) Frames() int {
+	if d.Animation == nil {
+		return 1
+	}
+	return d.Animation.Frames
+}
+
+//The description of a frame of the scene, which builds the scene
+//and the camera as they are at the time of the frame. It shares
+//everything else with the description it is made from.
+func (d *Description) Frame(frame int) *Description {
+	f := *d
+	if d.Animation != nil {
+		f.time = d.Animation.Time(frame)
+	}
+	return &f
+}
+
+//Render the scene, with colors brighter than white clipped.
+//progress may be nil.
+func (d *Description) Snapshot(progress pathtrace.Progress) (*image.NRGBA, error) {
+	img, err := d.SnapshotHDR(progress)
+	if err != nil {
+		return nil, err
+	}
+	return img.ToneMap(hdr.Clamp(), 1), nil
+}
+
+//Render the scene without tone mapping it. progress may be nil.
+func (d *Description) SnapshotHDR(progress pathtrace.Progress) (*hdr.Image, error) {
+	img, _, err := d.SnapshotStatistics(progress)
+	return img, err
+}
+
+//Render the scene without tone mapping it and return the lengths
+//of the paths that were traced. progress may be nil.
+func (d *Description) SnapshotStatistics(progress pathtrace.Progress) (*hdr.Image, *pathtrace.PathStatistics, error) {
+	film, stats, err := d.SnapshotFilm(progress)
+	if err != nil {
+		return nil, nil, err
+	}
+	return film.Image(), stats, nil
+}
+
+//Render the scene onto a film, from which the picture and its features
+//can be had, and return the lengths of the paths that were traced.
+//progress may be nil.
+func (d *Description) SnapshotFilm(progress pathtrace.Progress) (*pathtrace.Film, *pathtrace.PathStatistics, error) {
+	build, camera, err := d.builders()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := d.Render
+	film, stats := pathtrace.SnapshotFilm(build, camera, r.Width, r.Height, r.Depth,
+		r.MinSamples, r.MaxSamples, r.MaxMeanVariance, r.Routines, progress)
+	return film, stats, nil
+}
+
+//Render the scene in passes into p, which must be the size of the picture
+//and may have been taken up from a checkpoint made with the same filter.
+//Each pass adds up to samples rays to each pixel, or if there is a budget,
+//shares that many for each pixel among the parts of the picture that need
+//them most. pass is called after each pass with the number of pixels or
+//rays left, and may be nil.
+func (d *Description) Progressive(p *pathtrace.Progressive, samples int,
+	pass func(p *pathtrace.Progressive, remaining int) bool) error {
+	r := d.Render
+	if p.Width != r.Width || p.Height != r.Height {
+		return fmt.Errorf("scene: the picture is %dx%d but the render is %dx%d", p.Width, p.Height, r.Width, r.Height)
+	}
+	if f, g := p.Filter(), r.NewFilter(); p.Spent() > 0 && g != nil && (f.Name != g.Name || f.Radius != g.Radius) {
+		return fmt.Errorf("scene: the picture was made with the %s filter of radius %g", f.Name, f.Radius)
+	}
+
+	build, camera, err := d.builders()
+	if err != nil {
+		return err
+	}
+
+	if r.Budget > 0 {
+		p.RenderAdaptive(build, camera, r.Depth, r.MinSamples, r.MaxSamples,
+			r.Budget*r.Width*r.Height, r.MaxError, samples, r.Routines, pass)
+	} else {
+		p.Render(build, camera, r.Depth, r.MinSamples, r.MaxSamples, r.MaxMeanVariance, samples, r.Routines, pass)
+	}
+	return nil
+}
+
+//A function which gives each goroutine its own copy of the scene,
+//all of which are built before rendering starts, and the camera.
+func (d *Description) builders() (func() *pathtrace.Scene, pathtrace.GenerateRay, error) {
+	build, err := d.buildScenes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	camera, err := d.BuildCamera()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return build, camera, nil
+}
+
+//Build a copy of the scene for each goroutine before rendering starts,
+//so that an error in building any of them is returned rather than lost,
+//and return a function which gives them out one at a time.
+func (d *Description) buildScenes() (func() *pathtrace.Scene, error) {
+	scenes := make(chan *pathtrace.Scene, d.Render.Routines)
+	for i := 0; i < d.Render.Routines; i++ {
+		scene, err := d.BuildScene()
+		if err != nil {
+			return nil, err
+		}
+		scenes <- scene
+	}
+	close(scenes)
+
+	return func() *pathtrace.Scene {
+		return <-scenes
+	}, nil
+}
+
+//Load a scene from a file and return it along with its camera.
+func LoadScene(filename string) (*pathtrace.Scene, pathtrace.GenerateRay, error) {
+	d, err := Load(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scene, err := d.BuildScene()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	camera, err := d.BuildCamera()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return scene, camera, nil
+}
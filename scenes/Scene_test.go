@@ -0,0 +1,583 @@
+package scenes
+
+import "testing"
+import "math"
+import "os"
+import "path/filepath"
+import "strings"
+import "github.com/DanielKrawisz/CurvedSpace/distributions"
+import "github.com/DanielKrawisz/CurvedSpace/pathtrace"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+var scene_err float64 = .00001
+
+//A glowing sphere in front of the camera.
+var sphereScene string = `{
+  "camera": {"type": "flat", "position": [0, 0, 0], "look": [0, 0, 1], "up": [0, 1, 0], "right": [1, 0, 0], "fov": [1, 1]},
+  "background": {"type": "constant", "color": [0.1, 0.1, 0.1]},
+  "objects": [
+    {"surface": {"type": "sphere", "center": [0, 0, 5], "radius": 1},
+     "material": {"type": "glow", "glow": [0.5, 0.7, 0.9]}}
+  ]
+}`
+
+func TestReadScene(t *testing.T) {
+  d, err := Read(strings.NewReader(sphereScene))
+  if err != nil {
+    t.Error("read scene error 1: ", err)
+    return
+  }
+
+  if d.Render != DefaultRender {
+    t.Error("read scene error 2")
+  }
+
+  scene, err := d.BuildScene()
+  if err != nil {
+    t.Error("read scene error 3: ", err)
+    return
+  }
+
+  if c := scene.TracePath([]float64{0, 0, 0}, []float64{0, 0, 1}, 4, 1./256.);
+    !test.VectorCloseEnough(c, []float64{.5, .7, .9}, scene_err) {
+    t.Error("read scene error 4: ", c)
+  }
+  if c := scene.TracePath([]float64{0, 0, 0}, []float64{0, 0, -1}, 4, 1./256.);
+    !test.VectorCloseEnough(c, []float64{.1, .1, .1}, scene_err) {
+    t.Error("read scene error 5: ", c)
+  }
+
+  camera, err := d.BuildCamera()
+  if err != nil || camera == nil {
+    t.Error("read scene error 6: ", err)
+  }
+
+  //A camera with a lens focuses on where it looks.
+  d.Camera.Aperture, d.Camera.Blades = .1, 6
+  camera, err = d.BuildCamera()
+  if err != nil || camera == nil {
+    t.Error("read scene error 10: ", err)
+  } else if pos, dir := camera(distributions.NewRandomSampler(1), 320, 240);
+    !test.VectorCloseEnough(vector.Plus(pos, dir), []float64{0, 0, 1}, .01) {
+    t.Error("read scene error 11: ", pos, dir)
+  }
+  d.Camera.Aperture, d.Camera.Blades = 0, 0
+
+  //A camera which looks through a real lens sees the sphere in the
+  //middle of the picture, and the lens stops some of its rays.
+  lens := *d.Camera
+  lens.Type, lens.Prescription, lens.Scale, lens.Film = "lens", "double_gauss", .01, []float64{.36, .24}
+  lens.Look = []float64{0, 0, 5}
+  camera, err = lens.Build(64, 48)
+  if err != nil || camera == nil {
+    t.Error("read scene error 12: ", err)
+  } else {
+    s := distributions.NewRandomSampler(1)
+    var stopped int
+    for k := 0; k < 100; k ++ {
+      pos, dir := camera(s, 32, 24)
+      if dir == nil {
+        stopped ++
+      } else if c := scene.TracePath(pos, dir, 4, 1./256.); !test.VectorCloseEnough(c, []float64{.5, .7, .9}, scene_err) {
+        t.Error("read scene error 13: ", pos, dir, c)
+      }
+    }
+    if stopped == 0 || stopped == 100 {
+      t.Error("read scene error 14: ", stopped)
+    }
+  }
+
+  //A spectral scene sees the same colors on average.
+  d.Render.Wavelengths = 3
+  scene, err = d.BuildScene()
+  if err != nil {
+    t.Error("read scene error 8: ", err)
+    return
+  }
+  sum := make([]float64, 3)
+  for i := 0; i < 20000; i ++ {
+    c := scene.TracePath([]float64{0, 0, 0}, []float64{0, 0, 1}, 4, 1./256.)
+    for j := range sum {
+      sum[j] += c[j] / 20000
+    }
+  }
+  if !test.VectorCloseEnough(sum, []float64{.5, .7, .9}, .03) {
+    t.Error("read scene error 9: ", sum)
+  }
+
+  //The scene can be built again.
+  if _, err := d.BuildScene(); err != nil {
+    t.Error("read scene error 7: ", err)
+  }
+}
+
+//A sphere and a simplex in four dimensions, which the camera sees in
+//the slice of the space through it, and which are of different sizes in
+//each slice. The slice through the origin cuts the sphere in a sphere of
+//radius 0.8.
+var fourDimensionalScene string = `{
+  "camera": {"type": "flat", "position": [0, 0, 0, 0], "look": [0, 0, 1, 0], "up": [0, 1, 0, 0], "right": [1, 0, 0, 0], "fov": [0.01, 0.01]},
+  "background": {"type": "constant", "color": [0.1, 0.1, 0.1]},
+  "objects": [
+    {"surface": {"type": "sphere", "center": [0, 0, 5, 0.6], "radius": 1},
+     "material": {"type": "glow", "glow": [0.5, 0.7, 0.9]}},
+    {"surface": {"type": "simplex", "points": [[-1, -1, 8, -1], [1, -1, 8, -1], [0, 1, 8, -1], [0, 0, 9, -1], [0, 0, 8.5, 2]]},
+     "material": {"type": "glow", "glow": [0.9, 0.2, 0.1]}}
+  ]
+}`
+
+func TestFourDimensionalScene(t *testing.T) {
+  d, err := Read(strings.NewReader(fourDimensionalScene))
+  if err != nil {
+    t.Error("four dimensional scene error 1: ", err)
+    return
+  }
+  scene, err := d.BuildScene()
+  if err != nil {
+    t.Error("four dimensional scene error 2: ", err)
+    return
+  }
+
+  sphere, simplex, background := []float64{.5, .7, .9}, []float64{.9, .2, .1}, []float64{.1, .1, .1}
+  if c := scene.TracePath([]float64{0, 0, 0, 0}, []float64{.18, 0, 1, 0}, 4, 1./256.);
+    !test.VectorCloseEnough(c, background, scene_err) {
+    t.Error("four dimensional scene error 3: ", c)
+  }
+
+  //The camera is moved through the fourth dimension, and then
+  //turned to look along it.
+  cases := []struct {
+    position []float64
+    rotations []Rotation
+    expected []float64
+  }{
+    {[]float64{0, 0, 0, 0}, nil, sphere},
+    {[]float64{0, 0, 0, -.6}, nil, simplex},
+    {[]float64{0, 0, 0, 3}, nil, background},
+    {[]float64{0, 0, 5, -3}, []Rotation{{[]int{0, 3}, math.Pi / 2}}, sphere},
+    {[]float64{0, 0, 5, -3}, []Rotation{{[]int{0, 3}, -math.Pi / 2}}, background}}
+
+  s := distributions.NewRandomSampler(1)
+  for i, c := range cases {
+    d.Camera.Position, d.Camera.Rotations = c.position, c.rotations
+    d.Camera.Look = vector.Plus(c.position, []float64{0, 0, 1, 0})
+    camera, err := d.Camera.Build(3, 3)
+    if err != nil {
+      t.Error("four dimensional scene error 4: ", i, err)
+      continue
+    }
+
+    pos, dir := camera(s, 1, 1)
+    if col := scene.TracePath(pos, dir, 4, 1./256.); !test.VectorCloseEnough(col, c.expected, scene_err) {
+      t.Error("four dimensional scene error 5: ", i, pos, dir, col)
+    }
+  }
+}
+
+func TestSpline(t *testing.T) {
+  if NewSpline(nil, nil) != nil || NewSpline([]float64{0, 1}, [][]float64{{0}}) != nil ||
+    NewSpline([]float64{0, 0}, [][]float64{{0}, {1}}) != nil || NewSpline([]float64{0, 1}, [][]float64{{0}, {1, 2}}) != nil {
+    t.Error("spline error 1")
+  }
+
+  times := []float64{0, 1, 3}
+  values := [][]float64{{0, 1}, {2, 1}, {6, 1}}
+  s := NewSpline(times, values)
+  for i := range times {
+    if v := s.At(times[i]); !test.VectorCloseEnough(v, values[i], scene_err) {
+      t.Error("spline error 2: ", i, v)
+    }
+  }
+
+  //It stays where it is at the ends, and goes straight through values on a line.
+  for _, c := range []struct{
+    t float64
+    expected []float64
+  }{{-1, []float64{0, 1}}, {4, []float64{6, 1}}, {.5, []float64{1, 1}}, {2, []float64{4, 1}}, {2.7, []float64{5.4, 1}}} {
+    if v := s.At(c.t); !test.VectorCloseEnough(v, c.expected, scene_err) {
+      t.Error("spline error 3: ", c.t, v)
+    }
+  }
+
+  //Otherwise, it goes smoothly through the keyframes.
+  s = NewSpline([]float64{0, 1, 2}, [][]float64{{0}, {1}, {0}})
+  if v := s.At(.999)[0] - s.At(1.001)[0]; !test.CloseEnough(v, 0, .00001) || !(s.At(.5)[0] > .5) {
+    t.Error("spline error 4: ", s.At(.5), s.At(.999), s.At(1.001))
+  }
+}
+
+//The sphere moves to the right while the camera backs away from it.
+var animatedScene string = strings.Replace(sphereScene, `"objects"`, `"animation": {"frames": 3, "rate": 2,
+    "camera": [{"time": 0, "position": [0, 0, 0]}, {"time": 1, "position": [0, 0, -2]}],
+    "objects": [{"object": 0, "keys": [{"time": 0, "translate": [0, 0, 0]}, {"time": 1, "translate": [3, 0, 0]}]}]},
+  "objects"`, 1)
+
+func TestAnimation(t *testing.T) {
+  d, err := Read(strings.NewReader(animatedScene))
+  if err != nil {
+    t.Error("animation error 1: ", err)
+    return
+  }
+  if d.Frames() != 3 || d.Animation.Time(1) != .5 {
+    t.Error("animation error 2: ", d.Frames(), d.Animation.Time(1))
+  }
+
+  s := distributions.NewRandomSampler(1)
+  for i, c := range []struct {
+    position, color []float64
+  }{{[]float64{0, 0, 0}, []float64{.5, .7, .9}}, {[]float64{0, 0, -1}, []float64{.1, .1, .1}},
+    {[]float64{0, 0, -2}, []float64{.1, .1, .1}}} {
+    f := d.Frame(i)
+    scene, err := f.BuildScene()
+    if err != nil {
+      t.Error("animation error 3: ", i, err)
+      continue
+    }
+    camera, err := f.BuildCamera()
+    if err != nil {
+      t.Error("animation error 4: ", i, err)
+      continue
+    }
+
+    pos, dir := camera(s, 320, 240)
+    if !test.VectorCloseEnough(pos, c.position, scene_err) {
+      t.Error("animation error 5: ", i, pos)
+    }
+    if col := scene.TracePath(pos, dir, 4, 1./256.); !test.VectorCloseEnough(col, c.color, scene_err) {
+      t.Error("animation error 6: ", i, col)
+    }
+  }
+
+  //The description it was made from is still at the first frame.
+  if camera, _ := d.BuildCamera(); camera != nil {
+    if pos, _ := camera(s, 320, 240); !test.VectorCloseEnough(pos, []float64{0, 0, 0}, scene_err) {
+      t.Error("animation error 7: ", pos)
+    }
+  }
+
+  //Without an animation, there is one frame.
+  if d, _ := Read(strings.NewReader(sphereScene)); d.Frames() != 1 {
+    t.Error("animation error 8")
+  }
+}
+
+//While the shutter is open, the sphere moves out of the middle of the
+//picture, which sees it for the first two thirds of the time.
+func TestMotionBlur(t *testing.T) {
+  d, err := Read(strings.NewReader(strings.Replace(animatedScene, `"fov"`, `"shutter": [0, 0.5], "fov"`, 1)))
+  if err != nil {
+    t.Error("motion blur error 1: ", err)
+    return
+  }
+  d.Render.Width, d.Render.Height, d.Render.MinSamples, d.Render.MaxSamples = 41, 41, 1000, 1000
+
+  img, err := d.Frame(0).SnapshotHDR(nil)
+  if err != nil {
+    t.Error("motion blur error 2: ", err)
+    return
+  }
+  if c := img.At(20, 20); !test.VectorCloseEnough(c, []float64{.1 / 3 + .5 * 2 / 3, .1 / 3 + .7 * 2 / 3, .1 / 3 + .9 * 2 / 3}, .03) {
+    t.Error("motion blur error 3: ", c)
+  }
+}
+
+func TestBadScenes(t *testing.T) {
+  bad := []string{
+    ``,
+    `{"camera": {"type": "flat"}}`,
+    `{"background": {"type": "constant", "color": [0, 0, 0]}}`,
+    `{"unknown": 1}`,
+    strings.Replace(sphereScene, `"sphere"`, `"sphear"`, 1),
+    strings.Replace(sphereScene, `"glow", "glow"`, `"glow", "absorb"`, 1),
+    strings.Replace(sphereScene, `"radius": 1`, `"radius": 1, "translate": [1, 2]`, 1),
+    strings.Replace(sphereScene, `"objects": [`, `"objects": [
+      {"surface": {"type": "sphere", "center": [0, 0], "radius": 1}, "material": {"type": "glow", "glow": [1, 1, 1]}},`, 1),
+    strings.Replace(sphereScene, `"type": "flat"`, `"type": "fisheye"`, 1),
+    strings.Replace(sphereScene, `"fov": [1, 1]`, `"fov": [1]`, 1),
+    strings.Replace(sphereScene, `"up": [0, 1, 0]`, `"up": [0, 0, 2]`, 1),
+    strings.Replace(sphereScene, `"position": [0, 0, 0]`, `"position": [0, 0, 0, 0]`, 1),
+    strings.Replace(sphereScene, `"fov"`, `"rotations": [{"plane": [0, 3], "angle": 1}], "fov"`, 1),
+    strings.Replace(sphereScene, `"fov"`, `"rotations": [{"plane": [1], "angle": 1}], "fov"`, 1),
+    strings.Replace(sphereScene, `"constant"`, `"gradient"`, 1),
+    strings.Replace(sphereScene, `"camera"`, `"render": {"wavelengths": -1}, "camera"`, 1),
+    strings.Replace(sphereScene, `"camera"`, `"render": {"roulette": -1}, "camera"`, 1),
+    strings.Replace(sphereScene, `"camera"`, `"render": {"sampler": "sobel"}, "camera"`, 1),
+    strings.Replace(sphereScene, `"camera"`, `"render": {"budget": -1}, "camera"`, 1),
+    strings.Replace(sphereScene, `"camera"`, `"render": {"filter": "blur"}, "camera"`, 1),
+    strings.Replace(sphereScene, `"camera"`, `"render": {"filter": "tent", "filter_radius": -1}, "camera"`, 1),
+    strings.Replace(sphereScene, `"fov"`, `"aperture": -0.1, "fov"`, 1),
+    strings.Replace(sphereScene, `"fov"`, `"aperture": 0.1, "focus": -1, "fov"`, 1),
+    `{"camera": {"type": "toroidal", "position": [0, 0, 0], "major": [[1, 0, 0], [0, 1, 0]], "minor": [[0, 0, 1], [0.5, 0, 0]], "aperture": 0.1, "focus": 1}}`,
+    strings.Replace(sphereScene, `"type": "flat"`, `"type": "lens", "prescription": "double_gauss", "scale": 0.01`, 1),
+    strings.Replace(sphereScene, `"type": "flat"`, `"type": "lens", "prescription": "zoom", "film": [0.36, 0.24]`, 1),
+    strings.Replace(sphereScene, `"type": "flat"`, `"type": "lens", "prescription": "double_gauss", "scale": -1, "film": [0.36, 0.24]`, 1),
+    strings.Replace(sphereScene, `"type": "flat"`, `"type": "lens", "film": [0.36, 0.24],
+      "elements": [{"radius": 1, "thickness": 0.1, "index": 1.5, "aperture": 2}, {"thickness": 1, "aperture": 1}]`, 1),
+    strings.Replace(sphereScene, `"type": "flat"`, `"type": "lens", "film": [0.36, 0.24],
+      "elements": [{"radius": 1, "thickness": 0.1, "index": 1.5, "abbe": -1, "aperture": 0.5}, {"thickness": 1, "aperture": 1}]`, 1),
+    strings.Replace(sphereScene, `"type": "flat"`, `"type": "lens", "prescription": "double_gauss", "scale": 0.01, "film": [0.36, 0.24], "focus": 0.1`, 1),
+    strings.Replace(sphereScene, `"type": "flat", "position": [0, 0, 0], "look": [0, 0, 1]`,
+      `"type": "lens", "prescription": "double_gauss", "scale": 0.01, "film": [0.36, 0.24], "position": [0, 0, 0], "look": [0, 0, 1, 0]`, 1),
+    strings.Replace(sphereScene, `"type": "flat", "position": [0, 0, 0], "look": [0, 0, 1]`,
+      `"type": "lens", "prescription": "double_gauss", "scale": 0.01, "film": [0.36, 0.24], "position": [0, 0, 0], "look": [0, 0]`, 1),
+    strings.Replace(sphereScene, `"glow", "glow"`, `"dispersive", "transmit": 1, "absorb"`, 1),
+    strings.Replace(animatedScene, `"frames": 3`, `"frames": 0`, 1),
+    strings.Replace(animatedScene, `"object": 0`, `"object": 1`, 1),
+    strings.Replace(animatedScene, `"time": 1, "position"`, `"time": 0, "position"`, 1),
+    strings.Replace(animatedScene, `"position": [0, 0, -2]`, `"position": [0, -2]`, 1),
+    strings.Replace(animatedScene, `"translate": [3, 0, 0]`, `"translate": [3, 0]`, 1),
+    strings.Replace(animatedScene, `"translate": [3, 0, 0]`, `"transform": [[1, 0], [0, 1]]`, 1),
+    strings.Replace(animatedScene, `"translate": [3, 0, 0]`, `"transform": [[1, 0], [0, 1, 0]]`, 1),
+    strings.Replace(sphereScene, `"fov"`, `"shutter": [1, 0], "fov"`, 1),
+    strings.Replace(sphereScene, `"fov"`, `"shutter": [1], "fov"`, 1),
+    strings.Replace(strings.Replace(animatedScene, `"fov"`, `"shutter": [0, 0.5], "fov"`, 1), `"translate": [3, 0, 0]`, `"translate": [3, 0]`, 1),
+    strings.Replace(sphereScene, `"objects"`, `"space": {"type": "spherical", "radius": 0}, "objects"`, 1),
+    strings.Replace(sphereScene, `"objects"`, `"space": {"type": "minkowski", "step": 0.1, "error": 0.001, "max_steps": 100}, "objects"`, 1) + "x",
+  }
+
+  for i, s := range bad {
+    d, err := Read(strings.NewReader(s))
+    if err != nil {
+      continue
+    }
+    if _, err = d.BuildScene(); err != nil {
+      continue
+    }
+    if _, err = d.BuildCamera(); err != nil {
+      continue
+    }
+    t.Error("bad scene error, case ", i)
+  }
+}
+
+//Every kind of surface, material, and background should be readable.
+func TestSceneParts(t *testing.T) {
+  d, err := Read(strings.NewReader(`{
+    "camera": {"type": "toroidal", "position": [0, 0, 0], "major": [[1, 0, 0], [0, 1, 0]], "minor": [[0, 0, 1], [0.5, 0, 0]]},
+    "background": {"type": "spotlights", "color": [0.1, 0.1, 0.1], "lights": [{"direction": [0, 0, 1], "spread": 0.9, "color": [1, 1, 1]}]},
+    "objects": [
+      {"surface": {"type": "ellipsoid", "center": [0, 0, 0], "axes": [[1, 0, 0], [0, 1, 0], [0, 0, 1]], "radii": [1, 2, 3]},
+       "material": {"type": "lambertian", "absorb": [0.5, 0.5, 0.5]}},
+      {"surface": {"type": "plane", "point": [0, 0, -5], "normal": [0, 0, 1], "outward": true},
+       "material": {"type": "mirror", "absorb": [0.5, 0.5, 0.5]}},
+      {"surface": {"type": "cylinder", "center": [0, 0, 0], "axes": [[1, 0, 0], [0, 1, 0]], "ends": [[0, 0, 1]], "radii": [1, 1, 2]},
+       "material": {"type": "refractive", "absorb": [1, 1, 1], "index": 1.5}},
+      {"surface": {"type": "torus", "center": [0, 0, 0], "axis": [0, 0, 1], "radius": 3, "minor_radius": 1},
+       "material": {"type": "specular", "glow": [1, 1, 1], "scatter": 0.1}},
+      {"surface": {"type": "bounding", "a": {"type": "sphere", "center": [0, 0, 0], "radius": 1},
+         "b": {"type": "insubstantial", "dimension": 3, "tau": 0.1}},
+       "material": {"type": "scatter", "absorb": [1, 1, 1], "scatter": 0.5}},
+      {"surface": {"type": "subtraction", "a": {"type": "cube", "center": [0, 0, 0], "radius": 2},
+         "b": {"type": "sphere", "center": [0, 0, 0], "radius": 1.5}},
+       "material": {"type": "shiny", "glow": [1, 1, 1], "absorb": [0.5, 0.5, 0.5], "absorption": 0.5, "shine": 0.2, "scatter": 0.1}},
+      {"surface": {"type": "parallelepiped", "corner": [0, 0, 0], "edges": [[1, 0, 0], [0, 1, 0], [0, 0, 1]]},
+       "material": {"type": "glass", "absorb": [1, 1, 1], "index": 1.5, "transmit": 0.9, "reflect": 0.1}},
+      {"surface": {"type": "sphere", "center": [0, 0, 0], "radius": 1},
+       "material": {"type": "dispersive", "absorb": [1, 1, 1], "cauchy": [1.5, 0.004], "transmit": 1}},
+      {"surface": {"type": "sphere", "center": [0, 0, 0], "radius": 1},
+       "material": {"type": "dispersive", "absorb": [1, 1, 1], "transmit": 0.9, "reflect": 0.1,
+         "sellmeier_b": [1.04, 0.23, 1.01], "sellmeier_c": [0.006, 0.02, 103.6]}},
+      {"surface": {"type": "simplex", "points": [[0, 0, 0], [1, 0, 0], [0, 1, 0], [0, 0, 1]]},
+       "material": {"type": "glow", "glow": [1, 1, 1]}},
+      {"surface": {"type": "convex_hull", "points": [[0, 0, 0], [1, 0, 0], [0, 1, 0], [0, 0, 1]],
+         "transform": [[2, 0, 0], [0, 2, 0], [0, 0, 2]], "translate": [5, 5, 5]},
+       "material": {"type": "glow", "glow": [1, 1, 1]}},
+      {"surface": {"type": "polyhedron", "normals": [[1, 0, 0], [-1, 0, 0], [0, 1, 0], [0, -1, 0], [0, 0, 1], [0, 0, -1]],
+         "distances": [1, 1, 1, 1, 1, 1]},
+       "material": {"type": "glow", "glow": [1, 1, 1]}}
+    ]
+  }`))
+  if err != nil {
+    t.Error("scene parts error 1: ", err)
+    return
+  }
+
+  if _, err := d.BuildScene(); err != nil {
+    t.Error("scene parts error 2: ", err)
+  }
+  if _, err := d.BuildCamera(); err != nil {
+    t.Error("scene parts error 3: ", err)
+  }
+}
+
+func TestCurvedScene(t *testing.T) {
+  d, err := Read(strings.NewReader(strings.Replace(sphereScene, `"objects"`,
+    `"space": {"type": "minkowski", "step": 0.1, "error": 0.000001, "max_steps": 10000}, "objects"`, 1)))
+  if err != nil {
+    t.Error("curved scene error 1: ", err)
+    return
+  }
+
+  scene, err := d.BuildScene()
+  if err != nil {
+    t.Error("curved scene error 2: ", err)
+    return
+  }
+
+  if c := scene.TracePath([]float64{0, 0, 0}, []float64{0, 0, 1}, 4, 1./256.);
+    !test.VectorCloseEnough(c, []float64{.5, .7, .9}, .0001) {
+    t.Error("curved scene error 3: ", c)
+  }
+
+  //A wormhole has three regions, each with its own background.
+  d, err = Read(strings.NewReader(`{
+    "camera": {"type": "flat", "position": [0, 1, 0], "look": [0, 0, 0], "up": [0, 0, 1], "right": [1, 0, 0], "fov": [1, 1]},
+    "space": {"type": "wormhole", "throat": 1, "step": 0.1, "error": 0.001, "escape": 20, "max_steps": 1000},
+    "backgrounds": [{"type": "constant", "color": [1, 0, 0]}, {"type": "constant", "color": [0, 0, 1]},
+      {"type": "constant", "color": [0, 1, 0]}],
+    "objects": [
+      {"surface": {"type": "sphere", "center": [0, 5, 0], "radius": 1}, "material": {"type": "glow", "glow": [1, 1, 1]}, "region": 1}
+    ]
+  }`))
+  if err != nil {
+    t.Error("curved scene error 4: ", err)
+    return
+  }
+  if _, err := d.BuildScene(); err != nil {
+    t.Error("curved scene error 5: ", err)
+  }
+}
+
+func TestLoadScene(t *testing.T) {
+  examples, _ := filepath.Glob(filepath.Join("examples", "*.json"))
+  if len(examples) == 0 {
+    t.Error("load scene error 1: no examples")
+  }
+  for _, e := range examples {
+    scene, camera, err := LoadScene(e)
+    if err != nil || scene == nil || camera == nil {
+      t.Error("load scene error 1: ", e, err)
+    }
+  }
+
+  if _, _, err := LoadScene(filepath.Join("examples", "missing.json")); err == nil {
+    t.Error("load scene error 2")
+  }
+
+  //Meshes are found next to the scene file.
+  dir, err := os.MkdirTemp("", "scenes")
+  if err != nil {
+    t.Error("load scene error 3: ", err)
+    return
+  }
+  defer os.RemoveAll(dir)
+
+  obj := "v 0 0 0\nv 1 0 0\nv 0 1 0\nv 0 0 1\nf 1 3 2\nf 1 2 4\nf 1 4 3\nf 2 3 4\n"
+  if err := os.WriteFile(filepath.Join(dir, "tetrahedron.obj"), []byte(obj), 0644); err != nil {
+    t.Error("load scene error 4: ", err)
+    return
+  }
+
+  meshScene := strings.Replace(sphereScene, `{"type": "sphere", "center": [0, 0, 5], "radius": 1}`,
+    `{"type": "mesh", "file": "tetrahedron.obj", "translate": [-0.1, -0.1, 5]}`, 1)
+  if err := os.WriteFile(filepath.Join(dir, "scene.json"), []byte(meshScene), 0644); err != nil {
+    t.Error("load scene error 5: ", err)
+    return
+  }
+
+  scene, _, err := LoadScene(filepath.Join(dir, "scene.json"))
+  if err != nil {
+    t.Error("load scene error 6: ", err)
+    return
+  }
+
+  if c := scene.TracePath([]float64{0, 0, 0}, []float64{0, 0, 1}, 4, 1./256.);
+    !test.VectorCloseEnough(c, []float64{.5, .7, .9}, scene_err) {
+    t.Error("load scene error 7: ", c)
+  }
+}
+
+//Each goroutine is given a copy of the scene of its own, and they are
+//all built before rendering starts.
+func TestBuildScenes(t *testing.T) {
+  dir, err := os.MkdirTemp("", "scenes")
+  if err != nil {
+    t.Error("build scenes error 1: ", err)
+    return
+  }
+  defer os.RemoveAll(dir)
+
+  mesh := filepath.Join(dir, "tetrahedron.obj")
+  obj := "v 0 0 0\nv 1 0 0\nv 0 1 0\nv 0 0 1\nf 1 3 2\nf 1 2 4\nf 1 4 3\nf 2 3 4\n"
+  if err := os.WriteFile(mesh, []byte(obj), 0644); err != nil {
+    t.Error("build scenes error 2: ", err)
+    return
+  }
+
+  d, err := Read(strings.NewReader(strings.Replace(sphereScene, `{"type": "sphere", "center": [0, 0, 5], "radius": 1}`,
+    `{"type": "mesh", "file": "` + filepath.ToSlash(mesh) + `"}`, 1)))
+  if err != nil {
+    t.Error("build scenes error 3: ", err)
+    return
+  }
+  d.Render.Routines = 3
+
+  build, err := d.buildScenes()
+  if err != nil {
+    t.Error("build scenes error 4: ", err)
+    return
+  }
+
+  built := make(map[*pathtrace.Scene]bool)
+  for i := 0; i < d.Render.Routines; i ++ {
+    scene := build()
+    if scene == nil || built[scene] {
+      t.Error("build scenes error 5: ", i)
+    }
+    built[scene] = true
+  }
+
+  //Without the mesh, the scenes cannot be built.
+  os.Remove(mesh)
+  if _, err := d.buildScenes(); err == nil {
+    t.Error("build scenes error 6")
+  }
+  if _, err := d.Snapshot(nil); err == nil {
+    t.Error("build scenes error 7")
+  }
+}
+
+func TestProgressiveScene(t *testing.T) {
+  d, err := Read(strings.NewReader(sphereScene))
+  if err != nil {
+    t.Error("progressive scene error 1: ", err)
+    return
+  }
+  d.Render.Width, d.Render.Height, d.Render.MaxSamples = 5, 5, 4
+
+  if d.Progressive(pathtrace.NewProgressive(4, 5), 1, nil) == nil {
+    t.Error("progressive scene error 2")
+  }
+
+  p := pathtrace.NewProgressive(5, 5)
+  if err := d.Progressive(p, 2, nil); err != nil {
+    t.Error("progressive scene error 3: ", err)
+    return
+  }
+  //The corner only sees the background, so it is done as soon as it
+  //has more than the least number of samples.
+  if p.Passes != 3 || p.Samples(0, 0) != 2 {
+    t.Error("progressive scene error 4: ", p.Passes, p.Samples(0, 0))
+  }
+  if c := p.Image().At(0, 0); !test.VectorCloseEnough(c, []float64{.1, .1, .1}, scene_err) {
+    t.Error("progressive scene error 5: ", c)
+  }
+
+  //With a budget, no more rays than it allows are traced.
+  d.Render.Budget = 3
+  p = pathtrace.NewProgressive(5, 5)
+  if err := d.Progressive(p, 2, nil); err != nil || p.Spent() > 3 * 25 || p.Samples(0, 0) != 2 {
+    t.Error("progressive scene error 6: ", err, p.Spent(), p.Samples(0, 0))
+  }
+
+  //A picture cannot be taken up with another filter.
+  d.Render.Filter = "gaussian"
+  if err := d.Progressive(p, 2, nil); err == nil {
+    t.Error("progressive scene error 7")
+  }
+  p = pathtrace.NewProgressive(5, 5)
+  if err := d.Progressive(p, 2, nil); err != nil || p.Filter().Name != "gaussian" {
+    t.Error("progressive scene error 8: ", err)
+  }
+}
@@ -0,0 +1,112 @@
+package scenes
+
+import (
+	"fmt"
+	"github.com/DanielKrawisz/CurvedSpace/color"
+	"github.com/DanielKrawisz/CurvedSpace/geometry"
+	"github.com/DanielKrawisz/CurvedSpace/pathtrace"
+	"math"
+)
+
+//A curved space in which light follows geodesics.
+//
+//  minkowski     - flat space
+//  spherical     - radius
+//  hyperbolic    - radius
+//  schwarzschild - mass
+//  kerr          - mass, spin
+//  wormhole      - throat
+//
+//The other fields are given to pathtrace.NewCurvedScene. If escape is
+//left out, it is infinite. In a space with several regions, objects
+//may be put in any region, and if the scene has backgrounds, each
+//region uses its own.
+type SpaceSpec struct {
+	Type     string  `json:"type"`
+	Radius   float64 `json:"radius"`
+	Mass     float64 `json:"mass"`
+	Spin     float64 `json:"spin"`
+	Throat   float64 `json:"throat"`
+	Region   int     `json:"region"`
+	Step     float64 `json:"step"`
+	Error    float64 `json:"error"`
+	Escape   float64 `json:"escape"`
+	MaxSteps int     `json:"max_steps"`
+}
+
+func (s *SpaceSpec) space() (geometry.Space, error) {
+	switch s.Type {
+	case "minkowski":
+		return geometry.NewMinkowskiSpace(), nil
+	case "spherical":
+		if s.Radius > 0 {
+			return geometry.NewSphericalSpace(s.Radius), nil
+		}
+	case "hyperbolic":
+		if s.Radius > 0 {
+			return geometry.NewHyperbolicSpace(s.Radius), nil
+		}
+	case "schwarzschild":
+		//Checked for nil before it is turned into an interface.
+		if b := geometry.NewSchwarzschildSpace(s.Mass); b != nil {
+			return b, nil
+		}
+	case "kerr":
+		if b := geometry.NewKerrSpace(s.Mass, s.Spin); b != nil {
+			return b, nil
+		}
+	case "wormhole":
+		if w := geometry.NewEllisWormhole(s.Throat); w != nil {
+			return w, nil
+		}
+	default:
+		return nil, fmt.Errorf("scene: unknown space type %q", s.Type)
+	}
+
+	return nil, fmt.Errorf("scene: invalid parameters for %s space", s.Type)
+}
+
+func (s *SpaceSpec) scene(objects [][]*pathtrace.ExtendedObject,
+	background *BackgroundSpec, backgrounds []*BackgroundSpec) (*pathtrace.Scene, error) {
+	space, err := s.space()
+	if err != nil {
+		return nil, err
+	}
+
+	escape := s.Escape
+	if escape == 0 {
+		escape = math.Inf(1)
+	}
+
+	var scene *pathtrace.Scene
+	if backgrounds == nil {
+		bg, err := background.background()
+		if err != nil {
+			return nil, err
+		}
+
+		all := make([]*pathtrace.ExtendedObject, 0)
+		for i, o := range objects {
+			if i != s.Region && len(o) > 0 {
+				return nil, fmt.Errorf("scene: objects are in several regions but there is only one background")
+			}
+			all = append(all, o...)
+		}
+
+		scene = pathtrace.NewCurvedScene(all, bg, space, s.Region, s.Step, s.Error, escape, s.MaxSteps)
+	} else {
+		bgs := make([]color.SphericalColorFunction, len(backgrounds))
+		for i, b := range backgrounds {
+			if bgs[i], err = b.background(); err != nil {
+				return nil, err
+			}
+		}
+
+		scene = pathtrace.NewRegionalScene(objects, bgs, space, s.Region, s.Step, s.Error, escape, s.MaxSteps)
+	}
+
+	if scene == nil {
+		return nil, fmt.Errorf("scene: invalid parameters for curved scene")
+	}
+	return scene, nil
+}
@@ -0,0 +1,217 @@
+package scenes
+
+import (
+	"fmt"
+	"github.com/DanielKrawisz/CurvedSpace/surface"
+	"github.com/DanielKrawisz/CurvedSpace/surface/booleans"
+	"github.com/DanielKrawisz/CurvedSpace/surface/complexes"
+	"github.com/DanielKrawisz/CurvedSpace/surface/meshes"
+	"github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+	"path/filepath"
+	"strings"
+)
+
+//A surface. The fields which are used depend on the type.
+//
+//  sphere          - center, radius
+//  ellipsoid       - center, axes, radii
+//  plane           - point, normal, outward
+//  cylinder        - center, axes, ends, radii
+//  torus           - center, axis, radius, minor_radius
+//  insubstantial   - dimension, tau
+//  addition, subtraction, intersection, bounding, open_bounding - a, b
+//  simplex         - points
+//  parallelepiped  - corner, edges, outward
+//  convex_hull     - points
+//  polyhedron      - normals, distances
+//  mesh            - file, which may be .obj or .ply
+//
+//The Platonic and Archimedean solids in complexes may also be
+//given by name, such as dodecahedron or truncated_icosahedron,
+//with a center and a radius.
+//
+//Any surface may also be given a transform, which is a matrix used
+//to shift its coordinates, and then a vector to translate it by.
+type SurfaceSpec struct {
+	Type   string    `json:"type"`
+	Center []float64 `json:"center"`
+	Radius float64   `json:"radius"`
+	Point  []float64 `json:"point"`
+	Normal []float64 `json:"normal"`
+	//Whether the inside of a plane or parallelepiped
+	//is the side that the normal points away from.
+	Outward     bool         `json:"outward"`
+	Axes        [][]float64  `json:"axes"`
+	Ends        [][]float64  `json:"ends"`
+	Radii       []float64    `json:"radii"`
+	Axis        []float64    `json:"axis"`
+	MinorRadius float64      `json:"minor_radius"`
+	Dimension   int          `json:"dimension"`
+	Tau         float64      `json:"tau"`
+	A           *SurfaceSpec `json:"a"`
+	B           *SurfaceSpec `json:"b"`
+	Points      [][]float64  `json:"points"`
+	Corner      []float64    `json:"corner"`
+	Edges       [][]float64  `json:"edges"`
+	Normals     [][]float64  `json:"normals"`
+	Distances   []float64    `json:"distances"`
+	File        string       `json:"file"`
+	Transform   [][]float64  `json:"transform"`
+	Translate   []float64    `json:"translate"`
+}
+
+//The solids which are given by a center and a radius.
+var solids map[string]func([]float64, float64) surface.Surface = map[string]func([]float64, float64) surface.Surface{
+	"tetrahedron":           complexes.NewTetrahedron,
+	"cube":                  complexes.NewCube,
+	"octahedron":            complexes.NewOctahedron,
+	"dodecahedron":          complexes.NewDodecahedron,
+	"icosahedron":           complexes.NewIcosahedron,
+	"cuboctahedron":         complexes.NewCuboctahedron,
+	"truncated_tetrahedron": complexes.NewTruncatedTetrahedron,
+	"truncated_cube":        complexes.NewTruncatedCube,
+	"truncated_octahedron":  complexes.NewTruncatedOctahedron,
+	"rhombicuboctahedron":   complexes.NewRhombicuboctahedron,
+	"icosidodecahedron":     complexes.NewIcosidodecahedron,
+	"truncated_icosahedron": complexes.NewTruncatedIcosahedron}
+
+//Build a surface. dir is the directory in which to look for meshes.
+func (s *SurfaceSpec) surface(dir string) (surface.Surface, error) {
+	if s == nil {
+		return nil, fmt.Errorf("missing surface")
+	}
+
+	//Some constructors keep or change the vectors they are given,
+	//so they are given copies in case the scene is built again.
+	s = s.copy()
+
+	var surf surface.Surface
+	switch s.Type {
+	case "sphere":
+		surf = polynomialsurfaces.NewSphere(s.Center, s.Radius)
+	case "ellipsoid":
+		surf = polynomialsurfaces.NewEllipsoid(s.Center, s.Axes, s.Radii)
+	case "plane":
+		surf = polynomialsurfaces.NewPlaneByPointAndNormal(s.Point, s.Normal, s.Outward)
+	case "cylinder":
+		surf = polynomialsurfaces.NewCylinder(s.Center, s.Axes, s.Ends, s.Radii)
+	case "torus":
+		surf = polynomialsurfaces.NewTorus(s.Center, s.Axis, s.Radius, s.MinorRadius)
+	case "insubstantial":
+		if s.Dimension > 0 && s.Tau > 0 {
+			surf = surface.NewInsubstantialSurface(s.Dimension, s.Tau)
+		}
+	case "addition", "subtraction", "intersection", "bounding", "open_bounding":
+		a, err := s.A.surface(dir)
+		if err != nil {
+			return nil, err
+		}
+		b, err := s.B.surface(dir)
+		if err != nil {
+			return nil, err
+		}
+		switch s.Type {
+		case "addition":
+			surf = booleans.NewAddition(a, b)
+		case "subtraction":
+			surf = booleans.NewSubtraction(a, b)
+		case "intersection":
+			surf = booleans.NewIntersection(a, b)
+		case "bounding":
+			surf = booleans.NewBounding(a, b)
+		default:
+			surf = booleans.NewOpenBounding(a, b)
+		}
+	case "simplex":
+		surf = complexes.NewSimplex(s.Points)
+	case "parallelepiped":
+		surf = complexes.NewParallelpipedByCornerAndEdges(s.Corner, s.Edges, s.Outward)
+	case "convex_hull":
+		surf = complexes.NewConvexHull(s.Points)
+	case "polyhedron":
+		surf = complexes.NewConvexPolyhedron(s.Normals, s.Distances)
+	case "mesh":
+		var err error
+		if surf, err = loadMesh(filepath.Join(dir, s.File)); err != nil {
+			return nil, err
+		}
+	default:
+		if f, ok := solids[s.Type]; ok {
+			surf = f(s.Center, s.Radius)
+		} else {
+			return nil, fmt.Errorf("unknown surface type %q", s.Type)
+		}
+	}
+
+	if surf == nil {
+		return nil, fmt.Errorf("invalid parameters for %s", s.Type)
+	}
+
+	if s.Transform != nil {
+		if len(s.Transform) != surf.Dimension() {
+			return nil, fmt.Errorf("transform of %s has the wrong size", s.Type)
+		}
+		for _, row := range s.Transform {
+			if len(row) != surf.Dimension() {
+				return nil, fmt.Errorf("transform of %s has the wrong size", s.Type)
+			}
+		}
+		surf.CoordinateShift(s.Transform)
+	}
+
+	if s.Translate != nil {
+		if len(s.Translate) != surf.Dimension() {
+			return nil, fmt.Errorf("translation of %s has the wrong size", s.Type)
+		}
+		surf.Translate(s.Translate)
+	}
+
+	return surf, nil
+}
+
+func (s *SurfaceSpec) copy() *SurfaceSpec {
+	c := *s
+	c.Center = copyVector(s.Center)
+	c.Point = copyVector(s.Point)
+	c.Normal = copyVector(s.Normal)
+	c.Axes = copyVectors(s.Axes)
+	c.Ends = copyVectors(s.Ends)
+	c.Radii = copyVector(s.Radii)
+	c.Axis = copyVector(s.Axis)
+	c.Points = copyVectors(s.Points)
+	c.Corner = copyVector(s.Corner)
+	c.Edges = copyVectors(s.Edges)
+	c.Normals = copyVectors(s.Normals)
+	c.Distances = copyVector(s.Distances)
+	c.Transform = copyVectors(s.Transform)
+	c.Translate = copyVector(s.Translate)
+	return &c
+}
+
+func copyVector(v []float64) []float64 {
+	if v == nil {
+		return nil
+	}
+	return append([]float64{}, v...)
+}
+
+func copyVectors(v [][]float64) [][]float64 {
+	if v == nil {
+		return nil
+	}
+	c := make([][]float64, len(v))
+	for i := range v {
+		c[i] = copyVector(v[i])
+	}
+	return c
+}
+
+func loadMesh(filename string) (surface.Surface, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".obj":
+		return meshes.LoadOBJ(filename)
+	case ".ply":
+		return meshes.LoadPLY(filename)
+	}
+	return nil, fmt.Errorf("unknown mesh format %s", filename)
+}
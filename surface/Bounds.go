@@ -0,0 +1,178 @@
+package surface
+
+import "strings"
+import "fmt"
+import "math"
+import "math/rand"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+// Surfaces can optionally give a box that contains them, which
+// allows a scene to skip over them when a ray is not nearby.
+type Bounded interface {
+	// The lower and upper corners of an axis-aligned box which contains
+	// every point x for which F(x) >= 0. Some coordinates may be infinite.
+	BoundingBox() (min, max []float64)
+}
+
+// A box which contains every point x for which F(x) >= 0. If the
+// surface is not Bounded, the box is all of space.
+func BoundingBox(s Surface) (min, max []float64) {
+	if b, ok := s.(Bounded); ok {
+		return b.BoundingBox()
+	}
+
+	return InfiniteBox(s.Dimension())
+}
+
+// A box which contains all of space.
+func InfiniteBox(dim int) (min, max []float64) {
+	min = make([]float64, dim)
+	max = make([]float64, dim)
+	for i := 0; i < dim; i++ {
+		min[i] = math.Inf(-1)
+		max[i] = math.Inf(1)
+	}
+	return
+}
+
+// Whether a box is finite in every direction.
+func FiniteBox(min, max []float64) bool {
+	for i := 0; i < len(min); i++ {
+		if math.IsInf(min[i], 0) || math.IsInf(max[i], 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// The intersection of two boxes.
+func BoxIntersection(amin, amax, bmin, bmax []float64) (min, max []float64) {
+	min = make([]float64, len(amin))
+	max = make([]float64, len(amin))
+	for i := 0; i < len(amin); i++ {
+		min[i] = math.Max(amin[i], bmin[i])
+		max[i] = math.Min(amax[i], bmax[i])
+	}
+	return
+}
+
+// The smallest box which contains two boxes.
+func BoxUnion(amin, amax, bmin, bmax []float64) (min, max []float64) {
+	min = make([]float64, len(amin))
+	max = make([]float64, len(amin))
+	for i := 0; i < len(amin); i++ {
+		min[i] = math.Min(amin[i], bmin[i])
+		max[i] = math.Max(amax[i], bmax[i])
+	}
+	return
+}
+
+// The smallest box which contains a set of points.
+func PointsBox(p [][]float64) (min, max []float64) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+
+	min = make([]float64, len(p[0]))
+	max = make([]float64, len(p[0]))
+	copy(min, p[0])
+	copy(max, p[0])
+	for _, x := range p[1:] {
+		for i := 0; i < len(min); i++ {
+			min[i] = math.Min(min[i], x[i])
+			max[i] = math.Max(max[i], x[i])
+		}
+	}
+	return
+}
+
+// A surface which is given a bounding box explicitly, for surfaces
+// that are bounded but which cannot work out their own bounds.
+type boundedSurface struct {
+	Surface
+	min, max []float64
+}
+
+func (s *boundedSurface) BoundingBox() (min, max []float64) {
+	min = make([]float64, len(s.min))
+	max = make([]float64, len(s.max))
+	copy(min, s.min)
+	copy(max, s.max)
+	return
+}
+
+func (s *boundedSurface) Components() []Surface {
+	return []Surface{s.Surface}
+}
+
+func (s *boundedSurface) RandomIntersection(r *rand.Rand, x, v []float64) []float64 {
+	return RandomIntersection(r, s.Surface, x, v)
+}
+
+func (s *boundedSurface) Translate(x []float64) Surface {
+	s.Surface.Translate(x)
+	for i := 0; i < len(s.min); i++ {
+		s.min[i] += x[i]
+		s.max[i] += x[i]
+	}
+	return s
+}
+
+// After a coordinate shift by m, the surface contains the points x for which
+// the transpose of m times x was inside it before, so the new box contains the
+// corners of the old box transformed by the inverse of the transpose of m.
+func (s *boundedSurface) CoordinateShift(m [][]float64) Surface {
+	s.Surface.CoordinateShift(m)
+
+	//The inverse of the transpose is the transpose of the inverse.
+	//vector.Transpose is not used because it changes m.
+	inv := vector.Inverse(m)
+	if inv == nil || !FiniteBox(s.min, s.max) {
+		s.min, s.max = InfiniteBox(len(s.min))
+		return s
+	}
+
+	dim := len(s.min)
+	corners := make([][]float64, 1<<uint(dim))
+	for k := range corners {
+		c := make([]float64, dim)
+		for i := 0; i < dim; i++ {
+			if k&(1<<uint(i)) == 0 {
+				c[i] = s.min[i]
+			} else {
+				c[i] = s.max[i]
+			}
+		}
+		corners[k] = make([]float64, dim)
+		for i := 0; i < dim; i++ {
+			for j := 0; j < dim; j++ {
+				corners[k][i] += inv[j][i] * c[j]
+			}
+		}
+	}
+
+	s.min, s.max = PointsBox(corners)
+	return s
+}
+
+func (s *boundedSurface) String() string {
+	return strings.Join([]string{"bounded{", s.Surface.String(), ", ", fmt.Sprint(s.min), ", ", fmt.Sprint(s.max), "}"}, "")
+}
+
+// Give a bounding box to a surface. The box must contain
+// every point x for which F(x) >= 0.
+// May return nil.
+func NewBoundedSurface(s Surface, min, max []float64) Surface {
+	if s == nil || min == nil || max == nil {
+		return nil
+	}
+	if len(min) != s.Dimension() || len(max) != s.Dimension() {
+		return nil
+	}
+
+	bmin := make([]float64, len(min))
+	bmax := make([]float64, len(max))
+	copy(bmin, min)
+	copy(bmax, max)
+	return &boundedSurface{s, bmin, bmax}
+}
@@ -8,6 +8,24 @@ import "github.com/DanielKrawisz/CurvedSpace/vector"
 
 var insubstantialRand func() float64 = rand.Float64
 
+//Surfaces whose intersections are random, such as mist, can take their
+//random numbers from a given source, so that a picture can be made
+//again exactly.
+type Stochastic interface {
+	//The same as Intersection, but with random numbers from r.
+	RandomIntersection(r *rand.Rand, x, v []float64) []float64
+}
+
+//The intersections of a line with s, with random numbers from r if s is
+//Stochastic. If r is nil, this is the same as s.Intersection.
+func RandomIntersection(r *rand.Rand, s Surface, x, v []float64) []float64 {
+	if st, ok := s.(Stochastic); ok && r != nil {
+		return st.RandomIntersection(r, x, v)
+	}
+
+	return s.Intersection(x, v)
+}
+
 var log2 float64 = math.Log(2)
 
 //The insubstantial "surface" is basically a mist that fills up all of space.
@@ -26,10 +44,19 @@ func (i *insubstantial) F(x []float64) float64 {
 	return 1
 }
 
-func (i *insubstantial) Intersection(x, v []float64) []float64 {
+//The distance to the intersection, given a random number u in (0, 1].
+func (i *insubstantial) intersection(u float64, v []float64) []float64 {
 	d := vector.Length(v)
 
-	return []float64{math.Log(1./insubstantialRand()) * i.tau / (log2 * d)}
+	return []float64{math.Log(1./u) * i.tau / (log2 * d)}
+}
+
+func (i *insubstantial) Intersection(x, v []float64) []float64 {
+	return i.intersection(insubstantialRand(), v)
+}
+
+func (i *insubstantial) RandomIntersection(r *rand.Rand, x, v []float64) []float64 {
+	return i.intersection(r.Float64(), v)
 }
 
 func (i *insubstantial) Translate(x []float64) Surface {
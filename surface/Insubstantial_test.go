@@ -1,6 +1,7 @@
 package surface
 
 import "testing"
+import "math"
 import "math/rand"
 import "github.com/DanielKrawisz/CurvedSpace/test"
 
@@ -29,3 +30,35 @@ func TestInsubstantial(t *testing.T) {
 
   insubstantialRand = rand.Float64
 }
+
+func TestRandomIntersection(t *testing.T) {
+  insub := NewInsubstantialSurface(1, 1.05)
+  x, v := []float64{0}, []float64{1}
+
+  a, b := rand.New(rand.NewSource(3)), rand.New(rand.NewSource(3))
+  for i := 0; i < 10; i ++ {
+    u := rand.New(rand.NewSource(3))
+    for j := 0; j < i; j ++ {
+      u.Float64()
+    }
+    expected := math.Log(1. / u.Float64()) * 1.05 / math.Log(2)
+
+    got := RandomIntersection(a, insub, x, v)
+    if len(got) != 1 || !test.CloseEnough(got[0], expected, .000001) {
+      t.Error("random intersection error 1: ", i, got, expected)
+    }
+
+    //The same source gives the same intersection.
+    if again := RandomIntersection(b, insub, x, v); again[0] != got[0] {
+      t.Error("random intersection error 2: ", i, again, got)
+    }
+  }
+
+  //Without a source, the intersection is the ordinary one.
+  insubstantialRand = mockInsubstantialRand
+  mockInsubstantialValue = .5
+  if got := RandomIntersection(nil, insub, x, v); !test.CloseEnough(got[0], 1.05, .000001) {
+    t.Error("random intersection error 3: ", got)
+  }
+  insubstantialRand = rand.Float64
+}
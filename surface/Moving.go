@@ -0,0 +1,142 @@
+package surface
+
+import "strings"
+import "math/rand"
+
+// Surfaces which move can be put where they are at a given time.
+type Moving interface {
+	SetTime(t float64)
+}
+
+// Surfaces which are made of other surfaces can give them.
+type Composite interface {
+	Components() []Surface
+}
+
+// The moving surfaces which a surface is or is made of, however
+// deeply they are inside other surfaces.
+func MovingParts(s Surface) []Moving {
+	var moving []Moving
+	if m, ok := s.(Moving); ok {
+		moving = append(moving, m)
+	}
+	if c, ok := s.(Composite); ok {
+		for _, part := range c.Components() {
+			moving = append(moving, MovingParts(part)...)
+		}
+	}
+	return moving
+}
+
+// Where a surface is at a time. Its coordinates are shifted by m, if m is
+// not nil, as by CoordinateShift, and then it is translated by x, if x is
+// not nil, as by Translate. m must be square and both must have the
+// dimension of the surface.
+type Motion func(t float64) (m [][]float64, x []float64)
+
+// A surface which moves. Rather than the surface being moved, the points
+// and lines it is given are moved back to where the surface was before it
+// was moved, so the surface is only made once.
+type movingSurface struct {
+	Surface
+	motion Motion
+	// Where the surface is at the time that was set last.
+	m [][]float64
+	x []float64
+}
+
+func (s *movingSurface) SetTime(t float64) {
+	s.m, s.x = s.motion(t)
+}
+
+// A point in the coordinates of the surface before it was moved, which
+// is the transpose of m times the point minus x.
+func (s *movingSurface) point(y []float64) []float64 {
+	p := make([]float64, len(y))
+	copy(p, y)
+	if s.x != nil {
+		for i := range p {
+			p[i] -= s.x[i]
+		}
+	}
+	return s.direction(p)
+}
+
+// A direction in the coordinates of the surface before it was moved.
+func (s *movingSurface) direction(v []float64) []float64 {
+	if s.m == nil {
+		return v
+	}
+
+	d := make([]float64, len(v))
+	for i := range d {
+		for j := range v {
+			d[i] += s.m[j][i] * v[j]
+		}
+	}
+	return d
+}
+
+func (s *movingSurface) Components() []Surface {
+	return []Surface{s.Surface}
+}
+
+func (s *movingSurface) F(y []float64) float64 {
+	return s.Surface.F(s.point(y))
+}
+
+// The parameters of the line are the same in either coordinates.
+func (s *movingSurface) Intersection(y, v []float64) []float64 {
+	return s.Surface.Intersection(s.point(y), s.direction(v))
+}
+
+func (s *movingSurface) RandomIntersection(r *rand.Rand, y, v []float64) []float64 {
+	return RandomIntersection(r, s.Surface, s.point(y), s.direction(v))
+}
+
+// The gradient of F(m^T (y - x)) is m times the gradient of F.
+func (s *movingSurface) Gradient(y []float64) []float64 {
+	g := s.Surface.Gradient(s.point(y))
+	if s.m == nil {
+		return g
+	}
+
+	grad := make([]float64, len(g))
+	for i := range grad {
+		for j := range g {
+			grad[i] += s.m[i][j] * g[j]
+		}
+	}
+	return grad
+}
+
+// The surface is translated before it is moved.
+func (s *movingSurface) Translate(x []float64) Surface {
+	s.Surface.Translate(x)
+	return s
+}
+
+// The coordinates of the surface are shifted before it is moved.
+func (s *movingSurface) CoordinateShift(m [][]float64) Surface {
+	s.Surface.CoordinateShift(m)
+	return s
+}
+
+func (s *movingSurface) String() string {
+	return strings.Join([]string{"moving{", s.Surface.String(), "}"}, "")
+}
+
+// A surface which is moved by motion to where it is at the time that was
+// set last, which is zero at first. Since it could be anywhere, it has no
+// bounding box, and since it has only one time, a moving surface must not
+// be shared among goroutines.
+// May return nil.
+func NewMovingSurface(s Surface, motion Motion) Surface {
+	if s == nil || motion == nil {
+		return nil
+	}
+
+	ms := &movingSurface{Surface: s, motion: motion}
+	ms.SetTime(0)
+	return ms
+}
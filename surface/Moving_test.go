@@ -0,0 +1,127 @@
+package surface
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+
+//The ellipsoid x^2 + 4 y^2 + 4 z^2 <= 1.
+type mockEllipsoid struct {
+  dim int
+}
+
+var mockEllipsoidWeights []float64 = []float64{1, 4, 4}
+
+func (b *mockEllipsoid) Dimension() int {
+  return b.dim
+}
+
+func (b *mockEllipsoid) F(x []float64) float64 {
+  f := 1.
+  for i, c := range x {
+    f -= mockEllipsoidWeights[i] * c * c
+  }
+  return f
+}
+
+func (b *mockEllipsoid) Intersection(x, v []float64) []float64 {
+  var a, p, c float64
+  for i, w := range mockEllipsoidWeights {
+    a += w * v[i] * v[i]
+    p += w * x[i] * v[i]
+    c += w * x[i] * x[i]
+  }
+  d := p * p - a * (c - 1)
+  if d < 0 {
+    return []float64{}
+  }
+  return []float64{(-p - math.Sqrt(d)) / a, (-p + math.Sqrt(d)) / a}
+}
+
+func (b *mockEllipsoid) Gradient(x []float64) []float64 {
+  g := make([]float64, len(x))
+  for i := range x {
+    g[i] = -2 * mockEllipsoidWeights[i] * x[i]
+  }
+  return g
+}
+
+func (b *mockEllipsoid) CoordinateShift(m [][]float64) Surface {
+  return b
+}
+
+func (b *mockEllipsoid) Translate(x []float64) Surface {
+  return b
+}
+
+func (b *mockEllipsoid) String() string {
+  return "ball"
+}
+
+func TestMovingSurface(t *testing.T) {
+  if NewMovingSurface(nil, func(t float64) ([][]float64, []float64) { return nil, nil }) != nil ||
+    NewMovingSurface(&mockEllipsoid{3}, nil) != nil {
+    t.Error("moving surface error 1")
+  }
+
+  //The ball goes along the x axis and turns a quarter of the
+  //way around the z axis each second.
+  s := NewMovingSurface(&mockEllipsoid{3}, func(t float64) ([][]float64, []float64) {
+    c, s := math.Cos(t * math.Pi / 2), math.Sin(t * math.Pi / 2)
+    return [][]float64{{c, -s, 0}, {s, c, 0}, {0, 0, 1}}, []float64{t, 0, 0}
+  })
+  m, ok := s.(Moving)
+  if !ok {
+    t.Error("moving surface error 2")
+    return
+  }
+
+  //After a whole number of half turns, the long axis of
+  //the ellipsoid is along the x axis.
+  for _, time := range []float64{0, 2} {
+    m.SetTime(time)
+    if s.F([]float64{time, 0, 0}) != 1 || !(s.F([]float64{time, .6, 0}) < 0) {
+      t.Error("moving surface error 3: ", time)
+    }
+
+    u := s.Intersection([]float64{time - 5, 0, 0}, []float64{2, 0, 0})
+    if len(u) != 2 || !test.CloseEnough(u[0], 2, .000001) || !test.CloseEnough(u[1], 3, .000001) {
+      t.Error("moving surface error 4: ", time, u)
+    }
+
+    //The gradient points inward from where the ellipsoid is.
+    g := s.Gradient([]float64{time, .5, 0})
+    if !test.VectorCloseEnough(g, []float64{0, -4, 0}, .000001) {
+      t.Error("moving surface error 5: ", time, g)
+    }
+  }
+
+  //After a quarter turn, it is along the y axis.
+  m.SetTime(1)
+  if u := s.Intersection([]float64{-4, 0, 0}, []float64{2, 0, 0}); len(u) != 2 ||
+    !test.CloseEnough(u[0], 2.25, .000001) || !test.CloseEnough(u[1], 2.75, .000001) {
+    t.Error("moving surface error 6: ", u)
+  }
+  if g := s.Gradient([]float64{1, 1, 0}); !test.VectorCloseEnough(g, []float64{0, -2, 0}, .000001) {
+    t.Error("moving surface error 7: ", g)
+  }
+
+  if min, max := BoundingBox(s); FiniteBox(min, max) {
+    t.Error("moving surface error 8")
+  }
+}
+
+//Moving surfaces are found inside other surfaces.
+func TestMovingParts(t *testing.T) {
+  still := func(t float64) ([][]float64, []float64) { return nil, nil }
+  inner := NewMovingSurface(&mockEllipsoid{3}, still)
+  outer := NewMovingSurface(NewBoundedSurface(inner, []float64{-1, -1, -1}, []float64{1, 1, 1}), still)
+
+  for i, c := range []struct {
+    s Surface
+    n int
+  }{{&mockEllipsoid{3}, 0}, {inner, 1}, {NewBoundedSurface(inner, []float64{-1, -1, -1}, []float64{1, 1, 1}), 1}, {outer, 2}} {
+    if n := len(MovingParts(c.s)); n != c.n {
+      t.Error("moving parts error, case ", i, ": ", n)
+    }
+  }
+}
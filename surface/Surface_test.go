@@ -1,10 +1,7 @@
 package surface
 
 import "testing"
-import "math"
-import "errors"
 import "github.com/DanielKrawisz/CurvedSpace/test"
-import "github.com/DanielKrawisz/CurvedSpace/vector"
 
 var err_bs float64 = .0001
 
@@ -32,6 +29,14 @@ func (m *mockTestSurface) Gradient(x []float64) []float64 {
   return m.grad
 }
 
+func (m *mockTestSurface) CoordinateShift(x [][]float64) Surface {
+  return m
+}
+
+func (m *mockTestSurface) Translate(x []float64) Surface {
+  return m
+}
+
 func (m *mockTestSurface) String() string {
   return "mock test surface"
 }
@@ -7,11 +7,16 @@ import (
 	"github.com/DanielKrawisz/CurvedSpace/surface"
 	"github.com/DanielKrawisz/CurvedSpace/vector"
 	"math"
+	"math/rand"
 	"strings"
 )
 
+//Booleans are Stochastic so that a mist inside them can be given its
+//random numbers. If neither of their surfaces is Stochastic, the
+//random numbers are not used.
 type Boolean interface {
 	surface.Surface
+	surface.Stochastic
 	SurfaceA() surface.Surface
 	SurfaceB() surface.Surface
 }
@@ -29,6 +34,10 @@ func (s *boolean) SurfaceB() surface.Surface {
 	return s.b
 }
 
+func (s *boolean) Components() []surface.Surface {
+	return []surface.Surface{s.a, s.b}
+}
+
 func (s *boolean) coordinateShift(x [][]float64) {
 	s.a.CoordinateShift(x)
 	s.b.CoordinateShift(x)
@@ -67,8 +76,12 @@ func (s *addition) Gradient(x []float64) []float64 {
 }
 
 func (s *addition) Intersection(x, v []float64) []float64 {
-	inta := s.a.Intersection(x, v)
-	intb := s.b.Intersection(x, v)
+	return s.RandomIntersection(nil, x, v)
+}
+
+func (s *addition) RandomIntersection(r *rand.Rand, x, v []float64) []float64 {
+	inta := surface.RandomIntersection(r, s.a, x, v)
+	intb := surface.RandomIntersection(r, s.b, x, v)
 
 	z := make([]float64, len(inta)+len(intb))
 
@@ -165,8 +178,12 @@ func (s *intersection) findCommonIntersectionPoints(x, v, inta, intb []float64)
 }
 
 func (s *intersection) Intersection(x, v []float64) []float64 {
-	inta := s.a.Intersection(x, v)
-	intb := s.b.Intersection(x, v)
+	return s.RandomIntersection(nil, x, v)
+}
+
+func (s *intersection) RandomIntersection(r *rand.Rand, x, v []float64) []float64 {
+	inta := surface.RandomIntersection(r, s.a, x, v)
+	intb := surface.RandomIntersection(r, s.b, x, v)
 
 	return s.findCommonIntersectionPoints(x, v, inta, intb)
 }
@@ -192,7 +209,11 @@ type bounding struct {
 }
 
 func (s *bounding) Intersection(x, v []float64) []float64 {
-	inta := s.a.Intersection(x, v)
+	return s.RandomIntersection(nil, x, v)
+}
+
+func (s *bounding) RandomIntersection(r *rand.Rand, x, v []float64) []float64 {
+	inta := surface.RandomIntersection(r, s.a, x, v)
 
 	//The only difference between intersection and bounding right here.
 	//This allows for object a to be a very simple bounding object that
@@ -202,7 +223,7 @@ func (s *bounding) Intersection(x, v []float64) []float64 {
 		return inta
 	}
 
-	intb := s.b.Intersection(x, v)
+	intb := surface.RandomIntersection(r, s.b, x, v)
 
 	return s.intersection.findCommonIntersectionPoints(x, v, inta, intb)
 }
@@ -217,7 +238,11 @@ type openBounding struct {
 }
 
 func (s *openBounding) Intersection(x, v []float64) []float64 {
-	intb := s.b.Intersection(x, v)
+	return s.RandomIntersection(nil, x, v)
+}
+
+func (s *openBounding) RandomIntersection(r *rand.Rand, x, v []float64) []float64 {
+	intb := surface.RandomIntersection(r, s.b, x, v)
 
 	z := make([]float64, len(intb))
 	var zi int = 0
@@ -258,8 +283,12 @@ func (s *subtraction) Gradient(x []float64) []float64 {
 }
 
 func (s *subtraction) Intersection(x, v []float64) []float64 {
-	inta := s.a.Intersection(x, v)
-	intb := s.b.Intersection(x, v)
+	return s.RandomIntersection(nil, x, v)
+}
+
+func (s *subtraction) RandomIntersection(r *rand.Rand, x, v []float64) []float64 {
+	inta := surface.RandomIntersection(r, s.a, x, v)
+	intb := surface.RandomIntersection(r, s.b, x, v)
 
 	z := make([]float64, len(inta)+len(intb))
 
@@ -0,0 +1,23 @@
+package booleans
+
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+
+//Bounding boxes for booleans. They are only finite if the
+//bounding boxes of the surfaces they are made of are.
+
+func (s *addition) BoundingBox() (min, max []float64) {
+	amin, amax := surface.BoundingBox(s.a)
+	bmin, bmax := surface.BoundingBox(s.b)
+	return surface.BoxUnion(amin, amax, bmin, bmax)
+}
+
+//Also used by bounding and open bounding objects.
+func (s *intersection) BoundingBox() (min, max []float64) {
+	amin, amax := surface.BoundingBox(s.a)
+	bmin, bmax := surface.BoundingBox(s.b)
+	return surface.BoxIntersection(amin, amax, bmin, bmax)
+}
+
+func (s *subtraction) BoundingBox() (min, max []float64) {
+	return surface.BoundingBox(s.a)
+}
@@ -0,0 +1,37 @@
+package booleans_test
+
+import "testing"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/surface/booleans"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+
+func TestBooleanBoundingBoxes(t *testing.T) {
+  a := polynomialsurfaces.NewSphere([]float64{-2, 0}, 3)
+  b := polynomialsurfaces.NewSphere([]float64{2, 0}, 3)
+  plane := polynomialsurfaces.NewPlaneByPointAndNormal([]float64{0, 0}, []float64{0, 1}, false)
+
+  min, max := surface.BoundingBox(booleans.NewAddition(a, b))
+  if !test.VectorCloseEnough(min, []float64{-5, -3}, b_err) || !test.VectorCloseEnough(max, []float64{5, 3}, b_err) {
+    t.Error("boolean bounding box error 1: ", min, max)
+  }
+
+  min, max = surface.BoundingBox(booleans.NewIntersection(a, b))
+  if !test.VectorCloseEnough(min, []float64{-1, -3}, b_err) || !test.VectorCloseEnough(max, []float64{1, 3}, b_err) {
+    t.Error("boolean bounding box error 2: ", min, max)
+  }
+
+  min, max = surface.BoundingBox(booleans.NewSubtraction(a, b))
+  if !test.VectorCloseEnough(min, []float64{-5, -3}, b_err) || !test.VectorCloseEnough(max, []float64{1, 3}, b_err) {
+    t.Error("boolean bounding box error 3: ", min, max)
+  }
+
+  //An intersection with something unbounded is still bounded.
+  if !surface.FiniteBox(surface.BoundingBox(booleans.NewIntersection(a, plane))) {
+    t.Error("boolean bounding box error 4")
+  }
+
+  if surface.FiniteBox(surface.BoundingBox(booleans.NewAddition(a, plane))) {
+    t.Error("boolean bounding box error 5")
+  }
+}
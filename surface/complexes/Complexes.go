@@ -1,7 +1,7 @@
 package complexes
 
 import "github.com/DanielKrawisz/CurvedSpace/vector"
-import "github.com/DanielKrawisz/CurvedSpace/surface/"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
 import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
 import "github.com/DanielKrawisz/CurvedSpace/surface/booleans"
 
@@ -47,7 +47,19 @@ func NewSimplex(p [][]float64) surface.Surface {
 		}
 	}
 
-	return s
+	//If the simplex is right side in, it is inside the box around its points.
+	center := make([]float64, dim)
+	for _, x := range p {
+		for j := 0; j < dim; j++ {
+			center[j] += x[j] / float64(l)
+		}
+	}
+	if !surface.SurfaceInterior(s, center) {
+		return s
+	}
+
+	min, max := surface.PointsBox(p)
+	return surface.NewBoundedSurface(s, min, max)
 }
 
 //A parallelpiped is given here by a corner point and
@@ -121,5 +133,24 @@ func NewParallelpipedByCornerAndEdges(P []float64, V [][]float64, right_side_out
 		}
 	}
 
-	return s
+	if right_side_out {
+		return s
+	}
+
+	//The box around the corners.
+	corners := make([][]float64, 1<<uint(dim))
+	for k := range corners {
+		corners[k] = make([]float64, dim)
+		copy(corners[k], P)
+		for i := 0; i < dim; i++ {
+			if k&(1<<uint(i)) != 0 {
+				for j := 0; j < dim; j++ {
+					corners[k][j] += V[i][j]
+				}
+			}
+		}
+	}
+
+	min, max := surface.PointsBox(corners)
+	return surface.NewBoundedSurface(s, min, max)
 }
@@ -10,25 +10,25 @@ import "github.com/DanielKrawisz/CurvedSpace/vector"
 //For both these next two, I only have to test that
 //a given point is interior or exterior to the complex.
 func TestNewSimplex(t *testing.T) {
-	if NewSimplex(nil) != nil {
+	if complexes.NewSimplex(nil) != nil {
 		t.Error("simplex error 1")
 	}
-	if NewSimplex([][]float64{}) != nil {
+	if complexes.NewSimplex([][]float64{}) != nil {
 		t.Error("simplex error 2")
 	}
-	if NewSimplex([][]float64{[]float64{}}) != nil {
+	if complexes.NewSimplex([][]float64{[]float64{}}) != nil {
 		t.Error("simplex error 3")
 	}
-	if NewSimplex([][]float64{[]float64{1}}) != nil {
+	if complexes.NewSimplex([][]float64{[]float64{1}}) != nil {
 		t.Error("simplex error 4")
 	}
-	if NewSimplex([][]float64{[]float64{1}, []float64{2}}) == nil {
+	if complexes.NewSimplex([][]float64{[]float64{1}, []float64{2}}) == nil {
 		t.Error("simplex error 5")
 	}
-	if NewSimplex([][]float64{[]float64{0, 0}, []float64{0, 0}, []float64{0, 1}}) != nil {
+	if complexes.NewSimplex([][]float64{[]float64{0, 0}, []float64{0, 0}, []float64{0, 1}}) != nil {
 		t.Error("simplex error 6")
 	}
-	if NewSimplex([][]float64{[]float64{0, 0}, []float64{1, 0}, []float64{0, 1, 0}}) != nil {
+	if complexes.NewSimplex([][]float64{[]float64{0, 0}, []float64{1, 0}, []float64{0, 1, 0}}) != nil {
 		t.Error("simplex error 7")
 	}
 
@@ -44,7 +44,7 @@ func TestNewSimplex(t *testing.T) {
 			points[j][j-1] = 1
 		}
 
-		simplex := NewSimplex(points)
+		simplex := complexes.NewSimplex(points)
 
 		if simplex == nil {
 			t.Error("simplex error 8, dimension", dim)
@@ -55,7 +55,7 @@ func TestNewSimplex(t *testing.T) {
 			}
 
 			//Ensure the center point is inside the simplex.
-			if !SurfaceInterior(simplex, inside) {
+			if !surface.SurfaceInterior(simplex, inside) {
 				t.Error("simplex error 9, dimension", dim)
 			}
 
@@ -97,7 +97,7 @@ func TestNewParallelpiped(t *testing.T) {
 			v[j][j] += 2
 		}
 
-		pp := NewParallelpipedByCornerAndEdges(p, v, true)
+		pp := complexes.NewParallelpipedByCornerAndEdges(p, v, true)
 
 		m := vector.Inverse(v)
 
@@ -108,7 +108,7 @@ func TestNewParallelpiped(t *testing.T) {
 			for k := 0; k < dim; k++ {
 				test_p[k] = point[k] - p[k]
 			}
-			test_inside := SurfaceInterior(pp, point)
+			test_inside := surface.SurfaceInterior(pp, point)
 
 			inverse := vector.MatrixMultiply(m, test_p)
 
@@ -129,7 +129,7 @@ func TestNewParallelpiped(t *testing.T) {
 //and intersection should work if some more fundamental tests
 //on boolean and polynomials pass.
 func TestParallelpipedGradientAndIntersectionSpecialCases(t *testing.T) {
-	pp := NewParallelpipedByCornerAndEdges([]float64{0, 0, 0},
+	pp := complexes.NewParallelpipedByCornerAndEdges([]float64{0, 0, 0},
 		[][]float64{[]float64{2, 0, 0}, []float64{0, 2, 0}, []float64{0, 0, 2}}, true)
 
 	test_points := [][]float64{[]float64{1, 1, 0}, []float64{1, 0, 1},
@@ -0,0 +1,484 @@
+package complexes
+
+import "math"
+import "strings"
+import "fmt"
+import "github.com/DanielKrawisz/CurvedSpace/combinatorics"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+
+//A convex polyhedron is the region inside a set of flat faces. Each face
+//has an outward normal n and a distance d so that the inside is where
+//n.x <= d for every face. The function is the smallest value of d - n.x,
+//which is the same as intersecting the planes as booleans, but a line
+//is intersected with all the faces at once so that it is quicker.
+type convexPolyhedron struct {
+	dim     int
+	normals [][]float64
+	d       []float64
+	//The corners, or nil if the polyhedron is unbounded.
+	vertices [][]float64
+}
+
+func (s *convexPolyhedron) Dimension() int {
+	return s.dim
+}
+
+//The face nearest to x and the value of d - n.x for that face.
+func (s *convexPolyhedron) nearestFace(x []float64) (int, float64) {
+	var face int
+	var f float64 = math.Inf(1)
+	for i, n := range s.normals {
+		if g := s.d[i] - vector.Dot(n, x); g < f {
+			face, f = i, g
+		}
+	}
+	return face, f
+}
+
+func (s *convexPolyhedron) F(x []float64) float64 {
+	_, f := s.nearestFace(x)
+	return f
+}
+
+func (s *convexPolyhedron) Gradient(x []float64) []float64 {
+	face, _ := s.nearestFace(x)
+	return vector.Negative(s.normals[face])
+}
+
+//Returns the parameters at which the line enters and leaves the
+//polyhedron, leaving out either one if it is infinite.
+func (s *convexPolyhedron) Intersection(x, v []float64) []float64 {
+	var enter, exit float64 = math.Inf(-1), math.Inf(1)
+
+	for i, n := range s.normals {
+		nv := vector.Dot(n, v)
+		g := s.d[i] - vector.Dot(n, x)
+		if nv == 0 {
+			if g < 0 {
+				return []float64{}
+			}
+			continue
+		}
+
+		u := g / nv
+		if nv > 0 {
+			exit = math.Min(exit, u)
+		} else {
+			enter = math.Max(enter, u)
+		}
+	}
+
+	if enter > exit {
+		return []float64{}
+	}
+
+	z := make([]float64, 0, 2)
+	if !math.IsInf(enter, 0) {
+		z = append(z, enter)
+	}
+	if !math.IsInf(exit, 0) {
+		z = append(z, exit)
+	}
+	return z
+}
+
+func (s *convexPolyhedron) Translate(x []float64) surface.Surface {
+	for i, n := range s.normals {
+		s.d[i] += vector.Dot(n, x)
+	}
+
+	for _, p := range s.vertices {
+		for i := 0; i < s.dim; i++ {
+			p[i] += x[i]
+		}
+	}
+	return s
+}
+
+//After the shift, the inside is where n.(m^T x) <= d, so
+//the normals are multiplied by m.
+func (s *convexPolyhedron) CoordinateShift(m [][]float64) surface.Surface {
+	for i, n := range s.normals {
+		mn := vector.MatrixMultiply(m, n)
+		if l := vector.Length(mn); l != 0 {
+			vector.Times(1/l, mn)
+			s.d[i] /= l
+		}
+		s.normals[i] = mn
+	}
+
+	inv := vector.Inverse(m)
+	if inv == nil {
+		s.vertices = nil
+		return s
+	}
+
+	for k, p := range s.vertices {
+		q := make([]float64, s.dim)
+		for i := 0; i < s.dim; i++ {
+			for j := 0; j < s.dim; j++ {
+				q[i] += inv[j][i] * p[j]
+			}
+		}
+		s.vertices[k] = q
+	}
+	return s
+}
+
+func (s *convexPolyhedron) BoundingBox() (min, max []float64) {
+	if s.vertices == nil {
+		return surface.InfiniteBox(s.dim)
+	}
+	return surface.PointsBox(s.vertices)
+}
+
+func (s *convexPolyhedron) String() string {
+	return strings.Join([]string{"polyhedron{", fmt.Sprint(s.normals), ", ", fmt.Sprint(s.d), "}"}, "")
+}
+
+//A tolerance for deciding whether points are on a plane, relative
+//to the size of the numbers involved.
+const polyhedronTolerance = 1e-9
+
+//Calls f for every combination of rank of the numbers from 0 to n - 1.
+type combinationLoop struct {
+	f func([]uint)
+}
+
+func (c *combinationLoop) Iterate(index []uint, x int) {
+	c.f(index)
+}
+
+func combinations(rank, n int, f func([]uint)) {
+	combinatorics.NestedForAsymmetric(&combinationLoop{f}, uint(rank), uint(n))
+}
+
+//Adds a face unless it is already in the list.
+func addFace(normals [][]float64, d []float64, n []float64, e, tol float64) ([][]float64, []float64) {
+	for i := range normals {
+		if math.Abs(d[i]-e) <= tol && vector.Dot(normals[i], n) >= 1-polyhedronTolerance {
+			return normals, d
+		}
+	}
+	return append(normals, n), append(d, e)
+}
+
+//The smallest convex polyhedron which contains the given points, in any
+//number of dimensions. A face is found for every set of dim points which
+//has all the others on one side of it, so this is only quick for small
+//numbers of points.
+//May return nil
+func NewConvexHull(points [][]float64) surface.Surface {
+	if points == nil || len(points) == 0 || points[0] == nil {
+		return nil
+	}
+	dim := len(points[0])
+	if dim < 2 || len(points) <= dim {
+		return nil
+	}
+
+	var scale float64
+	for _, p := range points {
+		if p == nil || len(p) != dim {
+			return nil
+		}
+		for _, x := range p {
+			scale = math.Max(scale, math.Abs(x))
+		}
+	}
+	tol := polyhedronTolerance * math.Max(scale, 1)
+
+	var normals [][]float64
+	var d []float64
+	edges := make([][]float64, dim-1)
+	combinations(dim, len(points), func(index []uint) {
+		p := points[index[0]]
+		var size float64 = 1
+		for i := 1; i < dim; i++ {
+			edges[i-1] = vector.Minus(points[index[i]], p)
+			size *= vector.Length(edges[i-1])
+		}
+
+		//Skip points which all lie along a lower-dimensional plane.
+		n := vector.Cross(edges)
+		l := vector.Length(n)
+		if l <= polyhedronTolerance*size {
+			return
+		}
+		vector.Times(1/l, n)
+		e := vector.Dot(n, p)
+
+		var above, below bool
+		for _, q := range points {
+			g := vector.Dot(n, q) - e
+			if g > tol {
+				above = true
+			} else if g < -tol {
+				below = true
+			}
+		}
+
+		if above && below {
+			return
+		}
+		if above {
+			n = vector.Negative(n)
+			e = -e
+		}
+		if above || below {
+			normals, d = addFace(normals, d, n, e, tol)
+		}
+	})
+
+	if len(normals) <= dim {
+		return nil
+	}
+
+	vertices := make([][]float64, len(points))
+	for i, p := range points {
+		vertices[i] = make([]float64, dim)
+		copy(vertices[i], p)
+	}
+
+	return &convexPolyhedron{dim, normals, d, vertices}
+}
+
+//Whether the polyhedron n.x <= d goes on forever in some direction w,
+//which it does if n.w <= 0 for every face. The cone of such directions
+//either contains a line or has an edge along which all but one of the
+//planes n.w == 0 meet.
+func unboundedHalfSpaces(normals [][]float64, tol float64) bool {
+	dim := len(normals[0])
+
+	var rank bool
+	combinations(dim, len(normals), func(index []uint) {
+		m := make([][]float64, dim)
+		for i, k := range index {
+			m[i] = normals[k]
+		}
+		if math.Abs(vector.Det(m)) > tol {
+			rank = true
+		}
+	})
+	if !rank {
+		return true
+	}
+
+	var unbounded bool
+	m := make([][]float64, dim-1)
+	combinations(dim-1, len(normals), func(index []uint) {
+		for i, k := range index {
+			m[i] = normals[k]
+		}
+		w := vector.Cross(m)
+		if vector.Length(w) <= tol {
+			return
+		}
+
+		var pos, neg bool = true, true
+		for _, n := range normals {
+			nw := vector.Dot(n, w)
+			if nw > tol {
+				pos = false
+			}
+			if nw < -tol {
+				neg = false
+			}
+		}
+		if pos || neg {
+			unbounded = true
+		}
+	})
+	return unbounded
+}
+
+//The convex polyhedron where n.x <= d for each normal n and distance d.
+//If it is bounded, its corners are found, which takes a while if there
+//are very many faces.
+//May return nil
+func NewConvexPolyhedron(normals [][]float64, d []float64) surface.Surface {
+	if normals == nil || d == nil || len(normals) == 0 || len(normals) != len(d) || normals[0] == nil {
+		return nil
+	}
+	dim := len(normals[0])
+	if dim < 2 {
+		return nil
+	}
+
+	s := &convexPolyhedron{dim, make([][]float64, len(normals)), make([]float64, len(d)), nil}
+	var scale float64 = 1
+	for i, n := range normals {
+		if n == nil || len(n) != dim {
+			return nil
+		}
+		l := vector.Length(n)
+		if l == 0 {
+			return nil
+		}
+		s.normals[i] = vector.Times(1/l, append([]float64{}, n...))
+		s.d[i] = d[i] / l
+		scale = math.Max(scale, math.Abs(s.d[i]))
+	}
+	tol := polyhedronTolerance * scale
+
+	if unboundedHalfSpaces(s.normals, polyhedronTolerance) {
+		return s
+	}
+
+	//Every corner is where dim of the faces meet.
+	s.vertices = make([][]float64, 0)
+	m := make([][]float64, dim)
+	b := make([]float64, dim)
+	combinations(dim, len(s.normals), func(index []uint) {
+		for i, k := range index {
+			m[i] = s.normals[k]
+			b[i] = s.d[k]
+		}
+		inv := vector.Inverse(m)
+		if inv == nil {
+			return
+		}
+
+		p := vector.MatrixMultiply(inv, b)
+		for i, n := range s.normals {
+			if vector.Dot(n, p) > s.d[i]+tol {
+				return
+			}
+		}
+		s.vertices = append(s.vertices, p)
+	})
+
+	//The half spaces have nothing in common.
+	if len(s.vertices) == 0 {
+		return nil
+	}
+
+	return s
+}
+
+//The golden ratio.
+var phi float64 = (1 + math.Sqrt(5)) / 2
+
+//Every way of changing the signs of the coordinates of x and of
+//permuting them. If cyclic is true, only cyclic permutations are used.
+func signedPermutations(x []float64, cyclic bool) [][]float64 {
+	var perms [][3]int
+	if cyclic {
+		perms = [][3]int{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}}
+	} else {
+		perms = [][3]int{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}, {0, 2, 1}, {2, 1, 0}, {1, 0, 2}}
+	}
+
+	seen := make(map[[3]float64]bool)
+	var points [][]float64
+	for _, p := range perms {
+		for signs := 0; signs < 8; signs++ {
+			var q [3]float64
+			for i := 0; i < 3; i++ {
+				q[i] = x[p[i]]
+				if signs&(1<<uint(i)) != 0 {
+					q[i] = -q[i]
+				}
+				//Get rid of negative zero.
+				q[i] += 0
+			}
+			if !seen[q] {
+				seen[q] = true
+				points = append(points, []float64{q[0], q[1], q[2]})
+			}
+		}
+	}
+	return points
+}
+
+//A polyhedron with the given vertices, scaled so that
+//they are a distance r from p.
+func newPolyhedronAroundPoint(p []float64, r float64, vertices [][]float64) surface.Surface {
+	if p == nil || len(p) != 3 || !(r > 0) {
+		return nil
+	}
+
+	l := vector.Length(vertices[0])
+	for _, v := range vertices {
+		for i := 0; i < 3; i++ {
+			v[i] = p[i] + v[i]*r/l
+		}
+	}
+	return NewConvexHull(vertices)
+}
+
+//The Platonic and Archimedean solids are all three-dimensional.
+//p is the center and r is the distance from the center to the corners.
+
+//May return nil
+func NewTetrahedron(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, [][]float64{{1, 1, 1}, {1, -1, -1}, {-1, 1, -1}, {-1, -1, 1}})
+}
+
+//May return nil
+func NewCube(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, signedPermutations([]float64{1, 1, 1}, true))
+}
+
+//May return nil
+func NewOctahedron(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, signedPermutations([]float64{1, 0, 0}, true))
+}
+
+//May return nil
+func NewDodecahedron(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, append(signedPermutations([]float64{1, 1, 1}, true),
+		signedPermutations([]float64{0, 1 / phi, phi}, true)...))
+}
+
+//May return nil
+func NewIcosahedron(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, signedPermutations([]float64{0, 1, phi}, true))
+}
+
+//May return nil
+func NewCuboctahedron(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, signedPermutations([]float64{1, 1, 0}, false))
+}
+
+//May return nil
+func NewTruncatedTetrahedron(p []float64, r float64) surface.Surface {
+	//Only the points with an even number of minus signs.
+	var vertices [][]float64
+	for _, v := range signedPermutations([]float64{3, 1, 1}, false) {
+		if v[0]*v[1]*v[2] > 0 {
+			vertices = append(vertices, v)
+		}
+	}
+	return newPolyhedronAroundPoint(p, r, vertices)
+}
+
+//May return nil
+func NewTruncatedCube(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, signedPermutations([]float64{math.Sqrt2 - 1, 1, 1}, false))
+}
+
+//May return nil
+func NewTruncatedOctahedron(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, signedPermutations([]float64{0, 1, 2}, false))
+}
+
+//May return nil
+func NewRhombicuboctahedron(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, signedPermutations([]float64{1, 1, 1 + math.Sqrt2}, false))
+}
+
+//May return nil
+func NewIcosidodecahedron(p []float64, r float64) surface.Surface {
+	return newPolyhedronAroundPoint(p, r, append(signedPermutations([]float64{0, 0, phi}, true),
+		signedPermutations([]float64{.5, phi / 2, phi * phi / 2}, true)...))
+}
+
+//May return nil
+func NewTruncatedIcosahedron(p []float64, r float64) surface.Surface {
+	vertices := signedPermutations([]float64{0, 1, 3 * phi}, true)
+	vertices = append(vertices, signedPermutations([]float64{1, 2 + phi, 2 * phi}, true)...)
+	vertices = append(vertices, signedPermutations([]float64{phi, 2, phi * phi * phi}, true)...)
+	return newPolyhedronAroundPoint(p, r, vertices)
+}
@@ -0,0 +1,199 @@
+package complexes
+
+import "testing"
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/surface/booleans"
+import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+var poly_err float64 = .000001
+
+func TestSolids(t *testing.T) {
+	solids := []struct {
+		name  string
+		new   func([]float64, float64) surface.Surface
+		faces int
+	}{
+		{"tetrahedron", NewTetrahedron, 4},
+		{"cube", NewCube, 6},
+		{"octahedron", NewOctahedron, 8},
+		{"dodecahedron", NewDodecahedron, 12},
+		{"icosahedron", NewIcosahedron, 20},
+		{"cuboctahedron", NewCuboctahedron, 14},
+		{"truncated tetrahedron", NewTruncatedTetrahedron, 8},
+		{"truncated cube", NewTruncatedCube, 14},
+		{"truncated octahedron", NewTruncatedOctahedron, 14},
+		{"rhombicuboctahedron", NewRhombicuboctahedron, 26},
+		{"icosidodecahedron", NewIcosidodecahedron, 32},
+		{"truncated icosahedron", NewTruncatedIcosahedron, 32},
+	}
+
+	p := []float64{1, -2, 3}
+	var r float64 = 2
+
+	for _, solid := range solids {
+		if solid.new(nil, r) != nil {
+			t.Error(solid.name, " error 1")
+		}
+		if solid.new([]float64{0, 0}, r) != nil {
+			t.Error(solid.name, " error 2")
+		}
+		if solid.new(p, 0) != nil {
+			t.Error(solid.name, " error 3")
+		}
+
+		s := solid.new(p, r)
+		if s == nil {
+			t.Error(solid.name, " error 4")
+			continue
+		}
+
+		poly := s.(*convexPolyhedron)
+		if len(poly.normals) != solid.faces {
+			t.Error(solid.name, " error 5: ", len(poly.normals), " faces")
+		}
+
+		for _, v := range poly.vertices {
+			if !test.CloseEnough(vector.Length(vector.Minus(v, p)), r, poly_err) {
+				t.Error(solid.name, " error 6")
+			}
+			if !test.CloseEnough(s.F(v), 0, poly_err) {
+				t.Error(solid.name, " error 7")
+			}
+		}
+
+		if !surface.SurfaceInterior(s, p) {
+			t.Error(solid.name, " error 8")
+		}
+
+		//A line through the center goes in and out.
+		for i := 0; i < 10; i++ {
+			v := test.RandFloatVector(-1, 1, 3)
+			x := vector.LinearSum(1, -5, p, v)
+			z := s.Intersection(x, v)
+			if len(z) != 2 || !(z[0] < 5 && z[1] > 5) {
+				t.Error(solid.name, " error 9: ", z)
+				continue
+			}
+			for _, u := range z {
+				if !test.CloseEnough(s.F(vector.LinearSum(1, u, x, v)), 0, poly_err) {
+					t.Error(solid.name, " error 10")
+				}
+			}
+		}
+	}
+}
+
+func TestConvexPolyhedron(t *testing.T) {
+	normals := [][]float64{{1, 0, 0}, {-1, 0, 0}, {0, 2, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+	d := []float64{1, 1, 2, 1, 1, 1}
+
+	if NewConvexPolyhedron(nil, d) != nil {
+		t.Error("convex polyhedron error 1")
+	}
+	if NewConvexPolyhedron(normals, d[1:]) != nil {
+		t.Error("convex polyhedron error 2")
+	}
+	if NewConvexPolyhedron([][]float64{{1, 0, 0}, {0, 0, 0}}, []float64{1, 1}) != nil {
+		t.Error("convex polyhedron error 3")
+	}
+	//Nothing is inside both of these.
+	if NewConvexPolyhedron(append(normals, []float64{1, 0, 0}), append(d, -2)) != nil {
+		t.Error("convex polyhedron error 4")
+	}
+
+	box := NewConvexPolyhedron(normals, d)
+	hull := NewCube([]float64{0, 0, 0}, math.Sqrt(3))
+	if box == nil || hull == nil {
+		t.Error("convex polyhedron error 5")
+		return
+	}
+
+	min, max := surface.BoundingBox(box)
+	if !test.VectorCloseEnough(min, []float64{-1, -1, -1}, poly_err) ||
+		!test.VectorCloseEnough(max, []float64{1, 1, 1}, poly_err) {
+		t.Error("convex polyhedron error 6: ", min, max)
+	}
+
+	for i := 0; i < 100; i++ {
+		x := test.RandFloatVector(-2, 2, 3)
+		if surface.SurfaceInterior(box, x) != surface.SurfaceInterior(hull, x) {
+			t.Error("convex polyhedron error 7: ", x)
+		}
+	}
+
+	//A half space or a wedge goes on forever.
+	for i, n := range [][][]float64{{{0, 0, 1}}, {{0, 0, 1}, {1, 0, 0}, {0, 1, 0}},
+		{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}}} {
+		s := NewConvexPolyhedron(n, make([]float64, len(n)))
+		if s == nil || surface.FiniteBox(surface.BoundingBox(s)) {
+			t.Error("convex polyhedron error 8, case ", i)
+		}
+	}
+
+	//A ray along the open side of a half space enters but does not leave.
+	s := NewConvexPolyhedron([][]float64{{0, 0, 1}}, []float64{0})
+	if z := s.Intersection([]float64{0, 0, 1}, []float64{0, 0, -1}); !test.VectorCloseEnough(z, []float64{1}, poly_err) {
+		t.Error("convex polyhedron error 9: ", z)
+	}
+}
+
+func TestPolyhedronTransformations(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		m := test.RandFloatMatrix(-1, 1, 3, 3)
+		x := test.RandFloatVector(-1, 1, 3)
+
+		a := NewIcosahedron([]float64{0, 0, 0}, 1)
+		b := NewIcosahedron([]float64{0, 0, 0}, 1)
+		a.CoordinateShift(m)
+		a.Translate(x)
+
+		for j := 0; j < 20; j++ {
+			y := test.RandFloatVector(-2, 2, 3)
+			z := vector.Minus(y, x)
+			mz := make([]float64, 3)
+			for k := 0; k < 3; k++ {
+				for l := 0; l < 3; l++ {
+					mz[k] += m[l][k] * z[l]
+				}
+			}
+
+			if surface.SurfaceInterior(a, y) != surface.SurfaceInterior(b, mz) {
+				t.Error("polyhedron transformation error 1, case ", i)
+			}
+		}
+
+		//The box must still hold the polyhedron.
+		min, max := surface.BoundingBox(a)
+		for _, v := range a.(*convexPolyhedron).vertices {
+			if !test.CloseEnough(a.F(v), 0, .0001) {
+				t.Error("polyhedron transformation error 2, case ", i)
+			}
+			for k := 0; k < 3; k++ {
+				if v[k] < min[k] || v[k] > max[k] {
+					t.Error("polyhedron transformation error 3, case ", i)
+				}
+			}
+		}
+	}
+}
+
+//Polyhedra should work with booleans.
+func TestPolyhedronBooleans(t *testing.T) {
+	cube := NewCube([]float64{0, 0, 0}, math.Sqrt(3))
+	hole := booleans.NewSubtraction(cube, polynomialsurfaces.NewSphere([]float64{0, 0, 0}, 1.2))
+
+	if surface.SurfaceInterior(hole, []float64{0, 0, 0}) {
+		t.Error("polyhedron boolean error 1")
+	}
+	if !surface.SurfaceInterior(hole, []float64{.95, .95, .95}) {
+		t.Error("polyhedron boolean error 2")
+	}
+
+	z := hole.Intersection([]float64{.5, .5, -5}, []float64{0, 0, 1})
+	if len(z) != 4 {
+		t.Error("polyhedron boolean error 3: ", z)
+	}
+}
@@ -0,0 +1,349 @@
+package meshes
+
+//A mesh is a closed surface made of triangles, such as a
+//model that has been made in another program.
+
+import (
+	"fmt"
+	"github.com/DanielKrawisz/CurvedSpace/surface"
+	"github.com/DanielKrawisz/CurvedSpace/vector"
+	"math"
+	"strings"
+)
+
+//A direction which is not likely to line up with the edges of a mesh,
+//used to count how many times a ray from a point crosses the surface.
+var insideDirection []float64 = vector.Normalize([]float64{0.5773502, 0.5773498, 0.5773505})
+
+//A closed surface made of triangles. The triangles are wound
+//counterclockwise as seen from the outside. The function is the
+//distance to the nearest triangle, which is positive inside.
+//
+//This mesh is three-dimensional!!!
+type triangleMesh struct {
+	vertices [][]float64
+	faces    [][3]int
+	//A normal for each corner of each triangle, pointing outward.
+	normals [][3][]float64
+	tree    *triangleTree
+}
+
+func (m *triangleMesh) Dimension() int {
+	return 3
+}
+
+//Whether a point is inside the mesh, which it is if a ray
+//from the point crosses the surface an odd number of times.
+func (m *triangleMesh) inside(x []float64) bool {
+	var crossings int
+	for _, u := range m.tree.intersections(m, x, insideDirection) {
+		if u > 0 {
+			crossings++
+		}
+	}
+	return crossings%2 == 1
+}
+
+func (m *triangleMesh) F(x []float64) float64 {
+	d, _, _ := m.tree.nearest(m, x)
+	if m.inside(x) {
+		return d
+	}
+	return -d
+}
+
+func (m *triangleMesh) Intersection(x, v []float64) []float64 {
+	return m.tree.intersections(m, x, v)
+}
+
+//The normals of the vertices of the nearest triangle are
+//interpolated. If the result points into the triangle, the
+//normal of the triangle itself is used instead.
+func (m *triangleMesh) Gradient(x []float64) []float64 {
+	_, f, p := m.tree.nearest(m, x)
+	a, b, c := m.corners(f)
+	face := cross3(vector.Minus(b, a), vector.Minus(c, a))
+
+	wa, wb, wc := barycentric(a, b, c, p)
+	n := m.normals[f]
+	grad := make([]float64, 3)
+	for i := 0; i < 3; i++ {
+		grad[i] = -(wa*n[0][i] + wb*n[1][i] + wc*n[2][i])
+	}
+
+	if vector.Dot(grad, face) >= 0 {
+		return vector.Negative(face)
+	}
+	return grad
+}
+
+//The points x for which the transpose of m times x was inside
+//the mesh before are inside it afterward. Nothing is done if m
+//cannot be inverted.
+func (m *triangleMesh) CoordinateShift(x [][]float64) surface.Surface {
+	inv := vector.Inverse(x)
+	if inv == nil {
+		return m
+	}
+
+	for k, p := range m.vertices {
+		q := make([]float64, 3)
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				q[i] += inv[j][i] * p[j]
+			}
+		}
+		m.vertices[k] = q
+	}
+
+	//Normals are gradients, so they go the other way.
+	for _, n := range m.normals {
+		for j := 0; j < 3; j++ {
+			n[j] = normalize(vector.MatrixMultiply(x, n[j]))
+		}
+	}
+
+	m.tree = newTriangleTree(m)
+	return m
+}
+
+func (m *triangleMesh) Translate(x []float64) surface.Surface {
+	for _, p := range m.vertices {
+		for i := 0; i < 3; i++ {
+			p[i] += x[i]
+		}
+	}
+
+	m.tree = newTriangleTree(m)
+	return m
+}
+
+func (m *triangleMesh) BoundingBox() (min, max []float64) {
+	min = make([]float64, 3)
+	max = make([]float64, 3)
+	copy(min, m.tree.min)
+	copy(max, m.tree.max)
+	return
+}
+
+func (m *triangleMesh) String() string {
+	return strings.Join([]string{"mesh{", fmt.Sprint(m.vertices), ", ", fmt.Sprint(m.faces), "}"}, "")
+}
+
+func (m *triangleMesh) corners(f int) (a, b, c []float64) {
+	return m.vertices[m.faces[f][0]], m.vertices[m.faces[f][1]], m.vertices[m.faces[f][2]]
+}
+
+func cross3(a, b []float64) []float64 {
+	return []float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+//Normalizes a vector unless it is zero.
+func normalize(v []float64) []float64 {
+	if vector.Length(v) == 0 {
+		return v
+	}
+	return vector.Normalize(v)
+}
+
+//The barycentric coordinates of a point p in the plane of a triangle.
+func barycentric(a, b, c, p []float64) (wa, wb, wc float64) {
+	e1 := vector.Minus(b, a)
+	e2 := vector.Minus(c, a)
+	d := vector.Minus(p, a)
+
+	d11 := vector.Dot(e1, e1)
+	d12 := vector.Dot(e1, e2)
+	d22 := vector.Dot(e2, e2)
+	d1 := vector.Dot(d, e1)
+	d2 := vector.Dot(d, e2)
+
+	det := d11*d22 - d12*d12
+	if det == 0 {
+		return 1, 0, 0
+	}
+
+	wb = (d22*d1 - d12*d2) / det
+	wc = (d11*d2 - d12*d1) / det
+	wa = 1 - wb - wc
+	return
+}
+
+//The parameter u at which the line x + u v crosses the triangle,
+//using the Moller-Trumbore algorithm. Returns false if it does not.
+func triangleIntersection(a, b, c, x, v []float64) (float64, bool) {
+	e1 := vector.Minus(b, a)
+	e2 := vector.Minus(c, a)
+	p := cross3(v, e2)
+
+	det := vector.Dot(e1, p)
+	if det == 0 {
+		return 0, false
+	}
+
+	s := vector.Minus(x, a)
+	wb := vector.Dot(s, p) / det
+	if wb < 0 || wb > 1 {
+		return 0, false
+	}
+
+	q := cross3(s, e1)
+	wc := vector.Dot(v, q) / det
+	if wc < 0 || wb+wc > 1 {
+		return 0, false
+	}
+
+	return vector.Dot(e2, q) / det, true
+}
+
+//The nearest point to p on a triangle. See Ericson,
+//Real-Time Collision Detection, section 5.1.5.
+func nearestPointOnTriangle(a, b, c, p []float64) []float64 {
+	ab := vector.Minus(b, a)
+	ac := vector.Minus(c, a)
+	ap := vector.Minus(p, a)
+	d1 := vector.Dot(ab, ap)
+	d2 := vector.Dot(ac, ap)
+	if d1 <= 0 && d2 <= 0 {
+		return a
+	}
+
+	bp := vector.Minus(p, b)
+	d3 := vector.Dot(ab, bp)
+	d4 := vector.Dot(ac, bp)
+	if d3 >= 0 && d4 <= d3 {
+		return b
+	}
+
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		return vector.LinearSum(1, d1/(d1-d3), a, ab)
+	}
+
+	cp := vector.Minus(p, c)
+	d5 := vector.Dot(ab, cp)
+	d6 := vector.Dot(ac, cp)
+	if d6 >= 0 && d5 <= d6 {
+		return c
+	}
+
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		return vector.LinearSum(1, d2/(d2-d6), a, ac)
+	}
+
+	va := d3*d6 - d5*d4
+	if va <= 0 && d4-d3 >= 0 && d5-d6 >= 0 {
+		return vector.LinearSum(1, (d4-d3)/((d4-d3)+(d5-d6)), b, vector.Minus(c, b))
+	}
+
+	denom := 1 / (va + vb + vc)
+	return vector.Plus(a, vector.LinearSum(vb*denom, vc*denom, ab, ac))
+}
+
+//Triangles which meet at an angle greater than this are
+//not smoothed into one another when normals are found.
+var creaseAngle float64 = math.Pi / 6
+
+//The normal of each corner of a triangle is the sum of the normals of the
+//triangles around the vertex, weighted by their angles at the vertex.
+//Triangles which meet the first at a crease are left out, so that
+//the edges of a shape like a cube stay sharp.
+func cornerNormals(vertices [][]float64, faces [][3]int) [][3][]float64 {
+	faceNormals := make([][]float64, len(faces))
+	angles := make([][3]float64, len(faces))
+	around := make([][][2]int, len(vertices))
+
+	for f, face := range faces {
+		a, b, c := vertices[face[0]], vertices[face[1]], vertices[face[2]]
+		faceNormals[f] = normalize(cross3(vector.Minus(b, a), vector.Minus(c, a)))
+		for j := 0; j < 3; j++ {
+			p := vertices[face[j]]
+			e1 := normalize(vector.Minus(vertices[face[(j+1)%3]], p))
+			e2 := normalize(vector.Minus(vertices[face[(j+2)%3]], p))
+			angles[f][j] = math.Acos(math.Max(-1, math.Min(1, vector.Dot(e1, e2))))
+			around[face[j]] = append(around[face[j]], [2]int{f, j})
+		}
+	}
+
+	crease := math.Cos(creaseAngle)
+	normals := make([][3][]float64, len(faces))
+	for f, face := range faces {
+		for j := 0; j < 3; j++ {
+			n := make([]float64, 3)
+			for _, g := range around[face[j]] {
+				if vector.Dot(faceNormals[f], faceNormals[g[0]]) < crease {
+					continue
+				}
+				for i := 0; i < 3; i++ {
+					n[i] += angles[g[0]][g[1]] * faceNormals[g[0]][i]
+				}
+			}
+			normals[f][j] = normalize(n)
+		}
+	}
+	return normals
+}
+
+//A mesh of polygons, each of which is given as a list of the indices
+//of its vertices, wound counterclockwise as seen from the outside.
+//Polygons with more than three sides are divided into triangles. The
+//mesh should be closed. normals gives a normal for each vertex and
+//may be nil, in which case they are found from the polygons, with
+//sharp edges where the polygons meet at a crease.
+//
+//This mesh is three-dimensional!!!
+//
+//May return nil
+func NewTriangleMesh(vertices, normals [][]float64, faces [][]int) surface.Surface {
+	if vertices == nil || faces == nil || len(faces) == 0 {
+		return nil
+	}
+
+	if normals != nil && len(normals) != len(vertices) {
+		return nil
+	}
+
+	m := &triangleMesh{make([][]float64, len(vertices)), make([][3]int, 0, len(faces)), nil, nil}
+
+	for i, v := range vertices {
+		if len(v) != 3 {
+			return nil
+		}
+		m.vertices[i] = []float64{v[0], v[1], v[2]}
+	}
+
+	for _, f := range faces {
+		if len(f) < 3 {
+			return nil
+		}
+		for _, k := range f {
+			if k < 0 || k >= len(vertices) {
+				return nil
+			}
+		}
+		for j := 1; j+1 < len(f); j++ {
+			m.faces = append(m.faces, [3]int{f[0], f[j], f[j+1]})
+		}
+	}
+
+	if normals == nil {
+		m.normals = cornerNormals(m.vertices, m.faces)
+	} else {
+		for _, n := range normals {
+			if len(n) != 3 {
+				return nil
+			}
+		}
+
+		m.normals = make([][3][]float64, len(m.faces))
+		for f, face := range m.faces {
+			for j, k := range face {
+				m.normals[f][j] = normalize([]float64{normals[k][0], normals[k][1], normals[k][2]})
+			}
+		}
+	}
+
+	m.tree = newTriangleTree(m)
+	return m
+}
@@ -0,0 +1,96 @@
+package meshes
+
+import "testing"
+import "math"
+import "sort"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+
+var mesh_err float64 = .00001
+
+//A cube from -1 to 1 in every direction, with square faces.
+var cubeVertices [][]float64 = [][]float64{
+  {-1, -1, -1}, {1, -1, -1}, {1, 1, -1}, {-1, 1, -1},
+  {-1, -1, 1}, {1, -1, 1}, {1, 1, 1}, {-1, 1, 1}}
+
+var cubeFaces [][]int = [][]int{
+  {0, 3, 2, 1}, {4, 5, 6, 7}, {0, 1, 5, 4},
+  {2, 3, 7, 6}, {1, 2, 6, 5}, {0, 4, 7, 3}}
+
+func TestNewTriangleMesh(t *testing.T) {
+  if NewTriangleMesh(nil, nil, cubeFaces) != nil {t.Error("mesh error 1")}
+  if NewTriangleMesh(cubeVertices, nil, nil) != nil {t.Error("mesh error 2")}
+  if NewTriangleMesh(cubeVertices, nil, [][]int{{0, 1}}) != nil {t.Error("mesh error 3")}
+  if NewTriangleMesh(cubeVertices, nil, [][]int{{0, 1, 8}}) != nil {t.Error("mesh error 4")}
+  if NewTriangleMesh(cubeVertices, [][]float64{{0, 0, 1}}, cubeFaces) != nil {t.Error("mesh error 5")}
+  if NewTriangleMesh([][]float64{{0, 0}, {1, 0}, {0, 1}}, nil, [][]int{{0, 1, 2}}) != nil {t.Error("mesh error 6")}
+
+  cube := NewTriangleMesh(cubeVertices, nil, cubeFaces)
+  if cube == nil {
+    t.Error("mesh error 7")
+    return
+  }
+  if len(cube.(*triangleMesh).faces) != 12 {t.Error("mesh error 8")}
+}
+
+//Compare the cube with the exact function for a cube.
+func TestCubeMesh(t *testing.T) {
+  cube := NewTriangleMesh(cubeVertices, nil, cubeFaces)
+
+  for i := 0; i < 200; i ++ {
+    x := test.RandFloatVector(-3, 3, 3)
+    v := test.RandFloatVector(-1, 1, 3)
+
+    inside := math.Abs(x[0]) < 1 && math.Abs(x[1]) < 1 && math.Abs(x[2]) < 1
+    if surface.SurfaceInterior(cube, x) != inside {
+      t.Error("cube mesh error 1, case ", i, ": ", x)
+    }
+
+    //Every intersection should be on the cube.
+    for _, u := range cube.Intersection(x, v) {
+      p := []float64{x[0] + u * v[0], x[1] + u * v[1], x[2] + u * v[2]}
+      m := math.Max(math.Abs(p[0]), math.Max(math.Abs(p[1]), math.Abs(p[2])))
+      if !test.CloseEnough(m, 1, mesh_err) {
+        t.Error("cube mesh error 2, case ", i, ": ", p)
+      }
+    }
+  }
+
+  //A ray through the middle hits twice.
+  z := cube.Intersection([]float64{.1, .2, -5}, []float64{0, 0, 1})
+  sort.Float64s(z)
+  if !test.VectorCloseEnough(z, []float64{4, 6}, mesh_err) {
+    t.Error("cube mesh error 3: ", z)
+  }
+
+  if !test.CloseEnough(cube.F([]float64{0, 0, .5}), .5, mesh_err) {t.Error("cube mesh error 4")}
+  if !test.CloseEnough(cube.F([]float64{0, 3, 0}), -2, mesh_err) {t.Error("cube mesh error 5")}
+
+  //In the middle of a face, the normal is that of the face.
+  if !test.VectorCloseEnough(surface.SurfaceNormal(cube, []float64{0, 0, 1}), []float64{0, 0, 1}, mesh_err) {
+    t.Error("cube mesh error 6: ", surface.SurfaceNormal(cube, []float64{0, 0, 1}))
+  }
+
+  min, max := surface.BoundingBox(cube)
+  if !test.VectorCloseEnough(min, []float64{-1, -1, -1}, mesh_err) ||
+    !test.VectorCloseEnough(max, []float64{1, 1, 1}, mesh_err) {
+    t.Error("cube mesh error 7")
+  }
+}
+
+func TestMeshTransformations(t *testing.T) {
+  cube := NewTriangleMesh(cubeVertices, nil, cubeFaces)
+
+  cube.Translate([]float64{1, 2, 3})
+  if !surface.SurfaceInterior(cube, []float64{1.5, 2.5, 3.5}) {t.Error("mesh transformation error 1")}
+  if surface.SurfaceInterior(cube, []float64{0, 0, 0}) {t.Error("mesh transformation error 2")}
+
+  //Stretch the cube by two in the x direction.
+  cube = NewTriangleMesh(cubeVertices, nil, cubeFaces)
+  cube.CoordinateShift([][]float64{{.5, 0, 0}, {0, 1, 0}, {0, 0, 1}})
+  if !surface.SurfaceInterior(cube, []float64{1.5, 0, 0}) {t.Error("mesh transformation error 3")}
+  if surface.SurfaceInterior(cube, []float64{2.5, 0, 0}) {t.Error("mesh transformation error 4")}
+  if !test.VectorCloseEnough(surface.SurfaceNormal(cube, []float64{2, .1, .2}), []float64{1, 0, 0}, mesh_err) {
+    t.Error("mesh transformation error 5")
+  }
+}
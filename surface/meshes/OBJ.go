@@ -0,0 +1,164 @@
+package meshes
+
+//Wavefront OBJ files. Only vertices, normals, and faces are read.
+//Texture coordinates, groups, materials and so on are ignored.
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/DanielKrawisz/CurvedSpace/surface"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//An index into a list in an OBJ file, which counts from 1,
+//or backwards from the end of the list if it is negative.
+func objIndex(s string, n int) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case i > 0 && i <= n:
+		return i - 1, nil
+	case i < 0 && -i <= n:
+		return n + i, nil
+	}
+	return 0, fmt.Errorf("index %d out of range", i)
+}
+
+func parseFloats(fields []string) ([]float64, error) {
+	x := make([]float64, len(fields))
+	for i, f := range fields {
+		var err error
+		x[i], err = strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+//Read a mesh from an OBJ file. If every corner of every face is given a
+//normal, those are used. Otherwise the normals are found from the faces.
+func ReadOBJ(r io.Reader) (surface.Surface, error) {
+	var positions, normals [][]float64
+	var faces, faceNormals [][]int
+	var smooth bool = true
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "v":
+			//There may be a fourth coordinate, which is ignored.
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("obj line %d: vertex needs three coordinates", line)
+			}
+			x, err := parseFloats(fields[1:4])
+			if err != nil {
+				return nil, fmt.Errorf("obj line %d: %s", line, err)
+			}
+			positions = append(positions, x)
+		case "vn":
+			if len(fields) != 4 {
+				return nil, fmt.Errorf("obj line %d: normal needs three coordinates", line)
+			}
+			x, err := parseFloats(fields[1:4])
+			if err != nil {
+				return nil, fmt.Errorf("obj line %d: %s", line, err)
+			}
+			normals = append(normals, x)
+		case "f":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("obj line %d: face needs three vertices", line)
+			}
+			face := make([]int, len(fields)-1)
+			faceNormal := make([]int, len(fields)-1)
+			for i, corner := range fields[1:] {
+				//A corner is v, v/vt, v/vt/vn, or v//vn.
+				parts := strings.Split(corner, "/")
+				v, err := objIndex(parts[0], len(positions))
+				if err != nil {
+					return nil, fmt.Errorf("obj line %d: %s", line, err)
+				}
+				face[i] = v
+
+				if len(parts) < 3 || parts[2] == "" {
+					smooth = false
+					continue
+				}
+				n, err := objIndex(parts[2], len(normals))
+				if err != nil {
+					return nil, fmt.Errorf("obj line %d: %s", line, err)
+				}
+				faceNormal[i] = n
+			}
+			faces = append(faces, face)
+			faceNormals = append(faceNormals, faceNormal)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(faces) == 0 {
+		return nil, fmt.Errorf("obj has no faces")
+	}
+
+	var mesh surface.Surface
+	if smooth {
+		mesh = NewTriangleMesh(splitVertices(positions, normals, faces, faceNormals))
+	} else {
+		mesh = NewTriangleMesh(positions, nil, faces)
+	}
+
+	if mesh == nil {
+		return nil, fmt.Errorf("obj does not describe a valid mesh")
+	}
+	return mesh, nil
+}
+
+//An OBJ file can give several normals to the same vertex on different
+//faces, so a vertex is made for every pair of position and normal.
+func splitVertices(positions, normals [][]float64, faces, faceNormals [][]int) ([][]float64, [][]float64, [][]int) {
+	index := make(map[[2]int]int)
+	var vertices, vertexNormals [][]float64
+	split := make([][]int, len(faces))
+
+	for i, face := range faces {
+		split[i] = make([]int, len(face))
+		for j, v := range face {
+			key := [2]int{v, faceNormals[i][j]}
+			k, ok := index[key]
+			if !ok {
+				k = len(vertices)
+				index[key] = k
+				vertices = append(vertices, positions[v])
+				vertexNormals = append(vertexNormals, normals[key[1]])
+			}
+			split[i][j] = k
+		}
+	}
+
+	return vertices, vertexNormals, split
+}
+
+//Load a mesh from an OBJ file.
+func LoadOBJ(filename string) (surface.Surface, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadOBJ(file)
+}
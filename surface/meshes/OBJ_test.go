@@ -0,0 +1,65 @@
+package meshes
+
+import "testing"
+import "strings"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+
+var cubeOBJ string = `# A cube
+o cube
+v -1 -1 -1
+v 1 -1 -1
+v 1 1 -1
+v -1 1 -1
+v -1 -1 1
+v 1 -1 1
+v 1 1 1
+v -1 1 1
+vt 0 0
+f 1/1 4/1 3/1 2/1
+f 5 6 7 8
+f 1 2 6 5
+f 3 4 8 7
+f 2 3 7 6
+f -8 -4 -1 -5
+`
+
+//Only the top face has normals, so they should be ignored.
+var normalsOBJ string = `v 0 0 0
+v 1 0 0
+v 0 1 0
+v 0 0 1
+vn 0 0 1
+f 1 3 2
+f 1 2 4
+f 1 4 3
+f 2//1 3//1 4//1
+`
+
+func TestReadOBJ(t *testing.T) {
+  cube, err := ReadOBJ(strings.NewReader(cubeOBJ))
+  if err != nil {
+    t.Error("obj error 1: ", err)
+    return
+  }
+
+  if !surface.SurfaceInterior(cube, []float64{.5, -.5, .2}) {t.Error("obj error 2")}
+  if surface.SurfaceInterior(cube, []float64{1.5, -.5, .2}) {t.Error("obj error 3")}
+  if !test.VectorCloseEnough(surface.SurfaceNormal(cube, []float64{-1, .2, .3}), []float64{-1, 0, 0}, mesh_err) {
+    t.Error("obj error 4")
+  }
+
+  tetrahedron, err := ReadOBJ(strings.NewReader(normalsOBJ))
+  if err != nil {
+    t.Error("obj error 5: ", err)
+    return
+  }
+  if !surface.SurfaceInterior(tetrahedron, []float64{.1, .1, .1}) {t.Error("obj error 6")}
+
+  for i, bad := range []string{"", "v 0 0 0\n", "v 0 0\nf 1 2 3\n", "v 0 0 0\nf 1 2 3\n",
+    "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3/1/1\n", "v a 0 0\n"} {
+    if _, err := ReadOBJ(strings.NewReader(bad)); err == nil {
+      t.Error("obj error 7, case ", i)
+    }
+  }
+}
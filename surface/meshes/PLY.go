@@ -0,0 +1,278 @@
+package meshes
+
+//Stanford PLY files, in ascii or binary. The vertex element gives
+//positions x, y, z and optionally normals nx, ny, nz, and the face
+//element gives lists of vertex indices. Other elements and properties
+//are read and ignored.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/DanielKrawisz/CurvedSpace/surface"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type plyProperty struct {
+	name string
+	//The type of the property, or of the items in a list.
+	kind string
+	//The type of the length of a list, or "" if it is not a list.
+	count string
+}
+
+type plyElement struct {
+	name       string
+	size       int
+	properties []*plyProperty
+}
+
+//The number of bytes in each type that a property can have.
+var plyTypeSize map[string]int = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8}
+
+//Reads the values of properties one at a time.
+type plyReader interface {
+	read(kind string) (float64, error)
+}
+
+type plyAsciiReader struct {
+	words *bufio.Scanner
+}
+
+func (p *plyAsciiReader) read(kind string) (float64, error) {
+	if !p.words.Scan() {
+		if err := p.words.Err(); err != nil {
+			return 0, err
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	return strconv.ParseFloat(p.words.Text(), 64)
+}
+
+type plyBinaryReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	buf   []byte
+}
+
+func (p *plyBinaryReader) read(kind string) (float64, error) {
+	b := p.buf[:plyTypeSize[kind]]
+	if _, err := io.ReadFull(p.r, b); err != nil {
+		return 0, err
+	}
+
+	switch kind {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(p.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(p.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(p.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(p.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(p.order.Uint32(b))), nil
+	}
+	return math.Float64frombits(p.order.Uint64(b)), nil
+}
+
+//Read the header, up to and including the end_header line.
+func readPLYHeader(r *bufio.Reader) (string, []*plyElement, error) {
+	var format string
+	var elements []*plyElement
+
+	for line := 1; ; line++ {
+		text, err := r.ReadString('\n')
+		if err != nil {
+			return "", nil, fmt.Errorf("ply header line %d: %s", line, err)
+		}
+		fields := strings.Fields(text)
+
+		if line == 1 {
+			if len(fields) != 1 || fields[0] != "ply" {
+				return "", nil, fmt.Errorf("not a ply file")
+			}
+			continue
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "format":
+			if len(fields) != 3 {
+				return "", nil, fmt.Errorf("ply header line %d: bad format", line)
+			}
+			format = fields[1]
+			if format != "ascii" && format != "binary_little_endian" && format != "binary_big_endian" {
+				return "", nil, fmt.Errorf("ply header line %d: unknown format %s", line, format)
+			}
+		case "element":
+			if len(fields) != 3 {
+				return "", nil, fmt.Errorf("ply header line %d: bad element", line)
+			}
+			size, err := strconv.Atoi(fields[2])
+			if err != nil || size < 0 {
+				return "", nil, fmt.Errorf("ply header line %d: bad element size", line)
+			}
+			elements = append(elements, &plyElement{fields[1], size, nil})
+		case "property":
+			if len(elements) == 0 {
+				return "", nil, fmt.Errorf("ply header line %d: property before element", line)
+			}
+			var p *plyProperty
+			if len(fields) == 5 && fields[1] == "list" {
+				p = &plyProperty{fields[4], fields[3], fields[2]}
+			} else if len(fields) == 3 {
+				p = &plyProperty{fields[2], fields[1], ""}
+			} else {
+				return "", nil, fmt.Errorf("ply header line %d: bad property", line)
+			}
+			if _, ok := plyTypeSize[p.kind]; !ok {
+				return "", nil, fmt.Errorf("ply header line %d: unknown type %s", line, p.kind)
+			}
+			if _, ok := plyTypeSize[p.count]; p.count != "" && !ok {
+				return "", nil, fmt.Errorf("ply header line %d: unknown type %s", line, p.count)
+			}
+			e := elements[len(elements)-1]
+			e.properties = append(e.properties, p)
+		case "end_header":
+			if format == "" {
+				return "", nil, fmt.Errorf("ply header has no format")
+			}
+			return format, elements, nil
+		}
+	}
+}
+
+//The greatest length of a list, which is far more than any face has,
+//so that a bad file cannot make the reader allocate a huge list.
+const maxPLYList = 1 << 16
+
+//Read a mesh from a PLY file. If the vertices have normals, they are
+//used. Otherwise the normals are found from the faces.
+func ReadPLY(r io.Reader) (surface.Surface, error) {
+	br := bufio.NewReader(r)
+	format, elements, err := readPLYHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader plyReader
+	switch format {
+	case "ascii":
+		words := bufio.NewScanner(br)
+		words.Split(bufio.ScanWords)
+		reader = &plyAsciiReader{words}
+	case "binary_little_endian":
+		reader = &plyBinaryReader{br, binary.LittleEndian, make([]byte, 8)}
+	default:
+		reader = &plyBinaryReader{br, binary.BigEndian, make([]byte, 8)}
+	}
+
+	var vertices, normals [][]float64
+	var faces [][]int
+	var hasNormals bool
+
+	for _, e := range elements {
+		for i := 0; i < e.size; i++ {
+			x := make([]float64, 3)
+			n := make([]float64, 3)
+			var normalCount int
+
+			for _, p := range e.properties {
+				if p.count == "" {
+					value, err := reader.read(p.kind)
+					if err != nil {
+						return nil, fmt.Errorf("ply %s %d: %s", e.name, i, err)
+					}
+					if e.name != "vertex" {
+						continue
+					}
+					switch p.name {
+					case "x":
+						x[0] = value
+					case "y":
+						x[1] = value
+					case "z":
+						x[2] = value
+					case "nx":
+						n[0] = value
+						normalCount++
+					case "ny":
+						n[1] = value
+						normalCount++
+					case "nz":
+						n[2] = value
+						normalCount++
+					}
+					continue
+				}
+
+				length, err := reader.read(p.count)
+				if err != nil {
+					return nil, fmt.Errorf("ply %s %d: %s", e.name, i, err)
+				}
+				if !(length >= 0 && length <= maxPLYList) || length != math.Trunc(length) {
+					return nil, fmt.Errorf("ply %s %d: invalid list length %v", e.name, i, length)
+				}
+				list := make([]int, int(length))
+				for j := range list {
+					value, err := reader.read(p.kind)
+					if err != nil {
+						return nil, fmt.Errorf("ply %s %d: %s", e.name, i, err)
+					}
+					list[j] = int(value)
+				}
+
+				if e.name == "face" && (p.name == "vertex_indices" || p.name == "vertex_index") {
+					faces = append(faces, list)
+				}
+			}
+
+			if e.name == "vertex" {
+				vertices = append(vertices, x)
+				normals = append(normals, n)
+				hasNormals = normalCount == 3
+			}
+		}
+	}
+
+	if len(faces) == 0 {
+		return nil, fmt.Errorf("ply has no faces")
+	}
+
+	if !hasNormals {
+		normals = nil
+	}
+
+	mesh := NewTriangleMesh(vertices, normals, faces)
+	if mesh == nil {
+		return nil, fmt.Errorf("ply does not describe a valid mesh")
+	}
+	return mesh, nil
+}
+
+//Load a mesh from a PLY file.
+func LoadPLY(filename string) (surface.Surface, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadPLY(file)
+}
@@ -0,0 +1,96 @@
+package meshes
+
+import "testing"
+import "bytes"
+import "encoding/binary"
+import "strings"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+
+var tetrahedronVertices [][]float64 = [][]float64{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+var tetrahedronFaces [][]int = [][]int{{0, 2, 1}, {0, 1, 3}, {0, 3, 2}, {1, 2, 3}}
+
+var tetrahedronPLY string = `ply
+format ascii 1.0
+comment a tetrahedron
+element vertex 4
+property float x
+property float y
+property float z
+property uchar red
+element face 4
+property list uchar int vertex_indices
+end_header
+0 0 0 255
+1 0 0 255
+0 1 0 255
+0 0 1 255
+3 0 2 1
+3 0 1 3
+3 0 3 2
+3 1 2 3
+`
+
+func checkTetrahedron(t *testing.T, mesh surface.Surface, format string) {
+  if !surface.SurfaceInterior(mesh, []float64{.1, .1, .1}) {t.Error("ply ", format, " error 1")}
+  if surface.SurfaceInterior(mesh, []float64{.5, .5, .5}) {t.Error("ply ", format, " error 2")}
+  if surface.SurfaceInterior(mesh, []float64{-.1, .1, .1}) {t.Error("ply ", format, " error 3")}
+}
+
+func TestReadPLY(t *testing.T) {
+  mesh, err := ReadPLY(strings.NewReader(tetrahedronPLY))
+  if err != nil {
+    t.Error("ply error 1: ", err)
+  } else {
+    checkTetrahedron(t, mesh, "ascii")
+  }
+
+  for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+    var format string = "binary_little_endian"
+    if order == binary.BigEndian {
+      format = "binary_big_endian"
+    }
+
+    var b bytes.Buffer
+    b.WriteString("ply\nformat " + format + " 1.0\nelement vertex 4\nproperty double x\n" +
+      "property double y\nproperty double z\nelement face 4\nproperty list uchar int vertex_indices\nend_header\n")
+    for _, v := range tetrahedronVertices {
+      binary.Write(&b, order, v)
+    }
+    for _, f := range tetrahedronFaces {
+      b.WriteByte(3)
+      for _, k := range f {
+        binary.Write(&b, order, int32(k))
+      }
+    }
+
+    mesh, err := ReadPLY(&b)
+    if err != nil {
+      t.Error("ply error 2: ", format, " ", err)
+    } else {
+      checkTetrahedron(t, mesh, format)
+    }
+  }
+
+  for i, bad := range []string{"", "plx\n", "ply\nend_header\n",
+    "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nend_header\n",
+    "ply\nformat ascii 1.0\nproperty float x\nend_header\n",
+    "ply\nformat ascii 1.0\nelement vertex 1\nproperty bad x\nend_header\n",
+    strings.Replace(tetrahedronPLY, "3 1 2 3", "3 1 2 7", 1),
+    strings.Replace(tetrahedronPLY, "3 1 2 3\n", "3 1 2", 1),
+    strings.Replace(tetrahedronPLY, "3 1 2 3", "-3 1 2 3", 1),
+    strings.Replace(tetrahedronPLY, "3 1 2 3", "2.5 1 2 3", 1),
+    strings.Replace(tetrahedronPLY, "3 1 2 3", "nan 1 2 3", 1),
+    strings.Replace(tetrahedronPLY, "3 1 2 3", "1e12 1 2 3", 1)} {
+    if _, err := ReadPLY(strings.NewReader(bad)); err == nil {
+      t.Error("ply error 3, case ", i)
+    }
+  }
+
+  //A binary list whose length is huge is not read.
+  var b bytes.Buffer
+  b.WriteString("ply\nformat binary_little_endian 1.0\nelement face 1\nproperty list uint int vertex_indices\nend_header\n")
+  binary.Write(&b, binary.LittleEndian, uint32(0xffffffff))
+  if _, err := ReadPLY(&b); err == nil {
+    t.Error("ply error 4")
+  }
+}
@@ -0,0 +1,169 @@
+package meshes
+
+import (
+	"github.com/DanielKrawisz/CurvedSpace/surface"
+	"github.com/DanielKrawisz/CurvedSpace/vector"
+	"math"
+	"sort"
+)
+
+//The largest number of triangles in a leaf of the tree.
+const maxLeafTriangles = 4
+
+//A bounding volume hierarchy for the triangles in a mesh.
+type triangleTree struct {
+	min, max    []float64
+	left, right *triangleTree
+	//The triangles in a leaf.
+	faces []int
+}
+
+//Whether the line x + u v goes through the box for any u.
+func (t *triangleTree) hit(x, v []float64) bool {
+	enter, exit := math.Inf(-1), math.Inf(1)
+	for i := 0; i < 3; i++ {
+		if v[i] == 0 {
+			if x[i] < t.min[i] || x[i] > t.max[i] {
+				return false
+			}
+			continue
+		}
+
+		a := (t.min[i] - x[i]) / v[i]
+		b := (t.max[i] - x[i]) / v[i]
+		if a > b {
+			a, b = b, a
+		}
+		enter = math.Max(enter, a)
+		exit = math.Min(exit, b)
+		if enter > exit {
+			return false
+		}
+	}
+	return true
+}
+
+//The square of the distance from x to the box.
+func (t *triangleTree) distance2(x []float64) (d float64) {
+	for i := 0; i < 3; i++ {
+		if x[i] < t.min[i] {
+			d += (t.min[i] - x[i]) * (t.min[i] - x[i])
+		} else if x[i] > t.max[i] {
+			d += (x[i] - t.max[i]) * (x[i] - t.max[i])
+		}
+	}
+	return
+}
+
+//Every parameter at which the line x + u v crosses the mesh.
+func (t *triangleTree) intersections(m *triangleMesh, x, v []float64) []float64 {
+	if !t.hit(x, v) {
+		return []float64{}
+	}
+
+	if t.faces != nil {
+		z := make([]float64, 0)
+		for _, f := range t.faces {
+			a, b, c := m.corners(f)
+			if u, ok := triangleIntersection(a, b, c, x, v); ok {
+				z = append(z, u)
+			}
+		}
+		return z
+	}
+
+	return append(t.left.intersections(m, x, v), t.right.intersections(m, x, v)...)
+}
+
+//The distance from x to the nearest triangle, the triangle,
+//and the nearest point on it.
+func (t *triangleTree) nearest(m *triangleMesh, x []float64) (float64, int, []float64) {
+	d2, f, p := t.nearestWithin(m, x, math.Inf(1), -1, nil)
+	return math.Sqrt(d2), f, p
+}
+
+func (t *triangleTree) nearestWithin(m *triangleMesh, x []float64,
+	d2 float64, f int, p []float64) (float64, int, []float64) {
+	if t.distance2(x) >= d2 {
+		return d2, f, p
+	}
+
+	if t.faces != nil {
+		for _, g := range t.faces {
+			a, b, c := m.corners(g)
+			q := nearestPointOnTriangle(a, b, c, x)
+			r := vector.Minus(q, x)
+			if e := vector.Dot(r, r); e < d2 {
+				d2, f, p = e, g, q
+			}
+		}
+		return d2, f, p
+	}
+
+	//Look in the nearer box first.
+	first, second := t.left, t.right
+	if second.distance2(x) < first.distance2(x) {
+		first, second = second, first
+	}
+	d2, f, p = first.nearestWithin(m, x, d2, f, p)
+	return second.nearestWithin(m, x, d2, f, p)
+}
+
+//The box and center of a triangle.
+type boxedTriangle struct {
+	face             int
+	min, max, center []float64
+}
+
+func newTriangleTree(m *triangleMesh) *triangleTree {
+	boxes := make([]*boxedTriangle, len(m.faces))
+	for f := range m.faces {
+		a, b, c := m.corners(f)
+		min, max := surface.PointsBox([][]float64{a, b, c})
+		center := make([]float64, 3)
+		for i := 0; i < 3; i++ {
+			center[i] = (a[i] + b[i] + c[i]) / 3
+		}
+		boxes[f] = &boxedTriangle{f, min, max, center}
+	}
+
+	return buildTriangleTree(boxes)
+}
+
+//Split the triangles in half along the axis in
+//which their centers are the most spread out.
+func buildTriangleTree(boxes []*boxedTriangle) *triangleTree {
+	min, max := boxes[0].min, boxes[0].max
+	for _, b := range boxes[1:] {
+		min, max = surface.BoxUnion(min, max, b.min, b.max)
+	}
+
+	if len(boxes) <= maxLeafTriangles {
+		faces := make([]int, len(boxes))
+		for i, b := range boxes {
+			faces[i] = b.face
+		}
+		return &triangleTree{min, max, nil, nil, faces}
+	}
+
+	var axis int
+	var spread float64 = -1
+	for i := 0; i < 3; i++ {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, b := range boxes {
+			lo = math.Min(lo, b.center[i])
+			hi = math.Max(hi, b.center[i])
+		}
+		if hi-lo > spread {
+			spread = hi - lo
+			axis = i
+		}
+	}
+
+	sort.Slice(boxes, func(i, j int) bool {
+		return boxes[i].center[axis] < boxes[j].center[axis]
+	})
+
+	half := len(boxes) / 2
+	return &triangleTree{min, max, buildTriangleTree(boxes[:half]), buildTriangleTree(boxes[half:]), nil}
+}
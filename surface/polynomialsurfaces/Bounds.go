@@ -0,0 +1,83 @@
+package polynomialsurfaces
+
+import "math"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
+
+//Bounding boxes for the polynomial surfaces that have them.
+
+func (s *sphere) BoundingBox() (min, max []float64) {
+	r := math.Sqrt(s.r2)
+	min = make([]float64, s.dim)
+	max = make([]float64, s.dim)
+	for i := 0; i < s.dim; i++ {
+		min[i] = s.p[i] - r
+		max[i] = s.p[i] + r
+	}
+	return
+}
+
+//Whether a symmetric matrix is positive definite, by
+//attempting a Cholesky decomposition.
+func positiveDefinite(m [][]float64) bool {
+	n := len(m)
+	l := make([][]float64, n)
+	for i := 0; i < n; i++ {
+		l[i] = make([]float64, i+1)
+		for j := 0; j <= i; j++ {
+			sum := m[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return false
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return true
+}
+
+//A quadratic surface is bounded if c is negative definite, in which case
+//it is an ellipsoid. Otherwise the box is infinite.
+//
+//With m = -c, the interior is (x - x0) m (x - x0) <= F(x0), where x0 is
+//the center, and the box has half-widths sqrt(F(x0) m^-1_ii).
+func (s *quadraticSurface) BoundingBox() (min, max []float64) {
+	n := s.dimension
+	m := make([][]float64, n)
+	for i := 0; i < n; i++ {
+		m[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			m[i][j] = -s.c[i][j]
+			m[j][i] = -s.c[i][j]
+		}
+	}
+
+	if !positiveDefinite(m) {
+		return surface.InfiniteBox(n)
+	}
+
+	inv := vector.Inverse(m)
+	if inv == nil {
+		return surface.InfiniteBox(n)
+	}
+
+	x0 := vector.Times(.5, vector.MatrixMultiply(inv, s.b))
+	k := math.Max(s.F(x0), 0)
+
+	min = make([]float64, n)
+	max = make([]float64, n)
+	for i := 0; i < n; i++ {
+		w := math.Sqrt(k * inv[i][i])
+		min[i] = x0[i] - w
+		max[i] = x0[i] + w
+	}
+	return
+}
@@ -0,0 +1,84 @@
+package polynomialsurfaces
+
+import "testing"
+import "github.com/DanielKrawisz/CurvedSpace/test"
+import "github.com/DanielKrawisz/CurvedSpace/surface"
+
+//Check that every point inside the surface which can be
+//found by random sampling is inside its bounding box.
+func insideBoundingBox(s surface.Surface, width float64, samples int) bool {
+  min, max := surface.BoundingBox(s)
+
+  for i := 0; i < samples; i ++ {
+    x := test.RandFloatVector(-width, width, s.Dimension())
+    if s.F(x) < 0 {continue}
+
+    for j := 0; j < len(x); j ++ {
+      if x[j] < min[j] || x[j] > max[j] {return false}
+    }
+  }
+
+  return true
+}
+
+func TestBoundingBoxes(t *testing.T) {
+  sphere := NewSphere([]float64{1, -2, 3}, 2)
+  min, max := surface.BoundingBox(sphere)
+  if !test.VectorCloseEnough(min, []float64{-1, -4, 1}, .00001) ||
+    !test.VectorCloseEnough(max, []float64{3, 0, 5}, .00001) {
+    t.Error("bounding box error 1: ", min, max)
+  }
+
+  plane := NewPlaneByPointAndNormal([]float64{0, 0, 0}, []float64{0, 0, 1}, false)
+  if surface.FiniteBox(surface.BoundingBox(plane)) {
+    t.Error("bounding box error 2")
+  }
+
+  for i := 0; i < 20; i ++ {
+    p := test.RandFloatVector(-2, 2, 3)
+    param := test.RandFloatVector(.5, 3, 3)
+    basis := [][]float64{{1, 1, 0}, {1, -1, 0}, {0, 0, 1}}
+
+    ellipsoid := NewEllipsoid(p, basis, param)
+    if !surface.FiniteBox(surface.BoundingBox(ellipsoid)) {
+      t.Error("bounding box error 3, case ", i)
+    }
+    if !insideBoundingBox(ellipsoid, 8, 2000) {
+      t.Error("bounding box error 4, case ", i)
+    }
+
+    cylinder := NewCylinder(p, [][]float64{{1, 1, 0}, {1, -1, 0}}, [][]float64{{0, 0, 1}}, param)
+    if !surface.FiniteBox(surface.BoundingBox(cylinder)) {
+      t.Error("bounding box error 5, case ", i)
+    }
+    if !insideBoundingBox(cylinder, 8, 2000) {
+      t.Error("bounding box error 6, case ", i)
+    }
+
+    torus := NewTorus(p, []float64{0, .6, .8}, 3, param[0] / 3)
+    if !surface.FiniteBox(surface.BoundingBox(torus)) {
+      t.Error("bounding box error 7, case ", i)
+    }
+    if !insideBoundingBox(torus, 8, 2000) {
+      t.Error("bounding box error 8, case ", i)
+    }
+
+    //A rotated cylinder's box should turn with it, and the
+    //rotation should not be changed.
+    rotation := [][]float64{{.6, .8, 0}, {-.8, .6, 0}, {0, 0, 1}}
+    cylinder.CoordinateShift(rotation)
+    if !insideBoundingBox(cylinder, 8, 2000) {
+      t.Error("bounding box error 10, case ", i)
+    }
+    if rotation[0][1] != .8 {
+      t.Error("bounding box error 11, case ", i)
+    }
+
+    //A translated sphere's box should move with it.
+    s := NewSphere([]float64{0, 0, 0}, param[1])
+    s.Translate(p)
+    if !insideBoundingBox(s, 8, 2000) {
+      t.Error("bounding box error 9, case ", i)
+    }
+  }
+}
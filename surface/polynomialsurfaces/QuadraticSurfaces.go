@@ -3,6 +3,7 @@ package polynomialsurfaces
 import "github.com/DanielKrawisz/CurvedSpace/surface"
 import "github.com/DanielKrawisz/CurvedSpace/surface/polynomialsurfaces/polynomials"
 import "github.com/DanielKrawisz/CurvedSpace/surface/booleans"
+import "github.com/DanielKrawisz/CurvedSpace/vector"
 import "fmt"
 import "strings"
 import "math"
@@ -184,13 +185,39 @@ func NewCylinder(p []float64, vp, vn [][]float64, param []float64) surface.Surfa
 		}
 	}
 
-	for i := 0; i < len(vp); i++ {
+	for i := 0; i < len(vn); i++ {
 		for j := 0; j < dim; j++ {
 			vn[i][j] *= param[i+len(vp)]
 		}
 	}
 
-	return booleans.NewIntersection(NewInfiniteCylinder(p, vp), NewInfiniteCylinder(p, vn))
+	cylinder := booleans.NewIntersection(NewInfiniteCylinder(p, vp), NewInfiniteCylinder(p, vn))
+	if cylinder == nil {
+		return nil
+	}
+
+	//The cylinder is inside the parallelpiped where |w.(x - p)| <= 1
+	//for each of the vectors w.
+	w := make([][]float64, 0, dim)
+	w = append(w, vp...)
+	w = append(w, vn...)
+	inv := vector.Inverse(w)
+	if inv == nil {
+		return cylinder
+	}
+
+	min := make([]float64, dim)
+	max := make([]float64, dim)
+	for i := 0; i < dim; i++ {
+		var r float64
+		for k := 0; k < dim; k++ {
+			r += math.Abs(inv[i][k])
+		}
+		min[i] = p[i] - r
+		max[i] = p[i] + r
+	}
+
+	return surface.NewBoundedSurface(cylinder, min, max)
 }
 
 func NewCone(p []float64, axis []float64, v [][]float64, param []float64) surface.Surface {
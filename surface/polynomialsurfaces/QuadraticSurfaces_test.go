@@ -326,7 +326,38 @@ func TestInfiniteHyperboloidF(t *testing.T) {
 //all that needs to be tested here is whether the object has the correct shape
 //and whether the constructor fails and succeeds correctly.
 func TestNewCylinder(t *testing.T) {
-	//TODO
+	if nil != NewCylinder([]float64{0, 0, 0}, [][]float64{[]float64{1, 0, 0}}, [][]float64{[]float64{0, 0, 1}}, []float64{1, 1}) {
+		t.Error("New cylinder error 1")
+	}
+
+	//Every vector is scaled by its parameter, however many
+	//of them are in each set.
+	cylinders := []surface.Surface{
+		NewCylinder([]float64{0, 0, 0}, [][]float64{[]float64{1, 0, 0}, []float64{0, 1, 0}},
+			[][]float64{[]float64{0, 0, 1}}, []float64{1, .5, .5}),
+		NewCylinder([]float64{0, 0, 0}, [][]float64{[]float64{0, 0, 1}},
+			[][]float64{[]float64{1, 0, 0}, []float64{0, 1, 0}}, []float64{.5, 1, .5})}
+	inside := [][][]float64{
+		[][]float64{[]float64{.9, 0, 0}, []float64{0, 1.9, 0}, []float64{0, 0, 1.9}},
+		[][]float64{[]float64{0, 0, 1.9}, []float64{.9, 0, 0}, []float64{0, 1.9, 0}}}
+	outside := [][][]float64{
+		[][]float64{[]float64{1.1, 0, 0}, []float64{0, 2.1, 0}, []float64{0, 0, 2.1}},
+		[][]float64{[]float64{0, 0, 2.1}, []float64{1.1, 0, 0}, []float64{0, 2.1, 0}}}
+
+	for i, c := range cylinders {
+		if c == nil {
+			t.Error("New cylinder error 2, case ", i)
+			continue
+		}
+		for j := range inside[i] {
+			if !surface.SurfaceInterior(c, inside[i][j]) {
+				t.Error("New cylinder error 3, case ", i, ", point ", inside[i][j])
+			}
+			if surface.SurfaceInterior(c, outside[i][j]) {
+				t.Error("New cylinder error 4, case ", i, ", point ", outside[i][j])
+			}
+		}
+	}
 }
 
 func TestCylinderInterior(t *testing.T) {
@@ -27,7 +27,7 @@ func NewTorus(p []float64, v []float64, R, r float64) surface.Surface {
 	RR4 := -RR * 4
 	var t float64 = -1. / 3.
 
-	return (&quarticSurface{3,
+	torus := (&quarticSurface{3,
 		[][][][]float64{
 			[][][]float64{
 				[][]float64{[]float64{-1}}},
@@ -47,4 +47,9 @@ func NewTorus(p []float64, v []float64, R, r float64) surface.Surface {
 			[]float64{RR4 * v[1] * v[0], rR + RR4*v[1]*v[1]},
 			[]float64{RR4 * v[2] * v[0], RR4 * v[2] * v[1], rR + RR4*v[2]*v[2]}},
 		[]float64{0, 0, 0}, -rr*rr - RR*RR + 2*rr*RR}).Translate(p)
+
+	//The torus is inside the sphere of radius R + r.
+	return surface.NewBoundedSurface(torus,
+		[]float64{p[0] - R - r, p[1] - R - r, p[2] - R - r},
+		[]float64{p[0] + R + r, p[1] + R + r, p[2] + R + r})
 }
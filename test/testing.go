@@ -6,6 +6,7 @@ import "math"
 import "math/rand"
 import "time"
 import "fmt"
+import "testing"
 
 var seed_set bool = false
 
@@ -150,3 +151,64 @@ func MatrixCloseEnough(a, b [][]float64, e float64) bool {
 
   return true
 }
+
+//A function over space, such as a surface.
+type Function interface {
+  Dimension() int
+  F(x []float64) float64
+}
+
+//The gradient of a function at x, estimated from its values at
+//distances of h and 2h away, times the size of the coordinate if it is
+//more than one, so that the differences are not lost to rounding. The
+//estimate is exact for polynomials of up to the fourth degree.
+func GradientTester(f Function, x []float64, h float64) []float64 {
+  grad := make([]float64, f.Dimension())
+  y := make([]float64, len(x))
+  at := func(i int, d float64) float64 {
+    copy(y, x)
+    y[i] = x[i] + d
+    return f.F(y)
+  }
+
+  for i := range grad {
+    d := h * math.Max(1, math.Abs(x[i]))
+    grad[i] = (8 * (at(i, d) - at(i, -d)) - (at(i, 2 * d) - at(i, -2 * d))) / (12 * d)
+  }
+  return grad
+}
+
+//A function with intersections, such as a surface.
+type Intersector interface {
+  Function
+  Intersection(x, v []float64) []float64
+}
+
+//Given a point inside a surface and another outside it, test that the
+//surface has an intersection with the line between them, where the
+//function changes sign.
+func IntersectionTester(s Intersector, p1, p2 []float64, t *testing.T) {
+  v := make([]float64, len(p1))
+  for i := range v {
+    v[i] = p2[i] - p1[i]
+  }
+
+  at := func(u float64) float64 {
+    x := make([]float64, len(p1))
+    for i := range x {
+      x[i] = p1[i] + u * v[i]
+    }
+    return s.F(x)
+  }
+
+  for _, u := range s.Intersection(p1, v) {
+    if u < 0 || u > 1 {
+      continue
+    }
+    if at(u) == 0 || (at(u - .000001) >= 0) != (at(u + .000001) >= 0) {
+      return
+    }
+  }
+
+  t.Error("intersection error: ", s, " from ", p1, " to ", p2, " got ", s.Intersection(p1, v))
+}